@@ -0,0 +1,95 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+)
+
+// An aging (double-buffered) bloom filter using the 128-bit FNV-1 hash
+// function, like Filter. It holds two generations of items: new items are
+// added to the active generation, and when that has reached its capacity, the
+// older generation is discarded and the active one takes its place. The
+// filter therefore remembers at least the last n, and at most the last 2n,
+// distinct items added to it.
+type AgingFilter struct {
+	*filter
+	n      int
+	count  int
+	active *bitset.Bitset32
+	old    *bitset.Bitset32
+}
+
+func testBits32(b *bitset.Bitset32, is []uint32) bool {
+	for _, i := range is {
+		if !b.Test(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks whether data was added to the filter within the last one or two
+// generations. Returns true if yes, with a false positive chance near the
+// ratio specified upon creation of the filter. Data added more than two
+// generations ago is forgotten.
+func (f *AgingFilter) Test(data []byte) bool {
+	is := f.bits(data)
+	return testBits32(f.active, is) || testBits32(f.old, is)
+}
+
+// Adds data to the filter, rotating the generations if the active one has
+// reached its capacity.
+func (f *AgingFilter) Add(data []byte) {
+	f.add(f.bits(data))
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. Data is hashed only once.
+func (f *AgingFilter) TestAndAdd(data []byte) bool {
+	is := f.bits(data)
+	present := testBits32(f.active, is) || testBits32(f.old, is)
+	f.add(is)
+	return present
+}
+
+func (f *AgingFilter) add(is []uint32) {
+	if testBits32(f.active, is) {
+		return
+	}
+	if f.count >= f.n {
+		f.Rotate()
+	}
+	for _, i := range is {
+		f.active.Set(i)
+	}
+	f.count++
+}
+
+// Discards the old generation and makes the active one the old one, leaving
+// an empty active generation.
+func (f *AgingFilter) Rotate() {
+	f.old, f.active = f.active, f.old
+	f.active.Reset()
+	f.count = 0
+}
+
+// Resets the filter.
+func (f *AgingFilter) Reset() {
+	f.active.Reset()
+	f.old.Reset()
+	f.count = 0
+}
+
+// Create an aging bloom filter whose generations each hold n items, with an
+// acceptable false positive rate of p across both generations, e.g. 0.01.
+func NewAging(n int, p float64, opts ...Option) *AgingFilter {
+	// Test consults both generations, so each gets half the budget
+	m, k := estimates(int64(n), p/2)
+	f := &AgingFilter{
+		filter: newFilter(m, k, opts...),
+		n:      n,
+		active: bitset.New32(m),
+		old:    bitset.New32(m),
+	}
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
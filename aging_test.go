@@ -0,0 +1,35 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestAgingFilter(t *testing.T) {
+	f := NewAging(100, 0.001)
+	f.Add(foo)
+	for i := 0; i < 99; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	if !f.Test(foo) {
+		t.Error("foo not in filter")
+	}
+	// Fill the next generation; foo is now in the old one
+	for i := 100; i < 200; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	if !f.Test(foo) {
+		t.Error("foo not in filter after one rotation")
+	}
+	f.Add(bar)
+	if f.Test(foo) {
+		t.Error("foo still in filter after two rotations")
+	}
+	if !f.Test(bar) {
+		t.Error("bar not in filter")
+	}
+	f.Reset()
+	if f.Test(bar) {
+		t.Error("bar in filter after reset")
+	}
+}
@@ -0,0 +1,142 @@
+package bloom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+)
+
+// Opens the list of keys a Blocklist loads, e.g. a file or a URL.
+type Source func() (io.ReadCloser, error)
+
+// Returns a Source reading the file at path.
+func FileSource(path string) Source {
+	return func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+}
+
+// Returns a Source fetching url with http.Get. A response with a status other
+// than 200 OK is an error.
+func URLSource(url string) Source {
+	return func() (io.ReadCloser, error) {
+		resp, err := http.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("bloom: fetching %s: %s", url, resp.Status)
+		}
+		return resp.Body, nil
+	}
+}
+
+// A filter holding a list of keys, e.g. domains, IPs or hashes to block,
+// loaded from a Source, which can be reloaded while it is in use. The list
+// has one key per line; surrounding whitespace, empty lines, and lines
+// starting with # are ignored. Each load builds a new filter for the number
+// of keys in the list, which replaces the last one atomically, so Test can
+// be called concurrently with Reload, and never sees a partly loaded list.
+type Blocklist struct {
+	source Source
+	p      float64
+	opts   []Option
+	f      atomic.Pointer[Filter]
+	n      atomic.Int64
+}
+
+// Checks whether key is (probably) in the list, with the false positive rate
+// the blocklist was created with.
+func (b *Blocklist) Test(key []byte) bool {
+	return b.f.Load().Test(key)
+}
+
+// Checks whether key is (probably) in the list, like Test.
+func (b *Blocklist) TestString(key string) bool {
+	return b.Test([]byte(key))
+}
+
+// Returns the number of keys in the list last loaded.
+func (b *Blocklist) Len() int {
+	return int(b.n.Load())
+}
+
+// Loads the list from the source again, and replaces the filter with one
+// holding it. If the list can't be loaded, the filter in use is kept.
+func (b *Blocklist) Reload() error {
+	r, err := b.source()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	var keys [][]byte
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		key := bytes.TrimSpace(s.Bytes())
+		if len(key) == 0 || key[0] == '#' {
+			continue
+		}
+		keys = append(keys, append([]byte(nil), key...))
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	f, err := NewWithError(max(len(keys), 1), b.p, b.opts...)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		f.Add(key)
+	}
+	b.f.Store(f)
+	b.n.Store(int64(len(keys)))
+	return nil
+}
+
+// Reloads the list every interval, unless it is 0, and whenever the process
+// receives one of sigs, e.g. syscall.SIGHUP, until ctx is done. Errors
+// reloading the list are passed to onError, if it isn't nil; the last list
+// loaded stays in use.
+func (b *Blocklist) Watch(ctx context.Context, interval time.Duration, onError func(error), sigs ...os.Signal) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	sig := make(chan os.Signal, 1)
+	if len(sigs) > 0 {
+		signal.Notify(sig, sigs...)
+		defer signal.Stop(sig)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-sig:
+		}
+		if err := b.Reload(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// Create a blocklist holding the keys loaded from source, with an acceptable
+// false positive rate of p, e.g. 0.0001. Returns an error if the list can't be
+// loaded, or if p is invalid.
+func NewBlocklist(source Source, p float64, opts ...Option) (*Blocklist, error) {
+	b := &Blocklist{source: source, p: p, opts: opts}
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,69 @@
+package bloom
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist")
+	if err := os.WriteFile(path, []byte("# ads\nads.example.com\n\n  tracker.example.com \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewBlocklist(FileSource(path), 0.0001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 2 || !b.TestString("ads.example.com") || !b.TestString("tracker.example.com") || b.TestString("# ads") {
+		t.Fatalf("unexpected list of %d keys", b.Len())
+	}
+	if err := os.WriteFile(path, []byte("evil.example.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Watch(ctx, time.Millisecond, func(err error) { t.Error(err) })
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for !b.TestString("evil.example.com") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if b.TestString("ads.example.com") || !b.TestString("evil.example.com") {
+		t.Error("list not reloaded")
+	}
+
+	os.Remove(path)
+	if err := b.Reload(); err == nil || !b.TestString("evil.example.com") {
+		t.Errorf("reloading a missing list returned %v", err)
+	}
+}
+
+func TestBlocklistURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/list" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("10.0.0.1\n10.0.0.2\n"))
+	}))
+	defer srv.Close()
+	b, err := NewBlocklist(URLSource(srv.URL+"/list"), 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.TestString("10.0.0.2") || b.TestString("10.0.0.3") {
+		t.Error("unexpected list")
+	}
+	if _, err := NewBlocklist(URLSource(srv.URL+"/missing"), 0.001); err == nil {
+		t.Error("loaded a list which wasn't found")
+	}
+}
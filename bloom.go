@@ -3,56 +3,204 @@ package bloom
 import (
 	"github.com/pmylund/go-bitset"
 
-	"encoding/binary"
 	"fmt"
-	"hash"
-	"hash/fnv"
 	"math"
+	"time"
 )
 
 type filter struct {
-	m uint32
-	k uint32
-	h hash.Hash64
+	m     uint32
+	k     uint32
+	hf    HashFunc
+	hname string // the name of hf if it is registered
+	hpar  []byte // the parameters hf was created with
+	hid   uint64 // tells the hf of different calls to WithMapHash apart
+	seed  uint64
+	index IndexMode
+	ix    Indexer
+	// The expected number of items and false positive rate the filter was
+	// created with, if known
+	capacity uint64
+	fpRate   float64
+	legacy   bool     // whether the hashes are split from an FNV-64 sum
+	parts    []uint32 // partition sizes in one-hashing mode
+}
+
+// Returns the two 64-bit hashes from which the bit indexes of data are
+// derived, perturbed by the filter's seed if it has one.
+func (f *filter) sum(data []byte) (uint64, uint64) {
+	var x, y uint64
+	switch {
+	case f.hf != nil:
+		x, y = f.hf(data)
+	case f.legacy:
+		x = fnv64(data)
+		y = x >> 32
+	default:
+		hi, lo := fnv128(data)
+		x, y = mix64(lo), mix64(hi)
+	}
+	return f.seeded(x, y)
+}
+
+// Perturbs the hashes x and y by the filter's seed if it has one.
+func (f *filter) seeded(x, y uint64) (uint64, uint64) {
+	if f.seed != 0 {
+		x, y = mix64(x^f.seed), mix64(y^f.seed)
+	}
+	return x, y
 }
 
 func (f *filter) bits(data []byte) []uint32 {
-	f.h.Reset()
-	f.h.Write(data)
-	d := f.h.Sum(nil)
-	a := binary.BigEndian.Uint32(d[4:8])
-	b := binary.BigEndian.Uint32(d[0:4])
-	is := make([]uint32, f.k)
-	for i := uint32(0); i < f.k; i++ {
-		is[i] = (a + b*i) % f.m
+	return f.bitsTo(nil, data)
+}
+
+// Like bits, but stores the indexes in is if it has room for them, so that a
+// buffer can be reused for several items.
+func (f *filter) bitsTo(is []uint32, data []byte) []uint32 {
+	if f.parts != nil {
+		return f.oneHashBits(is, data)
+	}
+	return f.bitsN(is, data, f.k)
+}
+
+// Returns is resized to n indexes, reusing its storage if it is large enough.
+func resize32(is []uint32, n int) []uint32 {
+	if cap(is) >= n {
+		return is[:n]
 	}
+	return make([]uint32, n)
+}
+
+// Returns k bit indexes for data, stored in is if it has room for them; the
+// first f.k are the same as those returned by bits.
+func (f *filter) bitsN(is []uint32, data []byte, k uint32) []uint32 {
+	is = resize32(is, int(k))
+	if f.ix != nil || f.index == IndependentHashing {
+		is64 := make([]uint64, k)
+		if f.ix != nil {
+			f.ix(data, uint64(k), uint64(f.m), is64)
+		} else {
+			independentIndexes(is64, data, f.seed, uint64(f.m))
+		}
+		for i, v := range is64 {
+			is[i] = uint32(v)
+		}
+		return is
+	}
+	x, y := f.sum(data)
+	indexes32(is, uint32(x), uint32(y), f.m, f.index)
+	return is
+}
+
+// Returns the bit indexes for the hashes x and y returned by sum.
+func (f *filter) sumBits(x, y uint64) []uint32 {
+	if f.parts != nil {
+		return f.oneHashIndexes(nil, x)
+	}
+	is := make([]uint32, f.k)
+	indexes32(is, uint32(x), uint32(y), f.m, f.index)
 	return is
 }
 
-func newFilter(m, k uint32) *filter {
+// Returns n empty layers of m bits, or one if n is less than 1.
+func newLayers32(m uint32, n int) []*bitset.Bitset32 {
+	if n < 1 {
+		n = 1
+	}
+	b := make([]*bitset.Bitset32, n)
+	for i := range b {
+		b[i] = bitset.New32(m)
+	}
+	return b
+}
+
+func newFilter(m, k uint32, opts ...Option) *filter {
+	o := newOptions(opts)
 	return &filter{
-		m: m,
-		k: k,
-		h: fnv.New64(),
+		m:      m,
+		k:      k,
+		hf:     o.hash,
+		hname:  o.hashName,
+		hpar:   o.hashParams,
+		hid:    o.hashID,
+		seed:   o.seed,
+		index:  o.index,
+		ix:     o.indexer,
+		legacy: o.legacy,
+	}
+}
+
+// Returns the number of hash functions, i.e. the number of bits set per item.
+func (f *filter) K() uint32 {
+	return f.k
+}
+
+// Returns the number of bits in the filter, or in each of its layers.
+func (f *filter) M() uint32 {
+	return f.m
+}
+
+// Returns the expected number of items the filter was created with, or 0 if
+// it is unknown, e.g. for a decoded filter.
+func (f *filter) Capacity() uint64 {
+	return f.capacity
+}
+
+// Returns the acceptable false positive rate the filter was created with, or
+// 0 if it is unknown, e.g. for a decoded filter.
+func (f *filter) FPRate() float64 {
+	return f.fpRate
+}
+
+func estimates(n int64, p float64) (uint32, uint32) {
+	m, k, err := checkedEstimates(n, p)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to create a bloom filter: %v", err))
 	}
+	return m, k
 }
 
-func estimates(n uint32, p float64) (uint32, uint32) {
+// Like estimates, but returns an error instead of panicking if n or p are
+// invalid, or if the filter would be too large for a 32-bit bitset. The error
+// wraps ErrInvalidParameters, and ErrTooLarge in the latter case. m is
+// computed in float64, so that it can't overflow before it is checked.
+func checkedEstimates(n int64, p float64) (uint32, uint32, error) {
+	if err := checkParams(n, p); err != nil {
+		return 0, 0, err
+	}
+	m, k := optimalMK(n, p)
+	if !(m <= math.MaxUint32) {
+		return 0, 0, fmt.Errorf("%w: a 32-bit bloom filter with n %d and p %g requires %.0f bits, but this number overflows an uint32; please use the equivalent 64-bit bloom filter, e.g. New64, instead", ErrTooLarge, n, p, m)
+	}
+	return uint32(max(m, 1)), uint32(k), nil
+}
+
+// Returns the optimal number of bits and hash functions, as floats, for a
+// filter of n items with a false positive rate of p, which must be valid.
+func optimalMK(n int64, p float64) (m, k float64) {
 	nf := float64(n)
 	log2 := math.Log(2)
-	m := -1 * nf * math.Log(p) / math.Pow(log2, 2)
-	k := math.Ceil(log2 * m / nf)
+	m = -1 * nf * math.Log(p) / math.Pow(log2, 2)
+	k = math.Ceil(log2 * m / nf)
+	return m, k
+}
 
-	words := m + 31>>5
-	if words >= math.MaxInt32 {
-		panic(fmt.Sprintf("A 32-bit bloom filter with n %d and p %f requires a 32-bit bitset with a slice of %f words, but slices cannot contain more than %d elements. Please use the equivalent 64-bit bloom filter, e.g. New64(), instead.", n, p, words, math.MaxInt32-1))
-	} else if m > math.MaxUint32 {
-		panic(fmt.Sprintf("A 32-bit bloom filter with n %d and p %f requires a 32-bit bitset with %d bits, but this number overflows an uint32. Please use the equivalent 64-bit bloom filter, e.g. New64(), instead.", n, p, m))
+// Returns an error if no filter can be created for an expected n number of
+// items and a false positive rate of p.
+func checkParams(n int64, p float64) error {
+	if n <= 0 {
+		return fmt.Errorf("%w: the expected number of items is %d, but must be positive", ErrInvalidParameters, n)
+	}
+	if !(p > 0 && p < 1) {
+		return fmt.Errorf("%w: the false positive rate is %v, but must be between 0 and 1", ErrInvalidParameters, p)
 	}
-	return uint32(m), uint32(k)
+	return nil
 }
 
-// A standard bloom filter using the 64-bit FNV-1a hash function.
+// A standard bloom filter using the 128-bit FNV-1 hash function: the bit
+// indexes of an item are derived from the two mixed halves of its sum, or,
+// with WithLegacyHashing, from the two halves of its 64-bit FNV-1 sum.
 type Filter struct {
 	*filter
 	b *bitset.Bitset32
@@ -77,6 +225,26 @@ func (f *Filter) Add(data []byte) {
 	}
 }
 
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. Data is hashed only once.
+func (f *Filter) TestAndAdd(data []byte) bool {
+	present := true
+	for _, i := range f.bits(data) {
+		if !f.b.Test(i) {
+			present = false
+			f.b.Set(i)
+		}
+	}
+	return present
+}
+
+// Adds data to the filter, and returns whether all of its bits were already
+// set, i.e. whether adding it didn't change the filter. This is the same as
+// TestAndAdd.
+func (f *Filter) AddReporting(data []byte) (alreadyPresent bool) {
+	return f.TestAndAdd(data)
+}
+
 // Resets the filter.
 func (f *Filter) Reset() {
 	f.b.Reset()
@@ -84,20 +252,59 @@ func (f *Filter) Reset() {
 
 // Create a bloom filter with an expected n number of items, and an acceptable
 // false positive rate of p, e.g. 0.01.
-func New(n int, p float64) *Filter {
-	m, k := estimates(uint32(n), p)
+func New(n int, p float64, opts ...Option) *Filter {
+	m, k := estimates(int64(n), p)
 	f := &Filter{
-		newFilter(m, k),
+		newFilter(m, k, opts...),
 		bitset.New32(m),
 	}
+	f.capacity, f.fpRate = uint64(n), p
 	return f
 }
 
-// A counting bloom filter using the 64-bit FNV-1a hash function. Supports
-// removing items from the filter.
+// Create a bloom filter like New, but return an error instead of panicking if
+// n or p are invalid, e.g. if n isn't positive, or p isn't between 0 and 1,
+// or if the filter would be too large for a 32-bit bitset. The error wraps
+// ErrInvalidParameters, and ErrTooLarge in the latter case.
+func NewWithError(n int, p float64, opts ...Option) (*Filter, error) {
+	if _, _, err := checkedEstimates(int64(n), p); err != nil {
+		return nil, err
+	}
+	return New(n, p, opts...), nil
+}
+
+// Create a bloom filter of exactly m bits, setting k bits per item, rather
+// than estimating them from the expected number of items and false positive
+// rate, e.g. to reproduce a filter created elsewhere, or to pin its size.
+// Capacity and FPRate return 0 for such a filter.
+func NewWithSize(m, k uint32, opts ...Option) *Filter {
+	if m == 0 || k == 0 {
+		panic("Unable to create a bloom filter without bits or hash functions.")
+	}
+	return &Filter{
+		newFilter(m, k, opts...),
+		bitset.New32(m),
+	}
+}
+
+// Create a bloom filter like New, but with a random seed (see WithSeed), so
+// that its false positives differ from those of other filters holding the
+// same items.
+func NewSeeded(n int, p float64, opts ...Option) *Filter {
+	return New(n, p, append([]Option{WithSeed(randomSeed())}, opts...)...)
+}
+
+// A counting bloom filter using the 128-bit FNV-1 hash function, like Filter.
+// Supports removing items from the filter. By default, its counters are
+// unbounded: the filter is a stack of bitsets, to which layers are added as
+// counters grow. WithCounterWidth makes it use an array of fixed-width
+// counters instead, which saturate rather than grow.
 type CountingFilter struct {
 	*filter
 	b []*bitset.Bitset32
+	c *counters // fixed-width counters replacing b, if given WithCounterWidth
+
+	conservative bool // whether only the smallest counters are incremented
 }
 
 // Checks whether data was previously added to the filter. Returns true if
@@ -105,18 +312,61 @@ type CountingFilter struct {
 // of the filter. The result cannot cannot be falsely negative (unless one
 // has removed an item that wasn't actually added to the filter previously.)
 func (f *CountingFilter) Test(data []byte) bool {
-	b := f.b[0]
-	for _, v := range f.bits(data) {
-		if !b.Test(v) {
-			return false
+	return f.test(f.bits(data))
+}
+
+func (f *CountingFilter) test(is []uint32) bool {
+	if f.c != nil {
+		for _, v := range is {
+			if f.c.get(uint64(v)) == 0 {
+				return false
+			}
 		}
+		return true
 	}
-	return true
+	return testBits32(f.b[0], is)
 }
 
 // Adds data to the filter.
 func (f *CountingFilter) Add(data []byte) {
-	for _, v := range f.bits(data) {
+	f.add(f.bits(data))
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. Data is hashed only once.
+func (f *CountingFilter) TestAndAdd(data []byte) bool {
+	is := f.bits(data)
+	present := f.test(is)
+	f.add(is)
+	return present
+}
+
+// Adds n occurrences of data to the filter, hashing it only once, e.g. to
+// replay aggregated counts.
+func (f *CountingFilter) AddN(data []byte, n int) {
+	is := f.bits(data)
+	for ; n > 0; n-- {
+		f.add(is)
+	}
+}
+
+func (f *CountingFilter) add(is []uint32) {
+	if f.conservative {
+		min := uint64(f.count(is))
+		for _, v := range is {
+			if f.counter(v) == min {
+				f.setCounter(v, min+1)
+			}
+		}
+		return
+	}
+	if f.c != nil {
+		for _, v := range is {
+			f.c.inc(uint64(v))
+		}
+		return
+	}
+	for _, v := range is {
 		done := false
 		for _, ov := range f.b {
 			if !ov.Test(v) {
@@ -133,11 +383,122 @@ func (f *CountingFilter) Add(data []byte) {
 	}
 }
 
+// Returns the (estimated) number of times data was added to the filter, minus
+// the number of times it was removed: the smallest of its counters. The count
+// may be too high if other items share all of its bits, but is never too low.
+func (f *CountingFilter) Count(data []byte) int {
+	return f.count(f.bits(data))
+}
+
+// Adds data to the filter, and returns its (estimated) count afterwards, as
+// reported by Count, hashing data only once, e.g. to allow at most a certain
+// number of requests per key.
+func (f *CountingFilter) AddAndCount(data []byte) int {
+	is := f.bits(data)
+	f.add(is)
+	return f.count(is)
+}
+
+// Adds data to the filter unless its (estimated) count, as reported by Count,
+// is already max or more, and returns whether it was added. This keeps an
+// item which is added far more often than the others, e.g. by abuse, from
+// making the filter add layers or saturate its counters. Since the count may
+// be too high, an item may be refused before it was added max times.
+func (f *CountingFilter) AddCapped(data []byte, max int) (added bool) {
+	is := f.bits(data)
+	if f.count(is) >= max {
+		return false
+	}
+	f.add(is)
+	return true
+}
+
+func (f *CountingFilter) count(is []uint32) int {
+	if f.c != nil {
+		min := f.c.max()
+		for _, v := range is {
+			if c := f.c.get(uint64(v)); c < min {
+				min = c
+			}
+		}
+		return int(min)
+	}
+	min := len(f.b)
+	for _, v := range is {
+		c := 0
+		for c < min && f.b[c].Test(v) {
+			c++
+		}
+		min = c
+	}
+	return min
+}
+
 // Removes data from the filter. This exact data must have been previously added
 // to the filter, or future results will be inconsistent.
 func (f *CountingFilter) Remove(data []byte) {
+	f.remove(f.bits(data))
+}
+
+// Adds every one of items to the filter, reusing one buffer for their
+// indexes, e.g. to add a batch of records at once.
+func (f *CountingFilter) AddAll(items [][]byte) {
+	var is []uint32
+	for _, data := range items {
+		is = f.bitsTo(is, data)
+		f.add(is)
+	}
+}
+
+// Removes every one of items from the filter, like Remove, reusing one buffer
+// for their indexes, e.g. to expire a batch of processed records at once.
+// Every item must have been previously added to the filter.
+func (f *CountingFilter) RemoveAll(items [][]byte) {
+	var is []uint32
+	for _, data := range items {
+		is = f.bitsTo(is, data)
+		f.remove(is)
+	}
+}
+
+// Removes data from the filter if it is (probably) present, as reported by
+// Test, and returns whether it was removed. If data wasn't added to the
+// filter, this usually leaves the filter unchanged, rather than making future
+// results inconsistent as Remove would, but a false positive is still removed.
+func (f *CountingFilter) TryRemove(data []byte) (removed bool) {
+	is := f.bits(data)
+	if !f.test(is) {
+		return false
+	}
+	f.remove(is)
+	return true
+}
+
+// Checks whether data is (probably) present, as reported by Test, and if so,
+// removes it from the filter, hashing data only once, e.g. to release an
+// item from a work queue. This is the same as TryRemove.
+func (f *CountingFilter) TestAndRemove(data []byte) bool {
+	return f.TryRemove(data)
+}
+
+// Removes data from the filter like TryRemove, but returns ErrNotPresent if
+// it isn't present, rather than false.
+func (f *CountingFilter) StrictRemove(data []byte) error {
+	if !f.TryRemove(data) {
+		return ErrNotPresent
+	}
+	return nil
+}
+
+func (f *CountingFilter) remove(is []uint32) {
+	if f.c != nil {
+		for _, v := range is {
+			f.c.dec(uint64(v))
+		}
+		return
+	}
 	last := len(f.b) - 1
-	for _, v := range f.bits(data) {
+	for _, v := range is {
 		for oi := last; oi >= 0; oi-- {
 			ov := f.b[oi]
 			if ov.Test(v) {
@@ -148,28 +509,103 @@ func (f *CountingFilter) Remove(data []byte) {
 	}
 }
 
-// Resets the filter.
+// Returns the number of times a counter wasn't incremented because it was
+// saturated, for a filter created WithCounterWidth. The count of items using
+// a saturated counter may be too high, and doesn't drop as they are removed.
+// Returns 0 for other filters, which add layers as needed instead.
+func (f *CountingFilter) OverflowCount() uint64 {
+	if f.c == nil {
+		return 0
+	}
+	return f.c.overflows
+}
+
+// Resets the filter. The layers added as the filter grew are kept, empty,
+// to be reused; use ResetAndShrink to release them.
 func (f *CountingFilter) Reset() {
-	f.b = f.b[:1]
+	if f.c != nil {
+		f.c.reset()
+		return
+	}
+	for _, b := range f.b {
+		b.Reset()
+	}
+}
+
+// Resets the filter, and releases every layer but the first, returning the
+// memory they use.
+func (f *CountingFilter) ResetAndShrink() {
+	if f.c != nil {
+		f.c.reset()
+		return
+	}
+	f.b = []*bitset.Bitset32{f.b[0]}
 	f.b[0].Reset()
 }
 
 // Create a counting bloom filter with an expected n number of items, and an
 // acceptable false positive rate of p. Counting bloom filters support
 // the removal of items from the filter.
-func NewCounting(n int, p float64) *CountingFilter {
-	m, k := estimates(uint32(n), p)
-	f := &CountingFilter{
-		newFilter(m, k),
-		[]*bitset.Bitset32{bitset.New32(m)},
+func NewCounting(n int, p float64, opts ...Option) *CountingFilter {
+	m, k := estimates(int64(n), p)
+	o := newOptions(opts)
+	f := &CountingFilter{filter: newFilter(m, k, opts...)}
+	f.conservative = o.conservative
+	if o.counterWidth != 0 {
+		f.c = newCounters(uint64(m), o.counterWidth)
+	} else {
+		f.b = newLayers32(m, o.layers)
 	}
+	f.capacity, f.fpRate = uint64(n), p
 	return f
 }
 
-// A layered bloom filter using the 64-bit FNV-1a hash function.
+// A layered bloom filter using the 128-bit FNV-1 hash function, like Filter.
 type LayeredFilter struct {
 	*filter
-	b []*bitset.Bitset32
+	b   []*bitset.Bitset32
+	max int // the most layers the filter may have, or 0 for no limit
+	// The parameters of each layer, if created with NewLayeredRates, of which
+	// the last is also used for any further layers
+	tiers []*filter
+	info  []LayerInfo // of each layer
+}
+
+// Returns the parameters of layer l, counting from 0.
+func (f *LayeredFilter) tier(l int) *filter {
+	switch {
+	case f.tiers == nil:
+		return f.filter
+	case l < len(f.tiers):
+		return f.tiers[l]
+	}
+	return f.tiers[len(f.tiers)-1]
+}
+
+// Adds a new, empty layer on top of the others.
+func (f *LayeredFilter) appendLayer() {
+	f.b = append(f.b, bitset.New32(f.tier(len(f.b)).m))
+	f.info = append(f.info, LayerInfo{Created: time.Now()})
+}
+
+// Returns the bit indexes of an item in layer l, counting from 0.
+type layerIndexes func(l int) []uint32
+
+// Returns the bit indexes of data in each layer, hashing data once for every
+// distinct set of layer parameters.
+func (f *LayeredFilter) indexes(data []byte) layerIndexes {
+	if f.tiers == nil {
+		is := f.bits(data)
+		return func(int) []uint32 { return is }
+	}
+	cache := make([][]uint32, len(f.tiers))
+	return func(l int) []uint32 {
+		t := min(l, len(f.tiers)-1)
+		if cache[t] == nil {
+			cache[t] = f.tiers[t].bits(data)
+		}
+		return cache[t]
+	}
 }
 
 // Checks whether data was previously added to the filter. Returns the number of
@@ -178,34 +614,73 @@ type LayeredFilter struct {
 // has a false positive chance near the ratio specified upon creation of the
 // filter. The result cannot be falsely negative.
 func (f *LayeredFilter) Test(data []byte) (int, bool) {
-	is := f.bits(data)
+	at := f.indexes(data)
 	for i := len(f.b) - 1; i >= 0; i-- {
-		v := f.b[i]
-		last := len(is) - 1
-		for oi, ov := range is {
-			if !v.Test(ov) {
-				break
-			}
-			if oi == last {
-				// Every test was positive at this layer
-				return i + 1, true
-			}
+		if testBits32(f.b[i], at(i)) {
+			// Every test was positive at this layer
+			return i + 1, true
 		}
 	}
 	return 0, false
 }
 
+// Returns the (estimated) number of times data was added to the filter: the
+// number of the last layer it was added to, as reported by Test, or 0 if it
+// wasn't added. The count may be too high if other items share its bits, but
+// is never too low.
+func (f *LayeredFilter) Count(data []byte) int {
+	n, _ := f.Test(data)
+	return n
+}
+
+// Checks whether data was (probably) added to the filter at least n times,
+// e.g. to drop an item after its 10th occurrence. Only the nth layer is
+// checked, since an item added n times is in every layer up to the nth.
+// Returns true if n is less than 1.
+func (f *LayeredFilter) TestAtLeast(data []byte, n int) bool {
+	if n < 1 {
+		return true
+	}
+	if n > len(f.b) {
+		return false
+	}
+	return testBits32(f.b[n-1], f.tier(n-1).bits(data))
+}
+
 // Adds data to the filter. Returns the number of the layer where the data
 // was added, e.g. 1 for the first layer.
 func (f *LayeredFilter) Add(data []byte) int {
-	is := f.bits(data)
-	var (
-		i int
-		v *bitset.Bitset32
-	)
-	for i, v = range f.b {
+	return f.add(f.indexes(data))
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. Data is hashed only once.
+func (f *LayeredFilter) TestAndAdd(data []byte) bool {
+	at := f.indexes(data)
+	present := testBits32(f.b[0], at(0))
+	f.add(at)
+	return present
+}
+
+// Adds n occurrences of data to the filter, hashing it only once, e.g. to
+// replay aggregated counts. Returns the number of the layer where the last
+// occurrence was added, as Add does, or 0 if n is less than 1.
+func (f *LayeredFilter) AddN(data []byte, n int) int {
+	if n < 1 {
+		return 0
+	}
+	at := f.indexes(data)
+	layer := 0
+	for ; n > 0; n-- {
+		layer = f.add(at)
+	}
+	return layer
+}
+
+func (f *LayeredFilter) add(at layerIndexes) int {
+	for i, v := range f.b {
 		here := false
-		for _, ov := range is {
+		for _, ov := range at(i) {
 			if here {
 				v.Set(ov)
 			} else if !v.Test(ov) {
@@ -217,29 +692,144 @@ func (f *LayeredFilter) Add(data []byte) int {
 			return i + 1
 		}
 	}
-	nb := bitset.New32(f.b[0].Len())
-	f.b = append(f.b, nb)
-	for _, v := range is {
+	if f.max > 0 && len(f.b) >= f.max {
+		return len(f.b)
+	}
+	f.appendLayer()
+	nb := f.b[len(f.b)-1]
+	for _, v := range at(len(f.b) - 1) {
 		nb.Set(v)
 	}
-	return i + 2
+	return len(f.b)
 }
 
-// Resets the filter.
+// Removes one occurrence of data from the filter by clearing its bits in the
+// last layer it was added to, as reported by Test, and returns the number of
+// that layer, or 0 if data wasn't in the filter. Other items sharing those
+// bits in that layer lose an occurrence too, so, as with a counting filter,
+// only data that was previously added should be removed, and a layered
+// filter which items are removed from has a higher chance of counting them
+// too low.
+func (f *LayeredFilter) Remove(data []byte) int {
+	at := f.indexes(data)
+	for i := len(f.b) - 1; i >= 0; i-- {
+		if is := at(i); testBits32(f.b[i], is) {
+			for _, v := range is {
+				f.b[i].Clear(v)
+			}
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Resets layer n of the filter, e.g. 1 for the first layer, as numbered by
+// Add and Test. Items in higher layers are still reported by Test with their
+// full count, but not by TestAtLeast for n or less. Layers which don't exist
+// are ignored. The layer's LayerInfo is reset too.
+func (f *LayeredFilter) ResetLayer(n int) {
+	if n >= 1 && n <= len(f.b) {
+		f.b[n-1].Reset()
+		f.info[n-1] = LayerInfo{Created: time.Now()}
+	}
+}
+
+// Drops the first layer of the filter, moving every other layer down by one,
+// and reuses it, empty, as the top layer, so that the count of every item
+// drops by one without rebuilding the filter. For example, if each item is
+// added at most once per epoch, and the layers are rotated at the end of
+// each epoch, an item's count is at most the number of the recent epochs it
+// was seen in, and items not seen for as many epochs as there are layers are
+// forgotten. The layers of a filter created with NewLayeredRates differ in
+// size, so they can't be rotated, and ErrNotRotatable is returned for it.
+func (f *LayeredFilter) RotateLayers() error {
+	if f.tiers != nil {
+		return ErrNotRotatable
+	}
+	first := f.b[0]
+	copy(f.b, f.b[1:])
+	first.Reset()
+	f.b[len(f.b)-1] = first
+	copy(f.info, f.info[1:])
+	f.info[len(f.info)-1] = LayerInfo{Created: time.Now()}
+	return nil
+}
+
+// Resets the filter. The layers added as the filter grew are kept, empty,
+// to be reused; use ResetAndShrink to release them.
 func (f *LayeredFilter) Reset() {
-	f.b = f.b[:1]
+	for _, b := range f.b {
+		b.Reset()
+	}
+	f.info = newLayerInfo(len(f.b))
+}
+
+// Resets the filter, and releases every layer but the first, returning the
+// memory they use.
+func (f *LayeredFilter) ResetAndShrink() {
+	f.b = []*bitset.Bitset32{f.b[0]}
 	f.b[0].Reset()
+	f.info = newLayerInfo(1)
 }
 
 // Create a layered bloom filter with an expected n number of items, and an
 // acceptable false positive rate of p. Layered bloom filters can be used
 // to keep track of a certain, arbitrary count of items, e.g. to check if some
 // given data was added to the filter 10 times or less.
-func NewLayered(n int, p float64) *LayeredFilter {
-	m, k := estimates(uint32(n), p)
+func NewLayered(n int, p float64, opts ...Option) *LayeredFilter {
+	m, k := estimates(int64(n), p)
+	f := &LayeredFilter{
+		filter: newFilter(m, k, opts...),
+		b:      newLayers32(m, newOptions(opts).layers),
+	}
+	f.info = newLayerInfo(len(f.b))
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
+
+// Create a layered bloom filter like NewLayered, but with at most maxLayers
+// layers, so that items which are added very often, e.g. by abuse, can't make
+// the filter allocate ever more layers. Once an item is in every layer,
+// adding it again returns maxLayers and leaves the filter unchanged, so its
+// count is at most maxLayers.
+func NewLayeredMax(n int, p float64, maxLayers int, opts ...Option) *LayeredFilter {
+	f := NewLayered(n, p, opts...)
+	f.max = maxLayers
+	return f
+}
+
+// Create a layered bloom filter with an expected n number of items, and an
+// acceptable false positive rate for each layer, e.g. a low rate for the
+// first layer, and higher ones for deeper layers, so that TestAtLeast is more
+// accurate for small counts than for large ones, and deep layers use less
+// memory. Layers past the last rate use the last rate. Each rate gives the
+// layer its own size and number of hash functions, so M and K report those
+// of the first layer. RotateLayers returns ErrNotRotatable for the filter.
+func NewLayeredRates(n int, rates []float64, opts ...Option) *LayeredFilter {
+	if len(rates) == 0 {
+		panic("Unable to create a layered bloom filter without false positive rates.")
+	}
+	tiers := make([]*filter, len(rates))
+	for i, p := range rates {
+		m, k := estimates(int64(n), p)
+		if i == 0 {
+			tiers[i] = newFilter(m, k, opts...)
+		} else {
+			tiers[i] = tiers[0].clone()
+			tiers[i].m, tiers[i].k = m, k
+		}
+	}
 	f := &LayeredFilter{
-		newFilter(m, k),
-		[]*bitset.Bitset32{bitset.New32(m)},
+		filter: tiers[0],
+		tiers:  tiers,
+	}
+	layers := newOptions(opts).layers
+	if layers < 1 {
+		layers = 1
+	}
+	for len(f.b) < layers {
+		f.appendLayer()
 	}
+	f.capacity, f.fpRate = uint64(n), rates[0]
 	return f
 }
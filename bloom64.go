@@ -3,53 +3,150 @@ package bloom
 import (
 	"github.com/pmylund/go-bitset"
 
-	"hash"
+	"fmt"
 	"hash/crc64"
-	"hash/fnv"
 	"math"
+	"time"
 )
 
+// The table of the CRC-64 giving the second hash of a filter64
+var crc64ECMA = crc64.MakeTable(crc64.ECMA)
+
 type filter64 struct {
-	m  uint64
-	k  uint64
-	h  hash.Hash64
-	oh hash.Hash64
+	m     uint64
+	k     uint64
+	hf    HashFunc
+	hname string // the name of hf if it is registered
+	hpar  []byte // the parameters hf was created with
+	hid   uint64 // tells the hf of different calls to WithMapHash apart
+	seed  uint64
+	index IndexMode
+	ix    Indexer
+	// The expected number of items and false positive rate the filter was
+	// created with, if known
+	capacity uint64
+	fpRate   float64
 }
 
 func (f *filter64) bits(data []byte) []uint64 {
-	f.h.Reset()
-	f.h.Write(data)
-	a := f.h.Sum64()
-
-	f.oh.Reset()
-	f.oh.Write(data)
-	b := f.oh.Sum64()
+	return f.bitsTo(nil, data)
+}
 
-	is := make([]uint64, f.k)
-	for i := uint64(0); i < f.k; i++ {
-		is[i] = (a + b*i) % f.m
+// Like bits, but stores the indexes in is if it has room for them, so that a
+// buffer can be reused for several items.
+func (f *filter64) bitsTo(is []uint64, data []byte) []uint64 {
+	if cap(is) >= int(f.k) {
+		is = is[:f.k]
+	} else {
+		is = make([]uint64, f.k)
+	}
+	if f.ix != nil {
+		f.ix(data, f.k, f.m, is)
+		return is
+	}
+	if f.index == IndependentHashing {
+		independentIndexes(is, data, f.seed, f.m)
+		return is
 	}
+	var a, b uint64
+	if f.hf != nil {
+		a, b = f.hf(data)
+	} else {
+		// Computed without hash.Hash64s, which keep state, so that the
+		// filter can be tested concurrently
+		a = fnv64(data)
+		b = crc64.Checksum(data, crc64ECMA)
+	}
+	if f.seed != 0 {
+		a, b = mix64(a^f.seed), mix64(b^f.seed)
+	}
+
+	indexes64(is, a, b, f.m, f.index)
 	return is
 }
 
-func newFilter64(m, k uint64) *filter64 {
+func testBits64(b *bitset.Bitset64, is []uint64) bool {
+	for _, i := range is {
+		if !b.Test(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns n empty layers of m bits, or one if n is less than 1.
+func newLayers64(m uint64, n int) []*bitset.Bitset64 {
+	if n < 1 {
+		n = 1
+	}
+	b := make([]*bitset.Bitset64, n)
+	for i := range b {
+		b[i] = bitset.New64(m)
+	}
+	return b
+}
+
+func newFilter64(m, k uint64, opts ...Option) *filter64 {
+	o := newOptions(opts)
 	return &filter64{
-		m:  m,
-		k:  k,
-		h:  fnv.New64(),
-		oh: crc64.New(crc64.MakeTable(crc64.ECMA)),
+		m:     m,
+		k:     k,
+		hf:    o.hash,
+		hname: o.hashName,
+		hpar:  o.hashParams,
+		hid:   o.hashID,
+		seed:  o.seed,
+		index: o.index,
+		ix:    o.indexer,
 	}
 }
 
-func estimates64(n uint64, p float64) (uint64, uint64) {
-	nf := float64(n)
-	log2 := math.Log(2)
-	m := -1 * nf * math.Log(p) / math.Pow(log2, 2)
-	k := math.Ceil(log2 * m / nf)
-	return uint64(m), uint64(k)
+// Returns the number of hash functions, i.e. the number of bits set per item.
+func (f *filter64) K() uint64 {
+	return f.k
+}
+
+// Returns the number of bits in the filter, or in each of its layers.
+func (f *filter64) M() uint64 {
+	return f.m
 }
 
-// A standard 64-bit bloom filter using the 64-bit FNV-1a hash function.
+// Returns the expected number of items the filter was created with, or 0 if
+// it is unknown, e.g. for a decoded filter.
+func (f *filter64) Capacity() uint64 {
+	return f.capacity
+}
+
+// Returns the acceptable false positive rate the filter was created with, or
+// 0 if it is unknown, e.g. for a decoded filter.
+func (f *filter64) FPRate() float64 {
+	return f.fpRate
+}
+
+func estimates64(n int64, p float64) (uint64, uint64) {
+	m, k, err := checkedEstimates64(n, p)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to create a 64-bit bloom filter: %v", err))
+	}
+	return m, k
+}
+
+// Like estimates64, but returns an error instead of panicking if n or p are
+// invalid, or if the words of the filter's bitset wouldn't fit in a slice.
+// The error wraps ErrInvalidParameters, and ErrTooLarge in the latter case.
+func checkedEstimates64(n int64, p float64) (uint64, uint64, error) {
+	if err := checkParams(n, p); err != nil {
+		return 0, 0, err
+	}
+	m, k := optimalMK(n, p)
+	if !(m/64 < math.MaxInt) {
+		return 0, 0, fmt.Errorf("%w: a 64-bit bloom filter with n %d and p %g requires %.0f bits, which is too many", ErrTooLarge, n, p, m)
+	}
+	return uint64(max(m, 1)), uint64(k), nil
+}
+
+// A standard 64-bit bloom filter using the 64-bit FNV-1 and CRC-64 hash
+// functions, from whose sums the bit indexes of an item are derived.
 type Filter64 struct {
 	*filter64
 	b *bitset.Bitset64
@@ -74,6 +171,26 @@ func (f *Filter64) Add(data []byte) {
 	}
 }
 
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. Data is hashed only once.
+func (f *Filter64) TestAndAdd(data []byte) bool {
+	present := true
+	for _, i := range f.bits(data) {
+		if !f.b.Test(i) {
+			present = false
+			f.b.Set(i)
+		}
+	}
+	return present
+}
+
+// Adds data to the filter, and returns whether all of its bits were already
+// set, i.e. whether adding it didn't change the filter. This is the same as
+// TestAndAdd.
+func (f *Filter64) AddReporting(data []byte) (alreadyPresent bool) {
+	return f.TestAndAdd(data)
+}
+
 // Resets the filter.
 func (f *Filter64) Reset() {
 	f.b.Reset()
@@ -81,20 +198,58 @@ func (f *Filter64) Reset() {
 
 // Create a bloom filter with an expected n number of items, and an acceptable
 // false positive rate of p, e.g. 0.01 for 1%.
-func New64(n int64, p float64) *Filter64 {
-	m, k := estimates64(uint64(n), p)
+func New64(n int64, p float64, opts ...Option) *Filter64 {
+	m, k := estimates64(n, p)
 	f := &Filter64{
-		newFilter64(m, k),
+		newFilter64(m, k, opts...),
 		bitset.New64(m),
 	}
+	f.capacity, f.fpRate = uint64(n), p
 	return f
 }
 
-// A counting bloom filter using the 64-bit FNV-1a hash function. Supports
-// removing items from the filter.
+// Create a bloom filter of exactly m bits, setting k bits per item, like
+// NewWithSize.
+func NewWithSize64(m, k uint64, opts ...Option) *Filter64 {
+	if m == 0 || k == 0 {
+		panic("Unable to create a 64-bit bloom filter without bits or hash functions.")
+	}
+	return &Filter64{
+		newFilter64(m, k, opts...),
+		bitset.New64(m),
+	}
+}
+
+// Create a bloom filter like New64, but return an error instead of creating a
+// useless filter, or panicking, if n or p are invalid, e.g. if n isn't
+// positive, or p isn't between 0 and 1, or if the filter would be too large
+// to allocate. The error wraps ErrInvalidParameters, and ErrTooLarge in the
+// latter case.
+func New64WithError(n int64, p float64, opts ...Option) (*Filter64, error) {
+	if _, _, err := checkedEstimates64(n, p); err != nil {
+		return nil, err
+	}
+	return New64(n, p, opts...), nil
+}
+
+// Create a bloom filter like New64, but with a random seed (see WithSeed), so
+// that its false positives differ from those of other filters holding the
+// same items.
+func NewSeeded64(n int64, p float64, opts ...Option) *Filter64 {
+	return New64(n, p, append([]Option{WithSeed(randomSeed())}, opts...)...)
+}
+
+// A counting bloom filter using the 64-bit FNV-1 and CRC-64 hash functions,
+// like Filter64. Supports removing items from the filter. By default, its
+// counters are unbounded: the filter is a stack of bitsets, to which layers
+// are added as counters grow. WithCounterWidth makes it use an array of
+// fixed-width counters instead, which saturate rather than grow.
 type CountingFilter64 struct {
 	*filter64
 	b []*bitset.Bitset64
+	c *counters // fixed-width counters replacing b, if given WithCounterWidth
+
+	conservative bool // whether only the smallest counters are incremented
 }
 
 // Checks whether data was previously added to the filter. Returns true if
@@ -102,18 +257,61 @@ type CountingFilter64 struct {
 // of the filter. The result cannot cannot be falsely negative (unless one
 // has removed an item that wasn't actually added to the filter previously.)
 func (f *CountingFilter64) Test(data []byte) bool {
-	b := f.b[0]
-	for _, v := range f.bits(data) {
-		if !b.Test(v) {
-			return false
+	return f.test(f.bits(data))
+}
+
+func (f *CountingFilter64) test(is []uint64) bool {
+	if f.c != nil {
+		for _, v := range is {
+			if f.c.get(v) == 0 {
+				return false
+			}
 		}
+		return true
 	}
-	return true
+	return testBits64(f.b[0], is)
 }
 
 // Adds data to the filter.
 func (f *CountingFilter64) Add(data []byte) {
-	for _, v := range f.bits(data) {
+	f.add(f.bits(data))
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. Data is hashed only once.
+func (f *CountingFilter64) TestAndAdd(data []byte) bool {
+	is := f.bits(data)
+	present := f.test(is)
+	f.add(is)
+	return present
+}
+
+// Adds n occurrences of data to the filter, hashing it only once, e.g. to
+// replay aggregated counts.
+func (f *CountingFilter64) AddN(data []byte, n int) {
+	is := f.bits(data)
+	for ; n > 0; n-- {
+		f.add(is)
+	}
+}
+
+func (f *CountingFilter64) add(is []uint64) {
+	if f.conservative {
+		min := uint64(f.count(is))
+		for _, v := range is {
+			if f.counter(v) == min {
+				f.setCounter(v, min+1)
+			}
+		}
+		return
+	}
+	if f.c != nil {
+		for _, v := range is {
+			f.c.inc(v)
+		}
+		return
+	}
+	for _, v := range is {
 		done := false
 		for _, ov := range f.b {
 			if !ov.Test(v) {
@@ -130,11 +328,122 @@ func (f *CountingFilter64) Add(data []byte) {
 	}
 }
 
+// Returns the (estimated) number of times data was added to the filter, minus
+// the number of times it was removed: the smallest of its counters. The count
+// may be too high if other items share all of its bits, but is never too low.
+func (f *CountingFilter64) Count(data []byte) int {
+	return f.count(f.bits(data))
+}
+
+// Adds data to the filter, and returns its (estimated) count afterwards, as
+// reported by Count, hashing data only once, e.g. to allow at most a certain
+// number of requests per key.
+func (f *CountingFilter64) AddAndCount(data []byte) int {
+	is := f.bits(data)
+	f.add(is)
+	return f.count(is)
+}
+
+// Adds data to the filter unless its (estimated) count, as reported by Count,
+// is already max or more, and returns whether it was added. This keeps an
+// item which is added far more often than the others, e.g. by abuse, from
+// making the filter add layers or saturate its counters. Since the count may
+// be too high, an item may be refused before it was added max times.
+func (f *CountingFilter64) AddCapped(data []byte, max int) (added bool) {
+	is := f.bits(data)
+	if f.count(is) >= max {
+		return false
+	}
+	f.add(is)
+	return true
+}
+
+func (f *CountingFilter64) count(is []uint64) int {
+	if f.c != nil {
+		min := f.c.max()
+		for _, v := range is {
+			if c := f.c.get(v); c < min {
+				min = c
+			}
+		}
+		return int(min)
+	}
+	min := len(f.b)
+	for _, v := range is {
+		c := 0
+		for c < min && f.b[c].Test(v) {
+			c++
+		}
+		min = c
+	}
+	return min
+}
+
 // Removes data from the filter. This exact data must have been previously added
 // to the filter, or future results will be inconsistent.
 func (f *CountingFilter64) Remove(data []byte) {
+	f.remove(f.bits(data))
+}
+
+// Adds every one of items to the filter, reusing one buffer for their
+// indexes, e.g. to add a batch of records at once.
+func (f *CountingFilter64) AddAll(items [][]byte) {
+	var is []uint64
+	for _, data := range items {
+		is = f.bitsTo(is, data)
+		f.add(is)
+	}
+}
+
+// Removes every one of items from the filter, like Remove, reusing one buffer
+// for their indexes, e.g. to expire a batch of processed records at once.
+// Every item must have been previously added to the filter.
+func (f *CountingFilter64) RemoveAll(items [][]byte) {
+	var is []uint64
+	for _, data := range items {
+		is = f.bitsTo(is, data)
+		f.remove(is)
+	}
+}
+
+// Removes data from the filter if it is (probably) present, as reported by
+// Test, and returns whether it was removed. If data wasn't added to the
+// filter, this usually leaves the filter unchanged, rather than making future
+// results inconsistent as Remove would, but a false positive is still removed.
+func (f *CountingFilter64) TryRemove(data []byte) (removed bool) {
+	is := f.bits(data)
+	if !f.test(is) {
+		return false
+	}
+	f.remove(is)
+	return true
+}
+
+// Checks whether data is (probably) present, as reported by Test, and if so,
+// removes it from the filter, hashing data only once, e.g. to release an
+// item from a work queue. This is the same as TryRemove.
+func (f *CountingFilter64) TestAndRemove(data []byte) bool {
+	return f.TryRemove(data)
+}
+
+// Removes data from the filter like TryRemove, but returns ErrNotPresent if
+// it isn't present, rather than false.
+func (f *CountingFilter64) StrictRemove(data []byte) error {
+	if !f.TryRemove(data) {
+		return ErrNotPresent
+	}
+	return nil
+}
+
+func (f *CountingFilter64) remove(is []uint64) {
+	if f.c != nil {
+		for _, v := range is {
+			f.c.dec(v)
+		}
+		return
+	}
 	last := len(f.b) - 1
-	for _, v := range f.bits(data) {
+	for _, v := range is {
 		for oi := last; oi >= 0; oi-- {
 			ov := f.b[oi]
 			if ov.Test(v) {
@@ -145,28 +454,64 @@ func (f *CountingFilter64) Remove(data []byte) {
 	}
 }
 
-// Resets the filter.
+// Returns the number of times a counter wasn't incremented because it was
+// saturated, for a filter created WithCounterWidth. The count of items using
+// a saturated counter may be too high, and doesn't drop as they are removed.
+// Returns 0 for other filters, which add layers as needed instead.
+func (f *CountingFilter64) OverflowCount() uint64 {
+	if f.c == nil {
+		return 0
+	}
+	return f.c.overflows
+}
+
+// Resets the filter. The layers added as the filter grew are kept, empty,
+// to be reused; use ResetAndShrink to release them.
 func (f *CountingFilter64) Reset() {
-	f.b = f.b[:1]
+	if f.c != nil {
+		f.c.reset()
+		return
+	}
+	for _, b := range f.b {
+		b.Reset()
+	}
+}
+
+// Resets the filter, and releases every layer but the first, returning the
+// memory they use.
+func (f *CountingFilter64) ResetAndShrink() {
+	if f.c != nil {
+		f.c.reset()
+		return
+	}
+	f.b = []*bitset.Bitset64{f.b[0]}
 	f.b[0].Reset()
 }
 
 // Create a counting bloom filter with an expected n number of items, and an
 // acceptable false positive rate of p. Counting bloom filters support
 // the removal of items from the filter.
-func NewCounting64(n int64, p float64) *CountingFilter64 {
-	m, k := estimates64(uint64(n), p)
-	f := &CountingFilter64{
-		newFilter64(m, k),
-		[]*bitset.Bitset64{bitset.New64(m)},
+func NewCounting64(n int64, p float64, opts ...Option) *CountingFilter64 {
+	m, k := estimates64(n, p)
+	o := newOptions(opts)
+	f := &CountingFilter64{filter64: newFilter64(m, k, opts...)}
+	f.conservative = o.conservative
+	if o.counterWidth != 0 {
+		f.c = newCounters(m, o.counterWidth)
+	} else {
+		f.b = newLayers64(m, o.layers)
 	}
+	f.capacity, f.fpRate = uint64(n), p
 	return f
 }
 
-// A layered bloom filter using the 64-bit FNV-1a hash function.
+// A layered bloom filter using the 64-bit FNV-1 and CRC-64 hash functions,
+// like Filter64.
 type LayeredFilter64 struct {
 	*filter64
-	b []*bitset.Bitset64
+	b    []*bitset.Bitset64
+	max  int         // the most layers the filter may have, or 0 for no limit
+	info []LayerInfo // of each layer
 }
 
 // Checks whether data was previously added to the filter. Returns the number of
@@ -192,10 +537,60 @@ func (f *LayeredFilter64) Test(data []byte) (int, bool) {
 	return 0, false
 }
 
+// Returns the (estimated) number of times data was added to the filter: the
+// number of the last layer it was added to, as reported by Test, or 0 if it
+// wasn't added. The count may be too high if other items share its bits, but
+// is never too low.
+func (f *LayeredFilter64) Count(data []byte) int {
+	n, _ := f.Test(data)
+	return n
+}
+
+// Checks whether data was (probably) added to the filter at least n times,
+// e.g. to drop an item after its 10th occurrence. Only the nth layer is
+// checked, since an item added n times is in every layer up to the nth.
+// Returns true if n is less than 1.
+func (f *LayeredFilter64) TestAtLeast(data []byte, n int) bool {
+	if n < 1 {
+		return true
+	}
+	if n > len(f.b) {
+		return false
+	}
+	return testBits64(f.b[n-1], f.bits(data))
+}
+
 // Adds data to the filter. Returns the number of the layer where the data
 // was added, e.g. 1 for the first layer.
 func (f *LayeredFilter64) Add(data []byte) int {
+	return f.add(f.bits(data))
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. Data is hashed only once.
+func (f *LayeredFilter64) TestAndAdd(data []byte) bool {
 	is := f.bits(data)
+	present := testBits64(f.b[0], is)
+	f.add(is)
+	return present
+}
+
+// Adds n occurrences of data to the filter, hashing it only once, e.g. to
+// replay aggregated counts. Returns the number of the layer where the last
+// occurrence was added, as Add does, or 0 if n is less than 1.
+func (f *LayeredFilter64) AddN(data []byte, n int) int {
+	if n < 1 {
+		return 0
+	}
+	is := f.bits(data)
+	layer := 0
+	for ; n > 0; n-- {
+		layer = f.add(is)
+	}
+	return layer
+}
+
+func (f *LayeredFilter64) add(is []uint64) int {
 	var (
 		i int
 		v *bitset.Bitset64
@@ -214,29 +609,103 @@ func (f *LayeredFilter64) Add(data []byte) int {
 			return i + 1
 		}
 	}
-	nb := bitset.New64(f.b[0].Len())
+	if f.max > 0 && len(f.b) >= f.max {
+		return len(f.b)
+	}
+	nb := bitset.New64(f.m)
 	f.b = append(f.b, nb)
+	f.info = append(f.info, LayerInfo{Created: time.Now()})
 	for _, v := range is {
 		nb.Set(v)
 	}
 	return i + 2
 }
 
-// Resets the filter.
+// Removes one occurrence of data from the filter by clearing its bits in the
+// last layer it was added to, as reported by Test, and returns the number of
+// that layer, or 0 if data wasn't in the filter. Other items sharing those
+// bits in that layer lose an occurrence too, so, as with a counting filter,
+// only data that was previously added should be removed, and a layered
+// filter which items are removed from has a higher chance of counting them
+// too low.
+func (f *LayeredFilter64) Remove(data []byte) int {
+	is := f.bits(data)
+	for i := len(f.b) - 1; i >= 0; i-- {
+		if testBits64(f.b[i], is) {
+			for _, v := range is {
+				f.b[i].Clear(v)
+			}
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Resets layer n of the filter, e.g. 1 for the first layer, as numbered by
+// Add and Test. Items in higher layers are still reported by Test with their
+// full count, but not by TestAtLeast for n or less. Layers which don't exist
+// are ignored. The layer's LayerInfo is reset too.
+func (f *LayeredFilter64) ResetLayer(n int) {
+	if n >= 1 && n <= len(f.b) {
+		f.b[n-1].Reset()
+		f.info[n-1] = LayerInfo{Created: time.Now()}
+	}
+}
+
+// Drops the first layer of the filter, moving every other layer down by one,
+// and reuses it, empty, as the top layer, so that the count of every item
+// drops by one without rebuilding the filter. For example, if each item is
+// added at most once per epoch, and the layers are rotated at the end of
+// each epoch, an item's count is at most the number of the recent epochs it
+// was seen in, and items not seen for as many epochs as there are layers are
+// forgotten. The layers of a 64-bit filter are all the same size, so unlike
+// LayeredFilter.RotateLayers, it always returns nil.
+func (f *LayeredFilter64) RotateLayers() error {
+	first := f.b[0]
+	copy(f.b, f.b[1:])
+	first.Reset()
+	f.b[len(f.b)-1] = first
+	copy(f.info, f.info[1:])
+	f.info[len(f.info)-1] = LayerInfo{Created: time.Now()}
+	return nil
+}
+
+// Resets the filter. The layers added as the filter grew are kept, empty,
+// to be reused; use ResetAndShrink to release them.
 func (f *LayeredFilter64) Reset() {
-	f.b = f.b[:1]
+	for _, b := range f.b {
+		b.Reset()
+	}
+	f.info = newLayerInfo(len(f.b))
+}
+
+// Resets the filter, and releases every layer but the first, returning the
+// memory they use.
+func (f *LayeredFilter64) ResetAndShrink() {
+	f.b = []*bitset.Bitset64{f.b[0]}
 	f.b[0].Reset()
+	f.info = newLayerInfo(1)
 }
 
 // Create a layered bloom filter with an expected n number of items, and an
 // acceptable false positive rate of p. Layered bloom filters can be used
 // to keep track of a certain, arbitrary count of items, e.g. to check if some
 // given data was added to the filter 10 times or less.
-func NewLayered64(n int64, p float64) *LayeredFilter64 {
-	m, k := estimates64(uint64(n), p)
+func NewLayered64(n int64, p float64, opts ...Option) *LayeredFilter64 {
+	m, k := estimates64(n, p)
 	f := &LayeredFilter64{
-		newFilter64(m, k),
-		[]*bitset.Bitset64{bitset.New64(m)},
+		filter64: newFilter64(m, k, opts...),
+		b:        newLayers64(m, newOptions(opts).layers),
 	}
+	f.info = newLayerInfo(len(f.b))
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
+
+// Create a layered bloom filter like NewLayered64, but with at most maxLayers
+// layers, like NewLayeredMax.
+func NewLayeredMax64(n int64, p float64, maxLayers int, opts ...Option) *LayeredFilter64 {
+	f := NewLayered64(n, p, opts...)
+	f.max = maxLayers
 	return f
 }
@@ -1,8 +1,6 @@
 package bloom
 
 import (
-	"github.com/pmylund/go-bitset"
-
 	"encoding/binary"
 	"strconv"
 	"testing"
@@ -59,7 +57,7 @@ func estimateP64(f *Filter64, n uint64) float64 {
 			fp++
 		}
 	}
-	return float64(fp) / float64(100)
+	return float64(fp) / float64(10000)
 }
 
 func TestDirect64_20_5(t *testing.T) {
@@ -67,13 +65,10 @@ func TestDirect64_20_5(t *testing.T) {
 	k := uint64(5)
 	load := uint64(20)
 	m := n * load
-	f := &Filter64{
-		newFilter64(m, k),
-		bitset.New64(m),
-	}
+	f := NewWithSize64(m, k)
 	p := estimateP64(f, n)
-	if p > 0.0001 {
-		t.Errorf("False positive rate too high: %f", p)
+	if want := theoreticalP(uint32(m), uint32(k), uint32(n)); p > 2*want {
+		t.Errorf("False positive rate too high: %f, expected about %f", p, want)
 	}
 }
 
@@ -82,13 +77,10 @@ func TestDirect64_15_10(t *testing.T) {
 	k := uint64(10)
 	load := uint64(15)
 	m := n * load
-	f := &Filter64{
-		newFilter64(m, k),
-		bitset.New64(m),
-	}
+	f := NewWithSize64(m, k)
 	p := estimateP64(f, n)
-	if p > 0.0001 {
-		t.Errorf("False positive rate too high: %f", p)
+	if want := theoreticalP(uint32(m), uint32(k), uint32(n)); p > 2*want {
+		t.Errorf("False positive rate too high: %f, expected about %f", p, want)
 	}
 }
 
@@ -126,6 +118,34 @@ func TestCountingFilter64(t *testing.T) {
 	}
 }
 
+func TestCountingFilter64Count(t *testing.T) {
+	f := NewCounting64(3000, 0.01)
+	f.Add(foo)
+	f.Add(foo)
+	f.Add(bar)
+	if c := f.Count(foo); c != 2 {
+		t.Errorf("count of foo %d, expected 2", c)
+	}
+	f.Remove(foo)
+	if c := f.Count(foo); c != 1 {
+		t.Errorf("count of foo %d after removing one, expected 1", c)
+	}
+	if c := f.Count(baz); c != 0 {
+		t.Errorf("count of baz %d, expected 0", c)
+	}
+}
+
+func TestCountingFilter64TryRemove(t *testing.T) {
+	f := NewCounting64(3000, 0.01)
+	f.Add(foo)
+	if f.TryRemove(bar) || f.StrictRemove(bar) != ErrNotPresent || !f.Test(foo) {
+		t.Error("bar removed, but it was never added")
+	}
+	if !f.TryRemove(foo) || f.Test(foo) {
+		t.Error("foo not removed")
+	}
+}
+
 func TestLayeredFilter64(t *testing.T) {
 	layers := 5
 	f := NewLayered(3000, 0.01)
@@ -161,3 +181,32 @@ func BenchmarkFilterAddExisting64(b *testing.B) {
 		f.Add(foo)
 	}
 }
+
+func TestAccessors64(t *testing.T) {
+	f := NewCounting64(1000, 0.01)
+	if f.K() != f.k || f.M() != f.m || f.Capacity() != 1000 || f.FPRate() != 0.01 {
+		t.Errorf("unexpected parameters %d %d %d %f", f.K(), f.M(), f.Capacity(), f.FPRate())
+	}
+}
+
+func TestResetAndShrink64(t *testing.T) {
+	c := NewCounting64(100, 0.01)
+	l := NewLayered64(100, 0.01)
+	for i := 0; i < 3; i++ {
+		c.Add(foo)
+		l.Add(foo)
+	}
+	c.Reset()
+	l.Reset()
+	if len(c.b) != 3 || len(l.b) != 3 {
+		t.Fatalf("%d and %d layers kept by Reset, expected 3", len(c.b), len(l.b))
+	}
+	c.ResetAndShrink()
+	l.ResetAndShrink()
+	if len(c.b) != 1 || len(l.b) != 1 {
+		t.Fatalf("%d and %d layers kept by ResetAndShrink, expected 1", len(c.b), len(l.b))
+	}
+	if _, ok := l.Test(foo); c.Test(foo) || ok {
+		t.Error("foo in a filter after ResetAndShrink")
+	}
+}
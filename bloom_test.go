@@ -4,6 +4,10 @@ import (
 	"github.com/pmylund/go-bitset"
 
 	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"slices"
 	"strconv"
 	"testing"
 )
@@ -67,7 +71,35 @@ func estimateP(f *Filter, n uint32) float64 {
 			fp++
 		}
 	}
-	return float64(fp) / float64(100)
+	return float64(fp) / float64(10000)
+}
+
+// Returns the bit indexes the baseline version of go-bloom derived for data:
+// the halves of its 64-bit FNV-1 sum, combined with double hashing.
+func baselineBits(m, k uint32, data []byte) []uint32 {
+	h := fnv.New64()
+	h.Write(data)
+	d := h.Sum(nil)
+	a := binary.BigEndian.Uint32(d[4:8])
+	b := binary.BigEndian.Uint32(d[0:4])
+	is := make([]uint32, k)
+	for i := uint32(0); i < k; i++ {
+		is[i] = (a + b*i) % m
+	}
+	return is
+}
+
+// Checks that a filter created with WithLegacyHashing derives the same bit
+// indexes for the first n integers as the baseline version did.
+func checkBaselineBits(t *testing.T, f *Filter, n uint32) {
+	t.Helper()
+	data := make([]byte, 4)
+	for i := uint32(0); i < n; i++ {
+		binary.BigEndian.PutUint32(data, i)
+		if got, want := f.bits(data), baselineBits(f.m, f.k, data); !slices.Equal(got, want) {
+			t.Fatalf("bits of %d are %v, expected %v", i, got, want)
+		}
+	}
 }
 
 func TestDirect20_5(t *testing.T) {
@@ -75,14 +107,16 @@ func TestDirect20_5(t *testing.T) {
 	k := uint32(5)
 	load := uint32(20)
 	m := n * load
-	f := &Filter{
-		newFilter(m, k),
-		bitset.New32(m),
-	}
+	f := NewWithSize(m, k, WithLegacyHashing())
+	checkBaselineBits(t, f, n)
 	p := estimateP(f, n)
 	if p > 0.0001 {
 		t.Errorf("False positive rate too high: %f", p)
 	}
+	f = NewWithSize(m, k)
+	if p, want := estimateP(f, n), theoreticalP(m, k, n); p > 2*want {
+		t.Errorf("False positive rate too high: %f, expected about %f", p, want)
+	}
 }
 
 func TestDirect15_10(t *testing.T) {
@@ -90,20 +124,23 @@ func TestDirect15_10(t *testing.T) {
 	k := uint32(10)
 	load := uint32(15)
 	m := n * load
-	f := &Filter{
-		newFilter(m, k),
-		bitset.New32(m),
-	}
+	f := NewWithSize(m, k, WithLegacyHashing())
+	checkBaselineBits(t, f, n)
 	p := estimateP(f, n)
 	if p > 0.0001 {
 		t.Errorf("False positive rate too high: %f", p)
 	}
+	f = NewWithSize(m, k)
+	if p, want := estimateP(f, n), theoreticalP(m, k, n); p > 2*want {
+		t.Errorf("False positive rate too high: %f, expected about %f", p, want)
+	}
 }
 
 func TestEstimated10_0001(t *testing.T) {
 	n := 10000
 	fp := 0.0001
-	f := New(n, fp)
+	f := New(n, fp, WithLegacyHashing())
+	checkBaselineBits(t, f, uint32(n))
 	p := estimateP(f, uint32(n))
 	if p > fp {
 		t.Errorf("False positive rate too high: %f", p)
@@ -113,13 +150,35 @@ func TestEstimated10_0001(t *testing.T) {
 func TestEstimated10_001(t *testing.T) {
 	n := 10000
 	fp := 0.001
-	f := New(n, fp)
+	f := New(n, fp, WithLegacyHashing())
+	checkBaselineBits(t, f, uint32(n))
 	p := estimateP(f, uint32(n))
 	if p > fp {
 		t.Errorf("False positive rate too high: %f", p)
 	}
 }
 
+// Returns the theoretical false positive rate of a filter with m bits and k
+// hash functions holding n items.
+func theoreticalP(m, k, n uint32) float64 {
+	return math.Pow(1-math.Exp(-float64(k)*float64(n)/float64(m)), float64(k))
+}
+
+func TestFPRate(t *testing.T) {
+	n := uint32(10000)
+	for _, f := range []*Filter{
+		New(int(n), 0.01),
+		New(int(n), 0.001),
+		{newFilter(n*15, 10), bitset.New32(n * 15)},
+		{newFilter(n*20, 5), bitset.New32(n * 20)},
+	} {
+		want := theoreticalP(f.m, f.k, n)
+		if p := estimateP(f, n); p > 2*want {
+			t.Errorf("m %d k %d: false positive rate %f, expected about %f", f.m, f.k, p, want)
+		}
+	}
+}
+
 func TestCountingFilter(t *testing.T) {
 	f := NewCounting(3000, 0.01)
 	f.Add(foo)
@@ -134,6 +193,77 @@ func TestCountingFilter(t *testing.T) {
 	}
 }
 
+func TestCountingFilterCount(t *testing.T) {
+	f := NewCounting(3000, 0.01)
+	for i := 1; i <= 3; i++ {
+		f.Add(foo)
+		if c := f.Count(foo); c != i {
+			t.Errorf("count %d after %d adds", c, i)
+		}
+	}
+	f.Add(bar)
+	f.Remove(foo)
+	if c := f.Count(foo); c != 2 {
+		t.Errorf("count %d after removing one of 3", c)
+	}
+	if c := f.Count(bar); c != 1 {
+		t.Errorf("count of bar %d, expected 1", c)
+	}
+	if c := f.Count(baz); c != 0 {
+		t.Errorf("count of baz %d, expected 0", c)
+	}
+}
+
+func TestCountingFilterTryRemove(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCounterWidth(4)}} {
+		f := NewCounting(3000, 0.01, opts...)
+		f.Add(foo)
+		f.Add(bar)
+		if f.TryRemove(baz) {
+			t.Error("baz removed, but it was never added")
+		}
+		if err := f.StrictRemove(baz); err != ErrNotPresent {
+			t.Errorf("StrictRemove of baz returned %v", err)
+		}
+		if !f.Test(foo) || !f.Test(bar) {
+			t.Fatal("removing baz changed the filter")
+		}
+		if !f.TryRemove(foo) || f.Test(foo) {
+			t.Error("foo not removed")
+		}
+		if f.TryRemove(foo) {
+			t.Error("foo removed twice")
+		}
+		f.Add(foo)
+		if !f.TestAndRemove(foo) || f.TestAndRemove(foo) {
+			t.Error("TestAndRemove of foo added once didn't succeed exactly once")
+		}
+		if err := f.StrictRemove(bar); err != nil || f.Test(bar) {
+			t.Errorf("StrictRemove of bar returned %v", err)
+		}
+	}
+}
+
+func TestCountingFilterAddAll(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCounterWidth(8)}} {
+		f := NewCounting(3000, 0.01, opts...)
+		f.AddAll([][]byte{foo, bar, foo})
+		if f.Count(foo) != 2 || f.Count(bar) != 1 {
+			t.Errorf("counts %d and %d after AddAll", f.Count(foo), f.Count(bar))
+		}
+		f.RemoveAll([][]byte{foo, bar})
+		if f.Count(foo) != 1 || f.Test(bar) {
+			t.Errorf("counts %d and %d after RemoveAll", f.Count(foo), f.Count(bar))
+		}
+	}
+	f := NewCounting64(3000, 0.01)
+	f.AddAll([][]byte{foo, bar})
+	f.RemoveAll([][]byte{foo})
+	if f.Test(foo) || !f.Test(bar) {
+		t.Error("unexpected 64-bit filter after AddAll and RemoveAll")
+	}
+}
+
 func TestLayeredFilter(t *testing.T) {
 	layers := 5
 	f := NewLayered(3000, 0.01)
@@ -147,6 +277,166 @@ func TestLayeredFilter(t *testing.T) {
 	}
 }
 
+func TestLayeredFilterCount(t *testing.T) {
+	f := NewLayered(3000, 0.01)
+	f64 := NewLayered64(3000, 0.01)
+	for i := 1; i <= 10; i++ {
+		f.Add(foo)
+		f64.Add(foo)
+		if c := f.Count(foo); c != i {
+			t.Errorf("count %d after adding foo %d times", c, i)
+		}
+		if c := f64.Count(foo); c != i {
+			t.Errorf("64-bit count %d after adding foo %d times", c, i)
+		}
+	}
+	for n := 0; n <= 11; n++ {
+		want := n <= 10
+		if got := f.TestAtLeast(foo, n); got != want {
+			t.Errorf("TestAtLeast(foo, %d) = %v", n, got)
+		}
+		if got := f64.TestAtLeast(foo, n); got != want {
+			t.Errorf("64-bit TestAtLeast(foo, %d) = %v", n, got)
+		}
+	}
+	if f.Count(bar) != 0 || f.TestAtLeast(bar, 1) {
+		t.Error("bar in the filter")
+	}
+}
+
+func TestLayeredFilterMax(t *testing.T) {
+	f := NewLayeredMax(3000, 0.01, 3)
+	f64 := NewLayeredMax64(3000, 0.01, 3)
+	for i := 1; i <= 6; i++ {
+		want := i
+		if want > 3 {
+			want = 3
+		}
+		if n := f.Add(foo); n != want {
+			t.Errorf("add %d returned layer %d, expected %d", i, n, want)
+		}
+		if n := f64.Add(foo); n != want {
+			t.Errorf("64-bit add %d returned layer %d, expected %d", i, n, want)
+		}
+	}
+	if len(f.b) != 3 || len(f64.b) != 3 || f.Clone().max != 3 {
+		t.Errorf("%d and %d layers allocated, expected 3", len(f.b), len(f64.b))
+	}
+	if n := f.Add(bar); n != 1 {
+		t.Errorf("bar added to layer %d", n)
+	}
+}
+
+func TestLayeredFilterRotate(t *testing.T) {
+	f := NewLayered(3000, 0.01, WithLayers(3))
+	f64 := NewLayered64(3000, 0.01, WithLayers(3))
+	for i := 0; i < 3; i++ {
+		f.Add(foo)
+		f64.Add(foo)
+	}
+	f.Add(bar)
+	f64.Add(bar)
+	if err := f.RotateLayers(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f64.RotateLayers(); err != nil {
+		t.Fatal(err)
+	}
+	if f.Count(foo) != 2 || f.Count(bar) != 0 || len(f.b) != 3 {
+		t.Errorf("counts %d and %d after rotating", f.Count(foo), f.Count(bar))
+	}
+	if f64.Count(foo) != 2 || f64.Count(bar) != 0 {
+		t.Errorf("64-bit counts %d and %d after rotating", f64.Count(foo), f64.Count(bar))
+	}
+	if n := f.Add(foo); n != 3 {
+		t.Errorf("foo added to layer %d after rotating", n)
+	}
+	f.ResetLayer(3)
+	f64.ResetLayer(2)
+	f.ResetLayer(0)
+	f.ResetLayer(4)
+	if f.Count(foo) != 2 || !f64.TestAtLeast(foo, 1) || f64.TestAtLeast(foo, 2) {
+		t.Error("unexpected filters after resetting a layer")
+	}
+}
+
+func TestLayeredFilterRemove(t *testing.T) {
+	f := NewLayered(3000, 0.01)
+	f64 := NewLayered64(3000, 0.01)
+	for i := 0; i < 3; i++ {
+		f.Add(foo)
+		f64.Add(foo)
+	}
+	for i := 3; i > 0; i-- {
+		if n := f.Remove(foo); n != i {
+			t.Errorf("foo removed from layer %d, expected %d", n, i)
+		}
+		if n := f64.Remove(foo); n != i {
+			t.Errorf("foo removed from 64-bit layer %d, expected %d", n, i)
+		}
+		if f.Count(foo) != i-1 || f64.Count(foo) != i-1 {
+			t.Errorf("counts %d and %d after removing foo", f.Count(foo), f64.Count(foo))
+		}
+	}
+	if f.Remove(foo) != 0 || f64.Remove(foo) != 0 {
+		t.Error("foo removed from an empty filter")
+	}
+}
+
+func TestLayeredFilterRates(t *testing.T) {
+	f := NewLayeredRates(3000, []float64{0.001, 0.1}, WithLayers(2))
+	if len(f.b) != 2 || f.b[0].Len() <= f.b[1].Len() {
+		t.Fatalf("layers of %d and %d bits", f.b[0].Len(), f.b[1].Len())
+	}
+	for i := 1; i <= 4; i++ {
+		if n := f.Add(foo); n != i {
+			t.Errorf("add %d returned layer %d", i, n)
+		}
+	}
+	if f.b[2].Len() != f.b[1].Len() {
+		t.Errorf("third layer of %d bits, expected %d", f.b[2].Len(), f.b[1].Len())
+	}
+	if f.Count(foo) != 4 || !f.TestAtLeast(foo, 4) || f.TestAtLeast(foo, 5) {
+		t.Errorf("count %d after adding foo 4 times", f.Count(foo))
+	}
+	if n := f.Remove(foo); n != 4 || f.Count(foo) != 3 {
+		t.Errorf("foo removed from layer %d", n)
+	}
+	g := f.Clone()
+	g.Add(bar)
+	if err := f.Merge(g); err != nil || f.Count(bar) != 1 {
+		t.Errorf("merging a clone: %v", err)
+	}
+	if err := f.Merge(NewLayered(3000, 0.001)); err != ErrIncompatible {
+		t.Errorf("merging a uniform filter returned %v", err)
+	}
+	if err := f.RotateLayers(); err != ErrNotRotatable || f.Count(foo) != 3 {
+		t.Errorf("rotating returned %v, count %d", err, f.Count(foo))
+	}
+}
+
+func TestAddN(t *testing.T) {
+	l := NewLayered(3000, 0.01)
+	l64 := NewLayered64(3000, 0.01)
+	c := NewCounting(3000, 0.01)
+	c64 := NewCounting64(3000, 0.01, WithCounterWidth(4))
+	if l.AddN(foo, 0) != 0 || l.Count(foo) != 0 {
+		t.Error("foo added 0 times is in the filter")
+	}
+	if n := l.AddN(foo, 4); n != 4 || l.Count(foo) != 4 {
+		t.Errorf("AddN of 4 occurrences returned layer %d", n)
+	}
+	if n := l64.AddN(foo, 3); n != 3 || l64.Count(foo) != 3 {
+		t.Errorf("64-bit AddN of 3 occurrences returned layer %d", n)
+	}
+	c.AddN(foo, 5)
+	c64.AddN(foo, 5)
+	c64.AddN(bar, -1)
+	if c.Count(foo) != 5 || c64.Count(foo) != 5 || c64.Test(bar) {
+		t.Errorf("counts %d and %d after AddN", c.Count(foo), c64.Count(foo))
+	}
+}
+
 const (
 	million = 1000000
 	billion = 1000 * million
@@ -185,3 +475,185 @@ func BenchmarkFilterAddExisting(b *testing.B) {
 		f.Add(foo)
 	}
 }
+
+func TestTestAndAdd(t *testing.T) {
+	for _, f := range []Set{
+		New(1000, 0.01),
+		NewCounting(1000, 0.01),
+		New64(1000, 0.01),
+		NewCounting64(1000, 0.01),
+		NewAging(1000, 0.01),
+		NewDeletable(1000, 0.01, 100),
+		NewQuotient(1000, 0.01),
+		NewWeighted(1000, 0.01),
+		NewInverse(1000),
+	} {
+		if f.TestAndAdd(foo) {
+			t.Errorf("%T: foo present before it was added", f)
+		}
+		if !f.Test(foo) {
+			t.Errorf("%T: foo not added", f)
+		}
+		if !f.TestAndAdd(foo) {
+			t.Errorf("%T: foo not present after it was added", f)
+		}
+	}
+	d := NewDLeft(1000, 0.01)
+	if d.TestAndAdd(foo) || !d.TestAndAdd(foo) {
+		t.Error("unexpected results from d-left filter")
+	}
+	l := NewLayered(1000, 0.01)
+	if l.TestAndAdd(foo) || !l.TestAndAdd(foo) {
+		t.Error("unexpected results from layered filter")
+	}
+	if n, _ := l.Test(foo); n != 2 {
+		t.Errorf("foo is in layer %d, expected 2", n)
+	}
+}
+
+func TestAddReporting(t *testing.T) {
+	f := New(1000, 0.01)
+	if f.AddReporting(foo) || !f.AddReporting(foo) || f.AddReporting(bar) {
+		t.Error("unexpected results from AddReporting")
+	}
+	f64 := New64(1000, 0.01)
+	if f64.AddReporting(foo) || !f64.AddReporting(foo) {
+		t.Error("unexpected results from AddReporting (64-bit)")
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	f := New(1000, 0.01)
+	if f.K() != f.k || f.M() != f.m || f.Capacity() != 1000 || f.FPRate() != 0.01 {
+		t.Errorf("unexpected parameters %d %d %d %f", f.K(), f.M(), f.Capacity(), f.FPRate())
+	}
+	if a := NewAging(500, 0.02); a.Capacity() != 500 || a.FPRate() != 0.02 {
+		t.Errorf("unexpected aging filter parameters %d %f", a.Capacity(), a.FPRate())
+	}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &Filter{}
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if g.K() != f.K() || g.M() != f.M() || g.Capacity() != 0 {
+		t.Errorf("unexpected decoded parameters %d %d %d", g.K(), g.M(), g.Capacity())
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	if f, err := NewWithError(1000, 0.01); err != nil || f.Capacity() != 1000 {
+		t.Fatalf("NewWithError returned %v", err)
+	}
+	for _, c := range []struct {
+		n int
+		p float64
+	}{
+		{0, 0.01},
+		{-1, 0.01},
+		{1000, 0},
+		{1000, 1},
+		{1000, math.NaN()},
+		{2 * billion, 0.01},
+	} {
+		if _, err := NewWithError(c.n, c.p); !errors.Is(err, ErrInvalidParameters) {
+			t.Errorf("NewWithError(%d, %f) returned %v", c.n, c.p, err)
+		}
+	}
+	if _, err := New64WithError(1000, -0.5); !errors.Is(err, ErrInvalidParameters) {
+		t.Errorf("New64WithError returned %v", err)
+	}
+	if _, err := New64WithError(1<<62, 1e-300); !errors.Is(err, ErrInvalidParameters) {
+		t.Errorf("New64WithError for a huge filter returned %v", err)
+	}
+	if _, err := New64WithError(1000, 0.01); err != nil {
+		t.Errorf("New64WithError returned %v", err)
+	}
+}
+
+func TestNewWithSize(t *testing.T) {
+	f := NewWithSize(1000, 3)
+	f64 := NewWithSize64(1000, 3)
+	if f.M() != 1000 || f.K() != 3 || f64.M() != 1000 || f64.K() != 3 {
+		t.Fatalf("got m %d, k %d and m %d, k %d; want 1000, 3", f.M(), f.K(), f64.M(), f64.K())
+	}
+	if f.Capacity() != 0 || f.FPRate() != 0 {
+		t.Errorf("got capacity %d and p %f; want 0", f.Capacity(), f.FPRate())
+	}
+	f.Add(foo)
+	f64.Add(foo)
+	if !f.Test(foo) || f.Test(bar) || !f64.Test(foo) || f64.Test(bar) {
+		t.Error("unexpected test results")
+	}
+	defer func() {
+		if x := recover(); x == nil {
+			t.Error("a filter without bits should have caused a panic")
+		}
+	}()
+	NewWithSize(0, 3)
+}
+
+func TestEstimatesOverflow(t *testing.T) {
+	// More than 2^32 items, which used to be truncated to 10, for a filter
+	// which still fits in 32 bits
+	m, _, err := checkedEstimates(1<<32+10, 0.99)
+	if err != nil || m < 1<<26 {
+		t.Errorf("checkedEstimates for 2^32+10 items returned m %d, %v", m, err)
+	}
+	for _, c := range []struct {
+		n int64
+		p float64
+	}{
+		{2 * billion, 0.01},
+		{1 << 40, 0.5},
+		{100 * million, 1e-300},
+	} {
+		if _, _, err := checkedEstimates(c.n, c.p); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("checkedEstimates(%d, %g) returned %v", c.n, c.p, err)
+		}
+	}
+	if _, _, err := checkedEstimates(-1, 0.01); !errors.Is(err, ErrInvalidParameters) || errors.Is(err, ErrTooLarge) {
+		t.Errorf("checkedEstimates for -1 items returned %v", err)
+	}
+	if _, _, err := checkedEstimates64(math.MaxInt64, 1e-300); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("checkedEstimates64 for MaxInt64 items returned %v", err)
+	}
+	if m, k, err := checkedEstimates64(1, 0.99); err != nil || m < 1 || k < 1 {
+		t.Errorf("checkedEstimates64 for a tiny filter returned m %d, k %d, %v", m, k, err)
+	}
+}
+
+func TestResetAndShrink(t *testing.T) {
+	c := NewCounting(100, 0.01)
+	l := NewLayered(100, 0.01)
+	for i := 0; i < 3; i++ {
+		c.Add(foo)
+		l.Add(foo)
+	}
+	c.Reset()
+	l.Reset()
+	if len(c.b) != 3 || len(l.b) != 3 {
+		t.Fatalf("%d and %d layers kept by Reset, expected 3", len(c.b), len(l.b))
+	}
+	if c.Test(foo) {
+		t.Error("foo in the counting filter after Reset")
+	}
+	if _, ok := l.Test(foo); ok {
+		t.Error("foo in the layered filter after Reset")
+	}
+	c.Add(foo)
+	l.Add(foo)
+	c.ResetAndShrink()
+	l.ResetAndShrink()
+	if len(c.b) != 1 || len(l.b) != 1 {
+		t.Fatalf("%d and %d layers kept by ResetAndShrink, expected 1", len(c.b), len(l.b))
+	}
+	if c.Test(foo) {
+		t.Error("foo in the counting filter after ResetAndShrink")
+	}
+	if _, ok := l.Test(foo); ok {
+		t.Error("foo in the layered filter after ResetAndShrink")
+	}
+}
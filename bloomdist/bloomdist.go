@@ -0,0 +1,383 @@
+// Package bloomdist is a client of a distributed bloom filter: one sharded
+// across several filter servers, e.g. ones served by bloomhttp, for key spaces
+// too large to fit in one node's memory. Each item belongs to one node, chosen
+// by consistent hashing of the item, so that adding or removing a node only
+// moves the items of about 1/N of the key space:
+//
+//	f := bloomdist.New(map[string]bloomdist.Backend{
+//		"filter-1": &bloomhttp.Client{URL: "http://filter-1:8080/seen"},
+//		"filter-2": &bloomhttp.Client{URL: "http://filter-2:8080/seen"},
+//	}, bloomdist.WithNegativeCache(time.Second, 100000))
+//	...
+//	err := f.Add(ctx, []byte("foo"))
+//	present, err := f.Test(ctx, []byte("foo"))
+//
+// Every client must be created with the same node names and number of
+// replicas, since they determine which node an item belongs to. The package
+// doesn't depend on a particular transport; Backend is implemented by
+// bloomhttp.Client, for example.
+package bloomdist
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"time"
+)
+
+// The defaults of the options
+const (
+	defaultReplicas = 100
+	defaultRetries  = 2
+	defaultBackoff  = 50 * time.Millisecond
+)
+
+// A remote filter holding the items of one node.
+type Backend interface {
+	// Adds items to the filter.
+	Add(ctx context.Context, items [][]byte) error
+
+	// Checks whether each of items was (probably) added to the filter.
+	Test(ctx context.Context, items [][]byte) ([]bool, error)
+}
+
+// Configures a Filter.
+type Option func(*Filter)
+
+// Gives each node n points on the hash ring, rather than 100. More points
+// spread the items more evenly across the nodes.
+func WithReplicas(n int) Option {
+	return func(f *Filter) {
+		f.replicas = n
+	}
+}
+
+// Makes the filter retry a failed call to a node n times, rather than twice,
+// waiting backoff before the first retry, and twice as long before each one
+// after it. A retry of Add may add items twice, which is harmless.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(f *Filter) {
+		f.retries, f.backoff = n, backoff
+	}
+}
+
+// Makes the filter remember for ttl that an item was absent from its node,
+// for up to size items, so that testing it again doesn't call the node. An
+// item added through this client is forgotten, so the cache only delays
+// seeing items added by other clients, by up to ttl.
+func WithNegativeCache(ttl time.Duration, size int) Option {
+	return func(f *Filter) {
+		f.negTTL, f.negSize = ttl, size
+	}
+}
+
+// The client of a distributed filter. It is safe for concurrent use, if its
+// backends are.
+type Filter struct {
+	nodes    map[string]Backend
+	ring     []point
+	replicas int
+	retries  int
+	backoff  time.Duration
+
+	mu      sync.Mutex
+	neg     map[string]time.Time // the items absent until the time given
+	negTTL  time.Duration
+	negSize int
+	now     func() time.Time
+
+	// Incremented by every call to forget. While calls to TestAll are in
+	// progress, the generation at which each item was last forgotten is
+	// kept in forgotten, so that they don't cache an item added since they
+	// started as absent; if too many are, forgotten is cleared, and the
+	// calls started before flushed cache nothing.
+	gen       uint64
+	flushed   uint64
+	forgotten map[string]uint64
+	testing   int
+}
+
+// A point of a node on the hash ring.
+type point struct {
+	hash uint64
+	node string
+}
+
+// Create a client of the distributed filter made of nodes, by name, e.g. the
+// address of each node.
+func New(nodes map[string]Backend, opts ...Option) *Filter {
+	if len(nodes) == 0 {
+		panic("Unable to create a distributed filter without nodes.")
+	}
+	f := &Filter{
+		nodes:    nodes,
+		replicas: defaultReplicas,
+		retries:  defaultRetries,
+		backoff:  defaultBackoff,
+		now:      time.Now,
+	}
+	for _, o := range opts {
+		o(f)
+	}
+	if f.replicas < 1 {
+		f.replicas = 1
+	}
+	for name := range nodes {
+		for i := 0; i < f.replicas; i++ {
+			f.ring = append(f.ring, point{hashOf(binary.BigEndian.AppendUint32([]byte(name), uint32(i))), name})
+		}
+	}
+	slices.SortFunc(f.ring, func(a, b point) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), cmp.Compare(a.node, b.node))
+	})
+	if f.negTTL > 0 && f.negSize > 0 {
+		f.neg = map[string]time.Time{}
+		f.forgotten = map[string]uint64{}
+	}
+	return f
+}
+
+// Returns the mixed 64-bit FNV-1a sum of data. The same on every client, it
+// places the nodes and items on the ring.
+func hashOf(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// Returns the name of the node item belongs to.
+func (f *Filter) Node(item []byte) string {
+	h := hashOf(item)
+	i, _ := slices.BinarySearchFunc(f.ring, h, func(p point, h uint64) int {
+		return cmp.Compare(p.hash, h)
+	})
+	if i == len(f.ring) {
+		i = 0
+	}
+	return f.ring[i].node
+}
+
+// Adds item to the filter.
+func (f *Filter) Add(ctx context.Context, item []byte) error {
+	return f.AddAll(ctx, [][]byte{item})
+}
+
+// Adds items to the filter, calling each of their nodes once, concurrently.
+// If a node fails, the items of the other nodes may still have been added.
+func (f *Filter) AddAll(ctx context.Context, items [][]byte) error {
+	err := f.each(ctx, f.group(items), func(ctx context.Context, b Backend, g *group) error {
+		return b.Add(ctx, g.items)
+	})
+	// Forgotten once added, so that a concurrent Test can't cache them as
+	// absent again
+	f.forget(items)
+	return err
+}
+
+// Checks whether item was (probably) added to the filter.
+func (f *Filter) Test(ctx context.Context, item []byte) (bool, error) {
+	present, err := f.TestAll(ctx, [][]byte{item})
+	if err != nil {
+		return false, err
+	}
+	return present[0], nil
+}
+
+// Checks whether each of items was (probably) added to the filter, calling
+// each of their nodes once, concurrently, for the items not in the negative
+// cache.
+func (f *Filter) TestAll(ctx context.Context, items [][]byte) ([]bool, error) {
+	present := make([]bool, len(items))
+	start := f.startTest()
+	var ask [][]byte
+	var at []int // the index in items of each item in ask
+	for i, item := range items {
+		if !f.knownAbsent(item) {
+			ask = append(ask, item)
+			at = append(at, i)
+		}
+	}
+	byNode := f.group(ask)
+	err := f.each(ctx, byNode, func(ctx context.Context, b Backend, g *group) error {
+		res, err := b.Test(ctx, g.items)
+		if err != nil {
+			return err
+		}
+		if len(res) != len(g.items) {
+			return fmt.Errorf("tested %d items, but got %d results", len(g.items), len(res))
+		}
+		for j, p := range res {
+			present[at[g.at[j]]] = p
+		}
+		return nil
+	})
+	if err != nil {
+		f.rememberAbsent(nil, start)
+		return nil, err
+	}
+	var absent [][]byte
+	for j, i := range at {
+		if !present[i] {
+			absent = append(absent, ask[j])
+		}
+	}
+	f.rememberAbsent(absent, start)
+	return present, nil
+}
+
+// The items of one node, and the index of each in the items grouped.
+type group struct {
+	items [][]byte
+	at    []int
+}
+
+// Groups items by node.
+func (f *Filter) group(items [][]byte) map[string]*group {
+	byNode := map[string]*group{}
+	for i, item := range items {
+		node := f.Node(item)
+		g := byNode[node]
+		if g == nil {
+			g = &group{}
+			byNode[node] = g
+		}
+		g.items = append(g.items, item)
+		g.at = append(g.at, i)
+	}
+	return byNode
+}
+
+// Calls fn with the backend of each node in byNode, concurrently, retrying it
+// when it fails. Returns the first error of a node which failed every time.
+func (f *Filter) each(ctx context.Context, byNode map[string]*group, fn func(context.Context, Backend, *group) error) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(byNode))
+	for node, g := range byNode {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f.retry(ctx, func() error { return fn(ctx, f.nodes[node], g) }); err != nil {
+				errs <- fmt.Errorf("bloomdist: node %s: %w", node, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// Calls fn until it succeeds, it has been retried as many times as allowed,
+// or ctx is done, and returns its last error.
+func (f *Filter) retry(ctx context.Context, fn func() error) error {
+	backoff := f.backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= f.retries || ctx.Err() != nil {
+			return err
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		backoff *= 2
+	}
+}
+
+// Checks whether item is in the negative cache.
+func (f *Filter) knownAbsent(item []byte) bool {
+	if f.neg == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	until, ok := f.neg[string(item)]
+	if ok && !f.now().Before(until) {
+		delete(f.neg, string(item))
+		return false
+	}
+	return ok
+}
+
+// Records the start of a call to TestAll, and returns the generation it
+// started at, to pass to rememberAbsent when it ends.
+func (f *Filter) startTest() uint64 {
+	if f.neg == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.testing++
+	return f.gen
+}
+
+// Adds the items found absent by a call to TestAll which started at
+// generation start to the negative cache, except those forgotten since, since
+// they may have been added after their nodes answered. When the cache is
+// full, expired items are removed, and if none are, the cache is cleared.
+func (f *Filter) rememberAbsent(items [][]byte, start uint64) {
+	if f.neg == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.testing--; f.testing == 0 {
+		defer clear(f.forgotten)
+	}
+	if start < f.flushed {
+		return
+	}
+	now := f.now()
+	for _, item := range items {
+		if f.forgotten[string(item)] > start {
+			continue
+		}
+		if len(f.neg) >= f.negSize {
+			for k, until := range f.neg {
+				if !now.Before(until) {
+					delete(f.neg, k)
+				}
+			}
+			if len(f.neg) >= f.negSize {
+				clear(f.neg)
+			}
+		}
+		f.neg[string(item)] = now.Add(f.negTTL)
+	}
+}
+
+// Removes items from the negative cache.
+func (f *Filter) forget(items [][]byte) {
+	if f.neg == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gen++
+	for _, item := range items {
+		delete(f.neg, string(item))
+	}
+	if f.testing == 0 {
+		return
+	}
+	if len(f.forgotten)+len(items) > f.negSize {
+		clear(f.forgotten)
+		f.flushed = f.gen
+		return
+	}
+	for _, item := range items {
+		f.forgotten[string(item)] = f.gen
+	}
+}
@@ -0,0 +1,209 @@
+package bloomdist
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A backend holding a filter in memory, which fails the first fails calls.
+type memBackend struct {
+	mu    sync.Mutex
+	f     *bloom.Filter
+	fails int
+	calls int
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{f: bloom.New(10000, 0.001)}
+}
+
+func (b *memBackend) call() error {
+	b.calls++
+	if b.fails > 0 {
+		b.fails--
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func (b *memBackend) Add(ctx context.Context, items [][]byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.call(); err != nil {
+		return err
+	}
+	for _, item := range items {
+		b.f.Add(item)
+	}
+	return nil
+}
+
+func (b *memBackend) Test(ctx context.Context, items [][]byte) ([]bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.call(); err != nil {
+		return nil, err
+	}
+	present := make([]bool, len(items))
+	for i, item := range items {
+		present[i] = b.f.Test(item)
+	}
+	return present, nil
+}
+
+func items(n int) [][]byte {
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = []byte(fmt.Sprint("item", i))
+	}
+	return items
+}
+
+func TestFilter(t *testing.T) {
+	backends := map[string]*memBackend{"a": newMemBackend(), "b": newMemBackend(), "c": newMemBackend()}
+	nodes := map[string]Backend{}
+	for name, b := range backends {
+		nodes[name] = b
+	}
+	f := New(nodes)
+	ctx := context.Background()
+	if err := f.AddAll(ctx, items(3000)); err != nil {
+		t.Fatal(err)
+	}
+	present, err := f.TestAll(ctx, items(3000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range present {
+		if !p {
+			t.Fatalf("item %d not found", i)
+		}
+	}
+	for name, b := range backends {
+		// Each node holds its share of the items only
+		if n := b.f.ApproximatedSize(); n < 700 || n > 1300 {
+			t.Errorf("node %s holds about %d items", name, n)
+		}
+	}
+	if ok, err := f.Test(ctx, []byte("other")); err != nil || ok {
+		t.Errorf("Test(other) = %v, %v", ok, err)
+	}
+}
+
+func TestFilterConsistentHashing(t *testing.T) {
+	three := New(map[string]Backend{"a": nil, "b": nil, "c": nil})
+	two := New(map[string]Backend{"a": nil, "b": nil})
+	moved := 0
+	for _, item := range items(3000) {
+		if n := three.Node(item); n != "c" && n != two.Node(item) {
+			moved++
+		}
+	}
+	if moved != 0 {
+		t.Errorf("removing a node moved %d items of the other nodes", moved)
+	}
+}
+
+func TestFilterRetries(t *testing.T) {
+	b := newMemBackend()
+	b.fails = 2
+	f := New(map[string]Backend{"a": b}, WithRetries(2, time.Millisecond))
+	ctx := context.Background()
+	if err := f.Add(ctx, []byte("foo")); err != nil || b.calls != 3 {
+		t.Fatalf("Add returned %v after %d calls", err, b.calls)
+	}
+	b.fails = 3
+	if _, err := f.Test(ctx, []byte("foo")); err == nil {
+		t.Error("Test succeeded though the node failed every retry")
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	b.fails, b.calls = 1, 0
+	if err := f.Add(canceled, []byte("foo")); err == nil || b.calls != 1 {
+		t.Errorf("Add with a canceled context returned %v after %d calls", err, b.calls)
+	}
+}
+
+func TestFilterNegativeCache(t *testing.T) {
+	b := newMemBackend()
+	f := New(map[string]Backend{"a": b}, WithNegativeCache(time.Minute, 2))
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if ok, err := f.Test(ctx, []byte("foo")); err != nil || ok {
+			t.Fatalf("Test(foo) = %v, %v", ok, err)
+		}
+	}
+	if b.calls != 1 {
+		t.Fatalf("%d calls, want 1", b.calls)
+	}
+	// Added by another client: the cache hides it until it expires
+	b.f.Add([]byte("foo"))
+	if ok, _ := f.Test(ctx, []byte("foo")); ok || b.calls != 1 {
+		t.Error("foo not cached")
+	}
+	now = now.Add(time.Minute)
+	if ok, _ := f.Test(ctx, []byte("foo")); !ok || b.calls != 2 {
+		t.Error("expired entry used")
+	}
+
+	// Added through the client: forgotten at once
+	f.Test(ctx, []byte("bar"))
+	f.Add(ctx, []byte("bar"))
+	if ok, _ := f.Test(ctx, []byte("bar")); !ok {
+		t.Error("bar not found after Add")
+	}
+
+	f.TestAll(ctx, [][]byte{[]byte("x"), []byte("y"), []byte("z")})
+	if len(f.neg) > 2 {
+		t.Errorf("the cache holds %d items", len(f.neg))
+	}
+}
+
+// A memBackend whose Test answers, then waits for answered to be closed
+// before returning.
+type slowBackend struct {
+	*memBackend
+	tested   chan struct{}
+	answered chan struct{}
+}
+
+func (b *slowBackend) Test(ctx context.Context, items [][]byte) ([]bool, error) {
+	present, err := b.memBackend.Test(ctx, items)
+	close(b.tested)
+	<-b.answered
+	return present, err
+}
+
+func TestFilterNegativeCacheConcurrentAdd(t *testing.T) {
+	b := &slowBackend{newMemBackend(), make(chan struct{}), make(chan struct{})}
+	f := New(map[string]Backend{"a": b}, WithNegativeCache(time.Minute, 100))
+	ctx := context.Background()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if ok, err := f.Test(ctx, []byte("foo")); err != nil || ok {
+			t.Errorf("Test(foo) = %v, %v", ok, err)
+		}
+	}()
+	// Added after the node answered, but before Test cached its answer
+	<-b.tested
+	if err := f.Add(ctx, []byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	close(b.answered)
+	<-done
+	if _, ok := f.neg["foo"]; ok {
+		t.Error("foo cached as absent after it was added")
+	}
+	if len(f.forgotten) != 0 {
+		t.Errorf("%d forgotten items kept after Test returned", len(f.forgotten))
+	}
+}
@@ -0,0 +1,167 @@
+// Package bloomgrpc implements the membership service defined in bloom.proto,
+// so that clients in any language can query a central filter, with streaming
+// batch calls.
+//
+// go-bloom doesn't depend on gRPC, so the package doesn't include generated
+// stubs: Server has a method for each call of the service, taking the
+// messages' fields and the streams' Recv and Send methods, which the
+// generated server interface forwards to, e.g.:
+//
+//	func (s *service) MTest(stream bloompb.Bloom_MTestServer) error {
+//		return s.srv.MTest(
+//			func() ([]byte, error) {
+//				item, err := stream.Recv()
+//				return item.GetData(), err
+//			},
+//			func(present bool) error {
+//				return stream.Send(&bloompb.Present{Present: present})
+//			},
+//		)
+//	}
+package bloomgrpc
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"encoding"
+	"errors"
+	"io"
+	"sync"
+)
+
+// The size of the chunks Dump sends
+const dumpChunkSize = 64 << 10
+
+// Returned by Dump if the filter can't be encoded.
+var ErrNotEncodable = errors.New("bloomgrpc: the filter can't be encoded")
+
+// The parameters and fill of a filter, as returned by Info. Fields the filter
+// doesn't report are zero.
+type Info struct {
+	Bits      uint64
+	Hashes    uint64
+	Capacity  uint64
+	FPRate    float64
+	FillRatio float64
+}
+
+// Serves a filter. Calls to the filter are guarded by a sync.RWMutex: adding
+// items takes the write lock, and testing them only the read lock, so any
+// filter whose Test doesn't change it, thread-safe or not, can be served, as
+// with bloomhttp. This includes every filter of package bloom.
+type Server struct {
+	mu sync.RWMutex
+	f  bloom.Set
+}
+
+// Create a server for f. The filter should only be changed through the
+// server while it is being served. Since f.Test is called concurrently under
+// a read lock, it must not change f.
+func NewServer(f bloom.Set) *Server {
+	return &Server{f: f}
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present.
+func (s *Server) Add(data []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.TestAndAdd(data)
+}
+
+// Checks whether data was (probably) added to the filter.
+func (s *Server) Test(data []byte) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.f.Test(data)
+}
+
+// Adds each item returned by recv to the filter until it returns io.EOF, and
+// returns the number of items added. Any other error is returned with the
+// number added before it.
+func (s *Server) MAdd(recv func() ([]byte, error)) (uint64, error) {
+	n := uint64(0)
+	for {
+		data, err := recv()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		s.mu.Lock()
+		s.f.Add(data)
+		s.mu.Unlock()
+		n++
+	}
+}
+
+// Checks each item returned by recv until it returns io.EOF, passing the
+// result for each to send.
+func (s *Server) MTest(recv func() ([]byte, error), send func(present bool) error) error {
+	for {
+		data, err := recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := send(s.Test(data)); err != nil {
+			return err
+		}
+	}
+}
+
+// Returns the parameters and fill of the filter.
+func (s *Server) Info() Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var info Info
+	switch f := s.f.(type) {
+	case interface {
+		M() uint32
+		K() uint32
+	}:
+		info.Bits, info.Hashes = uint64(f.M()), uint64(f.K())
+	case interface {
+		M() uint64
+		K() uint64
+	}:
+		info.Bits, info.Hashes = f.M(), f.K()
+	}
+	if f, ok := s.f.(interface {
+		Capacity() uint64
+		FPRate() float64
+	}); ok {
+		info.Capacity, info.FPRate = f.Capacity(), f.FPRate()
+	}
+	if f, ok := s.f.(interface{ FillRatio() float64 }); ok {
+		info.FillRatio = f.FillRatio()
+	}
+	return info
+}
+
+// Passes the filter's binary encoding to send in chunks of at most 64 KiB.
+// Returns ErrNotEncodable if the filter doesn't implement
+// encoding.BinaryMarshaler.
+func (s *Server) Dump(send func(chunk []byte) error) error {
+	m, ok := s.f.(encoding.BinaryMarshaler)
+	if !ok {
+		return ErrNotEncodable
+	}
+	s.mu.RLock()
+	data, err := m.MarshalBinary()
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		n := min(len(data), dumpChunkSize)
+		if err := send(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package bloomgrpc
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Returns a recv function returning each of items, then io.EOF.
+func items(items ...string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		if len(items) == 0 {
+			return nil, io.EOF
+		}
+		item := items[0]
+		items = items[1:]
+		return []byte(item), nil
+	}
+}
+
+// Tests filters served concurrently, for the race detector: Test must not
+// change them, since it is called under a read lock.
+func TestServerConcurrent(t *testing.T) {
+	for _, f := range []bloom.Set{
+		bloom.New(1000, 0.001),
+		bloom.NewRotating(1000, 0.001, time.Nanosecond, 2),
+		bloom.NewQuotient(1000, 0.001),
+	} {
+		s := NewServer(f)
+		s.Add([]byte("foo"))
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.Test([]byte("foo"))
+				s.MTest(items("foo", "bar"), func(bool) error { return nil })
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func TestServer(t *testing.T) {
+	f := bloom.New(1000, 0.001)
+	s := NewServer(f)
+	if s.Add([]byte("foo")) || !s.Add([]byte("foo")) || !s.Test([]byte("foo")) {
+		t.Fatal("unexpected results adding foo")
+	}
+	n, err := s.MAdd(items("bar", "baz"))
+	if err != nil || n != 2 {
+		t.Fatalf("added %d items: %v", n, err)
+	}
+	var present []bool
+	err = s.MTest(items("bar", "qux", "baz"), func(p bool) error {
+		present = append(present, p)
+		return nil
+	})
+	if err != nil || len(present) != 3 || !present[0] || present[1] || !present[2] {
+		t.Errorf("MTest returned %v: %v", present, err)
+	}
+	if info := s.Info(); info.Bits != uint64(f.M()) || info.Capacity != 1000 || info.FillRatio == 0 {
+		t.Errorf("info %+v", info)
+	}
+
+	var dump bytes.Buffer
+	if err := s.Dump(func(chunk []byte) error {
+		_, err := dump.Write(chunk)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	g := &bloom.Filter{}
+	if err := g.UnmarshalBinary(dump.Bytes()); err != nil || !g.Test([]byte("baz")) {
+		t.Errorf("dumped filter differs: %v", err)
+	}
+	if err := NewServer(bloom.NewAging(1000, 0.01)).Dump(nil); err != ErrNotEncodable {
+		t.Errorf("dumping an aging filter returned %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+// Package bloomhttp serves a bloom filter over HTTP, so that a filter can be
+// shared with other services as a small membership service:
+//
+//	f := bloom.New(100000, 0.01)
+//	http.Handle("/seen/", http.StripPrefix("/seen", bloomhttp.NewHandler(f)))
+//
+// The handler has the endpoints:
+//
+//	POST /add          adds each line of the body, and returns {"added": n}
+//	GET  /test?item=x  returns {"present": bool} for the item x
+//	POST /batch-test   returns {"present": [bool, ...]} for each line of the body
+//	GET  /stats        returns the filter's parameters and fill, where known
+//
+// Empty lines in a body are skipped. Calls to the filter are guarded by a
+// sync.RWMutex: adding items takes the write lock, and testing them only the
+// read lock, so any filter whose Test doesn't change it, thread-safe or not,
+// can be served. This includes every filter of package bloom. Client is a
+// client of the handler.
+package bloomhttp
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// The largest request body accepted unless another limit is given with
+// WithMaxBodySize
+const defaultMaxBodySize = 32 << 20
+
+// The header holding the API key given with WithAPIKey
+const APIKeyHeader = "X-API-Key"
+
+// Configures a Handler.
+type Option func(*Handler)
+
+// Makes the handler reject requests whose X-API-Key header isn't key with
+// 401 Unauthorized.
+func WithAPIKey(key string) Option {
+	return func(h *Handler) {
+		h.apiKey = []byte(key)
+	}
+}
+
+// Makes the handler reject requests with bodies larger than n bytes with 413
+// Request Entity Too Large. The default is 32 MiB.
+func WithMaxBodySize(n int64) Option {
+	return func(h *Handler) {
+		h.maxBody = n
+	}
+}
+
+// Makes the handler reject /add with 403 Forbidden, e.g. to serve a filter
+// which is populated by the application only.
+func WithReadOnly() Option {
+	return func(h *Handler) {
+		h.readOnly = true
+	}
+}
+
+// An http.Handler serving a filter.
+type Handler struct {
+	mu       sync.RWMutex
+	f        bloom.Set
+	apiKey   []byte
+	maxBody  int64
+	readOnly bool
+	mux      *http.ServeMux
+}
+
+// Create a handler serving f. The filter should only be changed through the
+// handler, or while holding the lock returned by Locker, since the handler
+// guards its calls to f with a mutex. Since f.Test is called concurrently
+// under a read lock, it must not change f.
+func NewHandler(f bloom.Set, opts ...Option) *Handler {
+	h := &Handler{
+		f:       f,
+		maxBody: defaultMaxBodySize,
+		mux:     http.NewServeMux(),
+	}
+	for _, o := range opts {
+		o(h)
+	}
+	h.mux.HandleFunc("/add", h.add)
+	h.mux.HandleFunc("/test", h.test)
+	h.mux.HandleFunc("/batch-test", h.batchTest)
+	h.mux.HandleFunc("/stats", h.stats)
+	return h
+}
+
+// Returns the lock guarding calls to the filter, so that the application can
+// change the filter while it is being served.
+func (h *Handler) Locker() sync.Locker {
+	return &h.mu
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.apiKey != nil && subtle.ConstantTimeCompare([]byte(r.Header.Get(APIKeyHeader)), h.apiKey) != 1 {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) add(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	if h.readOnly {
+		http.Error(w, "the filter is read-only", http.StatusForbidden)
+		return
+	}
+	items, ok := h.lines(w, r)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	for _, item := range items {
+		h.f.Add(item)
+	}
+	h.mu.Unlock()
+	reply(w, struct {
+		Added int `json:"added"`
+	}{len(items)})
+}
+
+func (h *Handler) test(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	item, ok := r.URL.Query()["item"]
+	if !ok || len(item) != 1 || item[0] == "" {
+		http.Error(w, "exactly one item must be given", http.StatusBadRequest)
+		return
+	}
+	h.mu.RLock()
+	present := h.f.Test([]byte(item[0]))
+	h.mu.RUnlock()
+	reply(w, struct {
+		Present bool `json:"present"`
+	}{present})
+}
+
+func (h *Handler) batchTest(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	items, ok := h.lines(w, r)
+	if !ok {
+		return
+	}
+	present := make([]bool, len(items))
+	h.mu.RLock()
+	for i, item := range items {
+		present[i] = h.f.Test(item)
+	}
+	h.mu.RUnlock()
+	reply(w, struct {
+		Present []bool `json:"present"`
+	}{present})
+}
+
+// The statistics returned by /stats. Fields the filter doesn't report are
+// left out.
+type Stats struct {
+	Bits            uint64  `json:"bits,omitempty"`
+	Hashes          uint64  `json:"hashes,omitempty"`
+	Capacity        uint64  `json:"capacity,omitempty"`
+	FPRate          float64 `json:"fp_rate,omitempty"`
+	FillRatio       float64 `json:"fill_ratio,omitempty"`
+	EstimatedFPRate float64 `json:"estimated_fp_rate,omitempty"`
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	h.mu.RLock()
+	s := stats(h.f)
+	h.mu.RUnlock()
+	reply(w, s)
+}
+
+// Returns the statistics f reports.
+func stats(f bloom.Set) Stats {
+	var s Stats
+	switch f := f.(type) {
+	case interface {
+		M() uint32
+		K() uint32
+	}:
+		s.Bits, s.Hashes = uint64(f.M()), uint64(f.K())
+	case interface {
+		M() uint64
+		K() uint64
+	}:
+		s.Bits, s.Hashes = f.M(), f.K()
+	}
+	if f, ok := f.(interface {
+		Capacity() uint64
+		FPRate() float64
+	}); ok {
+		s.Capacity, s.FPRate = f.Capacity(), f.FPRate()
+	}
+	if f, ok := f.(interface{ FillRatio() float64 }); ok {
+		s.FillRatio = f.FillRatio()
+	}
+	if f, ok := f.(interface{ EstimatedFPRate() float64 }); ok {
+		s.EstimatedFPRate = f.EstimatedFPRate()
+	}
+	return s
+}
+
+// Reads the non-empty lines of the request body.
+func (h *Handler) lines(w http.ResponseWriter, r *http.Request) ([][]byte, bool) {
+	var items [][]byte
+	s := bufio.NewScanner(http.MaxBytesReader(w, r.Body, h.maxBody))
+	s.Buffer(nil, int(h.maxBody))
+	for s.Scan() {
+		if len(s.Bytes()) > 0 {
+			items = append(items, append([]byte(nil), s.Bytes()...))
+		}
+	}
+	if err := s.Err(); err != nil {
+		if _, ok := err.(*http.MaxBytesError); ok || err == bufio.ErrTooLong {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "unable to read request body", http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	return items, true
+}
+
+// Checks that r uses method, and replies with 405 Method Not Allowed if not.
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method || method == http.MethodGet && r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func reply(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,104 @@
+package bloomhttp
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func do(t *testing.T, h http.Handler, method, target, body string, header map[string]string, v any) int {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, val := range header {
+		r.Header.Set(k, val)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if v != nil && w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: %v", method, target, err)
+		}
+	}
+	return w.Code
+}
+
+func TestHandler(t *testing.T) {
+	h := NewHandler(bloom.New(1000, 0.001))
+	var added struct{ Added int }
+	if code := do(t, h, "POST", "/add", "foo\n\nbar\n", nil, &added); code != http.StatusOK || added.Added != 2 {
+		t.Fatalf("add returned %d, added %d", code, added.Added)
+	}
+	var one struct{ Present bool }
+	if do(t, h, "GET", "/test?item=foo", "", nil, &one); !one.Present {
+		t.Error("foo not present")
+	}
+	if do(t, h, "GET", "/test?item=baz", "", nil, &one); one.Present {
+		t.Error("baz present")
+	}
+	var batch struct{ Present []bool }
+	do(t, h, "POST", "/batch-test", "foo\nbaz\nbar", nil, &batch)
+	if len(batch.Present) != 3 || !batch.Present[0] || batch.Present[1] || !batch.Present[2] {
+		t.Errorf("batch test returned %v", batch.Present)
+	}
+	var s Stats
+	do(t, h, "GET", "/stats", "", nil, &s)
+	if s.Bits == 0 || s.Hashes == 0 || s.Capacity != 1000 || s.FillRatio == 0 {
+		t.Errorf("stats %+v", s)
+	}
+	if code := do(t, h, "GET", "/add", "", nil, nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /add returned %d", code)
+	}
+	if code := do(t, h, "GET", "/test", "", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("test without an item returned %d", code)
+	}
+}
+
+// Tests filters served concurrently, for the race detector: Test must not
+// change them, since it is called under a read lock.
+func TestHandlerConcurrent(t *testing.T) {
+	for _, f := range []bloom.Set{
+		bloom.New(1000, 0.001),
+		bloom.NewRotating(1000, 0.001, time.Nanosecond, 2),
+		bloom.NewQuotient(1000, 0.001),
+	} {
+		h := NewHandler(f)
+		do(t, h, "POST", "/add", "foo\nbar\n", nil, nil)
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				do(t, h, "GET", "/test?item=foo", "", nil, nil)
+				do(t, h, "POST", "/batch-test", "foo\nbaz", nil, nil)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func TestHandlerOptions(t *testing.T) {
+	h := NewHandler(bloom.NewCounting(1000, 0.01), WithAPIKey("secret"), WithReadOnly(), WithMaxBodySize(8))
+	key := map[string]string{APIKeyHeader: "secret"}
+	if code := do(t, h, "GET", "/stats", "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("request without a key returned %d", code)
+	}
+	if code := do(t, h, "GET", "/stats", "", map[string]string{APIKeyHeader: "guess"}, nil); code != http.StatusUnauthorized {
+		t.Errorf("request with a wrong key returned %d", code)
+	}
+	var s Stats
+	if code := do(t, h, "GET", "/stats", "", key, &s); code != http.StatusOK || s.Bits == 0 || s.FillRatio != 0 {
+		t.Errorf("stats returned %d: %+v", code, s)
+	}
+	if code := do(t, h, "POST", "/add", "foo", key, nil); code != http.StatusForbidden {
+		t.Errorf("add to a read-only filter returned %d", code)
+	}
+	if code := do(t, h, "POST", "/batch-test", "0123456789\n", key, nil); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large body returned %d", code)
+	}
+}
@@ -0,0 +1,86 @@
+package bloomhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Returned by Client when an item can't be sent as a line of a request body,
+// i.e. when it is empty, or holds a newline or carriage return.
+var ErrInvalidItem = errors.New("bloomhttp: item can't be sent as a line")
+
+// A client of a filter served by a Handler, e.g. to use the filter as a
+// backend of a bloomdist.Filter.
+type Client struct {
+	// The URL the handler is served at, e.g. http://host:8080/seen
+	URL string
+
+	// The API key sent in the X-API-Key header, if not empty
+	APIKey string
+
+	// The client sending the requests, or http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Adds items to the filter with /add.
+func (c *Client) Add(ctx context.Context, items [][]byte) error {
+	return c.post(ctx, "/add", items, nil)
+}
+
+// Checks whether each of items was (probably) added to the filter with
+// /batch-test.
+func (c *Client) Test(ctx context.Context, items [][]byte) ([]bool, error) {
+	var res struct {
+		Present []bool `json:"present"`
+	}
+	if err := c.post(ctx, "/batch-test", items, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Present) != len(items) {
+		return nil, fmt.Errorf("bloomhttp: tested %d items, but got %d results", len(items), len(res.Present))
+	}
+	return res.Present, nil
+}
+
+// Posts items, one per line, to path, and decodes the reply into v unless it
+// is nil.
+func (c *Client) post(ctx context.Context, path string, items [][]byte, v any) error {
+	var body bytes.Buffer
+	for _, item := range items {
+		if len(item) == 0 || bytes.ContainsAny(item, "\r\n") {
+			return ErrInvalidItem
+		}
+		body.Write(item)
+		body.WriteByte('\n')
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	if c.APIKey != "" {
+		req.Header.Set(APIKeyHeader, c.APIKey)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("bloomhttp: %s %s: %s: %s", req.Method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
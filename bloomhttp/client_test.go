@@ -0,0 +1,38 @@
+package bloomhttp
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/seen/", http.StripPrefix("/seen", NewHandler(bloom.New(1000, 0.001), WithAPIKey("secret"))))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := &Client{URL: srv.URL + "/seen", APIKey: "secret"}
+	if err := c.Add(ctx, [][]byte{[]byte("foo"), []byte("bar")}); err != nil {
+		t.Fatal(err)
+	}
+	present, err := c.Test(ctx, [][]byte{[]byte("foo"), []byte("baz"), []byte("bar")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !present[0] || present[1] || !present[2] {
+		t.Errorf("unexpected results %v", present)
+	}
+	if err := c.Add(ctx, [][]byte{[]byte("a\nb")}); err != ErrInvalidItem {
+		t.Errorf("adding an item with a newline returned %v", err)
+	}
+	c.APIKey = "wrong"
+	if _, err := c.Test(ctx, [][]byte{[]byte("foo")}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("testing with the wrong API key returned %v", err)
+	}
+}
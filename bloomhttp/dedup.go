@@ -0,0 +1,130 @@
+package bloomhttp
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"net/http"
+	"sync"
+)
+
+// The header conventionally holding a key which a client sends again when it
+// retries a request, e.g. a webhook delivery
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Returns the key identifying a request for Dedup, or "" if the request
+// shouldn't be deduplicated.
+type KeyFunc func(r *http.Request) string
+
+// Returns a KeyFunc using the value of the header name, e.g.
+// IdempotencyKeyHeader.
+func HeaderKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// Returns middleware passing each request to the handler it wraps only if its
+// key, as returned by key, isn't (probably) in f, e.g. to make a webhook
+// endpoint idempotent. The key is added once the handler has replied with a
+// 2xx status, so that a request which failed can be retried; a filter which
+// forgets keys, like bloom.NewRotating's, bounds the memory used:
+//
+//	f := bloom.NewRotating(100000, 0.001, 24*time.Hour, 24)
+//	mux.Handle("/webhook", bloomhttp.Dedup(f, bloomhttp.HeaderKey(bloomhttp.IdempotencyKeyHeader), nil)(h))
+//
+// A request whose key was already added is passed to duplicate, or, if it is
+// nil, replied to with 409 Conflict. A request whose key is being handled by
+// another request is replied to with 409 Conflict and Retry-After. Calls to f
+// are guarded by a mutex, so any filter, thread-safe or not, can be used. As
+// with any bloom filter, a request with a new key is treated as a duplicate
+// with the false positive rate of f.
+func Dedup(f bloom.Set, key KeyFunc, duplicate http.Handler) func(http.Handler) http.Handler {
+	if duplicate == nil {
+		duplicate = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "duplicate request", http.StatusConflict)
+		})
+	}
+	d := &dedup{
+		f:         f,
+		key:       key,
+		duplicate: duplicate,
+		inFlight:  map[string]struct{}{},
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d.serve(next, w, r)
+		})
+	}
+}
+
+type dedup struct {
+	mu        sync.Mutex
+	f         bloom.Set
+	key       KeyFunc
+	duplicate http.Handler
+	inFlight  map[string]struct{}
+}
+
+func (d *dedup) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	key := d.key(r)
+	if key == "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+	d.mu.Lock()
+	if _, ok := d.inFlight[key]; ok {
+		d.mu.Unlock()
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "request in progress", http.StatusConflict)
+		return
+	}
+	if d.f.Test([]byte(key)) {
+		d.mu.Unlock()
+		d.duplicate.ServeHTTP(w, r)
+		return
+	}
+	d.inFlight[key] = struct{}{}
+	d.mu.Unlock()
+
+	sw := &statusWriter{ResponseWriter: w}
+	defer func() {
+		// If the handler panics before replying, the key is released
+		// without being added
+		d.mu.Lock()
+		delete(d.inFlight, key)
+		if sw.status >= 200 && sw.status < 300 {
+			d.f.Add([]byte(key))
+		}
+		d.mu.Unlock()
+	}()
+	next.ServeHTTP(sw, r)
+	if sw.status == 0 {
+		// Nothing was written, so the server replies 200 OK
+		sw.status = http.StatusOK
+	}
+}
+
+// Records the status a handler replies with.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Returns the wrapped writer, for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,82 @@
+package bloomhttp
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	calls := 0
+	fail := false
+	h := Dedup(bloom.NewRotating(1000, 0.001, time.Hour, 4), HeaderKey(IdempotencyKeyHeader), nil)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if fail {
+				http.Error(w, "failed", http.StatusInternalServerError)
+			}
+		}))
+	send := func(key string) int {
+		r := httptest.NewRequest("POST", "/webhook", nil)
+		if key != "" {
+			r.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := send("a"); code != http.StatusOK {
+		t.Errorf("first request returned %d", code)
+	}
+	if code := send("a"); code != http.StatusConflict || calls != 1 {
+		t.Errorf("duplicate returned %d after %d calls", code, calls)
+	}
+	send("")
+	send("")
+	if calls != 3 {
+		t.Errorf("requests without a key made %d calls", calls-1)
+	}
+	fail = true
+	if code := send("b"); code != http.StatusInternalServerError {
+		t.Errorf("failing request returned %d", code)
+	}
+	fail = false
+	if code := send("b"); code != http.StatusOK {
+		t.Errorf("retry of a failed request returned %d", code)
+	}
+}
+
+func TestDedupInFlight(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	seen := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	h := Dedup(bloom.New(1000, 0.001), HeaderKey(IdempotencyKeyHeader), seen)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(entered)
+			<-release
+		}))
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Header.Set(IdempotencyKeyHeader, "a")
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		close(done)
+	}()
+	<-entered
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("request in flight returned %d", w.Code)
+	}
+	close(release)
+	<-done
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("duplicate handler returned %d", w.Code)
+	}
+}
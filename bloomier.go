@@ -0,0 +1,160 @@
+package bloom
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+const bloomierMaxTries = 100
+
+// A Bloomier filter using the 64-bit FNV-1 hash function. It maps a static set
+// of keys to small values, using roughly 1.23 cells of (fingerprint + value)
+// per key. Looking up a key that was in the set always returns its value;
+// looking up any other key reports it as absent, except with a false positive
+// chance near the ratio specified upon creation of the filter, in which case
+// an arbitrary value is returned. Since it can't be changed, it is safe for
+// concurrent use by multiple goroutines.
+type BloomierFilter struct {
+	seed   uint64
+	seg    uint64
+	fpBits uint
+	cells  []uint32
+	hf     HashFunc
+}
+
+func (f *BloomierFilter) hash(data []byte) uint64 {
+	return mix64(hash64(f.hf, nil, data) ^ f.seed)
+}
+
+func (f *BloomierFilter) positions(x uint64) [3]uint64 {
+	return [3]uint64{
+		reduce32(uint32(x), f.seg),
+		reduce32(uint32(bits.RotateLeft64(x, 21)), f.seg) + f.seg,
+		reduce32(uint32(bits.RotateLeft64(x, 42)), f.seg) + 2*f.seg,
+	}
+}
+
+func (f *BloomierFilter) fingerprint(x uint64) uint32 {
+	return uint32(mix64(x)) & (1<<f.fpBits - 1)
+}
+
+// Maps v to [0, n) without a division.
+func reduce32(v uint32, n uint64) uint64 {
+	return uint64(v) * n >> 32
+}
+
+// Returns the value associated with data, and a boolean indicating whether
+// data was in the set the filter was built from. False positives are possible;
+// false negatives are not.
+func (f *BloomierFilter) Get(data []byte) (uint8, bool) {
+	x := f.hash(data)
+	p := f.positions(x)
+	c := f.cells[p[0]] ^ f.cells[p[1]] ^ f.cells[p[2]]
+	if c>>8 != f.fingerprint(x) {
+		return 0, false
+	}
+	return uint8(c), true
+}
+
+// Checks whether data was in the set the filter was built from.
+func (f *BloomierFilter) Test(data []byte) bool {
+	_, ok := f.Get(data)
+	return ok
+}
+
+// Tries to assign the cells using the current seed, and returns false if the
+// keys couldn't be ordered (peeled) and a different seed is needed.
+func (f *BloomierFilter) build(keys []string, values []uint8) bool {
+	var (
+		n      = uint64(len(f.cells))
+		hashes = make([]uint64, len(keys))
+		count  = make([]uint32, n)
+		xor    = make([]uint64, n)
+	)
+	for i, k := range keys {
+		x := f.hash([]byte(k))
+		hashes[i] = x
+		for _, p := range f.positions(x) {
+			count[p]++
+			xor[p] ^= uint64(i)
+		}
+	}
+	queue := make([]uint64, 0, n)
+	for p, c := range count {
+		if c == 1 {
+			queue = append(queue, uint64(p))
+		}
+	}
+	type peeled struct {
+		key  uint64
+		cell uint64
+	}
+	order := make([]peeled, 0, len(keys))
+	for len(queue) > 0 {
+		p := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if count[p] != 1 {
+			continue
+		}
+		ki := xor[p]
+		order = append(order, peeled{ki, p})
+		for _, op := range f.positions(hashes[ki]) {
+			count[op]--
+			xor[op] ^= ki
+			if count[op] == 1 {
+				queue = append(queue, op)
+			}
+		}
+	}
+	if len(order) != len(keys) {
+		return false
+	}
+	for i := range f.cells {
+		f.cells[i] = 0
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		o := order[i]
+		x := hashes[o.key]
+		c := f.fingerprint(x)<<8 | uint32(values[o.key])
+		for _, p := range f.positions(x) {
+			if p != o.cell {
+				c ^= f.cells[p]
+			}
+		}
+		f.cells[o.cell] = c
+	}
+	return true
+}
+
+// Create a Bloomier filter mapping the keys of m to their values, with an
+// acceptable false positive rate of p for keys that aren't in m, e.g. 0.01.
+// The filter is static: keys can't be added or removed after creation.
+func NewBloomier(m map[string]uint8, p float64, opts ...Option) *BloomierFilter {
+	fpBits := uint(math.Ceil(-math.Log2(p)))
+	if fpBits < 1 {
+		fpBits = 1
+	} else if fpBits > 24 {
+		panic(fmt.Sprintf("A Bloomier filter with p %f requires %d-bit fingerprints, but the maximum supported size is 24 bits.", p, fpBits))
+	}
+	keys := make([]string, 0, len(m))
+	values := make([]uint8, 0, len(m))
+	for k, v := range m {
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+	seg := uint64(math.Ceil((1.23*float64(len(m)) + 32) / 3))
+	f := &BloomierFilter{
+		seg:    seg,
+		fpBits: fpBits,
+		cells:  make([]uint32, 3*seg),
+		hf:     newOptions(opts).hash,
+	}
+	for i := 0; i < bloomierMaxTries; i++ {
+		f.seed = mix64(uint64(i) + 1)
+		if f.build(keys, values) {
+			return f
+		}
+	}
+	panic(fmt.Sprintf("Unable to build a Bloomier filter from %d keys after %d attempts. Are some of the keys hash duplicates?", len(m), bloomierMaxTries))
+}
@@ -0,0 +1,58 @@
+package bloom
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestBloomierFilter(t *testing.T) {
+	m := make(map[string]uint8)
+	for i := 0; i < 10000; i++ {
+		m[strconv.Itoa(i)] = uint8(i % 7)
+	}
+	f := NewBloomier(m, 0.001)
+	for k, v := range m {
+		if got, ok := f.Get([]byte(k)); !ok || got != v {
+			t.Fatalf("%s: got %d, %v, expected %d", k, got, ok, v)
+		}
+	}
+	fp := 0
+	for i := 10000; i < 20000; i++ {
+		if f.Test([]byte(strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if p := float64(fp) / 10000; p > 0.003 {
+		t.Errorf("False positive rate too high: %f", p)
+	}
+}
+
+func TestBloomierFilterEmpty(t *testing.T) {
+	f := NewBloomier(map[string]uint8{}, 0.01)
+	if f.Test(foo) {
+		t.Error("foo in empty filter")
+	}
+}
+
+func TestBloomierFilterConcurrentGet(t *testing.T) {
+	m := make(map[string]uint8)
+	for i := 0; i < 1000; i++ {
+		m[strconv.Itoa(i)] = uint8(i % 7)
+	}
+	f := NewBloomier(m, 0.001)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k, v := range m {
+				if got, ok := f.Get([]byte(k)); !ok || got != v {
+					t.Errorf("%s: got %d, %v, expected %d", k, got, ok, v)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
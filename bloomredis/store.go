@@ -0,0 +1,143 @@
+// Package bloomredis keeps the bits of a bloom filter in a Redis bitmap, so
+// that several instances of an application share one authoritative filter:
+//
+//	f, err := bloomredis.NewFilter(client, "seen", 1000000, 0.01)
+//	...
+//	err = f.Add([]byte("foo"))
+//	present, err := f.Test([]byte("foo"))
+//
+// An item's bits are tested and set with one BITFIELD command, so each call
+// is one round trip. Every instance must create the filter with the same
+// parameters and options, since they determine which bits an item maps to.
+//
+// The package doesn't depend on a particular Redis client; Client is
+// implemented by a small adapter over one, e.g. for go-redis:
+//
+//	type client struct{ *redis.Client }
+//
+//	func (c client) Do(args ...any) (any, error) {
+//		return c.Client.Do(context.Background(), args...).Result()
+//	}
+package bloomredis
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"errors"
+	"fmt"
+)
+
+// The most bits a Redis string can hold
+const maxBits = 1 << 32
+
+// Returned when Redis replies with something other than what the command
+// returns, e.g. because the key holds another type.
+var ErrUnexpectedReply = errors.New("bloomredis: unexpected reply")
+
+// Sends commands to Redis. Do sends the command made of args, e.g. "GETBIT",
+// "key", uint64(7), and returns its reply, with integers as int64 and arrays
+// as []any, as the common clients do.
+type Client interface {
+	Do(args ...any) (any, error)
+}
+
+// A bloom.BitStore keeping its bits in the Redis bitmap at a key.
+type Store struct {
+	c   Client
+	key string
+	m   uint64
+}
+
+// Create a store of m bits kept in the bitmap at key. Redis allocates the
+// bitmap as bits are set, up to the last one set.
+func NewStore(c Client, key string, m uint64) (*Store, error) {
+	if m > maxBits {
+		return nil, fmt.Errorf("%w: a Redis bitmap can't hold %d bits", bloom.ErrInvalidParameters, m)
+	}
+	return &Store{c, key, m}, nil
+}
+
+// Create a bloom filter with an expected n number of items, and an acceptable
+// false positive rate of p, whose bits are kept in the bitmap at key, as with
+// bloom.NewWithStore.
+func NewFilter(c Client, key string, n int64, p float64, opts ...bloom.Option) (*bloom.StoreFilter, error) {
+	return bloom.NewWithStore(n, p, func(m uint64) (bloom.BitStore, error) {
+		return NewStore(c, key, m)
+	}, opts...)
+}
+
+func (s *Store) Len() uint64 {
+	return s.m
+}
+
+func (s *Store) Test(i uint64) (bool, error) {
+	v, err := s.c.Do("GETBIT", s.key, i)
+	if err != nil {
+		return false, err
+	}
+	return bit(v)
+}
+
+func (s *Store) Set(i uint64) error {
+	_, err := s.c.Do("SETBIT", s.key, i, 1)
+	return err
+}
+
+func (s *Store) Clear(i uint64) error {
+	_, err := s.c.Do("SETBIT", s.key, i, 0)
+	return err
+}
+
+// Checks whether every bit in is is set with one BITFIELD command.
+func (s *Store) TestAll(is []uint64) (bool, error) {
+	v, err := s.c.Do(s.bitfield("GET", is)...)
+	if err != nil {
+		return false, err
+	}
+	vs, ok := v.([]any)
+	if !ok || len(vs) != len(is) {
+		return false, ErrUnexpectedReply
+	}
+	for _, v := range vs {
+		if set, err := bit(v); err != nil || !set {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Sets every bit in is with one BITFIELD command.
+func (s *Store) SetAll(is []uint64) error {
+	_, err := s.c.Do(s.bitfield("SET", is)...)
+	return err
+}
+
+// Deletes the bitmap.
+func (s *Store) Reset() error {
+	_, err := s.c.Do("DEL", s.key)
+	return err
+}
+
+// Returns the arguments of a BITFIELD command running op on each bit in is.
+func (s *Store) bitfield(op string, is []uint64) []any {
+	args := make([]any, 0, 2+4*len(is))
+	args = append(args, "BITFIELD", s.key)
+	for _, i := range is {
+		args = append(args, op, "u1", i)
+		if op == "SET" {
+			args = append(args, 1)
+		}
+	}
+	return args
+}
+
+// Returns the value of a bit in a reply.
+func bit(v any) (bool, error) {
+	switch v {
+	case int64(0):
+		return false, nil
+	case int64(1):
+		return true, nil
+	}
+	return false, ErrUnexpectedReply
+}
@@ -0,0 +1,114 @@
+package bloomredis
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// A Client running the few bitmap commands the store sends against maps in
+// memory, counting the commands.
+type fakeRedis struct {
+	keys     map[string]map[uint64]bool
+	commands int
+}
+
+func (r *fakeRedis) Do(args ...any) (any, error) {
+	r.commands++
+	cmd, key := args[0].(string), args[1].(string)
+	b := r.keys[key]
+	if b == nil {
+		b = map[uint64]bool{}
+		r.keys[key] = b
+	}
+	switch cmd {
+	case "GETBIT":
+		return reply(b[args[2].(uint64)]), nil
+	case "SETBIT":
+		i := args[2].(uint64)
+		old := b[i]
+		b[i] = args[3].(int) == 1
+		return reply(old), nil
+	case "BITFIELD":
+		var replies []any
+		for a := args[2:]; len(a) > 0; {
+			i := a[2].(uint64)
+			replies = append(replies, reply(b[i]))
+			if a[0] == "SET" {
+				b[i] = true
+				a = a[4:]
+			} else {
+				a = a[3:]
+			}
+		}
+		return replies, nil
+	case "DEL":
+		delete(r.keys, key)
+		return int64(1), nil
+	}
+	return nil, errors.New("unknown command " + cmd)
+}
+
+func reply(set bool) int64 {
+	if set {
+		return 1
+	}
+	return 0
+}
+
+func TestRedisFilter(t *testing.T) {
+	r := &fakeRedis{keys: map[string]map[uint64]bool{}}
+	f, err := NewFilter(r, "seen", 1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A second instance sharing the bitmap
+	g, err := NewFilter(r, "seen", 1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := f.Add([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r.commands != 100 {
+		t.Errorf("%d commands sent to add 100 items", r.commands)
+	}
+	for i := 0; i < 100; i++ {
+		if present, err := g.Test([]byte(strconv.Itoa(i))); err != nil || !present {
+			t.Fatalf("%d not present in the shared filter: %v", i, err)
+		}
+	}
+	if present, _ := g.Test([]byte("foo")); present {
+		t.Error("foo present")
+	}
+	if err := g.Reset(); err != nil || len(r.keys) != 0 {
+		t.Errorf("bitmap not deleted: %v", err)
+	}
+
+	s := f.Store()
+	if err := s.Set(3); err != nil {
+		t.Fatal(err)
+	}
+	if set, err := s.Test(3); err != nil || !set {
+		t.Errorf("bit 3 not set: %v", err)
+	}
+	if err := s.Clear(3); err != nil {
+		t.Fatal(err)
+	}
+	if set, _ := s.Test(3); set {
+		t.Error("bit 3 set after clearing it")
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	if _, err := NewStore(nil, "big", 1<<33); !errors.Is(err, bloom.ErrInvalidParameters) {
+		t.Errorf("creating a store of 2^33 bits returned %v", err)
+	}
+	if _, err := bit("1"); err != ErrUnexpectedReply {
+		t.Errorf("a string reply returned %v", err)
+	}
+}
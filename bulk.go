@@ -0,0 +1,244 @@
+package bloom
+
+import (
+	"bufio"
+	"context"
+	"encoding"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// The number of items a bulk operation processes between two checks of its
+// context. Checking it costs about as much as adding an item to a small
+// filter, so it isn't checked for every one.
+const bulkCheckEvery = 1024
+
+// The size of the chunks WriteToContext writes.
+const bulkChunkSize = 64 << 10
+
+// Adds every one of items to the filter, like AddAll, until ctx is done, e.g.
+// when the deadline of the request importing them passes. Returns the number
+// of items added, which is len(items) unless ctx was done, in which case it
+// is returned with ctx.Err(); items before that number were added, and the
+// others weren't.
+func (f *Filter) AddAllContext(ctx context.Context, items [][]byte) (int, error) {
+	var is []uint32
+	for n, data := range items {
+		if n%bulkCheckEvery == 0 && ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		is = f.bitsTo(is, data)
+		for _, i := range is {
+			f.b.Set(i)
+		}
+	}
+	return len(items), nil
+}
+
+// Checks whether each of items was (probably) added to the filter, until ctx
+// is done. Returns the results, and ctx.Err() if ctx was done before every
+// item was tested, in which case only the items before len(results) were.
+func (f *Filter) TestAllContext(ctx context.Context, items [][]byte) ([]bool, error) {
+	present := make([]bool, 0, len(items))
+	var is []uint32
+	for n, data := range items {
+		if n%bulkCheckEvery == 0 && ctx.Err() != nil {
+			return present, ctx.Err()
+		}
+		is = f.bitsTo(is, data)
+		present = append(present, testBits32(f.b, is))
+	}
+	return present, nil
+}
+
+// Adds every token of r, as split by split, or every line if split is nil,
+// to the filter, until ctx is done or r is exhausted. Empty tokens are
+// skipped. Returns the number of tokens added, and ctx.Err() if ctx was done,
+// or the error reading r, if any.
+func (f *Filter) LoadFrom(ctx context.Context, r io.Reader, split bufio.SplitFunc) (int, error) {
+	var is []uint32
+	return scanContext(ctx, r, split, func(data []byte) {
+		is = f.bitsTo(is, data)
+		for _, i := range is {
+			f.b.Set(i)
+		}
+	})
+}
+
+// Adds every one of items to the filter, like Filter.AddAllContext.
+func (f *Filter64) AddAllContext(ctx context.Context, items [][]byte) (int, error) {
+	var is []uint64
+	for n, data := range items {
+		if n%bulkCheckEvery == 0 && ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		is = f.bitsTo(is, data)
+		for _, i := range is {
+			f.b.Set(i)
+		}
+	}
+	return len(items), nil
+}
+
+// Checks whether each of items was (probably) added to the filter, like
+// Filter.TestAllContext.
+func (f *Filter64) TestAllContext(ctx context.Context, items [][]byte) ([]bool, error) {
+	present := make([]bool, 0, len(items))
+	var is []uint64
+	for n, data := range items {
+		if n%bulkCheckEvery == 0 && ctx.Err() != nil {
+			return present, ctx.Err()
+		}
+		is = f.bitsTo(is, data)
+		present = append(present, testBits64(f.b, is))
+	}
+	return present, nil
+}
+
+// Adds every token of r to the filter, like Filter.LoadFrom.
+func (f *Filter64) LoadFrom(ctx context.Context, r io.Reader, split bufio.SplitFunc) (int, error) {
+	var is []uint64
+	return scanContext(ctx, r, split, func(data []byte) {
+		is = f.bitsTo(is, data)
+		for _, i := range is {
+			f.b.Set(i)
+		}
+	})
+}
+
+// Adds every one of items to the filter, like Filter.AddAllContext.
+func (f *CountingFilter) AddAllContext(ctx context.Context, items [][]byte) (int, error) {
+	var is []uint32
+	for n, data := range items {
+		if n%bulkCheckEvery == 0 && ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		is = f.bitsTo(is, data)
+		f.add(is)
+	}
+	return len(items), nil
+}
+
+// Adds every one of items to the filter, like Filter.AddAllContext.
+func (f *CountingFilter64) AddAllContext(ctx context.Context, items [][]byte) (int, error) {
+	var is []uint64
+	for n, data := range items {
+		if n%bulkCheckEvery == 0 && ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		is = f.bitsTo(is, data)
+		f.add(is)
+	}
+	return len(items), nil
+}
+
+// Calls add with every non-empty token of r, as split by split, or every line
+// if split is nil, until ctx is done or r is exhausted, and returns the number
+// of tokens added.
+func scanContext(ctx context.Context, r io.Reader, split bufio.SplitFunc, add func([]byte)) (int, error) {
+	if split == nil {
+		split = bufio.ScanLines
+	}
+	if d, ok := r.(readDeadliner); ok {
+		defer abortOnDone(ctx, d.SetReadDeadline)()
+	}
+	s := bufio.NewScanner(&ctxReader{ctx: ctx, r: r})
+	s.Split(split)
+	n := 0
+	for s.Scan() {
+		if n%bulkCheckEvery == 0 && ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		add(s.Bytes())
+		n++
+	}
+	if ctx.Err() != nil {
+		return n, ctx.Err()
+	}
+	return n, s.Err()
+}
+
+// Writes the encoding of v, e.g. a filter, to w, in the format written by
+// WriteTo methods such as LayeredFilter.WriteTo, in chunks, until ctx is
+// done. If w has a SetWriteDeadline method, e.g. a net.Conn, a write blocked
+// when ctx is done is aborted by setting its deadline to the past; the
+// deadline isn't restored afterwards. Returns the number of bytes written,
+// and ctx.Err() if ctx was done before all of them were.
+func WriteToContext(ctx context.Context, w io.Writer, v encoding.BinaryMarshaler) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	data, err := v.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	if d, ok := w.(writeDeadliner); ok {
+		defer abortOnDone(ctx, d.SetWriteDeadline)()
+	}
+	data = append(binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(data)), uint64(len(data))), data...)
+	var written int64
+	for len(data) > 0 {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		chunk := data[:min(len(data), bulkChunkSize)]
+		n, err := w.Write(chunk)
+		written += int64(n)
+		if err != nil {
+			if ctx.Err() != nil {
+				return written, ctx.Err()
+			}
+			return written, err
+		}
+		data = data[n:]
+	}
+	return written, nil
+}
+
+// Reads an encoding written by WriteToContext, or by WriteTo methods such as
+// LayeredFilter.WriteTo, from r into v, e.g. a filter, until ctx is done. If
+// r has a SetReadDeadline method, e.g. a net.Conn, a read blocked when ctx is
+// done is aborted as WriteToContext aborts a write. Returns the number of
+// bytes read, and ctx.Err() if ctx was done before all of them were.
+func ReadFromContext(ctx context.Context, r io.Reader, v encoding.BinaryUnmarshaler) (int64, error) {
+	if d, ok := r.(readDeadliner); ok {
+		defer abortOnDone(ctx, d.SetReadDeadline)()
+	}
+	n, err := readFrom(&ctxReader{ctx: ctx, r: r}, v)
+	if err != nil && ctx.Err() != nil {
+		return n, ctx.Err()
+	}
+	return n, err
+}
+
+// Reads from r until ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// The methods of a connection, e.g. a net.Conn, setting its deadlines
+type (
+	readDeadliner  interface{ SetReadDeadline(time.Time) error }
+	writeDeadliner interface{ SetWriteDeadline(time.Time) error }
+)
+
+// Calls set, e.g. the SetReadDeadline method of a connection, with a time in
+// the past when ctx is done, so that a read or write blocked on the connection
+// returns. Returns a function which stops waiting for ctx.
+func abortOnDone(ctx context.Context, set func(time.Time) error) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		set(time.Unix(1, 0))
+	})
+}
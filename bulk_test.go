@@ -0,0 +1,203 @@
+package bloom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bulkItems(n int) [][]byte {
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+	return items
+}
+
+func TestAddAllContext(t *testing.T) {
+	items := bulkItems(3000)
+	f := New(len(items), 0.01)
+	f64 := New64(int64(len(items)), 0.01)
+	c := NewCounting(len(items), 0.01)
+	c64 := NewCounting64(int64(len(items)), 0.01)
+	sets := []interface {
+		Set
+		AddAllContext(context.Context, [][]byte) (int, error)
+	}{f, f64, c, c64}
+	for _, s := range sets {
+		n, err := s.AddAllContext(context.Background(), items)
+		if n != len(items) || err != nil {
+			t.Fatalf("%T: added %d, %v; want %d, nil", s, n, err, len(items))
+		}
+		for _, item := range items {
+			if !s.Test(item) {
+				t.Fatalf("%T: %s not added", s, item)
+			}
+		}
+	}
+}
+
+func TestAddAllContextCanceled(t *testing.T) {
+	items := bulkItems(3000)
+	f := New(len(items), 0.01)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n, err := f.AddAllContext(ctx, items)
+	if n != 0 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("added %d, %v; want 0, context.Canceled", n, err)
+	}
+	if f.Test(items[0]) {
+		t.Error("item added after cancellation")
+	}
+}
+
+func TestTestAllContext(t *testing.T) {
+	items := bulkItems(100)
+	f := New(len(items), 0.01)
+	f64 := New64(int64(len(items)), 0.01)
+	f.Add(items[1])
+	f64.Add(items[1])
+	for _, s := range []interface {
+		TestAllContext(context.Context, [][]byte) ([]bool, error)
+	}{f, f64} {
+		present, err := s.TestAllContext(context.Background(), items[:2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(present) != 2 || present[0] || !present[1] {
+			t.Errorf("%T: got %v, want [false true]", s, present)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		present, err = s.TestAllContext(ctx, items)
+		if len(present) != 0 || !errors.Is(err, context.Canceled) {
+			t.Errorf("%T: got %v, %v; want [], context.Canceled", s, present, err)
+		}
+	}
+}
+
+func TestLoadFrom(t *testing.T) {
+	f := New(100, 0.01)
+	n, err := f.LoadFrom(context.Background(), strings.NewReader("foo\n\nbar\r\nbaz"), nil)
+	if n != 3 || err != nil {
+		t.Fatalf("loaded %d, %v; want 3, nil", n, err)
+	}
+	for _, s := range []string{"foo", "bar", "baz"} {
+		if !f.TestString(s) {
+			t.Errorf("%s not loaded", s)
+		}
+	}
+	if f.TestString("") {
+		t.Error("empty line loaded")
+	}
+
+	f64 := New64(100, 0.01)
+	n, err = f64.LoadFrom(context.Background(), strings.NewReader("foo bar  baz"), bufio.ScanWords)
+	if n != 3 || err != nil {
+		t.Fatalf("loaded %d, %v; want 3, nil", n, err)
+	}
+	if !f64.Test([]byte("bar")) {
+		t.Error("bar not loaded")
+	}
+}
+
+func TestLoadFromCanceled(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go server.Write([]byte("foo\nbar\n"))
+
+	f := New(100, 0.01)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	var n int
+	var err error
+	go func() {
+		n, err = f.LoadFrom(ctx, client, nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("LoadFrom blocked after the deadline")
+	}
+	if n != 2 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("loaded %d, %v; want 2, context.DeadlineExceeded", n, err)
+	}
+}
+
+func TestWriteToContext(t *testing.T) {
+	f := New(100000, 0.01)
+	f.AddString("foo")
+	var buf bytes.Buffer
+	n, err := WriteToContext(context.Background(), &buf, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) || n <= bulkChunkSize {
+		t.Fatalf("wrote %d bytes, reported %d; want more than one chunk", buf.Len(), n)
+	}
+
+	// Compatible with the WriteTo methods
+	l := NewLayered(100, 0.01)
+	l.AddString("foo")
+	var lbuf bytes.Buffer
+	if _, err := l.WriteTo(&lbuf); err != nil {
+		t.Fatal(err)
+	}
+	var got LayeredFilter
+	if _, err := ReadFromContext(context.Background(), &lbuf, &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.TestString("foo"); !ok {
+		t.Error("foo not read")
+	}
+
+	var g Filter
+	m, err := ReadFromContext(context.Background(), &buf, &g)
+	if m != n || err != nil {
+		t.Fatalf("read %d, %v; want %d, nil", m, err, n)
+	}
+	if !g.TestString("foo") || g.TestString("bar") {
+		t.Error("unexpected decoded filter")
+	}
+}
+
+func TestWriteToContextCanceled(t *testing.T) {
+	// Nothing reads from the other end, so the write blocks until the
+	// deadline aborts it
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	f := New(100000, 0.01)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	var n int64
+	var err error
+	go func() {
+		n, err = WriteToContext(ctx, client, f)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("WriteToContext blocked after the deadline")
+	}
+	if n != 0 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wrote %d, %v; want 0, context.DeadlineExceeded", n, err)
+	}
+
+	var g Filter
+	if _, err := ReadFromContext(ctx, server, &g); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,192 @@
+package bloom
+
+import (
+	"math"
+)
+
+// Estimates the number of distinct items added to a filter with m bits and k
+// hash functions, x of which are set (Swamidass & Baldi). Returns +Inf if
+// every bit is set.
+func estimateCount(m, k, x float64) float64 {
+	return -m / k * math.Log1p(-x/m)
+}
+
+// Returns the numbers of bits set in a, in b, and in either of them.
+func unionBits(a, b *Filter) (na, nb, nu uint32, err error) {
+	if !a.compatible(b.filter) {
+		err = ErrIncompatible
+		return
+	}
+	for i := uint32(0); i < a.m; i++ {
+		x, y := a.b.Test(i), b.b.Test(i)
+		if x {
+			na++
+		}
+		if y {
+			nb++
+		}
+		if x || y {
+			nu++
+		}
+	}
+	return
+}
+
+// Estimates the number of distinct items added to either a or b from the
+// number of bits set in their union. Returns ErrIncompatible if the filters
+// were created with different parameters, or seeds. The estimate is +Inf if
+// the union has every bit set.
+func EstimateUnionCardinality(a, b *Filter) (float64, error) {
+	_, _, nu, err := unionBits(a, b)
+	if err != nil {
+		return 0, err
+	}
+	return estimateCount(float64(a.m), float64(a.k), float64(nu)), nil
+}
+
+// Estimates the number of distinct items added to both a and b, as the sum
+// of their estimated cardinalities minus that of their union. The estimate is
+// rough when the intersection is small relative to the filters. Returns
+// ErrIncompatible if the filters were created with different parameters, or
+// seeds.
+func EstimateIntersectionCardinality(a, b *Filter) (float64, error) {
+	na, nb, nu, err := unionBits(a, b)
+	if err != nil {
+		return 0, err
+	}
+	return intersection(float64(a.m), float64(a.k), na, nb, nu), nil
+}
+
+func intersection(m, k float64, na, nb, nu uint32) float64 {
+	e := estimateCount(m, k, float64(na)) + estimateCount(m, k, float64(nb)) - estimateCount(m, k, float64(nu))
+	if e < 0 || math.IsNaN(e) {
+		return 0
+	}
+	return e
+}
+
+// Estimates the Jaccard index of the items added to a and b, i.e. the size of
+// their intersection divided by the size of their union, from 0 to 1. Returns
+// ErrIncompatible if the filters were created with different parameters, or
+// seeds.
+func EstimateJaccard(a, b *Filter) (float64, error) {
+	na, nb, nu, err := unionBits(a, b)
+	if err != nil {
+		return 0, err
+	}
+	m, k := float64(a.m), float64(a.k)
+	u := estimateCount(m, k, float64(nu))
+	if u == 0 || math.IsInf(u, 1) {
+		return 0, nil
+	}
+	return math.Min(intersection(m, k, na, nb, nu)/u, 1), nil
+}
+
+// Returns the number of set bits in the filter.
+func (f *Filter) setBits() uint32 {
+	n := uint32(0)
+	for i := uint32(0); i < f.m; i++ {
+		if f.b.Test(i) {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *Filter64) setBits() uint64 {
+	n := uint64(0)
+	for i := uint64(0); i < f.m; i++ {
+		if f.b.Test(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// Estimates the number of distinct items added to the filter from the number
+// of bits set, e.g. to monitor whether the filter is nearing the number of
+// items it was created for. Returns math.MaxUint32 if every bit is set.
+func (f *Filter) ApproximatedSize() uint32 {
+	e := estimateCount(float64(f.m), float64(f.k), float64(f.setBits()))
+	if e >= math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(math.Round(e))
+}
+
+// Estimates the number of distinct items added to the filter from the number
+// of bits set. Returns math.MaxUint64 if every bit is set.
+func (f *Filter64) ApproximatedSize() uint64 {
+	e := estimateCount(float64(f.m), float64(f.k), float64(f.setBits()))
+	if e >= math.MaxUint64 {
+		return math.MaxUint64
+	}
+	return uint64(math.Round(e))
+}
+
+// Returns the fraction of the filter's bits that are set, from 0 to 1.
+func (f *Filter) FillRatio() float64 {
+	return float64(f.setBits()) / float64(f.m)
+}
+
+// Returns the fraction of the filter's bits that are set, from 0 to 1.
+func (f *Filter64) FillRatio() float64 {
+	return float64(f.setBits()) / float64(f.m)
+}
+
+// Returns the number of layers of the filter, i.e. the largest count it can
+// report, including layers which are empty after Reset.
+func (f *LayeredFilter) Layers() int {
+	return len(f.b)
+}
+
+// Returns the fraction of the bits of layer n of the filter that are set, e.g.
+// for n 1, the first layer, from 0 to 1, or 0 if there is no such layer. The
+// false positive rate of TestAtLeast for n is about the fill ratio to the
+// power of the number of hash functions, so a filter whose first layers are
+// nearly full should be reset, or rebuilt larger.
+func (f *LayeredFilter) LayerFillRatio(n int) float64 {
+	if n < 1 || n > len(f.b) {
+		return 0
+	}
+	b, set := f.b[n-1], 0
+	for i := uint32(0); i < b.Len(); i++ {
+		if b.Test(i) {
+			set++
+		}
+	}
+	return float64(set) / float64(b.Len())
+}
+
+// Returns the number of layers of the filter, including empty ones.
+func (f *LayeredFilter64) Layers() int {
+	return len(f.b)
+}
+
+// Returns the fraction of the bits of layer n of the filter that are set, like
+// LayeredFilter.LayerFillRatio.
+func (f *LayeredFilter64) LayerFillRatio(n int) float64 {
+	if n < 1 || n > len(f.b) {
+		return 0
+	}
+	b, set := f.b[n-1], 0
+	for i := uint64(0); i < f.m; i++ {
+		if b.Test(i) {
+			set++
+		}
+	}
+	return float64(set) / float64(f.m)
+}
+
+// Estimates the current false positive rate of the filter from its fill
+// ratio, e.g. to alert when it drifts above the rate the filter was created
+// for because more items were added than expected.
+func (f *Filter) EstimatedFPRate() float64 {
+	return math.Pow(f.FillRatio(), float64(f.k))
+}
+
+// Estimates the current false positive rate of the filter from its fill
+// ratio.
+func (f *Filter64) EstimatedFPRate() float64 {
+	return math.Pow(f.FillRatio(), float64(f.k))
+}
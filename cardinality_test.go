@@ -0,0 +1,115 @@
+package bloom
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestCardinalityEstimates(t *testing.T) {
+	a, b := New(10000, 0.01), New(10000, 0.01)
+	for i := 0; i < 6000; i++ {
+		a.Add([]byte(strconv.Itoa(i)))
+		b.Add([]byte(strconv.Itoa(i + 3000)))
+	}
+	u, err := EstimateUnionCardinality(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(u-9000) > 9000*0.05 {
+		t.Errorf("union cardinality %f, expected about 9000", u)
+	}
+	in, err := EstimateIntersectionCardinality(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(in-3000) > 3000*0.1 {
+		t.Errorf("intersection cardinality %f, expected about 3000", in)
+	}
+	j, err := EstimateJaccard(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(j-1.0/3) > 0.05 {
+		t.Errorf("Jaccard index %f, expected about 0.33", j)
+	}
+	if j, _ := EstimateJaccard(New(10000, 0.01), New(10000, 0.01)); j != 0 {
+		t.Errorf("Jaccard index of empty filters is %f", j)
+	}
+	if _, err := EstimateJaccard(a, New(1000, 0.01)); err != ErrIncompatible {
+		t.Errorf("estimate for incompatible filters returned %v", err)
+	}
+}
+
+func TestApproximatedSize(t *testing.T) {
+	f := New(10000, 0.01)
+	f64 := New64(10000, 0.01)
+	if f.ApproximatedSize() != 0 || f64.ApproximatedSize() != 0 {
+		t.Error("empty filter has a non-zero size")
+	}
+	for i := 0; i < 5000; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+		f64.Add([]byte(strconv.Itoa(i)))
+	}
+	if n := f.ApproximatedSize(); n < 4900 || n > 5100 {
+		t.Errorf("approximated size %d, expected about 5000", n)
+	}
+	if n := f64.ApproximatedSize(); n < 4900 || n > 5100 {
+		t.Errorf("approximated size %d, expected about 5000 (64-bit)", n)
+	}
+	s := New(1, 0.5)
+	for i := 0; i < 100; i++ {
+		s.Add([]byte(strconv.Itoa(i)))
+	}
+	if n := s.ApproximatedSize(); n != math.MaxUint32 {
+		t.Errorf("approximated size of a full filter is %d", n)
+	}
+}
+
+func TestEstimatedFPRate(t *testing.T) {
+	n := uint32(10000)
+	f := New(int(n), 0.01)
+	f64 := New64(int64(n), 0.01)
+	if f.FillRatio() != 0 || f.EstimatedFPRate() != 0 || f64.EstimatedFPRate() != 0 {
+		t.Error("empty filter has a non-zero false positive rate")
+	}
+	for i := uint32(0); i < n; i++ {
+		f.Add([]byte(strconv.Itoa(int(i))))
+		f64.Add([]byte(strconv.Itoa(int(i))))
+	}
+	if r := f.FillRatio(); math.Abs(r-0.5) > 0.02 {
+		t.Errorf("fill ratio %f, expected about 0.5", r)
+	}
+	if p := f.EstimatedFPRate(); math.Abs(p-0.01) > 0.002 {
+		t.Errorf("estimated false positive rate %f, expected about 0.01", p)
+	}
+	if p := f64.EstimatedFPRate(); math.Abs(p-0.01) > 0.002 {
+		t.Errorf("estimated false positive rate %f, expected about 0.01 (64-bit)", p)
+	}
+}
+
+func TestLayerFillRatio(t *testing.T) {
+	f := NewLayered(1000, 0.01)
+	f64 := NewLayered64(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+		f64.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < 100; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+		f64.Add([]byte(strconv.Itoa(i)))
+	}
+	if f.Layers() != 2 || f64.Layers() != 2 {
+		t.Fatalf("%d and %d layers, expected 2", f.Layers(), f64.Layers())
+	}
+	first, second := f.LayerFillRatio(1), f.LayerFillRatio(2)
+	if first < 0.4 || first > 0.6 || second <= 0 || second >= first {
+		t.Errorf("layer fill ratios %f and %f", first, second)
+	}
+	if f64.LayerFillRatio(1) < 0.4 || f64.LayerFillRatio(2) >= f64.LayerFillRatio(1) {
+		t.Errorf("64-bit layer fill ratios %f and %f", f64.LayerFillRatio(1), f64.LayerFillRatio(2))
+	}
+	if f.LayerFillRatio(0) != 0 || f.LayerFillRatio(3) != 0 {
+		t.Error("fill ratio of a layer that doesn't exist isn't 0")
+	}
+}
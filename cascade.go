@@ -0,0 +1,134 @@
+package bloom
+
+import (
+	"fmt"
+)
+
+const (
+	cascadeMaxLayers     = 64
+	cascadeMinLayerItems = 16
+)
+
+// A bloom filter cascade, as used for certificate revocation (e.g. CRLite.)
+// It is built from a set of included items and a set of excluded items: the
+// first layer holds the included items, the second holds the excluded items
+// that are false positives in the first, the third holds the included items
+// that are false positives in the second, and so on until no false positives
+// remain. Test gives exact answers for every item in either set; items in
+// neither set have a false positive chance near the ratio specified upon
+// creation.
+type Cascade struct {
+	layers []*Filter
+}
+
+// Checks whether data is in the included set.
+func (c *Cascade) Test(data []byte) bool {
+	for i, l := range c.layers {
+		if !l.Test(data) {
+			return i%2 == 1
+		}
+	}
+	return len(c.layers)%2 == 1
+}
+
+// Returns the number of layers in the cascade.
+func (c *Cascade) Layers() int {
+	return len(c.layers)
+}
+
+// Create a bloom filter cascade which includes the items in include and
+// excludes the items in exclude, with an acceptable false positive rate of p,
+// e.g. 0.01, for items in neither set. An item must not be in both sets.
+// Every layer is given its own seed, replacing any given with WithSeed.
+func NewCascade(include, exclude [][]byte, p float64, opts ...Option) *Cascade {
+	c, msg := buildCascade(include, exclude, p, opts)
+	if msg != "" {
+		panic(msg)
+	}
+	return c
+}
+
+// Build a cascade like NewCascade, e.g. of the revoked (included) and valid
+// (excluded) serial numbers of the certificates of a CA, and verify that it
+// gives the right answer for every item of both sets, so that it can be
+// published with no false positives or negatives over the universe of the
+// two. Returns an error wrapping ErrInvalidParameters instead of panicking if
+// an item is in both sets, or no cascade can be built, and one wrapping
+// ErrCascadeMismatch if the cascade fails verification.
+func BuildCascade(include, exclude [][]byte, p float64, opts ...Option) (*Cascade, error) {
+	if err := checkParams(int64(max(len(include), 1)), p); err != nil {
+		return nil, err
+	}
+	c, msg := buildCascade(include, exclude, p, opts)
+	if msg != "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidParameters, msg)
+	}
+	if err := c.Verify(include, exclude); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Checks that the cascade includes every item in include, and excludes every
+// item in exclude. Returns an error wrapping ErrCascadeMismatch naming the
+// first item for which it doesn't.
+func (c *Cascade) Verify(include, exclude [][]byte) error {
+	for _, v := range include {
+		if !c.Test(v) {
+			return fmt.Errorf("%w: %q is included, but isn't in the cascade", ErrCascadeMismatch, v)
+		}
+	}
+	for _, v := range exclude {
+		if c.Test(v) {
+			return fmt.Errorf("%w: %q is excluded, but is in the cascade", ErrCascadeMismatch, v)
+		}
+	}
+	return nil
+}
+
+// Builds the cascade of NewCascade, or returns a description of the problem
+// if it can't be built.
+func buildCascade(include, exclude [][]byte, p float64, opts []Option) (*Cascade, string) {
+	seen := make(map[string]struct{}, len(include))
+	for _, v := range include {
+		seen[string(v)] = struct{}{}
+	}
+	for _, v := range exclude {
+		if _, ok := seen[string(v)]; ok {
+			return nil, fmt.Sprintf("Cannot build a cascade where %q is both included and excluded.", v)
+		}
+	}
+	c := &Cascade{}
+	in, out := include, exclude
+	for len(in) > 0 {
+		i := len(c.layers)
+		if i == cascadeMaxLayers {
+			return nil, fmt.Sprintf("Unable to build a cascade within %d layers.", cascadeMaxLayers)
+		}
+		lp := 0.5
+		if i == 0 {
+			lp = p
+		}
+		// Every layer has its own seed, so that the layers' false
+		// positives are independent of each other. Very small layers
+		// are padded, since a layer of only a few bits could match
+		// everything.
+		n := len(in)
+		if n < cascadeMinLayerItems {
+			n = cascadeMinLayerItems
+		}
+		l := New(n, lp, append(opts, WithSeed(uint64(i)+1))...)
+		for _, v := range in {
+			l.Add(v)
+		}
+		c.layers = append(c.layers, l)
+		var fps [][]byte
+		for _, v := range out {
+			if l.Test(v) {
+				fps = append(fps, v)
+			}
+		}
+		in, out = fps, in
+	}
+	return c, ""
+}
@@ -0,0 +1,149 @@
+package bloom
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestCascade(t *testing.T) {
+	var include, exclude [][]byte
+	for i := 0; i < 20000; i++ {
+		v := []byte(strconv.Itoa(i))
+		if i%10 == 0 {
+			include = append(include, v)
+		} else {
+			exclude = append(exclude, v)
+		}
+	}
+	c := NewCascade(include, exclude, 0.01)
+	if c.Layers() < 2 {
+		t.Errorf("expected several layers, got %d", c.Layers())
+	}
+	for _, v := range include {
+		if !c.Test(v) {
+			t.Fatalf("%s not in cascade", v)
+		}
+	}
+	for _, v := range exclude {
+		if c.Test(v) {
+			t.Fatalf("%s in cascade", v)
+		}
+	}
+}
+
+func TestCascadeSmallLayers(t *testing.T) {
+	include := [][]byte{foo}
+	var exclude [][]byte
+	for i := 0; i < 5000; i++ {
+		exclude = append(exclude, []byte(strconv.Itoa(i)))
+	}
+	c := NewCascade(include, exclude, 0.5, WithSeed(7))
+	if err := c.Verify(include, exclude); err != nil {
+		t.Fatal(err)
+	}
+	for i, l := range c.layers {
+		if l.seed != uint64(i)+1 {
+			t.Errorf("layer %d has seed %d", i, l.seed)
+		}
+	}
+}
+
+func TestCascadeOverlapPanic(t *testing.T) {
+	defer func() {
+		if x := recover(); x == nil {
+			t.Errorf("an item in both sets should have caused a panic")
+		}
+	}()
+	NewCascade([][]byte{foo, bar}, [][]byte{bar}, 0.01)
+}
+
+func TestBuildCascade(t *testing.T) {
+	var revoked, valid [][]byte
+	for i := 0; i < 5000; i++ {
+		serial := []byte(strconv.FormatInt(int64(i)*7919, 16))
+		if i%50 == 0 {
+			revoked = append(revoked, serial)
+		} else {
+			valid = append(valid, serial)
+		}
+	}
+	c, err := BuildCascade(revoked, valid, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Verify(revoked, valid); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Verify(valid[:1], nil); !errors.Is(err, ErrCascadeMismatch) {
+		t.Errorf("verifying a wrong set returned %v", err)
+	}
+	if _, err := BuildCascade([][]byte{foo, bar}, [][]byte{bar}, 0.01); !errors.Is(err, ErrInvalidParameters) {
+		t.Errorf("building with overlapping sets returned %v", err)
+	}
+	if _, err := BuildCascade(revoked, valid, 1.5); !errors.Is(err, ErrInvalidParameters) {
+		t.Errorf("building with an invalid p returned %v", err)
+	}
+}
+
+func TestCascadeMarshal(t *testing.T) {
+	var include, exclude [][]byte
+	for i := 0; i < 2000; i++ {
+		v := []byte(strconv.Itoa(i))
+		if i%4 == 0 {
+			include = append(include, v)
+		} else {
+			exclude = append(exclude, v)
+		}
+	}
+	c := NewCascade(include, exclude, 0.01)
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Cascade{}
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if d.Layers() != c.Layers() {
+		t.Fatalf("decoded %d layers, want %d", d.Layers(), c.Layers())
+	}
+	if err := d.Verify(include, exclude); err != nil {
+		t.Error(err)
+	}
+	if err := d.UnmarshalBinary(data[:len(data)-1]); err != ErrInvalidEncoding {
+		t.Errorf("decoding truncated data returned %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	e := &Cascade{}
+	if _, err := e.ReadFrom(&buf); err != nil || e.Layers() != c.Layers() {
+		t.Errorf("ReadFrom returned %v", err)
+	}
+}
+
+func TestCascadeMarshalEmpty(t *testing.T) {
+	exclude := [][]byte{foo, bar}
+	c, err := BuildCascade(nil, exclude, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Cascade{}
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if d.Layers() != 0 {
+		t.Errorf("decoded %d layers, want 0", d.Layers())
+	}
+	if err := d.Verify(nil, exclude); err != nil {
+		t.Error(err)
+	}
+}
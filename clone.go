@@ -0,0 +1,205 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+
+	"sync/atomic"
+)
+
+// Returns a copy of f, so that changing the parameters of one doesn't affect
+// the other. Hashing with a filter keeps no state, so the copy can be used
+// concurrently with f.
+func (f *filter) clone() *filter {
+	c := *f
+	return &c
+}
+
+// Returns a copy of f.
+func (f *filter64) clone() *filter64 {
+	c := *f
+	return &c
+}
+
+func copyBits32(b *bitset.Bitset32) *bitset.Bitset32 {
+	c := bitset.New32(b.Len())
+	for i := uint32(0); i < b.Len(); i++ {
+		if b.Test(i) {
+			c.Set(i)
+		}
+	}
+	return c
+}
+
+func copyBits64(b *bitset.Bitset64) *bitset.Bitset64 {
+	c := bitset.New64(b.Len())
+	for i := uint64(0); i < b.Len(); i++ {
+		if b.Test(i) {
+			c.Set(i)
+		}
+	}
+	return c
+}
+
+func copyLayers32(bs []*bitset.Bitset32) []*bitset.Bitset32 {
+	c := make([]*bitset.Bitset32, len(bs))
+	for i, b := range bs {
+		c[i] = copyBits32(b)
+	}
+	return c
+}
+
+func copyLayers64(bs []*bitset.Bitset64) []*bitset.Bitset64 {
+	c := make([]*bitset.Bitset64, len(bs))
+	for i, b := range bs {
+		c[i] = copyBits64(b)
+	}
+	return c
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *Filter) Clone() *Filter {
+	return &Filter{f.filter.clone(), copyBits32(f.b)}
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *CountingFilter) Clone() *CountingFilter {
+	c := &CountingFilter{filter: f.filter.clone(), conservative: f.conservative}
+	if f.c != nil {
+		c.c = f.c.clone()
+	} else {
+		c.b = copyLayers32(f.b)
+	}
+	return c
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *LayeredFilter) Clone() *LayeredFilter {
+	return &LayeredFilter{
+		filter: f.filter.clone(),
+		b:      copyLayers32(f.b),
+		max:    f.max,
+		tiers:  f.tiers,
+		info:   append([]LayerInfo(nil), f.info...),
+	}
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *Filter64) Clone() *Filter64 {
+	return &Filter64{f.filter64.clone(), copyBits64(f.b)}
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *CountingFilter64) Clone() *CountingFilter64 {
+	c := &CountingFilter64{filter64: f.filter64.clone(), conservative: f.conservative}
+	if f.c != nil {
+		c.c = f.c.clone()
+	} else {
+		c.b = copyLayers64(f.b)
+	}
+	return c
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *LayeredFilter64) Clone() *LayeredFilter64 {
+	return &LayeredFilter64{
+		filter64: f.filter64.clone(),
+		b:        copyLayers64(f.b),
+		max:      f.max,
+		info:     append([]LayerInfo(nil), f.info...),
+	}
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *AgingFilter) Clone() *AgingFilter {
+	c := *f
+	c.filter = f.filter.clone()
+	c.active = copyBits32(f.active)
+	c.old = copyBits32(f.old)
+	return &c
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *RotatingFilter) Clone() *RotatingFilter {
+	c := *f
+	c.filter = f.filter.clone()
+	c.b = copyLayers32(f.b)
+	return &c
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *DeletableFilter) Clone() *DeletableFilter {
+	c := *f
+	c.filter = f.filter.clone()
+	c.b = copyBits32(f.b)
+	c.collisions = copyBits32(f.collisions)
+	return &c
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *WeightedFilter) Clone() *WeightedFilter {
+	return &WeightedFilter{f.filter.clone(), copyBits32(f.b)}
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *ShiftingFilter) Clone() *ShiftingFilter {
+	return &ShiftingFilter{f.filter.clone(), copyBits32(f.b), f.sets}
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *MultiFilter) Clone() *MultiFilter {
+	c := &MultiFilter{
+		f.filter.clone(),
+		append([]string(nil), f.ids...),
+		make(map[string]*bitset.Bitset32, len(f.b)),
+	}
+	for id, b := range f.b {
+		c.b[id] = copyBits32(b)
+	}
+	return c
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *DLeftFilter) Clone() *DLeftFilter {
+	c := *f
+	for t := range f.cells {
+		c.cells[t] = append([]uint32(nil), f.cells[t]...)
+	}
+	return &c
+}
+
+// Returns a copy of the filter which can be changed, and used concurrently,
+// independently of f.
+func (f *QuotientFilter) Clone() *QuotientFilter {
+	c := *f
+	c.rems = append([]uint64(nil), f.rems...)
+	c.occupieds = append([]uint64(nil), f.occupieds...)
+	c.runends = append([]uint64(nil), f.runends...)
+	c.filled = append([]uint64(nil), f.filled...)
+	return &c
+}
+
+// Returns a copy of the filter which can be changed independently of f.
+// Items are held by both filters until they are evicted from either.
+func (f *InverseFilter) Clone() *InverseFilter {
+	c := &InverseFilter{
+		slots: make([]atomic.Pointer[[]byte], len(f.slots)),
+		hf:    f.hf,
+	}
+	for i := range f.slots {
+		c.slots[i].Store(f.slots[i].Load())
+	}
+	return c
+}
@@ -0,0 +1,61 @@
+package bloom
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	type filter interface {
+		Add([]byte)
+		Test([]byte) bool
+	}
+	for _, pair := range []func() (filter, filter){
+		func() (filter, filter) { f := New(1000, 0.01); f.Add(foo); return f, f.Clone() },
+		func() (filter, filter) { f := NewCounting(1000, 0.01); f.Add(foo); return f, f.Clone() },
+		func() (filter, filter) { f := New64(1000, 0.01); f.Add(foo); return f, f.Clone() },
+		func() (filter, filter) { f := NewCounting64(1000, 0.01); f.Add(foo); return f, f.Clone() },
+		func() (filter, filter) { f := NewAging(1000, 0.01); f.Add(foo); return f, f.Clone() },
+		func() (filter, filter) {
+			f := NewRotating(1000, 0.01, time.Hour, 4)
+			f.Add(foo)
+			return f, f.Clone()
+		},
+		func() (filter, filter) { f := NewDeletable(1000, 0.01, 100); f.Add(foo); return f, f.Clone() },
+		func() (filter, filter) { f := NewQuotient(1000, 0.01); f.Add(foo); return f, f.Clone() },
+		func() (filter, filter) { f := NewWeighted(1000, 0.01); f.Add(foo); return f, f.Clone() },
+		func() (filter, filter) { f := NewInverse(1000); f.Add(foo); return f, f.Clone() },
+	} {
+		f, c := pair()
+		c.Add(bar)
+		if !c.Test(foo) || !c.Test(bar) {
+			t.Errorf("%T: clone does not hold foo and bar", c)
+		}
+		if f.Test(bar) {
+			t.Errorf("%T: adding to the clone changed the original", f)
+		}
+	}
+
+	d := NewDLeft(1000, 0.01)
+	d.Add(foo)
+	dc := d.Clone()
+	dc.Add(bar)
+	if !dc.Test(foo) || d.Test(bar) {
+		t.Error("d-left clone is not independent")
+	}
+	l := NewLayered(1000, 0.01)
+	l.Add(foo)
+	lc := l.Clone()
+	lc.Add(foo)
+	if n, _ := l.Test(foo); n != 1 {
+		t.Error("adding to the layered clone changed the original")
+	}
+	m := NewMulti(1000, 0.01)
+	m.AddTo("a", foo)
+	mc := m.Clone()
+	mc.AddTo("a", bar)
+	mc.AddTo("b", bar)
+	if m.Test("a", bar) || len(m.IDs()) != 1 || !mc.Test("a", foo) {
+		t.Error("multi filter clone is not independent")
+	}
+}
@@ -0,0 +1,366 @@
+// Command bloom creates, fills and inspects bloom filter files, e.g. in shell
+// pipelines, without writing Go. Filters are stored in the encoding of
+// bloom.Filter's MarshalBinary, or MarshalCompressed, and cascades in that of
+// bloom.Cascade's MarshalBinary.
+//
+// Usage:
+//
+//	bloom create [-n items] [-p rate] [-hash fnv|xxh3] [-compressed] FILE
+//	bloom add FILE [INPUT...]
+//	bloom test [-v] FILE [INPUT...]
+//	bloom stats FILE
+//	bloom merge -o OUT FILE...
+//	bloom convert -format binary|compressed IN OUT
+//	bloom cascade [-p rate] -revoked FILE -valid FILE OUT
+//
+// add and test read one item per line from each INPUT, or from standard input
+// if none is given, skipping empty lines. test prints the items which are
+// (probably) in the filter, or with -v, those which aren't; FILE may also be
+// a cascade.
+//
+// cascade builds a cascade of the items in -revoked, e.g. the serial numbers
+// of revoked certificates, excluding those in -valid, one per line, checks
+// that it gives the right answer for every item of both, and writes it to
+// OUT. -p is the false positive rate for items in neither file.
+package main
+
+import (
+	"github.com/pmylund/go-bloom"
+	"github.com/pmylund/go-bloom/xxh3"
+
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const usage = `usage:
+	bloom create [-n items] [-p rate] [-hash fnv|xxh3] [-compressed] FILE
+	bloom add FILE [INPUT...]
+	bloom test [-v] FILE [INPUT...]
+	bloom stats FILE
+	bloom merge -o OUT FILE...
+	bloom convert -format binary|compressed IN OUT
+	bloom cascade [-p rate] -revoked FILE -valid FILE OUT
+`
+
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if err == errUsage {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bloom:", err)
+		os.Exit(1)
+	}
+}
+
+// Runs the command given by args, reading items from stdin and writing
+// results to stdout and messages about flags to stderr.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	fs := flag.NewFlagSet("bloom "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	switch args[0] {
+	case "create":
+		n := fs.Int("n", 100000, "the expected number of items")
+		p := fs.Float64("p", 0.01, "the acceptable false positive rate")
+		hash := fs.String("hash", "fnv", "the hash function, fnv or xxh3")
+		compressed := fs.Bool("compressed", false, "optimize the filter for the compressed format, and store it so")
+		if fs.Parse(args[1:]) != nil || fs.NArg() != 1 {
+			return errUsage
+		}
+		return create(fs.Arg(0), *n, *p, *hash, *compressed)
+	case "add":
+		if fs.Parse(args[1:]) != nil || fs.NArg() < 1 {
+			return errUsage
+		}
+		return add(fs.Arg(0), fs.Args()[1:], stdin)
+	case "test":
+		invert := fs.Bool("v", false, "print the items which aren't in the filter instead")
+		if fs.Parse(args[1:]) != nil || fs.NArg() < 1 {
+			return errUsage
+		}
+		return test(fs.Arg(0), fs.Args()[1:], *invert, stdin, stdout)
+	case "stats":
+		if fs.Parse(args[1:]) != nil || fs.NArg() != 1 {
+			return errUsage
+		}
+		return stats(fs.Arg(0), stdout)
+	case "merge":
+		out := fs.String("o", "", "the file to write the merged filter to")
+		if fs.Parse(args[1:]) != nil || *out == "" || fs.NArg() < 1 {
+			return errUsage
+		}
+		return merge(*out, fs.Args())
+	case "convert":
+		format := fs.String("format", "compressed", "the format to convert to, binary or compressed")
+		if fs.Parse(args[1:]) != nil || fs.NArg() != 2 {
+			return errUsage
+		}
+		if *format != "binary" && *format != "compressed" {
+			return fmt.Errorf("unknown format %q", *format)
+		}
+		return convert(fs.Arg(0), fs.Arg(1), *format == "compressed")
+	case "cascade":
+		p := fs.Float64("p", 0.01, "the false positive rate for items in neither set")
+		revoked := fs.String("revoked", "", "the file of the items to include, e.g. revoked serial numbers")
+		valid := fs.String("valid", "", "the file of the items to exclude, e.g. valid serial numbers")
+		if fs.Parse(args[1:]) != nil || *revoked == "" || *valid == "" || fs.NArg() != 1 {
+			return errUsage
+		}
+		return cascade(fs.Arg(0), *revoked, *valid, *p, stdout)
+	}
+	return errUsage
+}
+
+func create(path string, n int, p float64, hash string, compressed bool) error {
+	if n < 1 || p <= 0 || p >= 1 {
+		return fmt.Errorf("invalid parameters: %d items at a false positive rate of %g", n, p)
+	}
+	var opts []bloom.Option
+	switch hash {
+	case "fnv":
+	case "xxh3":
+		opts = append(opts, bloom.WithRegisteredHash(xxh3.Name, nil))
+	default:
+		return fmt.Errorf("unknown hash function %q", hash)
+	}
+	if compressed {
+		return save(path, bloom.NewCompressed(n, p, opts...), true)
+	}
+	return save(path, bloom.New(n, p, opts...), false)
+}
+
+func add(path string, inputs []string, stdin io.Reader) error {
+	f, compressed, err := load(path)
+	if err != nil {
+		return err
+	}
+	err = eachItem(inputs, stdin, func(item []byte) error {
+		f.Add(item)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return save(path, f, compressed)
+}
+
+func test(path string, inputs []string, invert bool, stdin io.Reader, stdout io.Writer) error {
+	f, err := loadTester(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(stdout)
+	err = eachItem(inputs, stdin, func(item []byte) error {
+		if f.Test(item) == invert {
+			return nil
+		}
+		w.Write(item)
+		return w.WriteByte('\n')
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func stats(path string, stdout io.Writer) error {
+	f, compressed, err := load(path)
+	if err != nil {
+		return err
+	}
+	format := "binary"
+	if compressed {
+		format = "compressed"
+	}
+	_, err = fmt.Fprintf(stdout, "format\t%s\nbits\t%d\nhashes\t%d\nfill\t%.4f\nitems\t%d\nfprate\t%.6g\n",
+		format, f.M(), f.K(), f.FillRatio(), f.ApproximatedSize(), f.EstimatedFPRate())
+	return err
+}
+
+// Merges the filters in paths into a new filter at out, stored in the format
+// of the first.
+func merge(out string, paths []string) error {
+	f, compressed, err := load(paths[0])
+	if err != nil {
+		return err
+	}
+	for _, path := range paths[1:] {
+		g, _, err := load(path)
+		if err != nil {
+			return err
+		}
+		if err := f.Merge(g); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return save(out, f, compressed)
+}
+
+// Builds the cascade of the items in the file revoked, excluding those in
+// valid, and writes it to out once it has been verified.
+func cascade(out, revoked, valid string, p float64, stdout io.Writer) error {
+	if p <= 0 || p >= 1 {
+		return fmt.Errorf("invalid false positive rate %g", p)
+	}
+	var include, exclude [][]byte
+	err := eachItem([]string{revoked}, nil, func(item []byte) error {
+		include = append(include, append([]byte(nil), item...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = eachItem([]string{valid}, nil, func(item []byte) error {
+		exclude = append(exclude, append([]byte(nil), item...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c, err := bloom.BuildCascade(include, exclude, p)
+	if err != nil {
+		return err
+	}
+	data, err := c.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	// Verified again as it will be read, so that an artifact which doesn't
+	// decode to the same answers is never written
+	decoded := &bloom.Cascade{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	if err := decoded.Verify(include, exclude); err != nil {
+		return err
+	}
+	if err := writeFile(out, data); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "layers\t%d\nrevoked\t%d\nvalid\t%d\nbytes\t%d\n", c.Layers(), len(include), len(exclude), len(data))
+	return err
+}
+
+func convert(in, out string, compressed bool) error {
+	f, _, err := load(in)
+	if err != nil {
+		return err
+	}
+	return save(out, f, compressed)
+}
+
+// Reads the filter stored at path, and returns whether it is compressed.
+func load(path string) (*bloom.Filter, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	f := &bloom.Filter{}
+	if err := f.UnmarshalBinary(data); err == nil {
+		return f, false, nil
+	} else if err != bloom.ErrInvalidEncoding {
+		return nil, false, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := f.UnmarshalCompressed(data); err != nil {
+		return nil, false, fmt.Errorf("%s: %v", path, err)
+	}
+	return f, true, nil
+}
+
+// Loads the filter or cascade at path.
+func loadTester(path string) (interface{ Test([]byte) bool }, error) {
+	f, _, err := load(path)
+	if err == nil {
+		return f, nil
+	}
+	data, rerr := os.ReadFile(path)
+	if rerr != nil {
+		return nil, err
+	}
+	c := &bloom.Cascade{}
+	if c.UnmarshalBinary(data) != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Writes the filter to path, as writeFile does.
+func save(path string, f *bloom.Filter, compressed bool) error {
+	var (
+		data []byte
+		err  error
+	)
+	if compressed {
+		data, err = f.MarshalCompressed()
+	} else {
+		data, err = f.MarshalBinary()
+	}
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+// Writes data to path, replacing any file there only once data has been
+// written in full.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Calls fn with each non-empty line of the files named by inputs, or of stdin
+// if there are none.
+func eachItem(inputs []string, stdin io.Reader, fn func(item []byte) error) error {
+	if len(inputs) == 0 {
+		return eachLine(stdin, fn)
+	}
+	for _, name := range inputs {
+		r, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = eachLine(r, fn)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func eachLine(r io.Reader, fn func(item []byte) error) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(s.Bytes()); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
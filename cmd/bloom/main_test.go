@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runString(t *testing.T, stdin string, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := run(args, strings.NewReader(stdin), &out, io.Discard); err != nil {
+		t.Fatalf("bloom %s: %v", strings.Join(args, " "), err)
+	}
+	return out.String()
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.bloom"), filepath.Join(dir, "b.bloom")
+	runString(t, "", "create", "-n", "1000", "-p", "0.001", a)
+	runString(t, "", "create", "-n", "1000", "-p", "0.001", b)
+	runString(t, "foo\nbar\n\n", "add", a)
+	input := filepath.Join(dir, "items")
+	if err := os.WriteFile(input, []byte("baz\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	runString(t, "", "add", b, input)
+
+	if out := runString(t, "foo\nqux\nbar\n", "test", a); out != "foo\nbar\n" {
+		t.Errorf("test printed %q", out)
+	}
+	if out := runString(t, "foo\nqux\n", "test", "-v", a); out != "qux\n" {
+		t.Errorf("test -v printed %q", out)
+	}
+
+	merged := filepath.Join(dir, "merged.bloom")
+	runString(t, "", "merge", "-o", merged, a, b)
+	if out := runString(t, "foo\nbaz\n", "test", merged); out != "foo\nbaz\n" {
+		t.Errorf("merged filter printed %q", out)
+	}
+
+	small := filepath.Join(dir, "small.bloom")
+	runString(t, "", "convert", "-format", "compressed", merged, small)
+	out := runString(t, "", "stats", small)
+	if !strings.Contains(out, "format\tcompressed\n") || !strings.Contains(out, "items\t3\n") {
+		t.Errorf("stats printed %q", out)
+	}
+	if out := runString(t, "bar\nqux\n", "test", small); out != "bar\n" {
+		t.Errorf("converted filter printed %q", out)
+	}
+}
+
+func TestCascadeCommand(t *testing.T) {
+	dir := t.TempDir()
+	revoked, valid := filepath.Join(dir, "revoked"), filepath.Join(dir, "valid")
+	var r, v strings.Builder
+	for i := 0; i < 1000; i++ {
+		if i%10 == 0 {
+			fmt.Fprintf(&r, "%x\n", i)
+		} else {
+			fmt.Fprintf(&v, "%x\n", i)
+		}
+	}
+	os.WriteFile(revoked, []byte(r.String()), 0o600)
+	os.WriteFile(valid, []byte(v.String()), 0o600)
+	out := filepath.Join(dir, "crl.cascade")
+	stats := runString(t, "", "cascade", "-p", "0.01", "-revoked", revoked, "-valid", valid, out)
+	if !strings.Contains(stats, "revoked\t100\nvalid\t900\n") {
+		t.Errorf("cascade printed %q", stats)
+	}
+	if got := runString(t, "0\n1\n14\n", "test", out); got != "0\n14\n" {
+		t.Errorf("test printed %q", got)
+	}
+
+	os.WriteFile(valid, []byte("a\n"), 0o600)
+	if err := run([]string{"cascade", "-revoked", revoked, "-valid", valid, out}, nil, io.Discard, io.Discard); err == nil {
+		t.Error("built a cascade of overlapping sets")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"frobnicate"},
+		{"create"},
+		{"merge", "a.bloom"},
+		{"test", "-x", "a.bloom"},
+	} {
+		if err := run(args, nil, io.Discard, io.Discard); err != errUsage {
+			t.Errorf("bloom %s returned %v", strings.Join(args, " "), err)
+		}
+	}
+	if err := run([]string{"create", "-hash", "md5", filepath.Join(t.TempDir(), "a")}, nil, io.Discard, io.Discard); err == nil {
+		t.Error("created a filter with an unknown hash function")
+	}
+}
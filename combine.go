@@ -0,0 +1,297 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+
+	"bytes"
+)
+
+// Whether filters with the parameters of f and o set the same bits for the
+// same items. Custom hash functions given with WithHash or WithIndexer can't
+// be compared, and are assumed to be the same, except those of WithMapHash,
+// whose seeds differ.
+func (f *filter) compatible(o *filter) bool {
+	if f.m != o.m || f.k != o.k || f.seed != o.seed || f.index != o.index || f.legacy != o.legacy {
+		return false
+	}
+	if f.hname != o.hname || !bytes.Equal(f.hpar, o.hpar) || f.hid != o.hid || (f.hf == nil) != (o.hf == nil) || (f.ix == nil) != (o.ix == nil) {
+		return false
+	}
+	if len(f.parts) != len(o.parts) {
+		return false
+	}
+	for i, p := range f.parts {
+		if o.parts[i] != p {
+			return false
+		}
+	}
+	return true
+}
+
+// Like filter.compatible. The 64-bit filters have no legacy hashing or
+// one-hashing partitions, so only the other parameters are compared.
+func (f *filter64) compatible(o *filter64) bool {
+	if f.m != o.m || f.k != o.k || f.seed != o.seed || f.index != o.index {
+		return false
+	}
+	return f.hname == o.hname && bytes.Equal(f.hpar, o.hpar) && f.hid == o.hid && (f.hf == nil) == (o.hf == nil) && (f.ix == nil) == (o.ix == nil)
+}
+
+// Merges other into f, so that f holds the items of both filters, as if they
+// had all been added to f. Returns ErrIncompatible if the filters were created
+// with different parameters, or seeds. This lets filters built separately,
+// e.g. on different machines, be combined into one.
+func (f *Filter) Merge(other *Filter) error {
+	if !f.compatible(other.filter) {
+		return ErrIncompatible
+	}
+	for i := uint32(0); i < f.m; i++ {
+		if other.b.Test(i) {
+			f.b.Set(i)
+		}
+	}
+	return nil
+}
+
+// Returns a new filter holding the items of both f and other, leaving both
+// unchanged. Returns ErrIncompatible if the filters were created with
+// different parameters, or seeds.
+func (f *Filter) Union(other *Filter) (*Filter, error) {
+	if !f.compatible(other.filter) {
+		return nil, ErrIncompatible
+	}
+	u := &Filter{
+		f.filter.clone(),
+		bitset.New32(f.m),
+	}
+	for i := uint32(0); i < f.m; i++ {
+		if f.b.Test(i) || other.b.Test(i) {
+			u.b.Set(i)
+		}
+	}
+	return u, nil
+}
+
+// Merges other into f, so that f holds the items of both filters, as if they
+// had all been added to f. Returns ErrIncompatible if the filters were created
+// with different parameters, or seeds.
+func (f *Filter64) Merge(other *Filter64) error {
+	if !f.compatible(other.filter64) {
+		return ErrIncompatible
+	}
+	for i := uint64(0); i < f.m; i++ {
+		if other.b.Test(i) {
+			f.b.Set(i)
+		}
+	}
+	return nil
+}
+
+// Returns a new filter holding the items of both f and other, leaving both
+// unchanged. Returns ErrIncompatible if the filters were created with
+// different parameters, or seeds.
+func (f *Filter64) Union(other *Filter64) (*Filter64, error) {
+	if !f.compatible(other.filter64) {
+		return nil, ErrIncompatible
+	}
+	u := &Filter64{
+		f.filter64.clone(),
+		bitset.New64(f.m),
+	}
+	for i := uint64(0); i < f.m; i++ {
+		if f.b.Test(i) || other.b.Test(i) {
+			u.b.Set(i)
+		}
+	}
+	return u, nil
+}
+
+// Returns a new filter approximating the intersection of the items of f and
+// other, leaving both unchanged, e.g. to estimate the overlap of two sets.
+// Items added to both filters are always in the result, but its false
+// positive rate is higher than that of either filter: an item added to only
+// one of them is also reported as present if its other bits happen to be set
+// in the other filter. Returns ErrIncompatible if the filters were created
+// with different parameters, or seeds.
+func (f *Filter) Intersect(other *Filter) (*Filter, error) {
+	if !f.compatible(other.filter) {
+		return nil, ErrIncompatible
+	}
+	r := &Filter{
+		f.filter.clone(),
+		bitset.New32(f.m),
+	}
+	for i := uint32(0); i < f.m; i++ {
+		if f.b.Test(i) && other.b.Test(i) {
+			r.b.Set(i)
+		}
+	}
+	return r, nil
+}
+
+// Returns a new filter approximating the intersection of the items of f and
+// other, leaving both unchanged. As with Filter.Intersect, the false positive
+// rate of the result is higher than that of either filter. Returns
+// ErrIncompatible if the filters were created with different parameters, or
+// seeds.
+func (f *Filter64) Intersect(other *Filter64) (*Filter64, error) {
+	if !f.compatible(other.filter64) {
+		return nil, ErrIncompatible
+	}
+	r := &Filter64{
+		f.filter64.clone(),
+		bitset.New64(f.m),
+	}
+	for i := uint64(0); i < f.m; i++ {
+		if f.b.Test(i) && other.b.Test(i) {
+			r.b.Set(i)
+		}
+	}
+	return r, nil
+}
+
+// Returns whether f and other have the same parameters and the same bits set,
+// e.g. to verify that a replica of a filter is consistent with the original.
+func (f *Filter) Equal(other *Filter) bool {
+	if !f.compatible(other.filter) {
+		return false
+	}
+	for i := uint32(0); i < f.m; i++ {
+		if f.b.Test(i) != other.b.Test(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether f and other have the same parameters and every bit set in
+// other is also set in f, i.e. whether f (probably) holds every item added to
+// other.
+func (f *Filter) Contains(other *Filter) bool {
+	if !f.compatible(other.filter) {
+		return false
+	}
+	for i := uint32(0); i < f.m; i++ {
+		if other.b.Test(i) && !f.b.Test(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether f and other have the same parameters and the same bits set.
+func (f *Filter64) Equal(other *Filter64) bool {
+	if !f.compatible(other.filter64) {
+		return false
+	}
+	for i := uint64(0); i < f.m; i++ {
+		if f.b.Test(i) != other.b.Test(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether f and other have the same parameters and every bit set in
+// other is also set in f.
+func (f *Filter64) Contains(other *Filter64) bool {
+	if !f.compatible(other.filter64) {
+		return false
+	}
+	for i := uint64(0); i < f.m; i++ {
+		if other.b.Test(i) && !f.b.Test(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether f and o have the same parameters, including those of each layer.
+func (f *LayeredFilter) compatibleLayers(o *LayeredFilter) bool {
+	if !f.compatible(o.filter) || len(f.tiers) != len(o.tiers) {
+		return false
+	}
+	for i, t := range f.tiers {
+		if t.m != o.tiers[i].m || t.k != o.tiers[i].k {
+			return false
+		}
+	}
+	return true
+}
+
+// Merges other into f layer by layer, OR-ing together the layers with the
+// same number, and adding layers to f if other has more. An item's count in
+// f becomes the larger of its counts in the two filters, rather than their
+// sum, so that filters counting the occurrences of items in different
+// places, e.g. on several workers, can be combined into one. Layers added to
+// f keep the LayerInfo they have in other. If f has at most max layers, e.g.
+// created with NewLayeredMax, the layers of other past them are ignored, so
+// that counts are at most max. Returns ErrIncompatible if the filters were
+// created with different parameters, or seeds.
+func (f *LayeredFilter) Merge(other *LayeredFilter) error {
+	if !f.compatibleLayers(other) {
+		return ErrIncompatible
+	}
+	for l, ob := range other.b {
+		if f.max > 0 && l == f.max {
+			break
+		}
+		if l == len(f.b) {
+			f.appendLayer()
+			f.info[l] = other.info[l]
+		}
+		b := f.b[l]
+		for i := uint32(0); i < b.Len(); i++ {
+			if ob.Test(i) {
+				b.Set(i)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns a new filter merging the layers of f and other, leaving both
+// unchanged, like Merge. Returns ErrIncompatible if the filters were created
+// with different parameters, or seeds.
+func (f *LayeredFilter) Union(other *LayeredFilter) (*LayeredFilter, error) {
+	if !f.compatibleLayers(other) {
+		return nil, ErrIncompatible
+	}
+	u := f.Clone()
+	u.Merge(other)
+	return u, nil
+}
+
+// Merges other into f layer by layer, like LayeredFilter.Merge.
+func (f *LayeredFilter64) Merge(other *LayeredFilter64) error {
+	if !f.compatible(other.filter64) {
+		return ErrIncompatible
+	}
+	for l, ob := range other.b {
+		if f.max > 0 && l == f.max {
+			break
+		}
+		if l == len(f.b) {
+			f.b = append(f.b, bitset.New64(f.m))
+			f.info = append(f.info, other.info[l])
+		}
+		b := f.b[l]
+		for i := uint64(0); i < f.m; i++ {
+			if ob.Test(i) {
+				b.Set(i)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns a new filter merging the layers of f and other, leaving both
+// unchanged, like LayeredFilter.Union.
+func (f *LayeredFilter64) Union(other *LayeredFilter64) (*LayeredFilter64, error) {
+	if !f.compatible(other.filter64) {
+		return nil, ErrIncompatible
+	}
+	u := f.Clone()
+	u.Merge(other)
+	return u, nil
+}
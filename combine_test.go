@@ -0,0 +1,198 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestUnion(t *testing.T) {
+	a, b := New(1000, 0.01), New(1000, 0.01)
+	a.Add(foo)
+	b.Add(bar)
+	u, err := a.Union(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.Test(foo) || !u.Test(bar) || u.Test(baz) {
+		t.Error("union does not hold exactly foo and bar")
+	}
+	if a.Test(bar) || b.Test(foo) {
+		t.Error("union changed its operands")
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Test(foo) || !a.Test(bar) {
+		t.Error("merged filter does not hold foo and bar")
+	}
+	for _, other := range []*Filter{
+		New(2000, 0.01),
+		NewSeeded(1000, 0.01),
+		New(1000, 0.01, WithIndexMode(DoubleHashing)),
+		New(1000, 0.01, WithLegacyHashing()),
+	} {
+		if _, err := a.Union(other); err != ErrIncompatible {
+			t.Errorf("union of incompatible filters returned %v", err)
+		}
+		if err := a.Merge(other); err != ErrIncompatible {
+			t.Errorf("merge of incompatible filters returned %v", err)
+		}
+	}
+}
+
+func TestUnion64(t *testing.T) {
+	a, b := New64(1000, 0.01), New64(1000, 0.01)
+	a.Add(foo)
+	b.Add(bar)
+	u, err := a.Union(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.Test(foo) || !u.Test(bar) || u.Test(baz) {
+		t.Error("union does not hold exactly foo and bar")
+	}
+	if err := a.Merge(New64(1000, 0.01, WithSeed(1))); err != ErrIncompatible {
+		t.Errorf("merge of incompatible filters returned %v", err)
+	}
+	for _, opts := range [][]Option{
+		{WithMapHash()},
+		{WithRegisteredHash(SipHashName, make([]byte, 16))},
+		{WithIndexMode(DoubleHashing)},
+	} {
+		if err := a.Merge(New64(1000, 0.01, opts...)); err != ErrIncompatible {
+			t.Errorf("merge of filters hashed differently returned %v", err)
+		}
+	}
+	opt := WithMapHash()
+	c := New64(1000, 0.01, opt)
+	if err := c.Merge(New64(1000, 0.01, opt)); err != nil {
+		t.Errorf("merge of filters sharing a maphash seed returned %v", err)
+	}
+	if err := c.Merge(New64(1000, 0.01, WithMapHash())); err != ErrIncompatible {
+		t.Errorf("merge of filters with different maphash seeds returned %v", err)
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	a, b := New(1000, 0.01), New(1000, 0.01)
+	for i := 0; i < 500; i++ {
+		a.Add([]byte(strconv.Itoa(i)))
+		b.Add([]byte(strconv.Itoa(i + 250)))
+	}
+	r, err := a.Intersect(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	only := 0
+	for i := 0; i < 750; i++ {
+		in := r.Test([]byte(strconv.Itoa(i)))
+		if i >= 250 && i < 500 && !in {
+			t.Fatalf("%d is in both filters but not in the intersection", i)
+		}
+		if (i < 250 || i >= 500) && in {
+			only++
+		}
+	}
+	if only > 50 {
+		t.Errorf("%d of 500 items in only one filter are in the intersection", only)
+	}
+	if _, err := a.Intersect(New(1000, 0.01, WithSeed(1))); err != ErrIncompatible {
+		t.Errorf("intersection of incompatible filters returned %v", err)
+	}
+
+	a64, b64 := New64(1000, 0.01), New64(1000, 0.01)
+	a64.Add(foo)
+	a64.Add(bar)
+	b64.Add(foo)
+	r64, err := a64.Intersect(b64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r64.Test(foo) || r64.Test(bar) {
+		t.Error("64-bit intersection does not hold exactly foo")
+	}
+}
+
+func TestEqualContains(t *testing.T) {
+	a, b := New(1000, 0.01), New(1000, 0.01)
+	if !a.Equal(b) || !a.Contains(b) {
+		t.Error("empty filters differ")
+	}
+	a.Add(foo)
+	a.Add(bar)
+	b.Add(foo)
+	if a.Equal(b) || !a.Contains(b) || b.Contains(a) {
+		t.Error("unexpected results with a superset")
+	}
+	b.Add(bar)
+	if !a.Equal(b) {
+		t.Error("filters with the same items differ")
+	}
+	if a.Equal(New(1000, 0.01, WithSeed(1))) || a.Contains(New(1000, 0.01, WithSeed(1))) {
+		t.Error("incompatible filters compare equal")
+	}
+
+	a64, b64 := New64(1000, 0.01), New64(1000, 0.01)
+	a64.Add(foo)
+	if a64.Equal(b64) || !a64.Contains(b64) || b64.Contains(a64) {
+		t.Error("unexpected results with a 64-bit superset")
+	}
+	b64.Add(foo)
+	if !a64.Equal(b64) {
+		t.Error("64-bit filters with the same items differ")
+	}
+}
+
+func TestLayeredFilterUnion(t *testing.T) {
+	a := NewLayered(1000, 0.01)
+	b := NewLayered(1000, 0.01)
+	a.Add(foo)
+	for i := 0; i < 3; i++ {
+		b.Add(foo)
+	}
+	a.Add(bar)
+	a.Add(bar)
+	b.Add(baz)
+	u, err := a.Union(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Count(foo) != 3 || u.Count(bar) != 2 || u.Count(baz) != 1 {
+		t.Errorf("counts %d, %d and %d in the union", u.Count(foo), u.Count(bar), u.Count(baz))
+	}
+	if a.Count(foo) != 1 || len(a.b) != 2 {
+		t.Error("Union changed a")
+	}
+	if _, err := a.Union(NewLayered(1000, 0.01, WithSeed(1))); err != ErrIncompatible {
+		t.Errorf("union of filters with different seeds returned %v", err)
+	}
+
+	a64 := NewLayered64(1000, 0.01)
+	b64 := NewLayered64(1000, 0.01)
+	a64.Add(foo)
+	b64.Add(foo)
+	b64.Add(foo)
+	if err := a64.Merge(b64); err != nil {
+		t.Fatal(err)
+	}
+	if a64.Count(foo) != 2 {
+		t.Errorf("count %d after merging 64-bit filters", a64.Count(foo))
+	}
+
+	// Counts are clamped to the most layers of the filter merged into
+	m := NewLayeredMax(1000, 0.01, 2)
+	m64 := NewLayeredMax64(1000, 0.01, 2)
+	b64.Add(foo)
+	if err := m.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := m64.Merge(b64); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.b) != 2 || m.Count(foo) != 2 {
+		t.Errorf("%d layers and count %d after merging into a capped filter", len(m.b), m.Count(foo))
+	}
+	if len(m64.b) != 2 || m64.Count(foo) != 2 {
+		t.Errorf("%d 64-bit layers and count %d after merging into a capped filter", len(m64.b), m64.Count(foo))
+	}
+}
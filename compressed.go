@@ -0,0 +1,123 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// The most memory a compressed filter may use while in use, relative to a
+// filter created with New
+const compressedMaxGrowth = 8
+
+// Chooses m and k for a filter which will be compressed before transmission.
+// A sparser filter (a larger m with fewer hash functions) has the same false
+// positive rate but compresses much better, so the k which minimizes the
+// compressed size m*H(fill) is used, within the limit of compressedMaxGrowth.
+func estimatesCompressed(n int64, p float64) (uint32, uint32) {
+	m, k := estimates(n, p)
+	maxM := float64(m) * compressedMaxGrowth
+	best := math.Inf(1)
+	for tk := uint32(1); tk <= k; tk++ {
+		// The fill ratio q at which q^tk == p, and the m giving that ratio
+		q := math.Pow(p, 1/float64(tk))
+		tm := -float64(tk) * float64(n) / math.Log1p(-q)
+		if tm > maxM || tm > math.MaxUint32 {
+			continue
+		}
+		h := -q*math.Log2(q) - (1-q)*math.Log2(1-q)
+		if size := tm * h; size < best {
+			best = size
+			m, k = uint32(math.Ceil(tm)), tk
+		}
+	}
+	return m, k
+}
+
+// Create a bloom filter with an expected n number of items, and an acceptable
+// false positive rate of p, e.g. 0.01, which is optimized for transmission
+// with MarshalCompressed rather than for memory use. Such a filter is larger
+// while in use, but its compressed form is smaller than that of a filter
+// created with New.
+func NewCompressed(n int, p float64, opts ...Option) *Filter {
+	m, k := estimatesCompressed(int64(n), p)
+	f := &Filter{
+		newFilter(m, k, opts...),
+		bitset.New32(m),
+	}
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
+
+// Encodes the filter into a compressed binary form, coding the gaps between
+// set bits with Golomb-Rice codes. Filters created with NewCompressed compress
+// best.
+func (f *Filter) MarshalCompressed() ([]byte, error) {
+	var set []uint32
+	for i := uint32(0); i < f.m; i++ {
+		if f.b.Test(i) {
+			set = append(set, i)
+		}
+	}
+	// The gaps are roughly geometrically distributed, for which a remainder
+	// of about log2 of the mean gap is optimal.
+	var p uint
+	if len(set) > 0 {
+		if mean := uint64(f.m) / uint64(len(set)); mean > 1 {
+			p = uint(bits.Len64(mean) - 1)
+		}
+	}
+	buf := f.appendHeader(nil, formatFilterCompressed)
+	buf = append(buf, byte(p))
+	buf = binary.AppendUvarint(buf, uint64(len(set)))
+	w := &bitWriter{buf: buf, n: 8}
+	next := uint32(0)
+	for _, i := range set {
+		w.writeRice(uint64(i-next), p)
+		next = i + 1
+	}
+	return w.buf, nil
+}
+
+// Decodes a filter previously encoded with MarshalCompressed, replacing the
+// contents of f. If f was created with a custom hash function or indexer, it
+// is kept, unless the encoding names a registered hash function, as with
+// UnmarshalBinary. Returns ErrInvalidEncoding if the filter would take more
+// memory than CountingFilter.UnmarshalBinary allows, e.g. for corrupt data.
+func (f *Filter) UnmarshalCompressed(data []byte) error {
+	d := &decoder{data: data}
+	hf, ix := f.filter.custom()
+	fl := d.filter(formatFilterCompressed, hf, ix)
+	p := uint(d.byte())
+	count := d.uvarint()
+	if d.err != nil {
+		return d.err
+	}
+	m := uint64(fl.m)
+	if p > 32 || count > m {
+		return ErrInvalidEncoding
+	}
+	// A few bytes can describe an empty filter of any size
+	if d.counterBudget(m, 0, 1, len(data)); d.err != nil {
+		return d.err
+	}
+	b := bitset.New32(fl.m)
+	r := &bitReader{buf: d.data}
+	next := uint64(0)
+	for j := uint64(0); j < count; j++ {
+		gap, ok := r.readRice(p)
+		if !ok || next+gap >= m {
+			return ErrInvalidEncoding
+		}
+		b.Set(uint32(next + gap))
+		next += gap + 1
+	}
+	if (r.pos+7)/8 != uint64(len(r.buf)) {
+		return ErrInvalidEncoding
+	}
+	f.filter = fl
+	f.b = b
+	return nil
+}
@@ -0,0 +1,62 @@
+package bloom
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestCompressedFilter(t *testing.T) {
+	n := 10000
+	f := NewCompressed(n, 0.01)
+	std := New(n, 0.01)
+	for i := 0; i < n; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+		std.Add([]byte(strconv.Itoa(i)))
+	}
+	data, err := f.MarshalCompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdData, err := std.MarshalCompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= len(stdData) {
+		t.Errorf("compressed size %d isn't smaller than standard size %d", len(data), len(stdData))
+	}
+	if max := int(std.m / 8); len(data) >= max {
+		t.Errorf("compressed size %d isn't smaller than the uncompressed %d", len(data), max)
+	}
+
+	g := &Filter{}
+	if err := g.UnmarshalCompressed(data); err != nil {
+		t.Fatal(err)
+	}
+	fp := 0
+	for i := 0; i < 2*n; i++ {
+		v := []byte(strconv.Itoa(i))
+		if f.Test(v) != g.Test(v) {
+			t.Fatalf("decoded filter differs for %s", v)
+		}
+		if i >= n && g.Test(v) {
+			fp++
+		}
+	}
+	if p := float64(fp) / float64(n); p > 0.02 {
+		t.Errorf("False positive rate too high: %f", p)
+	}
+	if err := g.UnmarshalCompressed(data[:len(data)/2]); err != ErrInvalidEncoding {
+		t.Errorf("decoding truncated data returned %v", err)
+	}
+	if err := g.UnmarshalCompressed(append(data, 0)); err != ErrInvalidEncoding {
+		t.Errorf("decoding data with a trailing byte returned %v", err)
+	}
+
+	// An empty filter of 2^32-1 bits, in a few bytes
+	buf := newFilter(math.MaxUint32, 7).appendHeader(nil, formatFilterCompressed)
+	buf = append(buf, 0, 0)
+	if err := g.UnmarshalCompressed(buf); err != ErrInvalidEncoding {
+		t.Errorf("decoding a huge filter returned %v", err)
+	}
+}
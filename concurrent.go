@@ -0,0 +1,376 @@
+package bloom
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// The width of the counters of a ConcurrentCountingFilter unless another is
+// given with WithCounterWidth
+const defaultConcurrentCounterWidth = 8
+
+// An array of fixed-width counters like counters, which are changed with
+// atomic operations on the words they are packed into.
+type atomicCounters struct {
+	width     uint
+	words     []atomic.Uint64
+	overflows atomic.Uint64
+}
+
+func newAtomicCounters(n uint64, width uint) *atomicCounters {
+	if width != 4 && width != 8 && width != 16 {
+		panic(fmt.Sprintf("Unable to create counters of %d bits. The width must be 4, 8 or 16 bits.", width))
+	}
+	per := 64 / uint64(width)
+	return &atomicCounters{
+		width: width,
+		words: make([]atomic.Uint64, (n+per-1)/per),
+	}
+}
+
+func (c *atomicCounters) max() uint64 {
+	return 1<<c.width - 1
+}
+
+// Returns the word holding counter i, and the position of the counter in it.
+func (c *atomicCounters) word(i uint64) (*atomic.Uint64, uint64) {
+	per := 64 / uint64(c.width)
+	return &c.words[i/per], i % per * uint64(c.width)
+}
+
+func (c *atomicCounters) get(i uint64) uint64 {
+	w, shift := c.word(i)
+	return w.Load() >> shift & c.max()
+}
+
+// Adds delta, 1 or -1, to counter i unless it is saturated, or would drop
+// below 0, and returns the value it had before, which the change was based
+// on.
+func (c *atomicCounters) add(i uint64, delta int) uint64 {
+	w, shift := c.word(i)
+	for {
+		old := w.Load()
+		v := old >> shift & c.max()
+		if v == c.max() {
+			if delta > 0 {
+				c.overflows.Add(1)
+			}
+			return v
+		}
+		if delta < 0 && v == 0 {
+			return v
+		}
+		n := old
+		if delta > 0 {
+			n += 1 << shift
+		} else {
+			n -= 1 << shift
+		}
+		if w.CompareAndSwap(old, n) {
+			return v
+		}
+	}
+}
+
+// A counting bloom filter which is safe for concurrent use by multiple
+// goroutines without a mutex, e.g. to track in-flight requests. Its counters
+// are fixed-width, like those of a CountingFilter created with
+// WithCounterWidth, and are incremented and decremented with atomic
+// operations. Each counter changes atomically, but an item's counters don't
+// change together: an item being added or removed may be reported as present
+// by Test before it has been added, or after it has been removed, and
+// combined operations like AddCapped and TryRemove may race with other calls
+// for the same item; TestAndAdd doesn't. A custom hash function or indexer
+// must also be safe for concurrent use.
+type ConcurrentCountingFilter struct {
+	*filter
+	c *atomicCounters
+}
+
+// Checks whether data was previously added to the filter. Returns true if
+// yes, with a false positive chance near the ratio specified upon creation
+// of the filter. The result cannot be falsely negative (unless one has
+// removed an item that wasn't actually added to the filter previously.)
+func (f *ConcurrentCountingFilter) Test(data []byte) bool {
+	return f.test(f.bits(data))
+}
+
+func (f *ConcurrentCountingFilter) test(is []uint32) bool {
+	for _, v := range is {
+		if f.c.get(uint64(v)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Adds data to the filter.
+func (f *ConcurrentCountingFilter) Add(data []byte) {
+	for _, v := range f.bits(data) {
+		f.c.add(uint64(v), 1)
+	}
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present before it was added. Data is hashed only once. Whether it was
+// present is derived from the counters' values before each was incremented,
+// so of several goroutines adding the same item at once, at least one gets
+// false.
+func (f *ConcurrentCountingFilter) TestAndAdd(data []byte) bool {
+	present := true
+	for _, v := range f.bits(data) {
+		if f.c.add(uint64(v), 1) == 0 {
+			present = false
+		}
+	}
+	return present
+}
+
+// Returns the (estimated) number of times data was added to the filter, minus
+// the number of times it was removed, like CountingFilter.Count.
+func (f *ConcurrentCountingFilter) Count(data []byte) int {
+	return f.count(f.bits(data))
+}
+
+func (f *ConcurrentCountingFilter) count(is []uint32) int {
+	min := f.c.max()
+	for _, v := range is {
+		if c := f.c.get(uint64(v)); c < min {
+			min = c
+		}
+	}
+	return int(min)
+}
+
+// Adds data to the filter, and returns its (estimated) count afterwards, like
+// CountingFilter.AddAndCount. Concurrent changes to data's counters may be
+// included.
+func (f *ConcurrentCountingFilter) AddAndCount(data []byte) int {
+	is := f.bits(data)
+	for _, v := range is {
+		f.c.add(uint64(v), 1)
+	}
+	return f.count(is)
+}
+
+// Adds data to the filter unless its (estimated) count is already max or
+// more, and returns whether it was added, like CountingFilter.AddCapped. The
+// count may change concurrently between being checked and data being added,
+// so concurrent calls may add data a few times past max.
+func (f *ConcurrentCountingFilter) AddCapped(data []byte, max int) (added bool) {
+	is := f.bits(data)
+	if f.count(is) >= max {
+		return false
+	}
+	for _, v := range is {
+		f.c.add(uint64(v), 1)
+	}
+	return true
+}
+
+// Removes data from the filter. This exact data must have been previously added
+// to the filter, or future results will be inconsistent.
+func (f *ConcurrentCountingFilter) Remove(data []byte) {
+	for _, v := range f.bits(data) {
+		f.c.add(uint64(v), -1)
+	}
+}
+
+// Removes data from the filter if it is (probably) present, as reported by
+// Test, and returns whether it was removed, like CountingFilter.TryRemove.
+func (f *ConcurrentCountingFilter) TryRemove(data []byte) (removed bool) {
+	is := f.bits(data)
+	if !f.test(is) {
+		return false
+	}
+	for _, v := range is {
+		f.c.add(uint64(v), -1)
+	}
+	return true
+}
+
+// Checks whether data is (probably) present, and if so, removes it from the
+// filter, hashing data only once. This is the same as TryRemove.
+func (f *ConcurrentCountingFilter) TestAndRemove(data []byte) bool {
+	return f.TryRemove(data)
+}
+
+// Returns the number of times a counter wasn't incremented because it was
+// saturated, like CountingFilter.OverflowCount.
+func (f *ConcurrentCountingFilter) OverflowCount() uint64 {
+	return f.c.overflows.Load()
+}
+
+// Resets the filter. Items being added concurrently may be only partly
+// removed.
+func (f *ConcurrentCountingFilter) Reset() {
+	for i := range f.c.words {
+		f.c.words[i].Store(0)
+	}
+	f.c.overflows.Store(0)
+}
+
+// Create a counting bloom filter which is safe for concurrent use, with an
+// expected n number of items, and an acceptable false positive rate of p. Its
+// counters are 8 bits wide unless another width is given with
+// WithCounterWidth. WithConservativeUpdate isn't supported.
+func NewConcurrentCounting(n int, p float64, opts ...Option) *ConcurrentCountingFilter {
+	m, k := estimates(int64(n), p)
+	width := newOptions(opts).counterWidth
+	if width == 0 {
+		width = defaultConcurrentCounterWidth
+	}
+	f := &ConcurrentCountingFilter{
+		newFilter(m, k, opts...),
+		newAtomicCounters(uint64(m), width),
+	}
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
+
+// A layered bloom filter which is safe for concurrent use by multiple
+// goroutines. Adding an item may append a layer, so, unlike the counters of a
+// ConcurrentCountingFilter, the layers are guarded by a sync.RWMutex: Test and
+// the other queries hold a read lock, and Add and the other changes hold the
+// write lock, which makes combined operations like TestAndAdd atomic. The zero
+// value is only useful to decode a filter into, e.g. with ReadFrom.
+type ConcurrentLayeredFilter struct {
+	mu sync.RWMutex
+	f  *LayeredFilter
+}
+
+// Checks whether data was previously added to the filter, and returns the
+// number of the last layer where it was added, like LayeredFilter.Test.
+func (f *ConcurrentLayeredFilter) Test(data []byte) (int, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.f.Test(data)
+}
+
+// Returns the (estimated) number of times data was added to the filter, like
+// LayeredFilter.Count.
+func (f *ConcurrentLayeredFilter) Count(data []byte) int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.f.Count(data)
+}
+
+// Checks whether data was (probably) added to the filter at least n times,
+// like LayeredFilter.TestAtLeast.
+func (f *ConcurrentLayeredFilter) TestAtLeast(data []byte, n int) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.f.TestAtLeast(data, n)
+}
+
+// Adds data to the filter. Returns the number of the layer where the data
+// was added, e.g. 1 for the first layer.
+func (f *ConcurrentLayeredFilter) Add(data []byte) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.Add(data)
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. No other change to the
+// filter happens in between.
+func (f *ConcurrentLayeredFilter) TestAndAdd(data []byte) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.TestAndAdd(data)
+}
+
+// Adds n occurrences of data to the filter, like LayeredFilter.AddN.
+func (f *ConcurrentLayeredFilter) AddN(data []byte, n int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.AddN(data, n)
+}
+
+// Removes one occurrence of data from the filter, like LayeredFilter.Remove.
+func (f *ConcurrentLayeredFilter) Remove(data []byte) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.Remove(data)
+}
+
+// Returns the number of layers the filter has allocated.
+func (f *ConcurrentLayeredFilter) Layers() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.f.Layers()
+}
+
+// Resets layer n of the filter, like LayeredFilter.ResetLayer.
+func (f *ConcurrentLayeredFilter) ResetLayer(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.f.ResetLayer(n)
+}
+
+// Drops the first layer of the filter, like LayeredFilter.RotateLayers.
+func (f *ConcurrentLayeredFilter) RotateLayers() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.RotateLayers()
+}
+
+// Resets the filter, keeping its layers, like LayeredFilter.Reset.
+func (f *ConcurrentLayeredFilter) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.f.Reset()
+}
+
+// Resets the filter, and releases every layer but the first.
+func (f *ConcurrentLayeredFilter) ResetAndShrink() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.f.ResetAndShrink()
+}
+
+// Returns a copy of the filter as a LayeredFilter, e.g. to inspect it
+// without holding up other goroutines.
+func (f *ConcurrentLayeredFilter) Snapshot() *LayeredFilter {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.f.Clone()
+}
+
+// Encodes the filter into a binary form, like LayeredFilter.MarshalBinary.
+func (f *ConcurrentLayeredFilter) MarshalBinary() ([]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.f.MarshalBinary()
+}
+
+// Decodes a filter previously encoded with MarshalBinary, by this type or by
+// LayeredFilter, replacing the contents of f.
+func (f *ConcurrentLayeredFilter) UnmarshalBinary(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		f.f = &LayeredFilter{}
+	}
+	return f.f.UnmarshalBinary(data)
+}
+
+// Writes the filter to w, like LayeredFilter.WriteTo.
+func (f *ConcurrentLayeredFilter) WriteTo(w io.Writer) (int64, error) {
+	return writeTo(w, f)
+}
+
+// Reads a filter previously written with WriteTo from r, like
+// LayeredFilter.ReadFrom.
+func (f *ConcurrentLayeredFilter) ReadFrom(r io.Reader) (int64, error) {
+	return readFrom(r, f)
+}
+
+// Create a layered bloom filter which is safe for concurrent use, with an
+// expected n number of items, and an acceptable false positive rate of p, as
+// with NewLayered.
+func NewConcurrentLayered(n int, p float64, opts ...Option) *ConcurrentLayeredFilter {
+	return &ConcurrentLayeredFilter{f: NewLayered(n, p, opts...)}
+}
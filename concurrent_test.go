@@ -0,0 +1,132 @@
+package bloom
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConcurrentCountingFilter(t *testing.T) {
+	f := NewConcurrentCounting(1000, 0.01)
+	f.Add(foo)
+	f.Add(foo)
+	if f.Count(foo) != 2 || !f.Test(foo) {
+		t.Errorf("count of foo %d, expected 2", f.Count(foo))
+	}
+	if f.TryRemove(bar) || f.TestAndRemove(bar) {
+		t.Error("bar removed, but it was never added")
+	}
+	f.Remove(foo)
+	if !f.TestAndRemove(foo) || f.Test(foo) {
+		t.Error("foo still in the filter")
+	}
+	if f.TestAndAdd(bar) || !f.TestAndAdd(bar) {
+		t.Error("unexpected TestAndAdd of bar")
+	}
+	f.Reset()
+	if f.Test(bar) {
+		t.Error("bar in the filter after Reset")
+	}
+}
+
+func TestConcurrentCountingFilterSaturates(t *testing.T) {
+	f := NewConcurrentCounting(1000, 0.01, WithCounterWidth(4))
+	for i := 0; i < 20; i++ {
+		f.Add(foo)
+	}
+	if f.Count(foo) != 15 || f.OverflowCount() == 0 {
+		t.Errorf("count of foo %d with %d overflows", f.Count(foo), f.OverflowCount())
+	}
+	for i := 0; i < 20; i++ {
+		f.Remove(foo)
+	}
+	if !f.Test(foo) {
+		t.Error("saturated counters of foo decremented")
+	}
+}
+
+func TestConcurrentCountingFilterParallel(t *testing.T) {
+	f := NewConcurrentCounting(1000, 0.01, WithCounterWidth(16))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := []byte(strconv.Itoa(i % 100))
+				f.Add(key)
+				f.Remove(key)
+				f.Add(key)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 100; i++ {
+		if c := f.Count([]byte(strconv.Itoa(i))); c < 80 {
+			t.Fatalf("count of %d is %d, expected at least 80", i, c)
+		}
+	}
+}
+
+func TestConcurrentCountingFilterTestAndAddParallel(t *testing.T) {
+	f := NewConcurrentCounting(1000, 0.001, WithCounterWidth(16))
+	var absent [100]atomic.Int32
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range absent {
+				if !f.TestAndAdd([]byte(strconv.Itoa(i))) {
+					absent[i].Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for i := range absent {
+		if absent[i].Load() == 0 {
+			t.Errorf("every TestAndAdd of %d reported it as present", i)
+		}
+		if c := f.Count([]byte(strconv.Itoa(i))); c != 8 {
+			t.Errorf("count of %d is %d, expected 8", i, c)
+		}
+	}
+}
+
+func TestConcurrentLayeredFilterParallel(t *testing.T) {
+	f := NewConcurrentLayered(1000, 0.01)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				f.Add([]byte(strconv.Itoa(i)))
+				f.Test(foo)
+			}
+		}()
+	}
+	wg.Wait()
+	if f.Layers() < 8 {
+		t.Fatalf("%d layers, expected at least 8", f.Layers())
+	}
+	for i := 0; i < 100; i++ {
+		if c := f.Count([]byte(strconv.Itoa(i))); c < 8 {
+			t.Fatalf("count of %d is %d, expected at least 8", i, c)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	g := &ConcurrentLayeredFilter{}
+	if _, err := g.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if g.Count([]byte("7")) != f.Count([]byte("7")) || g.Snapshot().Layers() != f.Layers() {
+		t.Error("filter read back differs")
+	}
+}
@@ -0,0 +1,83 @@
+package bloom
+
+import (
+	"fmt"
+)
+
+// An array of fixed-width counters, packed into 64-bit words, which saturate
+// at their largest value rather than wrapping around.
+type counters struct {
+	width     uint // the number of bits per counter: 4, 8 or 16
+	words     []uint64
+	overflows uint64 // the number of increments of saturated counters
+}
+
+func newCounters(n uint64, width uint) *counters {
+	if width != 4 && width != 8 && width != 16 {
+		panic(fmt.Sprintf("Unable to create counters of %d bits. The width must be 4, 8 or 16 bits.", width))
+	}
+	per := 64 / uint64(width)
+	return &counters{
+		width: width,
+		words: make([]uint64, (n+per-1)/per),
+	}
+}
+
+// The largest value of a counter, at which it saturates.
+func (c *counters) max() uint64 {
+	return 1<<c.width - 1
+}
+
+func (c *counters) get(i uint64) uint64 {
+	per := 64 / uint64(c.width)
+	return c.words[i/per] >> (i % per * uint64(c.width)) & c.max()
+}
+
+func (c *counters) set(i, v uint64) {
+	per := 64 / uint64(c.width)
+	shift := i % per * uint64(c.width)
+	w := &c.words[i/per]
+	*w = *w&^(c.max()<<shift) | v<<shift
+}
+
+// Increments counter i, unless it is saturated.
+func (c *counters) inc(i uint64) {
+	v := c.get(i)
+	if v == c.max() {
+		c.overflows++
+		return
+	}
+	c.set(i, v+1)
+}
+
+// Decrements counter i, unless it is 0 or saturated. A saturated counter may
+// have been incremented any number of times, so it stays saturated to avoid
+// false negatives.
+func (c *counters) dec(i uint64) {
+	if v := c.get(i); v != 0 && v != c.max() {
+		c.set(i, v-1)
+	}
+}
+
+// Halves every counter, rounding down.
+func (c *counters) halve() {
+	// Shifting a word right moves the lowest bit of each counter into the
+	// highest bit of the one below it, which the mask clears
+	mask := ^uint64(0) / c.max() * (c.max() >> 1)
+	for i, w := range c.words {
+		c.words[i] = w >> 1 & mask
+	}
+}
+
+func (c *counters) reset() {
+	for i := range c.words {
+		c.words[i] = 0
+	}
+	c.overflows = 0
+}
+
+func (c *counters) clone() *counters {
+	n := *c
+	n.words = append([]uint64(nil), c.words...)
+	return &n
+}
@@ -0,0 +1,80 @@
+package bloom
+
+import (
+	"testing"
+)
+
+func TestCounters(t *testing.T) {
+	for _, width := range []uint{4, 8, 16} {
+		c := newCounters(100, width)
+		for i := uint64(0); i < 100; i++ {
+			c.set(i, i%c.max())
+		}
+		for i := uint64(0); i < 100; i++ {
+			if v := c.get(i); v != i%c.max() {
+				t.Fatalf("%d-bit counter %d is %d, expected %d", width, i, v, i%c.max())
+			}
+		}
+		c.reset()
+		for i := uint64(0); i < c.max()+3; i++ {
+			c.inc(7)
+		}
+		if v := c.get(7); v != c.max() || c.overflows != 3 {
+			t.Errorf("%d-bit counter is %d with %d overflows after %d increments", width, v, c.overflows, c.max()+3)
+		}
+		c.dec(7)
+		if c.get(7) != c.max() {
+			t.Errorf("saturated %d-bit counter decremented", width)
+		}
+		if c.get(6) != 0 || c.get(8) != 0 {
+			t.Errorf("%d-bit counters next to counter 7 changed", width)
+		}
+	}
+}
+
+func TestCountingFilterCounterWidth(t *testing.T) {
+	f := NewCounting(1000, 0.01, WithCounterWidth(4))
+	if f.b != nil || f.c == nil {
+		t.Fatal("counting filter with WithCounterWidth uses layers")
+	}
+	for i := 0; i < 20; i++ {
+		f.Add(foo)
+	}
+	f.Add(bar)
+	if c := f.Count(foo); c != 15 {
+		t.Errorf("count of foo %d, expected saturation at 15", c)
+	}
+	if f.OverflowCount() == 0 {
+		t.Error("no overflows reported")
+	}
+	c := f.Clone()
+	for i := 0; i < 20; i++ {
+		f.Remove(foo)
+	}
+	if !f.Test(foo) {
+		t.Error("foo not in the filter after removing it from saturated counters")
+	}
+	f.Remove(bar)
+	if f.Test(bar) {
+		t.Error("bar still in the filter")
+	}
+	if !c.Test(bar) {
+		t.Error("Remove changed the clone")
+	}
+	f.Reset()
+	if f.Test(foo) || f.OverflowCount() != 0 {
+		t.Error("filter not empty after Reset")
+	}
+
+	f64 := NewCounting64(1000, 0.01, WithCounterWidth(8))
+	f64.Add(foo)
+	f64.Add(foo)
+	if c := f64.Count(foo); c != 2 || f64.OverflowCount() != 0 {
+		t.Errorf("count of foo %d, expected 2", c)
+	}
+	f64.Remove(foo)
+	f64.Remove(foo)
+	if f64.Test(foo) {
+		t.Error("foo still in the 64-bit filter")
+	}
+}
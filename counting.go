@@ -0,0 +1,347 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+)
+
+// Returns counter i of the filter, whichever way its counters are stored.
+func (f *CountingFilter) counter(i uint32) uint64 {
+	if f.c != nil {
+		return f.c.get(uint64(i))
+	}
+	n := uint64(0)
+	for n < uint64(len(f.b)) && f.b[n].Test(i) {
+		n++
+	}
+	return n
+}
+
+// Sets counter i of the filter to v, adding layers as needed, or saturating a
+// fixed-width counter if v is too large for it.
+func (f *CountingFilter) setCounter(i uint32, v uint64) {
+	if f.c != nil {
+		if v > f.c.max() {
+			v = f.c.max()
+			f.c.overflows++
+		}
+		f.c.set(uint64(i), v)
+		return
+	}
+	for uint64(len(f.b)) < v {
+		f.b = append(f.b, bitset.New32(f.m))
+	}
+	for l, b := range f.b {
+		if uint64(l) < v {
+			b.Set(i)
+		} else {
+			b.Clear(i)
+		}
+	}
+}
+
+func (f *CountingFilter) compatible(o *CountingFilter) bool {
+	if (f.c == nil) != (o.c == nil) || (f.c != nil && f.c.width != o.c.width) {
+		return false
+	}
+	return f.filter.compatible(o.filter)
+}
+
+// Merges other into f by adding the counters of other to those of f, so that
+// f holds the items of both filters, as many times as they were added to
+// either, e.g. to aggregate the filters of several shards. Returns
+// ErrIncompatible if the filters were created with different parameters,
+// seeds or counter widths.
+func (f *CountingFilter) Merge(other *CountingFilter) error {
+	if !f.compatible(other) {
+		return ErrIncompatible
+	}
+	for i := uint32(0); i < f.m; i++ {
+		if c := other.counter(i); c > 0 {
+			f.setCounter(i, f.counter(i)+c)
+		}
+	}
+	return nil
+}
+
+// Returns a new filter whose counters are the sums of those of f and other,
+// leaving both unchanged. Returns ErrIncompatible if the filters were created
+// with different parameters, seeds or counter widths.
+func (f *CountingFilter) Union(other *CountingFilter) (*CountingFilter, error) {
+	if !f.compatible(other) {
+		return nil, ErrIncompatible
+	}
+	u := f.Clone()
+	u.Merge(other)
+	return u, nil
+}
+
+// Subtracts the counters of other from those of f, stopping at zero, e.g. to
+// remove the items of a retired shard from a filter it was merged into. The
+// items of other must have been added to f, or future results will be
+// inconsistent, as with Remove. Saturated counters of f are left unchanged.
+// Returns ErrIncompatible if the filters were created with different
+// parameters, seeds or counter widths.
+func (f *CountingFilter) Subtract(other *CountingFilter) error {
+	if !f.compatible(other) {
+		return ErrIncompatible
+	}
+	for i := uint32(0); i < f.m; i++ {
+		o := other.counter(i)
+		if o == 0 {
+			continue
+		}
+		c := f.counter(i)
+		switch {
+		case f.c != nil && c == f.c.max():
+		case o >= c:
+			f.setCounter(i, 0)
+		default:
+			f.setCounter(i, c-o)
+		}
+	}
+	return nil
+}
+
+// Halves every counter of the filter, rounding down, so that the counts of
+// items added long ago fade away, e.g. to approximate their recent popularity
+// for a cache admission policy like TinyLFU. Items added only once are
+// removed, and saturated counters can be incremented again.
+func (f *CountingFilter) Decay() {
+	if f.c != nil {
+		f.c.halve()
+		return
+	}
+	for i := uint32(0); i < f.m; i++ {
+		if c := f.counter(i); c > 0 {
+			f.setCounter(i, c/2)
+		}
+	}
+}
+
+// Returns the number of layers of bitsets the filter has allocated, or 0 if
+// it uses fixed-width counters. Many layers mean that a few items are added
+// far more often than the others; see WithCounterWidth.
+func (f *CountingFilter) Layers() int {
+	return len(f.b)
+}
+
+// Returns the largest counter of the filter, which bounds the count of every
+// item in it. For a filter using fixed-width counters, this is the maximum
+// value of a counter if any of them is saturated.
+func (f *CountingFilter) MaxCount() uint64 {
+	max := uint64(0)
+	if f.c != nil {
+		for i := uint32(0); i < f.m; i++ {
+			if c := f.c.get(uint64(i)); c > max {
+				max = c
+			}
+		}
+		return max
+	}
+	for l := len(f.b) - 1; l >= 0; l-- {
+		for i := uint32(0); i < f.m; i++ {
+			if f.b[l].Test(i) {
+				return uint64(l) + 1
+			}
+		}
+	}
+	return 0
+}
+
+// Returns the (approximate) number of bytes of memory used by the counters of
+// the filter.
+func (f *CountingFilter) SizeInBytes() uint64 {
+	if f.c != nil {
+		return uint64(len(f.c.words)) * 8
+	}
+	return uint64(len(f.b)) * ((uint64(f.m) + 31) / 32 * 4)
+}
+
+// Releases the empty layers at the top of the filter, e.g. those allocated
+// for items which have since been removed, returning the memory they use,
+// and returns the number released. The first layer is always kept. A filter
+// using fixed-width counters doesn't have layers, so nothing is released.
+func (f *CountingFilter) Compact() int {
+	n := len(f.b)
+	for n > 1 && emptyBits32(f.b[n-1], f.m) {
+		n--
+	}
+	released := len(f.b) - n
+	if released > 0 {
+		f.b = append([]*bitset.Bitset32(nil), f.b[:n]...)
+	}
+	return released
+}
+
+func emptyBits32(b *bitset.Bitset32, m uint32) bool {
+	for i := uint32(0); i < m; i++ {
+		if b.Test(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns counter i of the filter, whichever way its counters are stored.
+func (f *CountingFilter64) counter(i uint64) uint64 {
+	if f.c != nil {
+		return f.c.get(i)
+	}
+	n := uint64(0)
+	for n < uint64(len(f.b)) && f.b[n].Test(i) {
+		n++
+	}
+	return n
+}
+
+// Sets counter i of the filter to v, adding layers as needed, or saturating a
+// fixed-width counter if v is too large for it.
+func (f *CountingFilter64) setCounter(i, v uint64) {
+	if f.c != nil {
+		if v > f.c.max() {
+			v = f.c.max()
+			f.c.overflows++
+		}
+		f.c.set(i, v)
+		return
+	}
+	for uint64(len(f.b)) < v {
+		f.b = append(f.b, bitset.New64(f.m))
+	}
+	for l, b := range f.b {
+		if uint64(l) < v {
+			b.Set(i)
+		} else {
+			b.Clear(i)
+		}
+	}
+}
+
+func (f *CountingFilter64) compatible(o *CountingFilter64) bool {
+	if (f.c == nil) != (o.c == nil) || (f.c != nil && f.c.width != o.c.width) {
+		return false
+	}
+	return f.filter64.compatible(o.filter64)
+}
+
+// Merges other into f by adding the counters of other to those of f, like
+// CountingFilter.Merge.
+func (f *CountingFilter64) Merge(other *CountingFilter64) error {
+	if !f.compatible(other) {
+		return ErrIncompatible
+	}
+	for i := uint64(0); i < f.m; i++ {
+		if c := other.counter(i); c > 0 {
+			f.setCounter(i, f.counter(i)+c)
+		}
+	}
+	return nil
+}
+
+// Returns a new filter whose counters are the sums of those of f and other,
+// leaving both unchanged, like CountingFilter.Union.
+func (f *CountingFilter64) Union(other *CountingFilter64) (*CountingFilter64, error) {
+	if !f.compatible(other) {
+		return nil, ErrIncompatible
+	}
+	u := f.Clone()
+	u.Merge(other)
+	return u, nil
+}
+
+// Subtracts the counters of other from those of f, stopping at zero, like
+// CountingFilter.Subtract.
+func (f *CountingFilter64) Subtract(other *CountingFilter64) error {
+	if !f.compatible(other) {
+		return ErrIncompatible
+	}
+	for i := uint64(0); i < f.m; i++ {
+		o := other.counter(i)
+		if o == 0 {
+			continue
+		}
+		c := f.counter(i)
+		switch {
+		case f.c != nil && c == f.c.max():
+		case o >= c:
+			f.setCounter(i, 0)
+		default:
+			f.setCounter(i, c-o)
+		}
+	}
+	return nil
+}
+
+// Halves every counter of the filter, rounding down, like
+// CountingFilter.Decay.
+func (f *CountingFilter64) Decay() {
+	if f.c != nil {
+		f.c.halve()
+		return
+	}
+	for i := uint64(0); i < f.m; i++ {
+		if c := f.counter(i); c > 0 {
+			f.setCounter(i, c/2)
+		}
+	}
+}
+
+// Returns the number of layers of bitsets the filter has allocated, or 0 if
+// it uses fixed-width counters.
+func (f *CountingFilter64) Layers() int {
+	return len(f.b)
+}
+
+// Returns the largest counter of the filter, which bounds the count of every
+// item in it, like CountingFilter.MaxCount.
+func (f *CountingFilter64) MaxCount() uint64 {
+	max := uint64(0)
+	if f.c != nil {
+		for i := uint64(0); i < f.m; i++ {
+			if c := f.c.get(i); c > max {
+				max = c
+			}
+		}
+		return max
+	}
+	for l := len(f.b) - 1; l >= 0; l-- {
+		for i := uint64(0); i < f.m; i++ {
+			if f.b[l].Test(i) {
+				return uint64(l) + 1
+			}
+		}
+	}
+	return 0
+}
+
+// Returns the (approximate) number of bytes of memory used by the counters of
+// the filter.
+func (f *CountingFilter64) SizeInBytes() uint64 {
+	if f.c != nil {
+		return uint64(len(f.c.words)) * 8
+	}
+	return uint64(len(f.b)) * ((f.m + 63) / 64 * 8)
+}
+
+// Releases the empty layers at the top of the filter, returning the number
+// released, like CountingFilter.Compact.
+func (f *CountingFilter64) Compact() int {
+	n := len(f.b)
+	for n > 1 && emptyBits64(f.b[n-1], f.m) {
+		n--
+	}
+	released := len(f.b) - n
+	if released > 0 {
+		f.b = append([]*bitset.Bitset64(nil), f.b[:n]...)
+	}
+	return released
+}
+
+func emptyBits64(b *bitset.Bitset64, m uint64) bool {
+	for i := uint64(0); i < m; i++ {
+		if b.Test(i) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,236 @@
+package bloom
+
+import (
+	"testing"
+)
+
+func TestCountingFilterUnionSubtract(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCounterWidth(4)}} {
+		a := NewCounting(1000, 0.01, opts...)
+		b := NewCounting(1000, 0.01, opts...)
+		a.Add(foo)
+		a.Add(bar)
+		b.Add(foo)
+		b.Add(baz)
+		u, err := a.Union(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Count(foo) != 2 || u.Count(bar) != 1 || u.Count(baz) != 1 {
+			t.Errorf("counts %d, %d and %d in the union", u.Count(foo), u.Count(bar), u.Count(baz))
+		}
+		if a.Count(foo) != 1 || a.Test(baz) {
+			t.Error("Union changed a")
+		}
+		if err := u.Subtract(b); err != nil {
+			t.Fatal(err)
+		}
+		if u.Count(foo) != 1 || u.Count(bar) != 1 || u.Test(baz) {
+			t.Errorf("counts %d, %d and %d after subtracting b", u.Count(foo), u.Count(bar), u.Count(baz))
+		}
+		if err := u.Subtract(b); err != nil || u.Test(foo) || !u.Test(bar) {
+			t.Error("subtracting b twice didn't stop at zero")
+		}
+	}
+	if err := NewCounting(1000, 0.01).Merge(NewCounting(1000, 0.01, WithCounterWidth(4))); err != ErrIncompatible {
+		t.Errorf("merging filters with different counters returned %v", err)
+	}
+	if _, err := NewCounting(1000, 0.01).Union(NewCounting(1000, 0.01, WithSeed(1))); err != ErrIncompatible {
+		t.Errorf("union of filters with different seeds returned %v", err)
+	}
+}
+
+func TestCountingFilterMergeSaturates(t *testing.T) {
+	a := NewCounting(1000, 0.01, WithCounterWidth(4))
+	for i := 0; i < 10; i++ {
+		a.Add(foo)
+	}
+	if err := a.Merge(a.Clone()); err != nil {
+		t.Fatal(err)
+	}
+	if a.Count(foo) != 15 || a.OverflowCount() == 0 {
+		t.Errorf("count %d with %d overflows after merging", a.Count(foo), a.OverflowCount())
+	}
+}
+
+func TestCountingFilter64UnionSubtract(t *testing.T) {
+	a := NewCounting64(1000, 0.01)
+	b := NewCounting64(1000, 0.01)
+	a.Add(foo)
+	b.Add(foo)
+	b.Add(bar)
+	u, err := a.Union(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Count(foo) != 2 || u.Count(bar) != 1 {
+		t.Errorf("counts %d and %d in the union", u.Count(foo), u.Count(bar))
+	}
+	if err := u.Subtract(a); err != nil || u.Count(foo) != 1 || !u.Test(bar) {
+		t.Error("unexpected filter after subtracting a")
+	}
+}
+
+func TestCountingFilterDecay(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCounterWidth(4)}, {WithCounterWidth(8)}, {WithCounterWidth(16)}} {
+		f := NewCounting(1000, 0.01, opts...)
+		for i := 0; i < 7; i++ {
+			f.Add(foo)
+		}
+		f.Add(bar)
+		f.Decay()
+		if f.Count(foo) != 3 || f.Test(bar) {
+			t.Errorf("counts %d and %d after decaying", f.Count(foo), f.Count(bar))
+		}
+		f.Decay()
+		f.Decay()
+		if f.Test(foo) {
+			t.Errorf("count of foo %d after decaying three times", f.Count(foo))
+		}
+	}
+	f := NewCounting64(1000, 0.01, WithCounterWidth(4))
+	for i := 0; i < 20; i++ {
+		f.Add(foo)
+	}
+	f.Decay()
+	if f.Count(foo) != 7 {
+		t.Errorf("count of saturated foo %d after decaying, expected 7", f.Count(foo))
+	}
+}
+
+func TestCountingFilterConservativeUpdate(t *testing.T) {
+	for _, width := range []uint{0, 4} {
+		f := NewCounting(100, 0.01, WithConservativeUpdate(), WithCounterWidth(width))
+		g := NewCounting(100, 0.01, WithCounterWidth(width))
+		for i := 0; i < 300; i++ {
+			f.Add([]byte{byte(i)})
+			g.Add([]byte{byte(i)})
+		}
+		over, overG := 0, 0
+		for i := 0; i < 256; i++ {
+			want := 1
+			if i < 300-256 {
+				want = 2
+			}
+			c, cg := f.Count([]byte{byte(i)}), g.Count([]byte{byte(i)})
+			if c < want {
+				t.Fatalf("count of %d is %d, expected at least %d", i, c, want)
+			}
+			over += c - want
+			overG += cg - want
+		}
+		if over > overG {
+			t.Errorf("conservative update overestimated counts by %d, more than %d", over, overG)
+		}
+		if c := f.Clone(); !c.conservative {
+			t.Error("clone doesn't use conservative update")
+		}
+	}
+}
+
+func TestCountingFilterMetrics(t *testing.T) {
+	f := NewCounting(1000, 0.01)
+	if f.Layers() != 1 || f.MaxCount() != 0 {
+		t.Errorf("empty filter has %d layers and a maximum count of %d", f.Layers(), f.MaxCount())
+	}
+	size := f.SizeInBytes()
+	if size < uint64(f.m)/8 {
+		t.Errorf("filter of %d bits uses %d bytes", f.m, size)
+	}
+	for i := 0; i < 5; i++ {
+		f.Add(foo)
+	}
+	if f.Layers() != 5 || f.MaxCount() != 5 || f.SizeInBytes() != 5*size {
+		t.Errorf("%d layers, a maximum count of %d and %d bytes after adding foo 5 times", f.Layers(), f.MaxCount(), f.SizeInBytes())
+	}
+
+	c := NewCounting(1000, 0.01, WithCounterWidth(4))
+	for i := 0; i < 20; i++ {
+		c.Add(foo)
+	}
+	if c.Layers() != 0 || c.MaxCount() != 15 || c.SizeInBytes() < uint64(c.m)/2 {
+		t.Errorf("%d layers, a maximum count of %d and %d bytes with 4-bit counters", c.Layers(), c.MaxCount(), c.SizeInBytes())
+	}
+
+	f64 := NewCounting64(1000, 0.01)
+	f64.Add(foo)
+	f64.Add(foo)
+	if f64.Layers() != 2 || f64.MaxCount() != 2 || f64.SizeInBytes() < f64.m/4 {
+		t.Errorf("%d layers, a maximum count of %d and %d bytes in the 64-bit filter", f64.Layers(), f64.MaxCount(), f64.SizeInBytes())
+	}
+}
+
+func TestCountingFilterCompact(t *testing.T) {
+	f := NewCounting(1000, 0.01)
+	for i := 0; i < 5; i++ {
+		f.Add(foo)
+	}
+	f.Add(bar)
+	for i := 0; i < 3; i++ {
+		f.Remove(foo)
+	}
+	layers := f.Layers()
+	if n := f.Compact(); n != layers-2 || f.Layers() != 2 {
+		t.Errorf("%d of %d layers released, leaving %d", n, layers, f.Layers())
+	}
+	if f.Count(foo) != 2 || f.Count(bar) != 1 {
+		t.Errorf("counts %d and %d after compacting", f.Count(foo), f.Count(bar))
+	}
+	f.Reset()
+	if n := f.Compact(); n != 1 || f.Layers() != 1 {
+		t.Errorf("%d layers released from an empty filter, leaving %d", n, f.Layers())
+	}
+	if n := NewCounting(1000, 0.01, WithCounterWidth(4)).Compact(); n != 0 {
+		t.Errorf("%d layers released from a filter using counters", n)
+	}
+
+	f64 := NewCounting64(1000, 0.01)
+	f64.Add(foo)
+	f64.Add(foo)
+	f64.Remove(foo)
+	if n := f64.Compact(); n != 1 || f64.Layers() != 1 || !f64.Test(foo) {
+		t.Errorf("%d layers released from the 64-bit filter", n)
+	}
+}
+
+func TestAddAndCount(t *testing.T) {
+	c := NewCounting(1000, 0.01)
+	cw := NewCounting(1000, 0.01, WithCounterWidth(4), WithConservativeUpdate())
+	c64 := NewCounting64(1000, 0.01)
+	cc := NewConcurrentCounting(1000, 0.01)
+	for i := 1; i <= 5; i++ {
+		if n := c.AddAndCount(foo); n != i {
+			t.Errorf("count %d after adding foo %d times", n, i)
+		}
+		if n := cw.AddAndCount(foo); n != i {
+			t.Errorf("count %d after adding foo %d times with 4-bit counters", n, i)
+		}
+		if n := c64.AddAndCount(foo); n != i {
+			t.Errorf("count %d after adding foo %d times to the 64-bit filter", n, i)
+		}
+		if n := cc.AddAndCount(foo); n != i {
+			t.Errorf("count %d after adding foo %d times to the concurrent filter", n, i)
+		}
+	}
+}
+
+func TestAddCapped(t *testing.T) {
+	c := NewCounting(1000, 0.01)
+	c64 := NewCounting64(1000, 0.01, WithCounterWidth(4))
+	cc := NewConcurrentCounting(1000, 0.01)
+	for i := 0; i < 10; i++ {
+		want := i < 3
+		if added := c.AddCapped(foo, 3); added != want {
+			t.Errorf("AddCapped %d returned %v", i, added)
+		}
+		if added := c64.AddCapped(foo, 3); added != want {
+			t.Errorf("64-bit AddCapped %d returned %v", i, added)
+		}
+		if added := cc.AddCapped(foo, 3); added != want {
+			t.Errorf("concurrent AddCapped %d returned %v", i, added)
+		}
+	}
+	if c.Count(foo) != 3 || c.Layers() != 3 || c64.Count(foo) != 3 || cc.Count(foo) != 3 {
+		t.Errorf("counts %d, %d and %d after capped adds", c.Count(foo), c64.Count(foo), cc.Count(foo))
+	}
+}
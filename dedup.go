@@ -0,0 +1,135 @@
+package bloom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+)
+
+type dedupWriter struct {
+	w    io.Writer
+	f    TestAndAdder
+	line []byte // an incomplete line from previous writes
+}
+
+func (d *dedupWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			d.line = append(d.line, p...)
+			break
+		}
+		line := p[:i+1]
+		if len(d.line) > 0 {
+			d.line = append(d.line, line...)
+			line = d.line
+		}
+		if err := d.forward(line); err != nil {
+			return n - len(p), err
+		}
+		d.line = d.line[:0]
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// Writes line, including its newline if it has one, unless it was written
+// before.
+func (d *dedupWriter) forward(line []byte) error {
+	if d.f.TestAndAdd(bytes.TrimSuffix(line, []byte{'\n'})) {
+		return nil
+	}
+	_, err := d.w.Write(line)
+	return err
+}
+
+// Forwards a final line which didn't end with a newline.
+func (d *dedupWriter) Close() error {
+	if len(d.line) == 0 {
+		return nil
+	}
+	err := d.forward(d.line)
+	d.line = d.line[:0]
+	return err
+}
+
+// Returns a writer which forwards the lines written to it to w, except for
+// lines which f reports were seen before, e.g. to drop duplicate records from
+// a log. Every forwarded line is added to f. Lines are compared without their
+// trailing newline, and may be written in any number of pieces. Since f may
+// report false positives, an occasional line that wasn't seen before is also
+// dropped. Close forwards a final line that doesn't end with a newline; it
+// doesn't close w.
+func DedupWriter(w io.Writer, f TestAndAdder) io.WriteCloser {
+	return &dedupWriter{w: w, f: f}
+}
+
+// Reads the tokens of a bufio.Scanner, skipping those which a filter reports
+// were seen before. Returned by UniqueScanner.
+type UniqueLineScanner struct {
+	*bufio.Scanner
+	f TestAndAdder
+}
+
+// Advances to the next token which f reports wasn't seen before, and adds it
+// to f. Returns false at the end of the input, or an error, like
+// bufio.Scanner.Scan.
+func (s *UniqueLineScanner) Scan() bool {
+	for s.Scanner.Scan() {
+		if !s.f.TestAndAdd(s.Bytes()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a scanner reading the lines of r, without their newlines, as
+// bufio.Scanner does, except for lines which f reports were seen before, e.g.
+// to print the unique lines of a file too large to sort, in memory bounded by
+// the size of f:
+//
+//	s := bloom.UniqueScanner(os.Stdin, bloom.New(1e9, 0.0001))
+//	for s.Scan() {
+//		fmt.Println(s.Text())
+//	}
+//
+// Every returned line is added to f. Since f may report false positives, an
+// occasional line that wasn't seen before is also skipped. The embedded
+// bufio.Scanner's Split and Buffer can be used to change how r is split.
+func UniqueScanner(r io.Reader, f TestAndAdder) *UniqueLineScanner {
+	return &UniqueLineScanner{bufio.NewScanner(r), f}
+}
+
+// Returns a channel which receives the values received from in, except for
+// those whose key, as returned by keyFn, f reports was seen before. Every
+// forwarded value's key is added to f. Since f may report false positives, an
+// occasional value that wasn't seen before is also dropped. The channel is
+// closed when in is closed or ctx is done. f must not be used by anything
+// else until then, unless it is safe for concurrent use.
+func Dedup[T any](ctx context.Context, in <-chan T, keyFn func(T) []byte, f TestAndAdder) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				if f.TestAndAdd(keyFn(v)) {
+					continue
+				}
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,75 @@
+package bloom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDedupWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := DedupWriter(&out, New(1000, 0.01))
+	for _, s := range []string{"foo\nbar\n", "fo", "o\nbaz", "\nbar\nqux"} {
+		if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write returned %d, %v", n, err)
+		}
+	}
+	if got := out.String(); got != "foo\nbar\nbaz\n" {
+		t.Errorf("forwarded %q before Close", got)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "foo\nbar\nbaz\nqux" {
+		t.Errorf("forwarded %q", got)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	in := make(chan string)
+	go func() {
+		for _, s := range []string{"foo", "bar", "foo", "baz", "bar"} {
+			in <- s
+		}
+		close(in)
+	}()
+	var got []string
+	for s := range Dedup(context.Background(), in, func(s string) []byte { return []byte(s) }, NewLayered(1000, 0.01)) {
+		got = append(got, s)
+	}
+	if len(got) != 3 || got[0] != "foo" || got[1] != "bar" || got[2] != "baz" {
+		t.Errorf("received %v", got)
+	}
+}
+
+func TestDedupCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := Dedup(ctx, make(chan int), func(v int) []byte { return nil }, New(1000, 0.01))
+	cancel()
+	if _, ok := <-out; ok {
+		t.Error("received a value after cancelling")
+	}
+}
+
+func TestUniqueScanner(t *testing.T) {
+	s := UniqueScanner(strings.NewReader("foo\nbar\nfoo\n\nbaz\n\nbar"), New(1000, 0.01))
+	var got []string
+	for s.Scan() {
+		got = append(got, s.Text())
+	}
+	if s.Err() != nil || strings.Join(got, ",") != "foo,bar,,baz" {
+		t.Errorf("scanned %q: %v", got, s.Err())
+	}
+
+	s = UniqueScanner(strings.NewReader("a b a c"), New(1000, 0.01))
+	s.Split(bufio.ScanWords)
+	got = got[:0]
+	for s.Scan() {
+		got = append(got, s.Text())
+	}
+	if strings.Join(got, ",") != "a,b,c" {
+		t.Errorf("scanned words %q", got)
+	}
+}
@@ -0,0 +1,159 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Stores the snapshots of a Deduplicator, e.g. in a file, a database row, or
+// a compacted topic next to the consumer's offsets.
+type SnapshotStore interface {
+	// Saves a snapshot of the state of a Deduplicator which has processed
+	// every message up to and including offset, replacing any earlier one.
+	Save(offset int64, snapshot []byte) error
+
+	// Returns the last snapshot saved and its offset, or a nil snapshot if
+	// none was saved.
+	Load() (offset int64, snapshot []byte, err error)
+}
+
+// A SnapshotStore keeping the last snapshot in a file. Each snapshot is
+// written to a temporary file which then replaces the last one, so a crash
+// while saving leaves the last snapshot intact.
+type FileSnapshotStore struct {
+	Path string
+}
+
+func (s FileSnapshotStore) Save(offset int64, snapshot []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	buf := binary.LittleEndian.AppendUint64(make([]byte, 0, 8+len(snapshot)), uint64(offset))
+	_, err = tmp.Write(append(buf, snapshot...))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+func (s FileSnapshotStore) Load() (int64, []byte, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return -1, nil, nil
+	}
+	if err != nil {
+		return -1, nil, err
+	}
+	if len(data) < 8 {
+		return -1, nil, ErrInvalidEncoding
+	}
+	return int64(binary.LittleEndian.Uint64(data)), data[8:], nil
+}
+
+// Suppresses duplicate messages for an at-least-once consumer of a message
+// queue, e.g. a Kafka partition, remembering the keys of the messages it
+// processed in a RotatingFilter, and saving snapshots of the filter tagged
+// with the offset of the last message processed. After a crash, the
+// consumer restores the snapshot by creating a new Deduplicator, and resumes
+// consuming after Offset, so that every message is either in the restored
+// filter or consumed again. Messages processed after the last snapshot are
+// processed again, as with any at-least-once consumer, unless the snapshot is
+// saved whenever the consumer commits its offset.
+//
+// A Deduplicator handles the messages of one partition, in order. Its methods
+// are safe for concurrent use, e.g. to call Snapshot from a timer, but if
+// messages with the same key are processed concurrently, both may be
+// processed.
+type Deduplicator struct {
+	mu      sync.Mutex
+	f       *RotatingFilter
+	store   SnapshotStore
+	every   int
+	offset  int64 // of the last message processed
+	unsaved int   // the number of messages processed since the last snapshot
+}
+
+// Create a deduplicator remembering keys in f, and saving a snapshot to store
+// after every every messages processed, or only when Snapshot is called if
+// every is 0. If store has a snapshot, it replaces the contents of f.
+func NewDeduplicator(f *RotatingFilter, store SnapshotStore, every int) (*Deduplicator, error) {
+	offset, snapshot, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	d := &Deduplicator{f: f, store: store, every: every, offset: -1}
+	if snapshot != nil {
+		if err := f.UnmarshalBinary(snapshot); err != nil {
+			return nil, err
+		}
+		d.offset = offset
+	}
+	return d, nil
+}
+
+// Returns the offset of the last message processed, as of the last snapshot
+// when the deduplicator was created, or -1 if no message was processed.
+func (d *Deduplicator) Offset() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.offset
+}
+
+// Processes the message with key at offset by calling process, unless key
+// was (probably) processed before, in which case it returns true without
+// calling process. The key is only remembered if process returns nil, so a
+// message which failed can be processed again when it is redelivered. If a
+// snapshot is due, it is saved, and an error saving it is returned.
+func (d *Deduplicator) Process(key []byte, offset int64, process func() error) (duplicate bool, err error) {
+	d.mu.Lock()
+	dup := d.f.Test(key)
+	d.mu.Unlock()
+	if dup {
+		return true, nil
+	}
+	if err := process(); err != nil {
+		return false, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.f.Add(key)
+	if offset > d.offset {
+		d.offset = offset
+	}
+	d.unsaved++
+	if d.every > 0 && d.unsaved >= d.every {
+		return false, d.save()
+	}
+	return false, nil
+}
+
+// Saves a snapshot of the messages processed so far, e.g. before the
+// consumer commits its offset.
+func (d *Deduplicator) Snapshot() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.save()
+}
+
+func (d *Deduplicator) save() error {
+	snapshot, err := d.f.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := d.store.Save(d.offset, snapshot); err != nil {
+		return err
+	}
+	d.unsaved = 0
+	return nil
+}
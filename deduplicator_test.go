@@ -0,0 +1,62 @@
+package bloom
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeduplicator(t *testing.T) {
+	store := FileSnapshotStore{filepath.Join(t.TempDir(), "dedup")}
+	d, err := NewDeduplicator(NewRotating(1000, 0.001, time.Hour, 4), store, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Offset() != -1 {
+		t.Fatalf("offset %d before processing anything", d.Offset())
+	}
+	var processed []string
+	process := func(key string, offset int64) (bool, error) {
+		return d.Process([]byte(key), offset, func() error {
+			processed = append(processed, key)
+			return nil
+		})
+	}
+	errFailed := errors.New("failed")
+	for i, key := range []string{"a", "b", "a", "c"} {
+		if _, err := process(key, int64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Process([]byte("d"), 4, func() error { return errFailed }); err != errFailed {
+		t.Errorf("failed processing returned %v", err)
+	}
+	if len(processed) != 3 || d.Offset() != 3 {
+		t.Errorf("processed %v up to offset %d", processed, d.Offset())
+	}
+
+	// Restart from the snapshot taken after b, and consume again after it
+	d, err = NewDeduplicator(NewRotating(1000, 0.001, time.Hour, 4), store, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Offset() != 1 {
+		t.Fatalf("restored offset %d", d.Offset())
+	}
+	processed = processed[:0]
+	for i, key := range []string{"a", "c", "d", "c", "e"} {
+		if _, err := process(key, int64(2+i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(processed) != 3 || processed[0] != "c" || processed[1] != "d" || processed[2] != "e" {
+		t.Errorf("processed %v after restoring", processed)
+	}
+	if err := d.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if offset, _, _ := store.Load(); offset != 6 {
+		t.Errorf("snapshot at offset %d", offset)
+	}
+}
@@ -0,0 +1,96 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+)
+
+// A deletable bloom filter using the 128-bit FNV-1 hash function, like
+// Filter. The bits of the filter are divided into regions, and a small bitmap
+// records in which regions two items have set the same bit. Bits in
+// collision-free regions can safely be cleared, so most items can be removed
+// without the memory cost of counters.
+type DeletableFilter struct {
+	*filter
+	b          *bitset.Bitset32
+	collisions *bitset.Bitset32
+	regions    uint32
+}
+
+func (f *DeletableFilter) region(i uint32) uint32 {
+	return uint32(uint64(i) * uint64(f.regions) / uint64(f.m))
+}
+
+// Check whether data was previously added to the filter. Returns true if
+// yes, with a false positive chance near the ratio specified upon creation
+// of the filter. The result cannot be falsely negative.
+func (f *DeletableFilter) Test(data []byte) bool {
+	return testBits32(f.b, f.bits(data))
+}
+
+// Add data to the filter.
+func (f *DeletableFilter) Add(data []byte) {
+	f.TestAndAdd(data)
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. Data is hashed only once.
+func (f *DeletableFilter) TestAndAdd(data []byte) bool {
+	present := true
+	for _, i := range f.bits(data) {
+		if f.b.Test(i) {
+			f.collisions.Set(f.region(i))
+		} else {
+			present = false
+			f.b.Set(i)
+		}
+	}
+	return present
+}
+
+// Removes data from the filter by clearing its bits that lie in collision-free
+// regions. Returns false if data isn't in the filter, or if all of its bits lie
+// in regions with collisions, in which case it can't be removed. This exact
+// data must have been previously added to the filter, or future results will be
+// inconsistent.
+func (f *DeletableFilter) Remove(data []byte) bool {
+	is := f.bits(data)
+	if !testBits32(f.b, is) {
+		return false
+	}
+	removed := false
+	for _, i := range is {
+		if !f.collisions.Test(f.region(i)) {
+			f.b.Clear(i)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// Resets the filter.
+func (f *DeletableFilter) Reset() {
+	f.b.Reset()
+	f.collisions.Reset()
+}
+
+// Create a deletable bloom filter with an expected n number of items, an
+// acceptable false positive rate of p, e.g. 0.01, and the given number of
+// collision regions. More regions means more items can be removed; a few
+// percent of the number of bits in the filter is typical.
+func NewDeletable(n int, p float64, regions int, opts ...Option) *DeletableFilter {
+	m, k := estimates(int64(n), p)
+	r := uint32(regions)
+	if r < 1 {
+		r = 1
+	} else if r > m {
+		r = m
+	}
+	f := &DeletableFilter{
+		filter:     newFilter(m, k, opts...),
+		b:          bitset.New32(m),
+		collisions: bitset.New32(r),
+		regions:    r,
+	}
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
@@ -0,0 +1,44 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestDeletableFilter(t *testing.T) {
+	f := NewDeletable(3000, 0.01, 1000)
+	f.Add(foo)
+	f.Add(bar)
+	if !f.Test(foo) || !f.Test(bar) {
+		t.Fatal("foo or bar not in bloom filter")
+	}
+	if !f.Remove(foo) {
+		t.Fatal("foo couldn't be removed")
+	}
+	if f.Test(foo) {
+		t.Error("foo still in bloom filter")
+	}
+	if !f.Test(bar) {
+		t.Error("bar not in bloom filter after removing foo")
+	}
+	if f.Remove(baz) {
+		t.Error("baz removed without being added")
+	}
+}
+
+func TestDeletableFilterNoFalseNegatives(t *testing.T) {
+	n := 3000
+	f := NewDeletable(n, 0.01, 2000)
+	for i := 0; i < n; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	removed := map[int]bool{}
+	for i := 0; i < n; i += 2 {
+		removed[i] = f.Remove([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < n; i++ {
+		if !removed[i] && !f.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%d not in bloom filter", i)
+		}
+	}
+}
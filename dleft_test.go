@@ -0,0 +1,69 @@
+package bloom
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDLeftFilter(t *testing.T) {
+	f := NewDLeft(3000, 0.01)
+	f.Add(foo)
+	f.Add(foo)
+	f.Remove(foo)
+	if !f.Test(foo) {
+		t.Error("foo not in bloom filter")
+	}
+	f.Remove(foo)
+	if f.Test(foo) {
+		t.Error("foo still in bloom filter")
+	}
+}
+
+func TestDLeftFilterRate(t *testing.T) {
+	n := 10000
+	f := NewDLeft(n, 0.01)
+	for i := 0; i < n; i++ {
+		if !f.Add([]byte(strconv.Itoa(i))) {
+			t.Fatalf("no room for item %d", i)
+		}
+	}
+	for i := 0; i < n; i += 2 {
+		f.Remove([]byte(strconv.Itoa(i)))
+	}
+	for i := 1; i < n; i += 2 {
+		if !f.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%d not in bloom filter", i)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if f.Test([]byte(strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if p := float64(fp) / float64(n); p > 0.01 {
+		t.Errorf("False positive rate too high: %f", p)
+	}
+}
+
+func TestDLeftFilterConcurrentTest(t *testing.T) {
+	f := NewDLeft(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if !f.Test([]byte(strconv.Itoa(i))) {
+					t.Errorf("%d not in filter", i)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,870 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// Encoded formats, stored in the first byte of an encoded filter
+const (
+	formatFilter           = 1
+	formatFilterCompressed = 2
+	formatFilter64         = 3
+	formatCounting         = 4
+	formatCounting64       = 5
+	formatLayered          = 6
+	formatLayered64        = 7
+	formatRotating         = 8
+	formatCascade          = 9
+)
+
+// Header flags. The high four bits hold the IndexMode.
+const (
+	flagOneHashing = 1 << iota
+	flagHash128    // 128-bit FNV-1 rather than a split FNV-64 sum; see WithLegacyHashing
+	flagNamedHash  // the name and parameters of a registered hash follow the header
+
+	flagIndexShift = 4
+)
+
+// The largest number of hash functions accepted when decoding, to avoid
+// allocating huge index slices for corrupt input
+const maxDecodedK = 1 << 10
+
+// The largest counter of a counting filter using layers, and the most layers
+// of a layered filter or buckets of a rotating filter, accepted when
+// decoding, since each layer is as large as a Filter
+const maxDecodedLayers = 1 << 12
+
+// Reads the parts of an encoding, remembering the first error.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.data) < 1 {
+		d.err = ErrInvalidEncoding
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = ErrInvalidEncoding
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) uint64() uint64 {
+	if d.err != nil || len(d.data) < 8 {
+		d.err = ErrInvalidEncoding
+		return 0
+	}
+	v := binary.LittleEndian.Uint64(d.data)
+	d.data = d.data[8:]
+	return v
+}
+
+func (d *decoder) bool() bool {
+	switch d.byte() {
+	case 0:
+		return false
+	case 1:
+		return true
+	}
+	d.err = ErrInvalidEncoding
+	return false
+}
+
+func appendBool(buf []byte, v bool) []byte {
+	if v {
+		return append(buf, 1)
+	}
+	return append(buf, 0)
+}
+
+// Returns the next n bytes.
+func (d *decoder) bytes(n uint64) []byte {
+	if d.err != nil || uint64(len(d.data)) < n {
+		d.err = ErrInvalidEncoding
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+// Sets an error if there is data left over.
+func (d *decoder) done() error {
+	if d.err == nil && len(d.data) != 0 {
+		d.err = ErrInvalidEncoding
+	}
+	return d.err
+}
+
+func appendHeader(buf []byte, format, flags byte, m, k, seed uint64) []byte {
+	buf = append(buf, format, flags)
+	buf = binary.AppendUvarint(buf, m)
+	buf = binary.AppendUvarint(buf, k)
+	return binary.LittleEndian.AppendUint64(buf, seed)
+}
+
+func (d *decoder) header(format byte) (flags byte, m, k, seed uint64) {
+	if d.byte() != format {
+		d.err = ErrInvalidEncoding
+	}
+	flags = d.byte()
+	m = d.uvarint()
+	k = d.uvarint()
+	seed = d.uint64()
+	if d.err == nil && (m == 0 || k == 0 || k > maxDecodedK || IndexMode(flags>>flagIndexShift) >= numIndexModes) {
+		d.err = ErrInvalidEncoding
+	}
+	return
+}
+
+// Appends the name and parameters of a registered hash function.
+func appendHash(buf []byte, name string, params []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(name)))
+	buf = append(buf, name...)
+	buf = binary.AppendUvarint(buf, uint64(len(params)))
+	return append(buf, params...)
+}
+
+// Returns an option making a decoded filter use the registered hash function
+// whose name and parameters follow the header if flags says so, or else hf.
+func (d *decoder) hash(flags byte, hf HashFunc) Option {
+	if flags&flagNamedHash == 0 {
+		return WithHash(hf)
+	}
+	n := d.uvarint()
+	if n > maxHashName {
+		d.err = ErrInvalidEncoding
+	}
+	name := string(d.bytes(n))
+	params := append([]byte(nil), d.bytes(d.uvarint())...)
+	if d.err != nil {
+		return WithHash(hf)
+	}
+	h, err := lookupHash(name, params)
+	if err == ErrUnknownHash {
+		d.err = err
+	} else if err != nil {
+		d.err = ErrInvalidEncoding
+	}
+	return func(o *options) {
+		o.hash = h
+		o.hashName = name
+		o.hashParams = params
+	}
+}
+
+func (f *filter) appendHeader(buf []byte, format byte) []byte {
+	flags := byte(f.index) << flagIndexShift
+	if f.parts != nil {
+		flags |= flagOneHashing
+	}
+	if !f.legacy {
+		flags |= flagHash128
+	}
+	if f.hname != "" {
+		flags |= flagNamedHash
+	}
+	buf = appendHeader(buf, format, flags, uint64(f.m), uint64(f.k), f.seed)
+	if f.hname != "" {
+		buf = appendHash(buf, f.hname, f.hpar)
+	}
+	for _, p := range f.parts {
+		buf = binary.AppendUvarint(buf, uint64(p))
+	}
+	return buf
+}
+
+// Decodes a header written by appendHeader, returning a filter using hf
+// unless the header names a registered hash function, and using ix.
+func (d *decoder) filter(format byte, hf HashFunc, ix Indexer) *filter {
+	flags, m, k, seed := d.header(format)
+	if d.err == nil && m > math.MaxUint32 {
+		d.err = ErrInvalidEncoding
+	}
+	h := d.hash(flags, hf)
+	if d.err != nil {
+		return nil
+	}
+	f := newFilter(uint32(m), uint32(k), h, WithIndexer(ix), WithSeed(seed), WithIndexMode(IndexMode(flags>>flagIndexShift)))
+	f.legacy = flags&flagHash128 == 0
+	if flags&flagOneHashing != 0 {
+		f.parts = make([]uint32, k)
+		sum := uint64(0)
+		for i := range f.parts {
+			// A partition is a prime, so at least 2, and can't hold more
+			// bits than the filter
+			p := d.uvarint()
+			if d.err == nil && (p < 2 || p > math.MaxUint32) {
+				d.err = ErrInvalidEncoding
+			}
+			f.parts[i] = uint32(p)
+			sum += p
+		}
+		if d.err == nil && sum != m {
+			d.err = ErrInvalidEncoding
+		}
+	}
+	return f
+}
+
+// Appends the first n bits of b, eight to a byte, least significant bit first.
+func appendBits32(buf []byte, b *bitset.Bitset32, n uint32) []byte {
+	var c byte
+	for i := uint32(0); i < n; i++ {
+		if b.Test(i) {
+			c |= 1 << (i & 7)
+		}
+		if i&7 == 7 {
+			buf = append(buf, c)
+			c = 0
+		}
+	}
+	if n&7 != 0 {
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+func (d *decoder) bits32(n uint32) *bitset.Bitset32 {
+	data := d.bytes((uint64(n) + 7) / 8)
+	if d.err != nil {
+		return nil
+	}
+	b := bitset.New32(n)
+	for i := uint32(0); i < n; i++ {
+		if data[i>>3]&(1<<(i&7)) != 0 {
+			b.Set(i)
+		}
+	}
+	return b
+}
+
+func appendBits64(buf []byte, b *bitset.Bitset64, n uint64) []byte {
+	var c byte
+	for i := uint64(0); i < n; i++ {
+		if b.Test(i) {
+			c |= 1 << (i & 7)
+		}
+		if i&7 == 7 {
+			buf = append(buf, c)
+			c = 0
+		}
+	}
+	if n&7 != 0 {
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+func (d *decoder) bits64(n uint64) *bitset.Bitset64 {
+	if d.err == nil && !validM64(n) {
+		d.err = ErrInvalidEncoding
+	}
+	data := d.bytes((n + 7) / 8)
+	if d.err != nil {
+		return nil
+	}
+	b := bitset.New64(n)
+	for i := uint64(0); i < n; i++ {
+		if data[i>>3]&(1<<(i&7)) != 0 {
+			b.Set(i)
+		}
+	}
+	return b
+}
+
+// Returns the custom hash function and indexer of f, which decoding keeps.
+func (f *filter) custom() (HashFunc, Indexer) {
+	if f == nil {
+		return nil, nil
+	}
+	return f.hf, f.ix
+}
+
+func (f *filter64) appendHeader(buf []byte, format byte) []byte {
+	flags := byte(f.index) << flagIndexShift
+	if f.hname != "" {
+		flags |= flagNamedHash
+	}
+	buf = appendHeader(buf, format, flags, f.m, f.k, f.seed)
+	if f.hname != "" {
+		buf = appendHash(buf, f.hname, f.hpar)
+	}
+	return buf
+}
+
+// Whether a 64-bit filter of m bits can be decoded: its size in bytes,
+// (m+7)/8, must not overflow, and its bitset must fit in a slice.
+func validM64(m uint64) bool {
+	return m <= math.MaxUint64-7 && m/64 < math.MaxInt
+}
+
+// Decodes a header written by filter64.appendHeader, like decoder.filter.
+func (d *decoder) filter64(format byte, hf HashFunc, ix Indexer) *filter64 {
+	flags, m, k, seed := d.header(format)
+	if d.err == nil && !validM64(m) {
+		d.err = ErrInvalidEncoding
+	}
+	h := d.hash(flags, hf)
+	if d.err != nil {
+		return nil
+	}
+	return newFilter64(m, k, h, WithIndexer(ix), WithSeed(seed), WithIndexMode(IndexMode(flags>>flagIndexShift)))
+}
+
+func (f *filter64) custom() (HashFunc, Indexer) {
+	if f == nil {
+		return nil, nil
+	}
+	return f.hf, f.ix
+}
+
+// Encodes the filter into a binary form. The encoding includes the filter's
+// parameters and seed, and the name of a hash function given with
+// WithRegisteredHash, but not one given with WithHash: a filter created with
+// WithHash must be decoded into a filter created with the same hash function.
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	buf := f.appendHeader(nil, formatFilter)
+	return appendBits32(buf, f.b, f.m), nil
+}
+
+// Decodes a filter previously encoded with MarshalBinary, replacing the
+// contents of f. If f was created with a custom hash function or indexer, it
+// is kept, unless the encoding names a registered hash function. Returns
+// ErrUnknownHash if it names one that isn't registered.
+func (f *Filter) UnmarshalBinary(data []byte) error {
+	d := &decoder{data: data}
+	hf, ix := f.filter.custom()
+	fl := d.filter(formatFilter, hf, ix)
+	var b *bitset.Bitset32
+	if d.err == nil {
+		b = d.bits32(fl.m)
+	}
+	if err := d.done(); err != nil {
+		return err
+	}
+	f.filter = fl
+	f.b = b
+	return nil
+}
+
+// Encodes the filter into a binary form. The encoding includes the filter's
+// parameters and seed, and the name of a hash function given with
+// WithRegisteredHash, but not one given with WithHash: a filter created with
+// WithHash must be decoded into a filter created with the same hash function.
+func (f *Filter64) MarshalBinary() ([]byte, error) {
+	buf := f.appendHeader(nil, formatFilter64)
+	return appendBits64(buf, f.b, f.m), nil
+}
+
+// Decodes a filter previously encoded with MarshalBinary, replacing the
+// contents of f. If f was created with a custom hash function or indexer, it
+// is kept, unless the encoding names a registered hash function. Returns
+// ErrUnknownHash if it names one that isn't registered.
+func (f *Filter64) UnmarshalBinary(data []byte) error {
+	d := &decoder{data: data}
+	hf, ix := f.filter64.custom()
+	fl := d.filter64(formatFilter64, hf, ix)
+	var b *bitset.Bitset64
+	if d.err == nil {
+		b = d.bits64(fl.m)
+	}
+	if err := d.done(); err != nil {
+		return err
+	}
+	f.filter64 = fl
+	f.b = b
+	return nil
+}
+
+// Appends the counters of a counting filter with m counters, encoded as the
+// number of non-zero counters followed by the gap before each of them and its
+// value, since most counters of a filter are usually zero.
+func appendCounts(buf []byte, m uint64, get func(i uint64) uint64) []byte {
+	n := uint64(0)
+	for i := uint64(0); i < m; i++ {
+		if get(i) != 0 {
+			n++
+		}
+	}
+	buf = binary.AppendUvarint(buf, n)
+	next := uint64(0)
+	for i := uint64(0); i < m; i++ {
+		if v := get(i); v != 0 {
+			buf = binary.AppendUvarint(buf, i-next)
+			buf = binary.AppendUvarint(buf, v)
+			next = i + 1
+		}
+	}
+	return buf
+}
+
+// Decodes counters written by appendCounts, each at most max, passing each
+// non-zero counter to set.
+func (d *decoder) counts(m, max uint64, set func(i, v uint64)) {
+	n := d.uvarint()
+	if d.err == nil && n > m {
+		d.err = ErrInvalidEncoding
+	}
+	next := uint64(0)
+	for j := uint64(0); j < n && d.err == nil; j++ {
+		gap, v := d.uvarint(), d.uvarint()
+		if d.err != nil || gap >= m-next || v == 0 || v > max {
+			d.err = ErrInvalidEncoding
+			return
+		}
+		set(next+gap, v)
+		next += gap + 1
+	}
+}
+
+// Returns the largest counter a counting filter with counters of width bits,
+// or 0 for layers, may be decoded with.
+func (d *decoder) counterWidth() (width uint, max uint64) {
+	switch width = uint(d.byte()); width {
+	case 0:
+		return width, maxDecodedLayers
+	case 4, 8, 16:
+		return width, 1<<width - 1
+	}
+	d.err = ErrInvalidEncoding
+	return 0, 0
+}
+
+// The most memory, in bytes, decoding a counting or compressed filter
+// allocates for its counters or bits, or maxDecodedExpansion times the size of
+// its encoding if that is more, up to DefaultMaxLoadSize. Since only non-zero
+// counters or set bits are stored, a few bytes can describe a filter of any
+// size, so the size of the encoding alone doesn't bound the allocation, as it
+// does for the other filters.
+const (
+	maxDecodedCounterBytes = 64 << 20
+	maxDecodedExpansion    = 1 << 12
+)
+
+// Checks that m counters of width bits, or a layer of m bits if width is 0,
+// fit in the memory allowed for decoding an encoding of size bytes, and
+// returns the largest counter they may be decoded with: limit, or for layers,
+// the number of layers which fit if that is less.
+func (d *decoder) counterBudget(m uint64, width uint, limit uint64, size int) uint64 {
+	budget := min(max(maxDecodedCounterBytes, uint64(size)*maxDecodedExpansion), DefaultMaxLoadSize)
+	if m == 0 || !validM64(m) {
+		if d.err == nil {
+			d.err = ErrInvalidEncoding
+		}
+		return 0
+	}
+	layer := (m + 7) / 8
+	if width != 0 {
+		if d.err == nil && layer > budget/uint64(width) {
+			d.err = ErrInvalidEncoding
+		}
+		return limit
+	}
+	if d.err == nil && layer > budget {
+		d.err = ErrInvalidEncoding
+	}
+	return min(limit, budget/layer)
+}
+
+// Encodes the filter into a binary form, including its parameters and seed as
+// with Filter.MarshalBinary. Only its non-zero counters are stored, so the
+// encoding of a filter holding few items, relative to its size, is small.
+func (f *CountingFilter) MarshalBinary() ([]byte, error) {
+	buf := f.appendHeader(nil, formatCounting)
+	width := uint(0)
+	if f.c != nil {
+		width = f.c.width
+	}
+	buf = append(buf, byte(width))
+	buf = appendBool(buf, f.conservative)
+	buf = binary.AppendUvarint(buf, f.OverflowCount())
+	return appendCounts(buf, uint64(f.m), func(i uint64) uint64 { return f.counter(uint32(i)) }), nil
+}
+
+// Decodes a filter previously encoded with MarshalBinary, replacing the
+// contents of f, like Filter.UnmarshalBinary. Returns ErrInvalidEncoding if
+// the counters would take more than 64 MiB, or 4096 times the size of data
+// if that is more, e.g. for corrupt data.
+func (f *CountingFilter) UnmarshalBinary(data []byte) error {
+	d := &decoder{data: data}
+	hf, ix := f.filter.custom()
+	fl := d.filter(formatCounting, hf, ix)
+	width, max := d.counterWidth()
+	conservative := d.bool()
+	overflows := d.uvarint()
+	if d.err == nil {
+		max = d.counterBudget(uint64(fl.m), width, max, len(data))
+	}
+	if d.err != nil {
+		return d.err
+	}
+	c := &CountingFilter{filter: fl, conservative: conservative}
+	if width != 0 {
+		c.c = newCounters(uint64(fl.m), width)
+		c.c.overflows = overflows
+	} else {
+		c.b = newLayers32(fl.m, 1)
+	}
+	d.counts(uint64(fl.m), max, func(i, v uint64) { c.setCounter(uint32(i), v) })
+	if err := d.done(); err != nil {
+		return err
+	}
+	*f = *c
+	return nil
+}
+
+// Encodes the filter into a binary form, storing only its non-zero counters,
+// like CountingFilter.MarshalBinary.
+func (f *CountingFilter64) MarshalBinary() ([]byte, error) {
+	buf := f.appendHeader(nil, formatCounting64)
+	width := uint(0)
+	if f.c != nil {
+		width = f.c.width
+	}
+	buf = append(buf, byte(width))
+	buf = appendBool(buf, f.conservative)
+	buf = binary.AppendUvarint(buf, f.OverflowCount())
+	return appendCounts(buf, f.m, f.counter), nil
+}
+
+// Decodes a filter previously encoded with MarshalBinary, replacing the
+// contents of f, like Filter64.UnmarshalBinary, with the limit of
+// CountingFilter.UnmarshalBinary.
+func (f *CountingFilter64) UnmarshalBinary(data []byte) error {
+	d := &decoder{data: data}
+	hf, ix := f.filter64.custom()
+	fl := d.filter64(formatCounting64, hf, ix)
+	width, max := d.counterWidth()
+	conservative := d.bool()
+	overflows := d.uvarint()
+	if d.err == nil {
+		max = d.counterBudget(fl.m, width, max, len(data))
+	}
+	if d.err != nil {
+		return d.err
+	}
+	c := &CountingFilter64{filter64: fl, conservative: conservative}
+	if width != 0 {
+		c.c = newCounters(fl.m, width)
+		c.c.overflows = overflows
+	} else {
+		c.b = newLayers64(fl.m, 1)
+	}
+	d.counts(fl.m, max, c.setCounter)
+	if err := d.done(); err != nil {
+		return err
+	}
+	*f = *c
+	return nil
+}
+
+// Encodes the filter into a binary form, including its parameters and seed as
+// with Filter.MarshalBinary, its layer limit, the size and number of hash
+// functions of each layer if it was created with NewLayeredRates, and the bits
+// and LayerInfo of every layer.
+func (f *LayeredFilter) MarshalBinary() ([]byte, error) {
+	buf := f.appendHeader(nil, formatLayered)
+	buf = binary.AppendUvarint(buf, uint64(f.max))
+	buf = binary.AppendUvarint(buf, uint64(len(f.tiers)))
+	for _, t := range f.tiers {
+		buf = binary.AppendUvarint(buf, uint64(t.m))
+		buf = binary.AppendUvarint(buf, uint64(t.k))
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(f.b)))
+	for _, b := range f.b {
+		buf = appendBits32(buf, b, b.Len())
+	}
+	return appendLayerInfo(buf, f.info), nil
+}
+
+// Decodes a filter previously encoded with MarshalBinary, replacing the
+// contents of f, like Filter.UnmarshalBinary.
+func (f *LayeredFilter) UnmarshalBinary(data []byte) error {
+	d := &decoder{data: data}
+	hf, ix := f.filter.custom()
+	fl := d.filter(formatLayered, hf, ix)
+	max, tiers := d.uvarint(), d.uvarint()
+	if d.err == nil && (max > math.MaxInt32 || tiers > maxDecodedLayers || tiers > 0 && fl.parts != nil) {
+		d.err = ErrInvalidEncoding
+	}
+	if d.err != nil {
+		return d.err
+	}
+	l := &LayeredFilter{filter: fl, max: int(max)}
+	for i := uint64(0); i < tiers && d.err == nil; i++ {
+		m, k := d.uvarint(), d.uvarint()
+		if d.err == nil && (m == 0 || m > math.MaxUint32 || k == 0 || k > maxDecodedK || i == 0 && (m != uint64(fl.m) || k != uint64(fl.k))) {
+			d.err = ErrInvalidEncoding
+		}
+		t := fl
+		if i > 0 {
+			t = fl.clone()
+			t.m, t.k = uint32(m), uint32(k)
+		}
+		l.tiers = append(l.tiers, t)
+	}
+	n := d.uvarint()
+	if d.err == nil && (n == 0 || n > maxDecodedLayers || max > 0 && n > max) {
+		d.err = ErrInvalidEncoding
+	}
+	for i := 0; uint64(i) < n && d.err == nil; i++ {
+		l.b = append(l.b, d.bits32(l.tier(i).m))
+	}
+	l.info = d.layerInfo(n)
+	if err := d.done(); err != nil {
+		return err
+	}
+	*f = *l
+	return nil
+}
+
+// Writes the filter to w, encoded as with MarshalBinary and prefixed with the
+// length of the encoding, so that it can be read back with ReadFrom from a
+// stream holding other data.
+func (f *LayeredFilter) WriteTo(w io.Writer) (int64, error) {
+	return writeTo(w, f)
+}
+
+// Reads a filter previously written with WriteTo from r, replacing the contents
+// of f as UnmarshalBinary does. Only the filter's own bytes are read.
+func (f *LayeredFilter) ReadFrom(r io.Reader) (int64, error) {
+	return readFrom(r, f)
+}
+
+// Encodes the filter into a binary form, including its parameters and seed as
+// with Filter64.MarshalBinary, its layer limit, and the bits and LayerInfo of
+// every layer.
+func (f *LayeredFilter64) MarshalBinary() ([]byte, error) {
+	buf := f.appendHeader(nil, formatLayered64)
+	buf = binary.AppendUvarint(buf, uint64(f.max))
+	buf = binary.AppendUvarint(buf, uint64(len(f.b)))
+	for _, b := range f.b {
+		buf = appendBits64(buf, b, f.m)
+	}
+	return appendLayerInfo(buf, f.info), nil
+}
+
+// Decodes a filter previously encoded with MarshalBinary, replacing the
+// contents of f, like Filter64.UnmarshalBinary.
+func (f *LayeredFilter64) UnmarshalBinary(data []byte) error {
+	d := &decoder{data: data}
+	hf, ix := f.filter64.custom()
+	fl := d.filter64(formatLayered64, hf, ix)
+	max, n := d.uvarint(), d.uvarint()
+	if d.err == nil && (max > math.MaxInt32 || n == 0 || n > maxDecodedLayers || max > 0 && n > max) {
+		d.err = ErrInvalidEncoding
+	}
+	// Checked before any layer is allocated, since the layers must all be
+	// in the data
+	if d.err == nil && (fl.m+7)/8 > uint64(len(d.data))/n {
+		d.err = ErrInvalidEncoding
+	}
+	if d.err != nil {
+		return d.err
+	}
+	l := &LayeredFilter64{filter64: fl, max: int(max)}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		l.b = append(l.b, d.bits64(fl.m))
+	}
+	l.info = d.layerInfo(n)
+	if err := d.done(); err != nil {
+		return err
+	}
+	*f = *l
+	return nil
+}
+
+// Writes the filter to w, prefixed with its length, like
+// LayeredFilter.WriteTo.
+func (f *LayeredFilter64) WriteTo(w io.Writer) (int64, error) {
+	return writeTo(w, f)
+}
+
+// Reads a filter previously written with WriteTo from r, like
+// LayeredFilter.ReadFrom.
+func (f *LayeredFilter64) ReadFrom(r io.Reader) (int64, error) {
+	return readFrom(r, f)
+}
+
+// Encodes the filter into a binary form, including its parameters and seed as
+// with Filter.MarshalBinary, the duration of its buckets, the time the current
+// bucket started, and the bits of every bucket, so that a decoded filter
+// expires items at the same times.
+func (f *RotatingFilter) MarshalBinary() ([]byte, error) {
+	buf := f.appendHeader(nil, formatRotating)
+	buf = binary.AppendUvarint(buf, uint64(len(f.b)))
+	buf = binary.AppendUvarint(buf, uint64(f.cur))
+	buf = binary.AppendUvarint(buf, uint64(f.interval))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(f.start.UnixNano()))
+	for _, b := range f.b {
+		buf = appendBits32(buf, b, f.m)
+	}
+	return buf, nil
+}
+
+// Decodes a filter previously encoded with MarshalBinary, replacing the
+// contents of f, like Filter.UnmarshalBinary. Buckets whose time passed since
+// the filter was encoded are ignored by Test, and reset when it is next
+// rotated.
+func (f *RotatingFilter) UnmarshalBinary(data []byte) error {
+	d := &decoder{data: data}
+	hf, ix := f.filter.custom()
+	fl := d.filter(formatRotating, hf, ix)
+	n, cur, interval := d.uvarint(), d.uvarint(), d.uvarint()
+	start := time.Unix(0, int64(d.uint64()))
+	if d.err == nil && (n == 0 || n > maxDecodedLayers || cur >= n || interval == 0 || interval > math.MaxInt64) {
+		d.err = ErrInvalidEncoding
+	}
+	if d.err != nil {
+		return d.err
+	}
+	r := &RotatingFilter{
+		filter:   fl,
+		cur:      int(cur),
+		interval: time.Duration(interval),
+		start:    start,
+		now:      f.now,
+	}
+	if r.now == nil {
+		r.now = time.Now
+	}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		r.b = append(r.b, d.bits32(fl.m))
+	}
+	if err := d.done(); err != nil {
+		return err
+	}
+	*f = *r
+	return nil
+}
+
+// Encodes the cascade into a binary form: its number of layers, and the
+// encoding of each layer as with Filter.MarshalBinary.
+func (c *Cascade) MarshalBinary() ([]byte, error) {
+	buf := []byte{formatCascade}
+	buf = binary.AppendUvarint(buf, uint64(len(c.layers)))
+	for _, l := range c.layers {
+		data, err := l.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(data)))
+		buf = append(buf, data...)
+	}
+	return buf, nil
+}
+
+// Decodes a cascade previously encoded with MarshalBinary, replacing the
+// contents of c. A cascade of no layers, built from no included items, is
+// valid, and includes nothing.
+func (c *Cascade) UnmarshalBinary(data []byte) error {
+	d := &decoder{data: data}
+	if d.byte() != formatCascade && d.err == nil {
+		d.err = ErrInvalidEncoding
+	}
+	n := d.uvarint()
+	if d.err == nil && n > cascadeMaxLayers {
+		d.err = ErrInvalidEncoding
+	}
+	layers := make([]*Filter, 0, n)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		b := d.bytes(d.uvarint())
+		if d.err != nil {
+			break
+		}
+		l := &Filter{}
+		if err := l.UnmarshalBinary(b); err != nil {
+			return err
+		}
+		layers = append(layers, l)
+	}
+	if err := d.done(); err != nil {
+		return err
+	}
+	c.layers = layers
+	return nil
+}
+
+// Writes the cascade to w, as MarshalBinary encodes it, prefixed with the
+// length of the encoding, like LayeredFilter.WriteTo.
+func (c *Cascade) WriteTo(w io.Writer) (int64, error) {
+	return writeTo(w, c)
+}
+
+// Reads a cascade previously written with WriteTo from r, replacing the
+// contents of c. Only the cascade's own bytes are read.
+func (c *Cascade) ReadFrom(r io.Reader) (int64, error) {
+	return readFrom(r, c)
+}
+
+// Writes the encoding of v to w, prefixed with its length as a uvarint.
+func writeTo(w io.Writer, v encoding.BinaryMarshaler) (int64, error) {
+	data, err := v.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	buf := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(data)), uint64(len(data)))
+	n, err := w.Write(append(buf, data...))
+	return int64(n), err
+}
+
+// Reads an encoding written by writeTo from r into v, without reading past it.
+func readFrom(r io.Reader, v encoding.BinaryUnmarshaler) (int64, error) {
+	br := &byteReader{r: r}
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		return br.n, err
+	}
+	if size > math.MaxInt64 {
+		return br.n, ErrInvalidEncoding
+	}
+	// Copied rather than read in one piece, so that a corrupt length can't
+	// allocate more than the data which is actually there
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r, int64(size))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return br.n + n, err
+	}
+	return br.n + n, v.UnmarshalBinary(buf.Bytes())
+}
+
+// Reads single bytes from r, counting them.
+type byteReader struct {
+	r io.Reader
+	n int64
+}
+
+func (b *byteReader) ReadByte() (byte, error) {
+	var c [1]byte
+	if _, err := io.ReadFull(b.r, c[:]); err != nil {
+		return 0, err
+	}
+	b.n++
+	return c[0], nil
+}
@@ -0,0 +1,359 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFilterMarshal(t *testing.T) {
+	for _, f := range []*Filter{
+		New(1000, 0.01),
+		NewSeeded(1000, 0.01),
+		NewOneHashing(1000, 0.01),
+		New(1000, 0.01, WithIndexMode(DoubleHashing)),
+		New(1000, 0.01, WithIndexMode(FastRange)),
+	} {
+		for i := 0; i < 500; i++ {
+			f.Add([]byte(strconv.Itoa(i)))
+		}
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := &Filter{}
+		if err := g.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if g.index != f.index {
+			t.Fatalf("decoded index mode %v, expected %v", g.index, f.index)
+		}
+		if g.seed != f.seed || g.m != f.m || g.k != f.k {
+			t.Fatalf("decoded parameters differ: %d %d %d, expected %d %d %d", g.m, g.k, g.seed, f.m, f.k, f.seed)
+		}
+		for i := 0; i < 1000; i++ {
+			v := []byte(strconv.Itoa(i))
+			if f.Test(v) != g.Test(v) {
+				t.Fatalf("decoded filter differs for %s", v)
+			}
+		}
+		if err := g.UnmarshalBinary(data[:len(data)-1]); err != ErrInvalidEncoding {
+			t.Errorf("decoding truncated data returned %v", err)
+		}
+	}
+}
+
+func TestFilterUnmarshalPartitions(t *testing.T) {
+	// Crafted one-hashing encodings whose partitions sum to m, but include
+	// one which is too small
+	for _, parts := range [][]uint32{{0, 13}, {1, 12}} {
+		f := NewWithSize(13, 2)
+		f.parts = parts
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := (&Filter{}).UnmarshalBinary(data); err != ErrInvalidEncoding {
+			t.Errorf("decoding partitions %v returned %v", parts, err)
+		}
+	}
+}
+
+func TestFilter64Marshal(t *testing.T) {
+	f := NewSeeded64(1000, 0.01)
+	f.Add(foo)
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &Filter64{}
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Test(foo) || g.Test(bar) || g.seed != f.seed {
+		t.Error("decoded filter differs")
+	}
+}
+
+func TestFilter64UnmarshalInvalid(t *testing.T) {
+	// Filters whose size in bytes overflows, or which are much larger than
+	// their encoding
+	for _, m := range []uint64{math.MaxUint64, math.MaxUint64 - 6, 1 << 63} {
+		buf := newFilter64(m, 7).appendHeader(nil, formatFilter64)
+		buf = append(buf, 0xff)
+		if err := (&Filter64{}).UnmarshalBinary(buf); err != ErrInvalidEncoding {
+			t.Errorf("decoding a filter of %d bits returned %v", m, err)
+		}
+	}
+
+	f := New64(1000, 0.01)
+	f.Add(foo)
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := range data {
+		if err := (&Filter64{}).UnmarshalBinary(data[:n]); err != ErrInvalidEncoding {
+			t.Fatalf("decoding %d of %d bytes returned %v", n, len(data), err)
+		}
+	}
+}
+
+func TestSeededFiltersDiffer(t *testing.T) {
+	a := NewSeeded(1000, 0.01)
+	b := NewSeeded(1000, 0.01)
+	if a.seed == 0 || a.seed == b.seed {
+		t.Fatalf("seeds %d and %d", a.seed, b.seed)
+	}
+	same := 0
+	for i := 0; i < 100; i++ {
+		v := []byte(strconv.Itoa(i))
+		if a.bits(v)[0] == b.bits(v)[0] {
+			same++
+		}
+	}
+	if same > 5 {
+		t.Errorf("%d of 100 items share bits", same)
+	}
+}
+
+func TestCountingFilterMarshal(t *testing.T) {
+	for _, f := range []*CountingFilter{
+		NewCounting(1000, 0.01),
+		NewCounting(1000, 0.01, WithSeed(7), WithCounterWidth(4)),
+		NewCounting(1000, 0.01, WithCounterWidth(16), WithConservativeUpdate()),
+	} {
+		for i := 0; i < 500; i++ {
+			for j := 0; j <= i%3; j++ {
+				f.Add([]byte(strconv.Itoa(i)))
+			}
+		}
+		for i := 0; i < 20; i++ {
+			f.Add(foo)
+		}
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := f.SizeInBytes(); uint64(len(data)) > 2*size {
+			t.Errorf("encoded %d bytes of counters into %d bytes", size, len(data))
+		}
+		g := &CountingFilter{}
+		if err := g.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if g.seed != f.seed || g.conservative != f.conservative || (g.c == nil) != (f.c == nil) || g.OverflowCount() != f.OverflowCount() {
+			t.Fatal("decoded filter has different parameters")
+		}
+		for i := uint32(0); i < f.m; i++ {
+			if g.counter(i) != f.counter(i) {
+				t.Fatalf("counter %d is %d, expected %d", i, g.counter(i), f.counter(i))
+			}
+		}
+		if g.Count(foo) != f.Count(foo) {
+			t.Errorf("count of foo %d, expected %d", g.Count(foo), f.Count(foo))
+		}
+		for _, n := range []int{1, len(data) / 2, len(data) - 1} {
+			if err := g.UnmarshalBinary(data[:n]); err != ErrInvalidEncoding {
+				t.Errorf("decoding %d of %d bytes returned %v", n, len(data), err)
+			}
+		}
+	}
+}
+
+func TestCountingFilterUnmarshalLimit(t *testing.T) {
+	// An empty filter of 2^32-1 counters, in a few bytes
+	buf := newFilter(math.MaxUint32, 7).appendHeader(nil, formatCounting)
+	buf = append(buf, 0, 0, 0, 0)
+	if err := (&CountingFilter{}).UnmarshalBinary(buf); err != ErrInvalidEncoding {
+		t.Errorf("decoding a huge filter returned %v", err)
+	}
+	buf = newFilter64(math.MaxUint32, 7).appendHeader(nil, formatCounting64)
+	buf = append(buf, 16, 0, 0, 0)
+	if err := (&CountingFilter64{}).UnmarshalBinary(buf); err != ErrInvalidEncoding {
+		t.Errorf("decoding a huge 64-bit filter returned %v", err)
+	}
+
+	// Layered counters of 2^64-1 bits, whose layer size overflows to 0
+	for _, width := range []byte{0, 16} {
+		buf = newFilter64(math.MaxUint64, 7).appendHeader(nil, formatCounting64)
+		buf = append(buf, width, 0, 0, 0)
+		if err := (&CountingFilter64{}).UnmarshalBinary(buf); err != ErrInvalidEncoding {
+			t.Errorf("decoding 2^64-1 counters of width %d returned %v", width, err)
+		}
+	}
+	d := &decoder{}
+	if d.counterBudget(math.MaxUint64, 0, 1, 0); d.err != ErrInvalidEncoding {
+		t.Errorf("budget for layers of 2^64-1 bits returned %v", d.err)
+	}
+
+	// A counter of maxDecodedLayers, which would need as many layers of
+	// 1 MiB
+	buf = newFilter(8<<20, 7).appendHeader(nil, formatCounting)
+	buf = append(buf, 0, 0, 0, 1, 0)
+	buf = binary.AppendUvarint(buf, maxDecodedLayers)
+	if err := (&CountingFilter{}).UnmarshalBinary(buf); err != ErrInvalidEncoding {
+		t.Errorf("decoding a counter of %d layers returned %v", maxDecodedLayers, err)
+	}
+	// The same filter with a smaller counter decodes
+	buf[len(buf)-2], buf = 20, buf[:len(buf)-1]
+	g := &CountingFilter{}
+	if err := g.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+	if g.counter(0) != 20 {
+		t.Errorf("counter %d, expected 20", g.counter(0))
+	}
+}
+
+func TestCountingFilter64Marshal(t *testing.T) {
+	for _, f := range []*CountingFilter64{
+		NewCounting64(1000, 0.01),
+		NewCounting64(1000, 0.01, WithCounterWidth(8)),
+	} {
+		f.Add(foo)
+		f.Add(foo)
+		f.Add(bar)
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := &CountingFilter64{}
+		if err := g.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if g.Count(foo) != 2 || g.Count(bar) != 1 || g.Test(baz) {
+			t.Errorf("counts %d, %d and %d in the decoded filter", g.Count(foo), g.Count(bar), g.Count(baz))
+		}
+		if err := (&CountingFilter{}).UnmarshalBinary(data); err != ErrInvalidEncoding {
+			t.Errorf("decoding a 64-bit counting filter as a 32-bit one returned %v", err)
+		}
+	}
+}
+
+func TestLayeredFilterMarshal(t *testing.T) {
+	for _, f := range []*LayeredFilter{
+		NewLayered(1000, 0.01),
+		NewLayeredMax(1000, 0.01, 5, WithSeed(7)),
+		NewLayeredRates(1000, []float64{0.001, 0.01, 0.1}),
+	} {
+		for i := 0; i < 500; i++ {
+			for j := 0; j <= i%4; j++ {
+				f.Add([]byte(strconv.Itoa(i)))
+			}
+		}
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := &LayeredFilter{}
+		if err := g.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if g.seed != f.seed || g.max != f.max || len(g.b) != len(f.b) || len(g.tiers) != len(f.tiers) {
+			t.Fatal("decoded filter has different parameters")
+		}
+		for i := 0; i < 1000; i++ {
+			v := []byte(strconv.Itoa(i))
+			if f.Count(v) != g.Count(v) {
+				t.Fatalf("count of %s is %d, expected %d", v, g.Count(v), f.Count(v))
+			}
+		}
+		for _, n := range []int{1, len(data) / 2, len(data) - 1} {
+			if err := g.UnmarshalBinary(data[:n]); err != ErrInvalidEncoding {
+				t.Errorf("decoding %d of %d bytes returned %v", n, len(data), err)
+			}
+		}
+	}
+}
+
+func TestLayeredFilter64UnmarshalInvalid(t *testing.T) {
+	for layers := uint64(1); layers <= 3; layers++ {
+		for _, m := range []uint64{math.MaxUint64, math.MaxUint64 - 6, 1 << 63} {
+			buf := newFilter64(m, 7).appendHeader(nil, formatLayered64)
+			buf = binary.AppendUvarint(buf, 0)
+			buf = binary.AppendUvarint(buf, layers)
+			buf = append(buf, 0xff)
+			if err := (&LayeredFilter64{}).UnmarshalBinary(buf); err != ErrInvalidEncoding {
+				t.Errorf("decoding %d layers of %d bits returned %v", layers, m, err)
+			}
+		}
+	}
+
+	// Truncated in each of three layers
+	f := NewLayered64(1000, 0.01)
+	for i := 0; i < 3; i++ {
+		f.Add(foo)
+	}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := range data {
+		if err := (&LayeredFilter64{}).UnmarshalBinary(data[:n]); err != ErrInvalidEncoding {
+			t.Fatalf("decoding %d of %d bytes returned %v", n, len(data), err)
+		}
+	}
+}
+
+func TestLayeredFilterWriteTo(t *testing.T) {
+	f := NewLayered(1000, 0.01)
+	f64 := NewLayered64(1000, 0.01)
+	f.Add(foo)
+	f.Add(foo)
+	f64.Add(bar)
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("wrote %d of %d bytes: %v", n, buf.Len(), err)
+	}
+	if _, err := f64.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	g, g64 := &LayeredFilter{}, &LayeredFilter64{}
+	if m, err := g.ReadFrom(&buf); err != nil || m != n {
+		t.Fatalf("read %d of %d bytes: %v", m, n, err)
+	}
+	if _, err := g64.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if g.Count(foo) != 2 || g.Count(bar) != 0 || g64.Count(bar) != 1 || buf.Len() != 0 {
+		t.Error("filters read back differ")
+	}
+	if _, err := g.ReadFrom(&buf); err == nil {
+		t.Error("reading from an empty stream succeeded")
+	}
+}
+
+func TestRotatingFilterMarshal(t *testing.T) {
+	now := time.Now()
+	f := NewRotating(1000, 0.01, time.Minute, 4)
+	f.now = func() time.Time { return now }
+	f.Reset()
+	f.Add(foo)
+	now = now.Add(30 * time.Second)
+	f.Add(bar)
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &RotatingFilter{now: f.now}
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Test(foo) || !g.Test(bar) || g.Test([]byte("baz")) {
+		t.Error("decoded filter differs")
+	}
+	now = now.Add(35 * time.Second)
+	if g.Test(foo) || !g.Test(bar) {
+		t.Error("decoded filter expires items at different times")
+	}
+	for _, n := range []int{1, len(data) / 2, len(data) - 1} {
+		if err := g.UnmarshalBinary(data[:n]); err != ErrInvalidEncoding {
+			t.Errorf("decoding %d of %d bytes returned %v", n, len(data), err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// Returned when decoding data that wasn't produced by the matching
+	// MarshalBinary method, or that has been truncated or corrupted.
+	ErrInvalidEncoding = errors.New("bloom: invalid encoding")
+
+	// Returned when combining two structures whose parameters differ.
+	ErrIncompatible = errors.New("bloom: incompatible parameters")
+
+	// Wrapped by the errors returned by constructors such as NewWithError
+	// when given parameters no filter can be created with.
+	ErrInvalidParameters = errors.New("bloom: invalid parameters")
+
+	// Wrapped by the errors returned by constructors such as NewWithError
+	// when the filter for the parameters given would have more bits than its
+	// bitset can hold, e.g. more than 2^32 for a 32-bit filter, and by
+	// LoadFromReaderWithLimit for an encoding larger than its limit. It wraps
+	// ErrInvalidParameters.
+	ErrTooLarge = fmt.Errorf("%w: filter too large", ErrInvalidParameters)
+
+	// Returned when removing an item which isn't present in a filter, e.g. by
+	// CountingFilter.StrictRemove.
+	ErrNotPresent = errors.New("bloom: item not present")
+
+	// Returned when decoding a filter which uses a hash function that
+	// hasn't been registered with RegisterHash.
+	ErrUnknownHash = errors.New("bloom: unknown hash function")
+
+	// Returned by a Fetcher when the artifact it fetches hasn't changed since
+	// the version with the ETag given.
+	ErrNotModified = errors.New("bloom: artifact not modified")
+
+	// Returned by Cascade.Verify when the cascade gives the wrong answer for
+	// an item of the sets it was built from.
+	ErrCascadeMismatch = errors.New("bloom: cascade doesn't match its sets")
+
+	// Returned by LayeredFilter.RotateLayers when the layers of the filter
+	// differ in size, e.g. a filter created with NewLayeredRates.
+	ErrNotRotatable = errors.New("bloom: layers of different sizes can't be rotated")
+)
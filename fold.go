@@ -0,0 +1,102 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+
+	"fmt"
+	"math"
+)
+
+// Returns an error if a filter with index mode mode, m bits and the given
+// one-hashing partitions and indexer can't be folded by factor.
+func checkFold(factor int, m uint64, mode IndexMode, ix Indexer, oneHash bool) error {
+	switch {
+	case factor < 1 || uint64(factor) > m || m%uint64(factor) != 0:
+		return fmt.Errorf("%w: a filter of %d bits can't be folded by %d, which must divide it", ErrInvalidParameters, m, factor)
+	case ix != nil || oneHash:
+		return fmt.Errorf("%w: filters with a custom indexer, or in one-hashing mode, can't be folded", ErrInvalidParameters)
+	case mode == FastRange || mode == Blocked:
+		return fmt.Errorf("%w: filters using index mode %v can't be folded", ErrInvalidParameters, mode)
+	}
+	return nil
+}
+
+// The false positive rate of a filter of m bits with k hash functions holding
+// n items.
+func foldedFPRate(m, k, n uint64) float64 {
+	if n == 0 {
+		return 0
+	}
+	return math.Pow(1-math.Exp(-float64(k)*float64(n)/float64(m)), float64(k))
+}
+
+// Create a bloom filter like New, but with a number of bits that is a multiple
+// of maxFactor, so that it can be folded with Fold by maxFactor, or any factor
+// of it, e.g. by 2, 4 or 8 with a maxFactor of 8.
+func NewFoldable(n int, p float64, maxFactor int, opts ...Option) *Filter {
+	m, k := estimates(int64(n), p)
+	if maxFactor > 1 {
+		m += uint32(maxFactor) - 1
+		m -= m % uint32(maxFactor)
+	}
+	f := &Filter{
+		newFilter(m, k, opts...),
+		bitset.New32(m),
+	}
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
+
+// Shrinks the filter to 1/factor of its size, e.g. to half with a factor of 2,
+// by OR-ing together each of the factor slices of its bits. The folded filter
+// holds the same items, but has a higher false positive rate: FPRate reports
+// the rate it is expected to have once it holds the number of items it was
+// created for. This lets an over-sized filter be shrunk before it is sent to a
+// client with little memory. The factor must divide the number of bits in the
+// filter, as it does for filters created with NewFoldable. Filters using the
+// FastRange or Blocked index modes, a custom indexer, or one-hashing mode
+// can't be folded. Returns an error wrapping ErrInvalidParameters if the
+// filter can't be folded by factor.
+func (f *Filter) Fold(factor int) error {
+	if err := checkFold(factor, uint64(f.m), f.index, f.ix, f.parts != nil); err != nil {
+		return err
+	}
+	m := f.m / uint32(factor)
+	b := bitset.New32(m)
+	for i := uint32(0); i < f.m; i++ {
+		if f.b.Test(i) {
+			b.Set(i % m)
+		}
+	}
+	fl := f.filter.clone()
+	fl.m = m
+	if fl.capacity > 0 {
+		fl.fpRate = foldedFPRate(uint64(m), uint64(fl.k), fl.capacity)
+	}
+	f.filter = fl
+	f.b = b
+	return nil
+}
+
+// Shrinks the filter to 1/factor of its size by OR-ing together each of the
+// factor slices of its bits, like Filter.Fold.
+func (f *Filter64) Fold(factor int) error {
+	if err := checkFold(factor, f.m, f.index, f.ix, false); err != nil {
+		return err
+	}
+	m := f.m / uint64(factor)
+	b := bitset.New64(m)
+	for i := uint64(0); i < f.m; i++ {
+		if f.b.Test(i) {
+			b.Set(i % m)
+		}
+	}
+	fl := f.filter64.clone()
+	fl.m = m
+	if fl.capacity > 0 {
+		fl.fpRate = foldedFPRate(m, fl.k, fl.capacity)
+	}
+	f.filter64 = fl
+	f.b = b
+	return nil
+}
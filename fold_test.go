@@ -0,0 +1,70 @@
+package bloom
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	for _, mode := range []IndexMode{DoubleHashing, EnhancedDoubleHashing, IndependentHashing} {
+		f := NewFoldable(1000, 0.001, 4, WithIndexMode(mode))
+		f64 := New64(1000, 0.001, WithIndexMode(mode))
+		for i := 0; i < 1000; i++ {
+			f.Add([]byte(strconv.Itoa(i)))
+			f64.Add([]byte(strconv.Itoa(i)))
+		}
+		m, m64 := f.m, f64.m
+		if err := f.Fold(4); err != nil {
+			t.Fatal(err)
+		}
+		if f.m != m/4 || f.b.Len() != m/4 {
+			t.Errorf("%v: %d bits after folding %d by 4", mode, f.m, m)
+		}
+		if m64%2 == 0 {
+			if err := f64.Fold(2); err != nil {
+				t.Fatal(err)
+			}
+			if f64.m != m64/2 {
+				t.Errorf("%v: %d bits after folding %d by 2", mode, f64.m, m64)
+			}
+		}
+		for i := 0; i < 1000; i++ {
+			if !f.Test([]byte(strconv.Itoa(i))) || !f64.Test([]byte(strconv.Itoa(i))) {
+				t.Fatalf("%v: %d not in the folded filter", mode, i)
+			}
+		}
+	}
+}
+
+func TestFoldFPRate(t *testing.T) {
+	f := NewFoldable(1000, 0.01, 2)
+	if err := f.Fold(2); err != nil {
+		t.Fatal(err)
+	}
+	if p := f.FPRate(); p <= 0.01 || p > 0.2 {
+		t.Errorf("FPRate after folding by 2 is %f", p)
+	}
+}
+
+func TestFoldInvalid(t *testing.T) {
+	f := New(1000, 0.01)
+	if err := f.Fold(0); !errors.Is(err, ErrInvalidParameters) {
+		t.Errorf("Fold(0) returned %v", err)
+	}
+	if err := f.Fold(int(f.m) + 1); !errors.Is(err, ErrInvalidParameters) {
+		t.Errorf("Fold(m+1) returned %v", err)
+	}
+	if f := NewFoldable(1000, 0.01, 8); f.m%8 != 0 || f.Fold(8) != nil {
+		t.Errorf("NewFoldable filter of %d bits can't be folded by 8", f.m)
+	}
+	for _, mode := range []IndexMode{FastRange, Blocked} {
+		f := New(1000, 0.01, WithIndexMode(mode))
+		if err := f.Fold(1); !errors.Is(err, ErrInvalidParameters) {
+			t.Errorf("Fold of a %v filter returned %v", mode, err)
+		}
+	}
+	if err := NewOneHashing(1000, 0.01).Fold(1); !errors.Is(err, ErrInvalidParameters) {
+		t.Errorf("Fold of a one-hashing filter returned %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const gcsVersion = 1
+
+// Writes bits most significant bit first.
+type bitWriter struct {
+	buf []byte
+	n   uint // bits used in the last byte
+}
+
+func (w *bitWriter) writeBit(b bool) {
+	if w.n == 0 || w.n == 8 {
+		w.buf = append(w.buf, 0)
+		w.n = 0
+	}
+	if b {
+		w.buf[len(w.buf)-1] |= 0x80 >> w.n
+	}
+	w.n++
+}
+
+func (w *bitWriter) writeBits(v uint64, n uint) {
+	for i := n; i > 0; i-- {
+		w.writeBit(v>>(i-1)&1 == 1)
+	}
+}
+
+// Writes v as a Golomb-Rice code with a remainder of p bits.
+func (w *bitWriter) writeRice(v uint64, p uint) {
+	for q := v >> p; q > 0; q-- {
+		w.writeBit(true)
+	}
+	w.writeBit(false)
+	w.writeBits(v, p)
+}
+
+// Reads bits written by a bitWriter.
+type bitReader struct {
+	buf []byte
+	pos uint64
+}
+
+func (r *bitReader) readBit() (bool, bool) {
+	if r.pos >= uint64(len(r.buf))*8 {
+		return false, false
+	}
+	b := r.buf[r.pos>>3]&(0x80>>(r.pos&7)) != 0
+	r.pos++
+	return b, true
+}
+
+func (r *bitReader) readBits(n uint) (uint64, bool) {
+	var v uint64
+	for i := uint(0); i < n; i++ {
+		b, ok := r.readBit()
+		if !ok {
+			return 0, false
+		}
+		v <<= 1
+		if b {
+			v |= 1
+		}
+	}
+	return v, true
+}
+
+func (r *bitReader) readRice(p uint) (uint64, bool) {
+	var q uint64
+	for {
+		b, ok := r.readBit()
+		if !ok {
+			return 0, false
+		}
+		if !b {
+			break
+		}
+		q++
+	}
+	rem, ok := r.readBits(p)
+	return q<<p | rem, ok
+}
+
+// A Golomb-coded set: a static, compressed set of items using the 64-bit FNV-1
+// hash function. It uses close to the theoretical minimum of log2(1/p) bits per
+// item, making it well suited for transferring large sets, e.g. blocklists,
+// at the cost of slower queries, which have to decode the set from the start.
+type GolombSet struct {
+	n    uint64
+	p    uint
+	data []byte
+}
+
+// Maps data to [0, n<<p).
+func gcsHash(data []byte, n uint64, p uint) uint64 {
+	hi, _ := bits.Mul64(mix64(fnv64(data)), n<<p)
+	return hi
+}
+
+// Checks whether data is in the set. Returns true if yes, with a false
+// positive chance near the ratio specified upon creation of the set. The
+// result cannot be falsely negative.
+func (s *GolombSet) Test(data []byte) bool {
+	if s.n == 0 {
+		return false
+	}
+	target := gcsHash(data, s.n, s.p)
+	r := &bitReader{buf: s.data}
+	var v uint64
+	for i := uint64(0); i < s.n; i++ {
+		d, ok := r.readRice(s.p)
+		if !ok {
+			return false
+		}
+		v += d
+		if v == target {
+			return true
+		} else if v > target {
+			return false
+		}
+	}
+	return false
+}
+
+// Returns the number of items in the set.
+func (s *GolombSet) Len() int {
+	return int(s.n)
+}
+
+// Encodes the set into a binary form.
+func (s *GolombSet) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 2, 2+binary.MaxVarintLen64+len(s.data))
+	buf[0] = gcsVersion
+	buf[1] = byte(s.p)
+	buf = binary.AppendUvarint(buf, s.n)
+	return append(buf, s.data...), nil
+}
+
+// Decodes a set previously encoded with MarshalBinary, replacing the contents
+// of s.
+func (s *GolombSet) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 || data[0] != gcsVersion || data[1] > 32 {
+		return ErrInvalidEncoding
+	}
+	n, sz := binary.Uvarint(data[2:])
+	if sz <= 0 {
+		return ErrInvalidEncoding
+	}
+	s.n = n
+	s.p = uint(data[1])
+	s.data = append([]byte(nil), data[2+sz:]...)
+	return nil
+}
+
+// Create a Golomb-coded set containing items, with an acceptable false positive
+// rate of p, e.g. 0.01. The rate is rounded down to a power of two.
+func NewGolombSet(items [][]byte, p float64) *GolombSet {
+	bp := uint(math.Ceil(math.Log2(1 / p)))
+	if bp > 32 {
+		panic(fmt.Sprintf("A Golomb-coded set with p %f requires a remainder of %d bits, but the maximum supported size is 32 bits.", p, bp))
+	}
+	n := uint64(len(items))
+	vs := make([]uint64, 0, n)
+	for _, v := range items {
+		vs = append(vs, gcsHash(v, n, bp))
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i] < vs[j] })
+	w := &bitWriter{}
+	var last uint64
+	for _, v := range vs {
+		w.writeRice(v-last, bp)
+		last = v
+	}
+	return &GolombSet{
+		n:    n,
+		p:    bp,
+		data: w.buf,
+	}
+}
@@ -0,0 +1,52 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestGolombSet(t *testing.T) {
+	n := 10000
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = []byte(strconv.Itoa(i))
+	}
+	s := NewGolombSet(items, 0.001)
+	for _, v := range items {
+		if !s.Test(v) {
+			t.Fatalf("%s not in set", v)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if s.Test([]byte(strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if p := float64(fp) / float64(n); p > 0.002 {
+		t.Errorf("False positive rate too high: %f", p)
+	}
+	// About log2(1/p) + 1.5 bits per item
+	if bpi := float64(len(s.data)*8) / float64(n); bpi > 13 {
+		t.Errorf("%f bits per item", bpi)
+	}
+
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2 := &GolombSet{}
+	if err := s2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if s2.Len() != n || !s2.Test(items[42]) {
+		t.Error("decoded set differs")
+	}
+}
+
+func TestGolombSetEmpty(t *testing.T) {
+	s := NewGolombSet(nil, 0.01)
+	if s.Test(foo) {
+		t.Error("foo in empty set")
+	}
+}
@@ -0,0 +1,296 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/bits"
+	"unicode/utf16"
+)
+
+// The ordinals of Guava's BloomFilterStrategies, with which a filter's
+// serialized form starts
+const (
+	guavaMurmur128Mitz32 = 0
+	guavaMurmur128Mitz64 = 1
+)
+
+// The size of the header of Guava's serialized form: the strategy, the number
+// of hash functions, and the number of 64-bit words of bits
+const guavaHeaderSize = 6
+
+// Returns the 128-bit MurmurHash3 (x64 variant) sum of data with seed 0, as
+// Guava's Hashing.murmur3_128() computes it.
+func murmur3x64(data []byte) (uint64, uint64) {
+	const (
+		c1 = 0x87c37b91114253d5
+		c2 = 0x4cf5ad432745937f
+	)
+	var h1, h2 uint64
+	n := len(data)
+	for ; len(data) >= 16; data = data[16:] {
+		k1 := binary.LittleEndian.Uint64(data)
+		k2 := binary.LittleEndian.Uint64(data[8:])
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+		h1 = bits.RotateLeft64(h1, 27)
+		h1 += h2
+		h1 = h1*5 + 0x52dce729
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+		h2 = bits.RotateLeft64(h2, 31)
+		h2 += h1
+		h2 = h2*5 + 0x38495ab5
+	}
+	var k1, k2 uint64
+	for i := len(data) - 1; i >= 8; i-- {
+		k2 = k2<<8 | uint64(data[i])
+	}
+	for i := min(len(data), 8) - 1; i >= 0; i-- {
+		k1 = k1<<8 | uint64(data[i])
+	}
+	if len(data) > 8 {
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+	}
+	if len(data) > 0 {
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+	}
+	h1 ^= uint64(n)
+	h2 ^= uint64(n)
+	h1 += h2
+	h2 += h1
+	h1 = mix64(h1)
+	h2 = mix64(h2)
+	h1 += h2
+	h2 += h1
+	return h1, h2
+}
+
+// A bloom filter which hashes items and lays out its bits the way Guava's
+// com.google.common.hash.BloomFilter does, so that filters can be shared with
+// Java services: one serialized by Guava's BloomFilter.writeTo can be decoded
+// with UnmarshalBinary or ReadFrom, and one encoded by MarshalBinary or
+// WriteTo can be read with BloomFilter.readFrom. Filters created by NewGuava
+// use Guava's default strategy, MURMUR128_MITZ_64; decoded filters may also
+// use the older MURMUR128_MITZ_32.
+//
+// The items given to the filter must be the bytes the Java side's Funnel puts
+// into the hasher. For Funnels.byteArrayFunnel() that is the array itself,
+// and for Funnels.stringFunnel(UTF_8) a Go string's bytes; GuavaLong,
+// GuavaInt and GuavaChars return the bytes of the other common funnels.
+type GuavaFilter struct {
+	strategy byte
+	k        uint32
+	data     []uint64
+}
+
+// Create a filter for an expected n number of items, with an acceptable false
+// positive rate of p, e.g. 0.01, sized as Guava's BloomFilter.create(funnel,
+// n, p) sizes it, so that it has the same number of bits and hash functions
+// as the Java filter. Panics if n is negative or p isn't between 0 and 1.
+func NewGuava(n int64, p float64) *GuavaFilter {
+	if n < 0 || !(p > 0 && p < 1) {
+		panic("Unable to create a Guava filter for a negative number of items or an invalid false positive rate.")
+	}
+	if n == 0 {
+		n = 1
+	}
+	m := int64(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	if m < 1 || (m+63)/64 > math.MaxInt32 {
+		panic("Unable to create a Guava filter with more bits than Guava supports.")
+	}
+	k := max(1, int64(math.Floor(float64(m)/float64(n)*math.Ln2+0.5)))
+	return &GuavaFilter{
+		strategy: guavaMurmur128Mitz64,
+		k:        uint32(min(k, 255)),
+		data:     make([]uint64, (m+63)/64),
+	}
+}
+
+// Returns the number of hash functions (bits set per item).
+func (f *GuavaFilter) K() uint32 {
+	return f.k
+}
+
+// Returns the number of bits in the filter, a multiple of 64.
+func (f *GuavaFilter) M() uint64 {
+	return uint64(len(f.data)) * 64
+}
+
+// Calls fn with the index of each bit for data, until it returns false.
+func (f *GuavaFilter) each(data []byte, fn func(i uint64) bool) {
+	m := f.M()
+	h1, h2 := murmur3x64(data)
+	if f.strategy == guavaMurmur128Mitz32 {
+		x, y := int32(h1), int32(h1>>32)
+		for i := int32(1); i <= int32(f.k); i++ {
+			c := x + i*y
+			if c < 0 {
+				c = ^c
+			}
+			if !fn(uint64(c) % m) {
+				return
+			}
+		}
+		return
+	}
+	c := h1
+	for i := uint32(0); i < f.k; i++ {
+		if !fn((c & math.MaxInt64) % m) {
+			return
+		}
+		c += h2
+	}
+}
+
+// Checks whether data was (probably) added to the filter.
+func (f *GuavaFilter) Test(data []byte) bool {
+	found := true
+	f.each(data, func(i uint64) bool {
+		found = f.data[i/64]&(1<<(i%64)) != 0
+		return found
+	})
+	return found
+}
+
+// Adds data to the filter.
+func (f *GuavaFilter) Add(data []byte) {
+	f.each(data, func(i uint64) bool {
+		f.data[i/64] |= 1 << (i % 64)
+		return true
+	})
+}
+
+// Equivalent to calling Test(data) then Add(data). Returns the result of Test.
+func (f *GuavaFilter) TestAndAdd(data []byte) bool {
+	present := true
+	f.each(data, func(i uint64) bool {
+		if f.data[i/64]&(1<<(i%64)) == 0 {
+			present = false
+			f.data[i/64] |= 1 << (i % 64)
+		}
+		return true
+	})
+	return present
+}
+
+// Resets the filter.
+func (f *GuavaFilter) Reset() {
+	clear(f.data)
+}
+
+// Encodes the filter in Guava's serialized form, as BloomFilter.writeTo
+// writes it: the strategy's ordinal, the number of hash functions, the number
+// of 64-bit words, and the words, all big-endian.
+func (f *GuavaFilter) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, guavaHeaderSize, guavaHeaderSize+8*len(f.data))
+	buf[0] = f.strategy
+	buf[1] = byte(f.k)
+	binary.BigEndian.PutUint32(buf[2:], uint32(len(f.data)))
+	for _, w := range f.data {
+		buf = binary.BigEndian.AppendUint64(buf, w)
+	}
+	return buf, nil
+}
+
+// Decodes the header of Guava's serialized form.
+func decodeGuavaHeader(data []byte) (strategy byte, k uint32, words int, err error) {
+	strategy, k = data[0], uint32(data[1])
+	words = int(int32(binary.BigEndian.Uint32(data[2:])))
+	if strategy > guavaMurmur128Mitz64 || k == 0 || words < 1 {
+		return 0, 0, 0, ErrInvalidEncoding
+	}
+	return strategy, k, words, nil
+}
+
+// Decodes a filter in Guava's serialized form, e.g. one written by
+// BloomFilter.writeTo, replacing the contents of f.
+func (f *GuavaFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < guavaHeaderSize {
+		return ErrInvalidEncoding
+	}
+	strategy, k, words, err := decodeGuavaHeader(data)
+	if err != nil {
+		return err
+	}
+	data = data[guavaHeaderSize:]
+	if len(data) != 8*words {
+		return ErrInvalidEncoding
+	}
+	f.strategy, f.k, f.data = strategy, k, make([]uint64, words)
+	for i := range f.data {
+		f.data[i] = binary.BigEndian.Uint64(data[8*i:])
+	}
+	return nil
+}
+
+// Writes the filter to w in Guava's serialized form, as MarshalBinary encodes
+// it. Unlike the WriteTo methods of the other filters it writes no length, so
+// that Guava's BloomFilter.readFrom can read it.
+func (f *GuavaFilter) WriteTo(w io.Writer) (int64, error) {
+	buf, _ := f.MarshalBinary()
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// Reads a filter in Guava's serialized form from r, e.g. one written by
+// BloomFilter.writeTo, replacing the contents of f. Only the filter's own
+// bytes are read.
+func (f *GuavaFilter) ReadFrom(r io.Reader) (int64, error) {
+	header := make([]byte, guavaHeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return int64(n), err
+	}
+	_, _, words, err := decodeGuavaHeader(header)
+	if err != nil {
+		return int64(n), err
+	}
+	// Read the words as they arrive rather than trusting the header with an
+	// allocation
+	var buf bytes.Buffer
+	buf.Write(header)
+	m, err := io.CopyN(&buf, r, 8*int64(words))
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return int64(n) + m, err
+	}
+	return int64(n) + m, f.UnmarshalBinary(buf.Bytes())
+}
+
+// Returns the bytes Guava's Funnels.longFunnel() puts for v, to add or test a
+// Long with a GuavaFilter.
+func GuavaLong(v int64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, uint64(v))
+}
+
+// Returns the bytes Guava's Funnels.integerFunnel() puts for v.
+func GuavaInt(v int32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, uint32(v))
+}
+
+// Returns the bytes Guava's Funnels.unencodedCharsFunnel() puts for s, i.e.
+// its UTF-16 code units, little-endian.
+func GuavaChars(s string) []byte {
+	var buf []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		buf = binary.LittleEndian.AppendUint16(buf, c)
+	}
+	return buf
+}
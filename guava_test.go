@@ -0,0 +1,122 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestMurmur3x64(t *testing.T) {
+	cases := []struct {
+		data string
+		sum  string // the bytes of the sum, as Guava's HashCode.toString
+	}{
+		{"", "00000000000000000000000000000000"},
+		{"The quick brown fox jumps over the lazy dog", "6c1b07bc7bbc4be347939ac4a93c437a"},
+	}
+	for _, c := range cases {
+		h1, h2 := murmur3x64([]byte(c.data))
+		var buf [16]byte
+		for i := 0; i < 8; i++ {
+			buf[i], buf[8+i] = byte(h1>>(8*i)), byte(h2>>(8*i))
+		}
+		if got := hex.EncodeToString(buf[:]); got != c.sum {
+			t.Errorf("murmur3x64(%q) = %s, want %s", c.data, got, c.sum)
+		}
+	}
+}
+
+func TestGuavaFilter(t *testing.T) {
+	f := NewGuava(1000, 0.01)
+	// BloomFilter.create(funnel, 1000, 0.01) has 9585 bits, rounded up to
+	// 150 words, and 7 hash functions
+	if f.M() != 9600 || f.K() != 7 {
+		t.Fatalf("m = %d, k = %d", f.M(), f.K())
+	}
+	for i := 0; i < 1000; i++ {
+		f.Add(GuavaLong(int64(i)))
+	}
+	fp := 0
+	for i := 0; i < 1000; i++ {
+		if !f.Test(GuavaLong(int64(i))) {
+			t.Fatalf("%d not found", i)
+		}
+		if f.Test(GuavaLong(int64(1000 + i))) {
+			fp++
+		}
+	}
+	if fp > 30 {
+		t.Errorf("%d false positives in 1000", fp)
+	}
+	if !f.TestAndAdd(GuavaLong(1)) || f.TestAndAdd([]byte("new")) || !f.Test([]byte("new")) {
+		t.Error("unexpected TestAndAdd")
+	}
+	f.Reset()
+	if f.Test(GuavaLong(1)) {
+		t.Error("item found after Reset")
+	}
+}
+
+func TestGuavaFilterMarshal(t *testing.T) {
+	f := NewGuava(100, 0.03)
+	f.Add([]byte("abc"))
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("01%02x%08x", f.K(), f.M()/64); hex.EncodeToString(data[:6]) != want {
+		t.Errorf("header %x, want %s", data[:6], want)
+	}
+	g := &GuavaFilter{}
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Test([]byte("abc")) || g.M() != f.M() || g.K() != f.K() {
+		t.Error("decoded filter differs")
+	}
+	if err := g.UnmarshalBinary(data[:len(data)-1]); err != ErrInvalidEncoding {
+		t.Errorf("decoding truncated data returned %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("trailer")
+	h := &GuavaFilter{}
+	if n, err := h.ReadFrom(&buf); err != nil || n != int64(len(data)) || buf.String() != "trailer" {
+		t.Fatalf("ReadFrom read %d bytes, %v", n, err)
+	}
+	if !h.Test([]byte("abc")) {
+		t.Error("read filter differs")
+	}
+	if _, err := h.ReadFrom(bytes.NewReader(data[:10])); err == nil {
+		t.Error("read truncated data")
+	}
+}
+
+func TestGuavaFilterMitz32(t *testing.T) {
+	f := &GuavaFilter{strategy: guavaMurmur128Mitz32, k: 5, data: make([]uint64, 16)}
+	f.Add(GuavaChars("héllo"))
+	data, _ := f.MarshalBinary()
+	if data[0] != 0 {
+		t.Fatalf("strategy %d", data[0])
+	}
+	g := &GuavaFilter{}
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Test(GuavaChars("héllo")) || g.Test(GuavaChars("hello")) {
+		t.Error("unexpected MURMUR128_MITZ_32 filter")
+	}
+}
+
+func TestGuavaFunnels(t *testing.T) {
+	if got := hex.EncodeToString(GuavaInt(0x01020304)); got != "04030201" {
+		t.Errorf("GuavaInt = %s", got)
+	}
+	if got := hex.EncodeToString(GuavaChars("a€")); got != "6100ac20" {
+		t.Errorf("GuavaChars = %s", got)
+	}
+}
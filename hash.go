@@ -0,0 +1,76 @@
+package bloom
+
+import (
+	"hash"
+	"math/bits"
+)
+
+// Finalizes a 64-bit hash value (the MurmurHash3 fmix64 step) so that every
+// input bit affects every output bit. FNV-1 on its own only mixes the last
+// bytes of its input into the low bits of the sum, which is a problem for
+// structures that use the high bits, e.g. HyperLogLog registers.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// Returns the mixed 64-bit sum of data using h.
+func sum64(h hash.Hash64, data []byte) uint64 {
+	h.Reset()
+	h.Write(data)
+	return mix64(h.Sum64())
+}
+
+// Returns a single mixed 64-bit hash of data, using hf if it is set, or else
+// h, or FNV-1 if h is nil.
+func hash64(hf HashFunc, h hash.Hash64, data []byte) uint64 {
+	if hf != nil {
+		x, _ := hf(data)
+		return mix64(x)
+	}
+	if h == nil {
+		return mix64(fnv64(data))
+	}
+	return sum64(h, data)
+}
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// Returns the 64-bit FNV-1 sum of data. Unlike a hash.Hash64 it keeps no
+// state, so it is safe for concurrent use.
+func fnv64(data []byte) uint64 {
+	h := uint64(fnvOffset64)
+	for _, c := range data {
+		h *= fnvPrime64
+		h ^= uint64(c)
+	}
+	return h
+}
+
+const (
+	fnvOffset128Hi = 0x6c62272e07bb0142
+	fnvOffset128Lo = 0x62b821756295c58d
+	fnvPrime128Lo  = 0x13b // the prime is 1<<88 + 0x13b
+)
+
+// Returns the high and low halves of the 128-bit FNV-1 sum of data.
+func fnv128(data []byte) (uint64, uint64) {
+	return fnv128Write(fnvOffset128Hi, fnvOffset128Lo, data)
+}
+
+// Returns the 128-bit FNV-1 sum whose state is hi, lo after writing data.
+func fnv128Write(hi, lo uint64, data []byte) (uint64, uint64) {
+	for _, c := range data {
+		phi, plo := bits.Mul64(lo, fnvPrime128Lo)
+		hi, lo = phi+hi*fnvPrime128Lo+lo<<24, plo
+		lo ^= uint64(c)
+	}
+	return hi, lo
+}
@@ -0,0 +1,19 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"testing"
+)
+
+func TestFNV128(t *testing.T) {
+	for _, v := range []string{"", "a", "foo", "foobar", "The quick brown fox jumps over the lazy dog"} {
+		h := fnv.New128()
+		h.Write([]byte(v))
+		sum := h.Sum(nil)
+		hi, lo := fnv128([]byte(v))
+		if hi != binary.BigEndian.Uint64(sum[:8]) || lo != binary.BigEndian.Uint64(sum[8:]) {
+			t.Errorf("fnv128(%q) = %016x%016x, expected %x", v, hi, lo, sum)
+		}
+	}
+}
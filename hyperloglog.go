@@ -0,0 +1,133 @@
+package bloom
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	hllMinPrecision = 4
+	hllMaxPrecision = 18
+	hllVersion      = 1
+)
+
+// A HyperLogLog cardinality estimator using the 64-bit FNV-1 hash function.
+// It estimates the number of distinct items added to it using a fixed amount
+// of memory, and can be used alongside a bloom filter fed with the same keys.
+type HyperLogLog struct {
+	p   uint8
+	reg []uint8
+	h   hash.Hash64
+}
+
+// Adds data to the estimator.
+func (l *HyperLogLog) Add(data []byte) {
+	x := sum64(l.h, data)
+	i := x >> (64 - l.p)
+	rho := uint8(bits.LeadingZeros64(x<<l.p|1<<(l.p-1)) + 1)
+	if rho > l.reg[i] {
+		l.reg[i] = rho
+	}
+}
+
+// Returns the estimated number of distinct items added to the estimator.
+func (l *HyperLogLog) Estimate() uint64 {
+	m := float64(len(l.reg))
+	sum := 0.0
+	zeros := 0
+	for _, v := range l.reg {
+		sum += 1 / float64(uint64(1)<<v)
+		if v == 0 {
+			zeros++
+		}
+	}
+	e := hllAlpha(len(l.reg)) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// Small range correction (linear counting)
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(e + 0.5)
+}
+
+// Merges other into l, so that l estimates the cardinality of the union of
+// both streams. Returns ErrIncompatible if the precisions differ.
+func (l *HyperLogLog) Merge(other *HyperLogLog) error {
+	if l.p != other.p {
+		return ErrIncompatible
+	}
+	for i, v := range other.reg {
+		if v > l.reg[i] {
+			l.reg[i] = v
+		}
+	}
+	return nil
+}
+
+// Resets the estimator.
+func (l *HyperLogLog) Reset() {
+	for i := range l.reg {
+		l.reg[i] = 0
+	}
+}
+
+// Encodes the estimator into a binary form.
+func (l *HyperLogLog) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 2+len(l.reg))
+	buf[0] = hllVersion
+	buf[1] = l.p
+	copy(buf[2:], l.reg)
+	return buf, nil
+}
+
+// Decodes an estimator previously encoded with MarshalBinary, replacing the
+// contents of l.
+func (l *HyperLogLog) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 || data[0] != hllVersion {
+		return ErrInvalidEncoding
+	}
+	p := data[1]
+	if p < hllMinPrecision || p > hllMaxPrecision || len(data) != 2+1<<p {
+		return ErrInvalidEncoding
+	}
+	l.p = p
+	l.reg = append([]uint8(nil), data[2:]...)
+	if l.h == nil {
+		l.h = fnv.New64()
+	}
+	return nil
+}
+
+func hllAlpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/float64(m))
+}
+
+// Create a HyperLogLog estimator with an acceptable standard error of e, e.g.
+// 0.01 for 1%. The estimator uses roughly (1.04/e)^2 bytes of memory. Panics
+// if e isn't between 0 and 1.
+func NewHyperLogLog(e float64) *HyperLogLog {
+	if !(e > 0 && e < 1) {
+		panic(fmt.Sprintf("Unable to create a HyperLogLog: %v: the standard error is %v, but must be between 0 and 1", ErrInvalidParameters, e))
+	}
+	p := uint8(math.Ceil(math.Log2(math.Pow(1.04/e, 2))))
+	if p < hllMinPrecision {
+		p = hllMinPrecision
+	} else if p > hllMaxPrecision {
+		panic(fmt.Sprintf("A HyperLogLog with standard error %f requires a precision of %d, but the maximum supported precision is %d.", e, p, hllMaxPrecision))
+	}
+	return &HyperLogLog{
+		p:   p,
+		reg: make([]uint8, 1<<p),
+		h:   fnv.New64(),
+	}
+}
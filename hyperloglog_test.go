@@ -0,0 +1,84 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+func addRange(l *HyperLogLog, from, to uint32) {
+	n := make([]byte, 4)
+	for i := from; i < to; i++ {
+		binary.BigEndian.PutUint32(n, i)
+		l.Add(n)
+	}
+}
+
+func checkEstimate(t *testing.T, l *HyperLogLog, n uint64, e float64) {
+	est := l.Estimate()
+	if diff := math.Abs(float64(est)-float64(n)) / float64(n); diff > e {
+		t.Errorf("estimate %d for %d items is off by %f", est, n, diff)
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	l := NewHyperLogLog(0.01)
+	addRange(l, 0, 100000)
+	// Adding the same items again shouldn't change the estimate
+	addRange(l, 0, 100000)
+	checkEstimate(t, l, 100000, 0.03)
+	l.Reset()
+	if est := l.Estimate(); est != 0 {
+		t.Errorf("estimate after reset is %d", est)
+	}
+	addRange(l, 0, 100)
+	checkEstimate(t, l, 100, 0.03)
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	a := NewHyperLogLog(0.01)
+	b := NewHyperLogLog(0.01)
+	addRange(a, 0, 60000)
+	addRange(b, 40000, 100000)
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	checkEstimate(t, a, 100000, 0.03)
+	if err := a.Merge(NewHyperLogLog(0.1)); err != ErrIncompatible {
+		t.Errorf("merging different precisions returned %v", err)
+	}
+}
+
+func TestHyperLogLogMarshal(t *testing.T) {
+	a := NewHyperLogLog(0.02)
+	addRange(a, 0, 5000)
+	data, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &HyperLogLog{}
+	if err := b.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if a.Estimate() != b.Estimate() {
+		t.Errorf("estimates differ after decoding: %d, %d", a.Estimate(), b.Estimate())
+	}
+	if err := b.UnmarshalBinary(data[:len(data)-1]); err != ErrInvalidEncoding {
+		t.Errorf("decoding truncated data returned %v", err)
+	}
+}
+
+func TestHyperLogLogInvalid(t *testing.T) {
+	for _, e := range []float64{0, -0.01, 1, 2, math.NaN()} {
+		func() {
+			defer func() {
+				r := recover()
+				if msg, _ := r.(string); !strings.Contains(msg, ErrInvalidParameters.Error()) {
+					t.Errorf("panic %v with standard error %v", r, e)
+				}
+			}()
+			NewHyperLogLog(e)
+		}()
+	}
+}
@@ -0,0 +1,180 @@
+package bloom
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// The scheme used to derive the k bit indexes of an item from its two hashes
+// a and b.
+type IndexMode uint8
+
+const (
+	// Double hashing: index i is (a + b*i) mod m. This is the scheme used by
+	// earlier versions of go-bloom. It degenerates when b mod m is 0, or
+	// shares factors with m, in which case several (or all) of the k indexes
+	// collapse onto the same few bits.
+	DoubleHashing IndexMode = iota
+
+	// Enhanced double hashing (Dillinger & Manolios): b is increased by i
+	// after every index, so that even when b mod m is 0 only the first two
+	// indexes coincide. This is the default.
+	EnhancedDoubleHashing
+
+	// Enhanced double hashing over the full width of the hashes, with each
+	// value mapped onto [0, m) by Lemire's multiply-shift reduction rather
+	// than by mod m. This avoids a division per index, as well as the slight
+	// bias of mod m when m is not a power of two. Since nearby values map
+	// onto the same index, b is increased by i times the golden ratio
+	// rather than by i.
+	FastRange
+
+	// Every index is derived from its own invocation of SipHash-2-4, keyed
+	// with the filter's seed and the index number, rather than from a and b.
+	// This is much slower, but the indexes are independent, as the textbook
+	// false positive formula assumes, so it is useful for verifying measured
+	// false positive rates. Any hash function given with WithHash is not used.
+	IndependentHashing
+
+	// Blocked (cache-line) hashing: a selects one block of 512 bits, and the
+	// k indexes are derived from b by enhanced double hashing within that
+	// block, so that adding or testing an item touches a single cache line.
+	// This is faster for large filters, at the cost of a somewhat higher
+	// false positive rate.
+	Blocked
+
+	numIndexModes
+)
+
+func (mode IndexMode) String() string {
+	switch mode {
+	case DoubleHashing:
+		return "DoubleHashing"
+	case EnhancedDoubleHashing:
+		return "EnhancedDoubleHashing"
+	case FastRange:
+		return "FastRange"
+	case IndependentHashing:
+		return "IndependentHashing"
+	case Blocked:
+		return "Blocked"
+	}
+	return fmt.Sprintf("IndexMode(%d)", uint8(mode))
+}
+
+// Makes the filter derive bit indexes using mode. Filters decoded from an
+// encoding always use the mode they were encoded with.
+func WithIndexMode(mode IndexMode) Option {
+	return func(o *options) {
+		o.index = mode
+	}
+}
+
+// A function filling out with the k bit indexes of data, each in [0, m). It
+// replaces the filter's index derivation entirely, including its hashing.
+type Indexer func(data []byte, k, m uint64, out []uint64)
+
+// Makes the filter derive the bit indexes of items using ix, e.g. to use a
+// locality-preserving scheme, or one matching an implementation elsewhere.
+// The hash function, seed and index mode of the filter are not used, and
+// one-hashing filters ignore ix. Like a hash function given with WithHash,
+// ix is not included when the filter is encoded.
+func WithIndexer(ix Indexer) Option {
+	return func(o *options) {
+		o.indexer = ix
+	}
+}
+
+// The number of bits in a block in Blocked mode: one 64-byte cache line
+const blockBits = 512
+
+// 2^32 and 2^64 divided by the golden ratio
+const (
+	golden32 = 0x9e3779b9
+	golden64 = 0x9e3779b97f4a7c15
+)
+
+// Fills is with indexes in [0, m) derived from a and b.
+func indexes32(is []uint32, a, b, m uint32, mode IndexMode) {
+	switch mode {
+	case EnhancedDoubleHashing:
+		x, y := uint64(a%m), uint64(b%m)
+		for i := range is {
+			is[i] = uint32(x)
+			x = (x + y) % uint64(m)
+			y = (y + uint64(i) + 1) % uint64(m)
+		}
+	case FastRange:
+		for i := range is {
+			is[i] = uint32(uint64(a) * uint64(m) >> 32)
+			a += b
+			b += (uint32(i) + 1) * golden32
+		}
+	case Blocked:
+		size := uint32(blockBits)
+		if m < size {
+			size = m
+		}
+		start := uint32(uint64(a)*uint64(m/size)>>32) * size
+		x, y := b%size, (a|1)%size
+		for i := range is {
+			is[i] = start + x
+			x = (x + y) % size
+			y = (y + uint32(i) + 1) % size
+		}
+	default:
+		for i := range is {
+			is[i] = (a + b*uint32(i)) % m
+		}
+	}
+}
+
+// Returns (x + y) mod m for x, y < m, without overflowing.
+func addMod64(x, y, m uint64) uint64 {
+	if x >= m-y {
+		return x - (m - y)
+	}
+	return x + y
+}
+
+func indexes64(is []uint64, a, b, m uint64, mode IndexMode) {
+	switch mode {
+	case EnhancedDoubleHashing:
+		x, y := a%m, b%m
+		for i := range is {
+			is[i] = x
+			x = addMod64(x, y, m)
+			y = addMod64(y, (uint64(i)+1)%m, m)
+		}
+	case FastRange:
+		for i := range is {
+			is[i], _ = bits.Mul64(a, m)
+			a += b
+			b += (uint64(i) + 1) * golden64
+		}
+	case Blocked:
+		size := uint64(blockBits)
+		if m < size {
+			size = m
+		}
+		block, _ := bits.Mul64(a, m/size)
+		start := block * size
+		x, y := b%size, (a|1)%size
+		for i := range is {
+			is[i] = start + x
+			x = (x + y) % size
+			y = (y + uint64(i) + 1) % size
+		}
+	default:
+		for i := range is {
+			is[i] = (a + b*uint64(i)) % m
+		}
+	}
+}
+
+// Fills is with independent indexes in [0, m) for data; see IndependentHashing.
+func independentIndexes(is []uint64, data []byte, seed, m uint64) {
+	for i := range is {
+		is[i] = sipHash(seed, uint64(i), data, 2, 4) % m
+	}
+}
@@ -0,0 +1,176 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+
+	"testing"
+)
+
+func TestIndexesDegenerate(t *testing.T) {
+	// b mod m == 0 collapses every index of plain double hashing onto a
+	is := make([]uint32, 8)
+	indexes32(is, 5, 1000, 1000, DoubleHashing)
+	for _, v := range is {
+		if v != 5 {
+			t.Fatalf("expected every double hashing index to be 5, got %v", is)
+		}
+	}
+	// Enhanced double hashing only repeats the first index
+	indexes32(is, 5, 1000, 1000, EnhancedDoubleHashing)
+	seen := map[uint32]bool{}
+	for _, v := range is[1:] {
+		if seen[v] {
+			t.Fatalf("enhanced double hashing repeated index %d: %v", v, is)
+		}
+		seen[v] = true
+	}
+
+	is64 := make([]uint64, 8)
+	indexes64(is64, 5, 1000, 1000, EnhancedDoubleHashing)
+	for i, v := range is64 {
+		if v != uint64(is[i]) {
+			t.Fatalf("64-bit indexes %v differ from 32-bit indexes %v", is64, is)
+		}
+	}
+}
+
+func TestIndexes64NoOverflow(t *testing.T) {
+	const m = 1<<64 - 59
+	is := make([]uint64, 16)
+	indexes64(is, m-1, m-2, m, EnhancedDoubleHashing)
+	for _, v := range is {
+		if v >= m {
+			t.Fatalf("index %d out of range", v)
+		}
+	}
+}
+
+func TestIndexModes(t *testing.T) {
+	for _, mode := range []IndexMode{DoubleHashing, EnhancedDoubleHashing, FastRange, IndependentHashing} {
+		f := New(1000, 0.01, WithIndexMode(mode))
+		f.Add(foo)
+		if !f.Test(foo) {
+			t.Errorf("%v: foo not in filter", mode)
+		}
+		if f.Test(bar) {
+			t.Errorf("%v: bar in filter", mode)
+		}
+	}
+}
+
+func TestFastRange(t *testing.T) {
+	is := make([]uint32, 4)
+	indexes32(is, 1<<31, 0, 1000, FastRange)
+	if is[0] != 500 || is[1] != 500 || is[2] == 500 || is[3] == is[2] {
+		t.Errorf("unexpected 32-bit indexes %v", is)
+	}
+	is64 := make([]uint64, 4)
+	indexes64(is64, 1<<63, 0, 1000, FastRange)
+	if is64[0] != 500 || is64[1] != 500 || is64[2] == 500 || is64[3] == is64[2] {
+		t.Errorf("unexpected 64-bit indexes %v", is64)
+	}
+	indexes32(is, 1<<32-1, 1<<32-1, 1000, FastRange)
+	for _, v := range is {
+		if v >= 1000 {
+			t.Fatalf("index %d out of range", v)
+		}
+	}
+
+	n := uint32(10000)
+	f := New(int(n), 0.001, WithIndexMode(FastRange))
+	want := theoreticalP(f.m, f.k, n)
+	if p := estimateP(f, n); p > 2*want {
+		t.Errorf("false positive rate %f, expected about %f", p, want)
+	}
+}
+
+func TestIndependentHashing(t *testing.T) {
+	n := uint32(10000)
+	for _, f := range []*Filter{
+		New(int(n), 0.01, WithIndexMode(IndependentHashing)),
+		{newFilter(n*15, 10, WithIndexMode(IndependentHashing)), bitset.New32(n * 15)},
+	} {
+		want := theoreticalP(f.m, f.k, n)
+		if p := estimateP(f, n); p > 2*want || p < want/2 {
+			t.Errorf("m %d k %d: false positive rate %f, expected about %f", f.m, f.k, p, want)
+		}
+	}
+
+	f := New64(int64(n), 0.01, WithIndexMode(IndependentHashing))
+	g := New64(int64(n), 0.01, WithIndexMode(IndependentHashing), WithSeed(1))
+	f.Add(foo)
+	g.Add(foo)
+	if !f.Test(foo) || !g.Test(foo) {
+		t.Error("foo not in 64-bit filter")
+	}
+	fi, gi := f.bits(foo), g.bits(foo)
+	if fi[0] == gi[0] && fi[1] == gi[1] {
+		t.Errorf("seeds don't change indexes: %v %v", fi, gi)
+	}
+}
+
+func TestWithIndexer(t *testing.T) {
+	// Sets the bits just after the first byte of data
+	ix := func(data []byte, k, m uint64, out []uint64) {
+		for i := range out {
+			out[i] = (uint64(data[0]) + uint64(i)) % m
+		}
+	}
+	f := New(1000, 0.01, WithIndexer(ix))
+	f.Add([]byte("a"))
+	for i := range f.bits([]byte("a")) {
+		if !f.b.Test(uint32('a' + i)) {
+			t.Errorf("bit %d not set", 'a'+i)
+		}
+	}
+	if !f.Test([]byte("abc")) || f.Test([]byte("b")) {
+		t.Error("unexpected results with custom indexer")
+	}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := New(10, 0.01, WithIndexer(ix))
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Test([]byte("abc")) || g.ix == nil {
+		t.Error("decoded filter does not keep the custom indexer")
+	}
+
+	f64 := New64(1000, 0.01, WithIndexer(ix))
+	f64.Add([]byte("a"))
+	if !f64.Test([]byte("abc")) || f64.Test([]byte("b")) {
+		t.Error("unexpected results with custom indexer (64-bit)")
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	is := make([]uint32, 8)
+	indexes32(is, 1<<31, 12345, 10000, Blocked)
+	for _, v := range is {
+		if v/blockBits != is[0]/blockBits {
+			t.Fatalf("indexes %v span several blocks", is)
+		}
+	}
+	is64 := make([]uint64, 8)
+	indexes64(is64, 1<<63, 12345, 10000, Blocked)
+	for i, v := range is64 {
+		if v != uint64(is[i]) {
+			t.Fatalf("64-bit indexes %v differ from 32-bit indexes %v", is64, is)
+		}
+	}
+	indexes32(is, 1<<32-1, 1<<32-1, 100, Blocked)
+	for _, v := range is {
+		if v >= 100 {
+			t.Fatalf("index %d out of range", v)
+		}
+	}
+
+	n := uint32(10000)
+	f := New(int(n), 0.01, WithIndexMode(Blocked))
+	want := theoreticalP(f.m, f.k, n)
+	if p := estimateP(f, n); p > 2*want {
+		t.Errorf("false positive rate %f, expected about %f", p, want)
+	}
+}
@@ -0,0 +1,61 @@
+package bloom
+
+import (
+	"bytes"
+	"sync/atomic"
+)
+
+// An inverse bloom filter using the 64-bit FNV-1 hash function: a fixed-size,
+// lossy hash table of recently seen items. Where a bloom filter can have false
+// positives but no false negatives, an inverse filter can have false negatives
+// (an item is forgotten when a newer item claims its slot) but no false
+// positives. It never grows, and is safe for concurrent use by multiple
+// goroutines.
+type InverseFilter struct {
+	slots []atomic.Pointer[[]byte]
+	hf    HashFunc
+}
+
+func (f *InverseFilter) slot(data []byte) *atomic.Pointer[[]byte] {
+	return &f.slots[hash64(f.hf, nil, data)%uint64(len(f.slots))]
+}
+
+// Checks whether data was recently added to the filter. Returns true only if
+// data definitely was added; false means it either wasn't, or was forgotten.
+func (f *InverseFilter) Test(data []byte) bool {
+	old := f.slot(data).Load()
+	return old != nil && bytes.Equal(*old, data)
+}
+
+// Adds data to the filter, possibly evicting another item.
+func (f *InverseFilter) Add(data []byte) {
+	c := append([]byte(nil), data...)
+	f.slot(data).Store(&c)
+}
+
+// Adds data to the filter, and returns whether it was already present, as
+// reported by Test, before it was added.
+func (f *InverseFilter) TestAndAdd(data []byte) bool {
+	c := append([]byte(nil), data...)
+	old := f.slot(data).Swap(&c)
+	return old != nil && bytes.Equal(*old, data)
+}
+
+// Resets the filter.
+func (f *InverseFilter) Reset() {
+	for i := range f.slots {
+		f.slots[i].Store(nil)
+	}
+}
+
+// Create an inverse bloom filter with room for size items. Memory usage is
+// proportional to size plus the size of the items that are held.
+func NewInverse(size int, opts ...Option) *InverseFilter {
+	if size < 1 {
+		size = 1
+	}
+	return &InverseFilter{
+		slots: make([]atomic.Pointer[[]byte], size),
+		hf:    newOptions(opts).hash,
+	}
+}
@@ -0,0 +1,44 @@
+package bloom
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestInverseFilter(t *testing.T) {
+	f := NewInverse(1000)
+	if f.TestAndAdd(foo) {
+		t.Error("foo reported as present before it was added")
+	}
+	if !f.TestAndAdd(foo) {
+		t.Error("foo not reported as present")
+	}
+	if f.Test(bar) {
+		t.Error("bar in filter")
+	}
+	f.Reset()
+	if f.Test(foo) {
+		t.Error("foo in filter after reset")
+	}
+}
+
+func TestInverseFilterNoFalsePositives(t *testing.T) {
+	f := NewInverse(10)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				f.Add([]byte(strconv.Itoa(g*1000 + i)))
+			}
+		}(g)
+	}
+	wg.Wait()
+	for i := 4000; i < 5000; i++ {
+		if f.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%d in filter", i)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package bloom
+
+import (
+	"hash"
+	"hash/fnv"
+)
+
+// Writes a single item into a filter in pieces, so that large items, e.g. the
+// contents of files, don't have to be held in memory. See NewItemWriter.
+type ItemWriter struct {
+	f      *Filter
+	hi, lo uint64      // FNV-128 state
+	h      hash.Hash64 // used instead in legacy mode
+	buf    []byte      // the whole item, if the filter's hashing can't stream
+}
+
+// Whether the filter's bit indexes can be derived without having all of an
+// item at once.
+func (f *filter) streams() bool {
+	return f.hf == nil && f.ix == nil && f.index != IndependentHashing
+}
+
+// Appends p to the item. It never returns an error.
+func (w *ItemWriter) Write(p []byte) (int, error) {
+	switch {
+	case !w.f.streams():
+		w.buf = append(w.buf, p...)
+	case w.f.legacy:
+		w.h.Write(p)
+	default:
+		w.hi, w.lo = fnv128Write(w.hi, w.lo, p)
+	}
+	return len(p), nil
+}
+
+// Equivalent to Write, but for strings.
+func (w *ItemWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *ItemWriter) bits() []uint32 {
+	f := w.f.filter
+	switch {
+	case !f.streams():
+		return f.bits(w.buf)
+	case f.legacy:
+		x := w.h.Sum64()
+		return f.sumBits(f.seeded(x, x>>32))
+	}
+	return f.sumBits(f.seeded(mix64(w.lo), mix64(w.hi)))
+}
+
+// Checks whether the item written so far was previously added to the filter,
+// as with Test.
+func (w *ItemWriter) Test() bool {
+	return testBits32(w.f.b, w.bits())
+}
+
+// Adds the item written so far to the filter, as with Add, and resets the
+// writer, so that it can be used to write another item.
+func (w *ItemWriter) Commit() {
+	for _, i := range w.bits() {
+		w.f.b.Set(i)
+	}
+	w.Reset()
+}
+
+// Discards the item written so far.
+func (w *ItemWriter) Reset() {
+	w.hi, w.lo = fnvOffset128Hi, fnvOffset128Lo
+	w.h.Reset()
+	w.buf = w.buf[:0]
+}
+
+// Returns a writer to which an item can be written in pieces before adding it
+// to, or testing it against, the filter. Writing an item in pieces gives the
+// same result as adding it with Add. Unless the filter uses the default
+// hashing, the item is still held in memory until it is committed.
+func (f *Filter) NewItemWriter() *ItemWriter {
+	w := &ItemWriter{
+		f: f,
+		h: fnv.New64(),
+	}
+	w.Reset()
+	return w
+}
@@ -0,0 +1,40 @@
+package bloom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestItemWriter(t *testing.T) {
+	item := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 1000)
+	for _, f := range []*Filter{
+		New(1000, 0.01),
+		New(1000, 0.01, WithLegacyHashing()),
+		NewSeeded(1000, 0.01),
+		NewOneHashing(1000, 0.01),
+		New(1000, 0.01, WithSipHashKey([16]byte{1})),
+		New(1000, 0.01, WithIndexMode(IndependentHashing)),
+	} {
+		w := f.NewItemWriter()
+		for i := 0; i < len(item); i += 100 {
+			w.WriteString(item[i : i+100])
+		}
+		if w.Test() {
+			t.Error("item in empty filter")
+		}
+		w.Commit()
+		if !f.Test([]byte(item)) {
+			t.Error("committed item not in filter")
+		}
+		w.WriteString("foo")
+		if w.Test() != f.Test(foo) {
+			t.Error("writer and filter results differ")
+		}
+		w.Reset()
+		w.Write(bar)
+		w.Commit()
+		if !f.Test(bar) {
+			t.Error("bar not in filter after reset")
+		}
+	}
+}
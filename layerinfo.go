@@ -0,0 +1,95 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Information about a layer of a layered filter. When the layers are used as
+// time buckets, e.g. by rotating them at the end of each epoch, the
+// information about the layer reported by Test tells roughly when an item
+// was seen.
+type LayerInfo struct {
+	Created time.Time // when the layer was added, or last reset
+	Label   string    // given with SetLayerLabel, e.g. the name of an epoch
+}
+
+// Returns the information of n new layers.
+func newLayerInfo(n int) []LayerInfo {
+	info := make([]LayerInfo, n)
+	now := time.Now()
+	for i := range info {
+		info[i].Created = now
+	}
+	return info
+}
+
+// Appends the creation time, in nanoseconds since the Unix epoch, and the
+// label of each layer.
+func appendLayerInfo(buf []byte, info []LayerInfo) []byte {
+	for _, li := range info {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(li.Created.UnixNano()))
+		buf = binary.AppendUvarint(buf, uint64(len(li.Label)))
+		buf = append(buf, li.Label...)
+	}
+	return buf
+}
+
+func (d *decoder) layerInfo(n uint64) []LayerInfo {
+	var info []LayerInfo
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		created := time.Unix(0, int64(d.uint64()))
+		label := string(d.bytes(d.uvarint()))
+		info = append(info, LayerInfo{Created: created, Label: label})
+	}
+	return info
+}
+
+// Returns the information about layer n of the filter, e.g. 1 for the first
+// layer, as numbered by Add and Test, and false if there is no such layer.
+func (f *LayeredFilter) LayerInfo(n int) (LayerInfo, bool) {
+	if n < 1 || n > len(f.info) {
+		return LayerInfo{}, false
+	}
+	return f.info[n-1], true
+}
+
+// Sets the label of layer n of the filter, e.g. the name of the epoch it
+// holds. Layers which don't exist are ignored. The label is cleared when the
+// layer is reset.
+func (f *LayeredFilter) SetLayerLabel(n int, label string) {
+	if n >= 1 && n <= len(f.info) {
+		f.info[n-1].Label = label
+	}
+}
+
+// Returns the information about layer n of the filter, like
+// LayeredFilter.LayerInfo.
+func (f *LayeredFilter64) LayerInfo(n int) (LayerInfo, bool) {
+	if n < 1 || n > len(f.info) {
+		return LayerInfo{}, false
+	}
+	return f.info[n-1], true
+}
+
+// Sets the label of layer n of the filter, like LayeredFilter.SetLayerLabel.
+func (f *LayeredFilter64) SetLayerLabel(n int, label string) {
+	if n >= 1 && n <= len(f.info) {
+		f.info[n-1].Label = label
+	}
+}
+
+// Returns the information about layer n of the filter, like
+// LayeredFilter.LayerInfo.
+func (f *ConcurrentLayeredFilter) LayerInfo(n int) (LayerInfo, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.f.LayerInfo(n)
+}
+
+// Sets the label of layer n of the filter, like LayeredFilter.SetLayerLabel.
+func (f *ConcurrentLayeredFilter) SetLayerLabel(n int, label string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.f.SetLayerLabel(n, label)
+}
@@ -0,0 +1,57 @@
+package bloom
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLayerInfo(t *testing.T) {
+	start := time.Now()
+	f := NewLayered(1000, 0.01, WithLayers(2))
+	f64 := NewLayered64(1000, 0.01)
+	f.SetLayerLabel(1, "monday")
+	f.SetLayerLabel(2, "tuesday")
+	f.SetLayerLabel(3, "wednesday")
+	f64.SetLayerLabel(1, "monday")
+	for i := 0; i < 3; i++ {
+		f.Add(foo)
+		f64.Add(foo)
+	}
+	if li, ok := f.LayerInfo(1); !ok || li.Label != "monday" || li.Created.Before(start) {
+		t.Errorf("layer 1 info %+v", li)
+	}
+	if li, ok := f.LayerInfo(3); !ok || li.Label != "" || li.Created.Before(start) {
+		t.Errorf("layer 3 info %+v", li)
+	}
+	if _, ok := f.LayerInfo(4); ok {
+		t.Error("info of a layer which doesn't exist")
+	}
+	if li, ok := f64.LayerInfo(1); !ok || li.Label != "monday" {
+		t.Errorf("64-bit layer 1 info %+v", li)
+	}
+	f.RotateLayers()
+	if li, _ := f.LayerInfo(1); li.Label != "tuesday" {
+		t.Errorf("layer 1 labelled %q after rotating", li.Label)
+	}
+	f.ResetLayer(1)
+	if li, _ := f.LayerInfo(1); li.Label != "" {
+		t.Errorf("layer 1 labelled %q after resetting it", li.Label)
+	}
+
+	f.SetLayerLabel(2, "thursday")
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &LayeredFilter{}
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := f.LayerInfo(2)
+	if li, _ := g.LayerInfo(2); li.Label != "thursday" || !li.Created.Equal(want.Created) {
+		t.Errorf("decoded layer 2 info %+v, expected %+v", li, want)
+	}
+	if li, _ := f.Clone().LayerInfo(2); li.Label != "thursday" {
+		t.Errorf("cloned layer 2 labelled %q", li.Label)
+	}
+}
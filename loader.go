@@ -0,0 +1,378 @@
+package bloom
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fetches a filter artifact, e.g. a filter built by a central job and
+// published to a file share, web server or object store, identifying each
+// version of it by an ETag.
+type Fetcher interface {
+	// Returns the current version of the artifact and its ETag, or
+	// ErrNotModified if its ETag is still etag. An empty etag fetches the
+	// artifact unconditionally.
+	Fetch(ctx context.Context, etag string) (r io.ReadCloser, newETag string, err error)
+}
+
+// A Fetcher reading an artifact from a local file. Its ETag is derived from
+// the file's size and modification time.
+type FileFetcher struct {
+	Path string
+}
+
+func (f FileFetcher) Fetch(ctx context.Context, etag string) (io.ReadCloser, string, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil, "", err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	tag := fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size())
+	if tag == etag {
+		file.Close()
+		return nil, "", ErrNotModified
+	}
+	return file, tag, nil
+}
+
+// A Fetcher downloading an artifact with HTTP GET, sending If-None-Match so
+// that an unchanged artifact isn't downloaded again, if the server supports
+// it.
+type HTTPFetcher struct {
+	// The URL of the artifact
+	URL string
+
+	// Headers sent with each request, e.g. Authorization
+	Header http.Header
+
+	// The client sending the requests, or http.DefaultClient if nil
+	Client *http.Client
+}
+
+func (f HTTPFetcher) Fetch(ctx context.Context, etag string) (io.ReadCloser, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for k, v := range f.Header {
+		req.Header[k] = v
+	}
+	return fetchHTTP(f.Client, req, etag)
+}
+
+// A Fetcher downloading an artifact from Amazon S3 or an S3-compatible object
+// store, e.g. MinIO or Ceph, signing its requests with AWS Signature Version
+// 4. S3 supports If-None-Match, so an unchanged artifact isn't downloaded
+// again.
+type S3Fetcher struct {
+	// The URL of the object, in the virtual-hosted style, e.g.
+	// https://bucket.s3.eu-west-1.amazonaws.com/filters/seen, or the path
+	// style, e.g. http://minio:9000/bucket/filters/seen
+	URL string
+
+	// The region of the bucket, e.g. eu-west-1; us-east-1 for most
+	// S3-compatible stores
+	Region string
+
+	// The credentials to sign requests with. SessionToken is only needed for
+	// temporary credentials.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// The client sending the requests, or http.DefaultClient if nil
+	Client *http.Client
+}
+
+func (f S3Fetcher) Fetch(ctx context.Context, etag string) (io.ReadCloser, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if f.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", f.SessionToken)
+	}
+	signV4(req, f.Region, f.AccessKeyID, f.SecretAccessKey, time.Now())
+	return fetchHTTP(f.Client, req, etag)
+}
+
+// Sends req, a GET, with If-None-Match: etag, unless etag is empty.
+func fetchHTTP(c *http.Client, req *http.Request, etag string) (io.ReadCloser, string, error) {
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, resp.Header.Get("ETag"), nil
+	case http.StatusNotModified:
+		resp.Body.Close()
+		return nil, "", ErrNotModified
+	}
+	resp.Body.Close()
+	return nil, "", fmt.Errorf("bloom: fetching %s: %s", req.URL.Redacted(), resp.Status)
+}
+
+// The SHA-256 sum of an empty payload, as sent in x-amz-content-sha256
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Signs req, which has no body, for S3 with AWS Signature Version 4, signing
+// its host and every header it has.
+func signV4(req *http.Request, region, accessKeyID, secretAccessKey string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, k := range names {
+		canonical.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		vs := append([]string(nil), query[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			params = append(params, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	creq := strings.Join([]string{req.Method, awsEscape(path, false), strings.Join(params, "&"), canonical.String(), signed, emptySHA256}, "\n")
+
+	scope := now.Format("20060102") + "/" + region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(creq))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{now.Format("20060102"), region, "s3", "aws4_request", toSign} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKeyID+"/"+scope+", SignedHeaders="+signed+", Signature="+hex.EncodeToString(key))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Percent-encodes s as Signature Version 4 requires: every byte but the
+// unreserved characters of RFC 3986, and, unless slash is true, '/'.
+func awsEscape(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !slash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// The most bytes of an encoding LoadFromReaderWithHeader, Load and Artifact
+// read, so that a corrupt or malicious artifact can't exhaust memory.
+const DefaultMaxLoadSize = 4 << 30
+
+// Reads a filter encoded by one of the MarshalBinary methods of Filter,
+// Filter64, CountingFilter, CountingFilter64 or RotatingFilter, or by
+// Filter.MarshalCompressed, from r, and returns it as the type of the format
+// given in the encoding's header. At most DefaultMaxLoadSize bytes are read.
+func LoadFromReaderWithHeader(r io.Reader) (PersistentSet, error) {
+	return LoadFromReaderWithLimit(r, DefaultMaxLoadSize)
+}
+
+// Reads a filter from r like LoadFromReaderWithHeader, but reads at most
+// limit bytes. Returns an error wrapping ErrTooLarge if the encoding is
+// larger.
+func LoadFromReaderWithLimit(r io.Reader, limit int64) (PersistentSet, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: encoding larger than %d bytes", ErrTooLarge, limit)
+	}
+	if len(data) == 0 {
+		return nil, ErrInvalidEncoding
+	}
+	var s PersistentSet
+	switch data[0] {
+	case formatFilter:
+		s = &Filter{}
+	case formatFilterCompressed:
+		f := &Filter{}
+		if err := f.UnmarshalCompressed(data); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case formatFilter64:
+		s = &Filter64{}
+	case formatCounting:
+		s = &CountingFilter{}
+	case formatCounting64:
+		s = &CountingFilter64{}
+	case formatRotating:
+		s = &RotatingFilter{}
+	default:
+		return nil, ErrInvalidEncoding
+	}
+	if err := s.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Loads the current version of an artifact with f, as LoadFromReaderWithHeader
+// reads it, and returns it with its ETag.
+func Load(ctx context.Context, f Fetcher) (PersistentSet, string, error) {
+	r, etag, err := f.Fetch(ctx, "")
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+	s, err := LoadFromReaderWithHeader(r)
+	if err != nil {
+		return nil, "", err
+	}
+	return s, etag, nil
+}
+
+// Loads the filter at rawURL, as LoadFromReaderWithHeader reads it, with an
+// HTTPFetcher for an http or https URL, or a FileFetcher for a file URL.
+func LoadFromURL(ctx context.Context, rawURL string) (PersistentSet, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var f Fetcher
+	switch u.Scheme {
+	case "http", "https":
+		f = HTTPFetcher{URL: rawURL}
+	case "file":
+		f = FileFetcher{Path: u.Path}
+	default:
+		return nil, fmt.Errorf("bloom: loading %s: unsupported scheme %q", rawURL, u.Scheme)
+	}
+	s, _, err := Load(ctx, f)
+	return s, err
+}
+
+// A filter artifact which is loaded with a Fetcher, and replaced when a new
+// version of it is published, e.g. when the job building it rotates it. Each
+// version is fetched only if its ETag changed, and replaces the last one
+// atomically, so Set can be called concurrently with Refresh. A version is
+// never changed once loaded, so concurrent calls to Test are safe if the
+// type of the filter allows them, e.g. for a Filter.
+type Artifact struct {
+	fetcher Fetcher
+	mu      sync.Mutex // serializes refreshes
+	etag    string
+	set     atomic.Pointer[PersistentSet]
+}
+
+// Create an artifact loaded with f, and load its current version. Returns an
+// error if it can't be loaded.
+func NewArtifact(ctx context.Context, f Fetcher) (*Artifact, error) {
+	a := &Artifact{fetcher: f}
+	if _, err := a.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Returns the version of the filter loaded last.
+func (a *Artifact) Set() PersistentSet {
+	return *a.set.Load()
+}
+
+// Returns the ETag of the version loaded last.
+func (a *Artifact) ETag() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.etag
+}
+
+// Loads the current version of the artifact if its ETag changed, and returns
+// whether it did. If it can't be loaded, the version loaded last is kept.
+func (a *Artifact) Refresh(ctx context.Context) (changed bool, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, etag, err := a.fetcher.Fetch(ctx, a.etag)
+	if errors.Is(err, ErrNotModified) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	s, err := LoadFromReaderWithHeader(r)
+	if err != nil {
+		return false, err
+	}
+	a.set.Store(&s)
+	a.etag = etag
+	return true, nil
+}
+
+// Refreshes the artifact every interval until ctx is done. Errors refreshing
+// it are passed to onError, if it isn't nil, unless they are caused by ctx
+// being done; the version loaded last stays in use.
+func (a *Artifact) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if _, err := a.Refresh(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+	}
+}
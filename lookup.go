@@ -0,0 +1,111 @@
+package bloom
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// A datastore, e.g. a database table, a remote cache, or go-cache, read and
+// written by a Lookup. An adapter over go-cache might be:
+//
+//	type goCache struct{ *cache.Cache }
+//
+//	func (c goCache) Get(ctx context.Context, k string) (any, bool, error) {
+//		v, ok := c.Cache.Get(k)
+//		return v, ok, nil
+//	}
+//
+//	func (c goCache) Set(ctx context.Context, k string, v any) error {
+//		c.Cache.SetDefault(k, v)
+//		return nil
+//	}
+type LookupStore[K, V any] interface {
+	// Returns the value of key, and whether the store has one.
+	Get(ctx context.Context, key K) (V, bool, error)
+
+	// Stores the value of key.
+	Set(ctx context.Context, key K, v V) error
+}
+
+// The counters of a Lookup.
+type LookupStats struct {
+	Lookups        uint64 // calls to Get
+	Skipped        uint64 // lookups answered by the filter, without the store
+	FalsePositives uint64 // lookups of keys the store didn't have
+}
+
+// A probabilistic negative cache in front of a datastore: a filter holding
+// every key the store has a value for, which Get tests before reading the
+// store, so that lookups of keys known to be absent, e.g. of users that don't
+// exist, or of URLs never shortened, don't reach the store. Keys written with
+// Set are added to the filter; keys written to the store by other means must
+// be added with Add, e.g. for every key of the store when the lookup is
+// created. Keys removed from the store stay in the filter, so they are looked
+// up in the store, as false positives are, until the filter is rebuilt.
+//
+// Calls to the filter are guarded by a sync.RWMutex, so any filter can be
+// used, and the lookup is safe for concurrent use if its store is.
+type Lookup[K, V any] struct {
+	mu    sync.RWMutex
+	f     Set
+	key   KeyFunc[K]
+	store LookupStore[K, V]
+
+	lookups, skipped, falsePositives atomic.Uint64
+}
+
+// Create a lookup reading and writing store, in front of which f holds the
+// keys of store, converted to bytes with key.
+func NewLookup[K, V any](f Set, key KeyFunc[K], store LookupStore[K, V]) *Lookup[K, V] {
+	return &Lookup[K, V]{f: f, key: key, store: store}
+}
+
+// Returns the value of key in the store, and whether it has one, without
+// reading the store if key isn't in the filter.
+func (l *Lookup[K, V]) Get(ctx context.Context, key K) (V, bool, error) {
+	l.lookups.Add(1)
+	if !l.Test(key) {
+		l.skipped.Add(1)
+		var zero V
+		return zero, false, nil
+	}
+	v, ok, err := l.store.Get(ctx, key)
+	if err == nil && !ok {
+		l.falsePositives.Add(1)
+	}
+	return v, ok, err
+}
+
+// Stores the value of key, adding key to the filter. The key is added before
+// the value is stored, so that a concurrent Get can't miss it, and stays in
+// the filter if storing it fails.
+func (l *Lookup[K, V]) Set(ctx context.Context, key K, v V) error {
+	l.Add(key)
+	return l.store.Set(ctx, key, v)
+}
+
+// Adds key to the filter, e.g. for a key written to the store by other means.
+func (l *Lookup[K, V]) Add(key K) {
+	b := l.key(nil, key)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.f.Add(b)
+}
+
+// Checks whether key is (probably) in the store, as far as the filter knows.
+func (l *Lookup[K, V]) Test(key K) bool {
+	b := l.key(nil, key)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.f.Test(b)
+}
+
+// Returns the counters of the lookup.
+func (l *Lookup[K, V]) Stats() LookupStats {
+	return LookupStats{
+		Lookups:        l.lookups.Load(),
+		Skipped:        l.skipped.Load(),
+		FalsePositives: l.falsePositives.Load(),
+	}
+}
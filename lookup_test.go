@@ -0,0 +1,68 @@
+package bloom
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// A LookupStore in a map, counting its reads.
+type mapStore struct {
+	mu   sync.Mutex
+	m    map[string]int
+	gets int
+	err  error
+}
+
+func (s *mapStore) Get(ctx context.Context, key string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets++
+	v, ok := s.m[key]
+	return v, ok, nil
+}
+
+func (s *mapStore) Set(ctx context.Context, key string, v int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.m[key] = v
+	return nil
+}
+
+func TestLookup(t *testing.T) {
+	store := &mapStore{m: map[string]int{"existing": 1}}
+	l := NewLookup[string, int](New(1000, 0.001), StringKey, store)
+	l.Add("existing")
+	ctx := context.Background()
+	if v, ok, err := l.Get(ctx, "existing"); v != 1 || !ok || err != nil {
+		t.Fatalf("Get(existing) = %v, %v, %v", v, ok, err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok, _ := l.Get(ctx, strconv.Itoa(i)); ok {
+			t.Fatalf("%d found", i)
+		}
+	}
+	if store.gets > 2 {
+		t.Errorf("%d reads of the store for one existing key", store.gets)
+	}
+	if err := l.Set(ctx, "new", 2); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, _ := l.Get(ctx, "new"); v != 2 || !ok {
+		t.Errorf("Get(new) = %v, %v", v, ok)
+	}
+	s := l.Stats()
+	if s.Lookups != 102 || s.Skipped+s.FalsePositives != 100 || s.Skipped < 98 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	store.err = errors.New("unavailable")
+	if err := l.Set(ctx, "failed", 3); err != store.err || !l.Test("failed") {
+		t.Errorf("Set returned %v", err)
+	}
+}
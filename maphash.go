@@ -0,0 +1,32 @@
+package bloom
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"sync/atomic"
+)
+
+// The number of calls to WithMapHash, which identifies the hash function
+// each returns
+var mapHashes atomic.Uint64
+
+// Makes the filter use hash/maphash with a random seed instead of the default
+// FNV-1 based hashing. This is much faster than FNV-1 for long items, and,
+// like WithSipHashKey, keeps an attacker from predicting the bits set by an
+// item. Since the seed is random and can't be extracted, the filter can only
+// be used within the process that created it: an encoding of it can't be
+// decoded meaningfully, and it can't be combined with filters created with
+// another call to WithMapHash, or decoded ones: Merge, Union and the like
+// return ErrIncompatible.
+func WithMapHash() Option {
+	seed := maphash.MakeSeed()
+	id := mapHashes.Add(1)
+	hash := WithHash(func(data []byte) (uint64, uint64) {
+		x := maphash.Bytes(seed, data)
+		return x, bits.RotateLeft64(x, 32)
+	})
+	return func(o *options) {
+		hash(o)
+		o.hashID = id
+	}
+}
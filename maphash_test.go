@@ -0,0 +1,38 @@
+package bloom
+
+import (
+	"testing"
+)
+
+func TestWithMapHash(t *testing.T) {
+	n := uint32(10000)
+	f := New(int(n), 0.001, WithMapHash())
+	f.Add(foo)
+	if !f.Test(foo) {
+		t.Error("foo not in bloom filter")
+	}
+	want := theoreticalP(f.m, f.k, n)
+	if p := estimateP(f, n); p > 2*want {
+		t.Errorf("false positive rate %f, expected about %f", p, want)
+	}
+	fx, _ := f.sum(foo)
+	gx, _ := New(int(n), 0.001, WithMapHash()).sum(foo)
+	if fx == gx {
+		t.Error("filters created with WithMapHash share a seed")
+	}
+}
+
+func TestWithMapHashIncompatible(t *testing.T) {
+	opt := WithMapHash()
+	f := New(1000, 0.01, opt)
+	f.Add(foo)
+	if err := f.Merge(New(1000, 0.01, opt)); err != nil {
+		t.Errorf("merging filters sharing a seed returned %v", err)
+	}
+	if err := f.Merge(New(1000, 0.01, WithMapHash())); err != ErrIncompatible {
+		t.Errorf("merging filters with different seeds returned %v", err)
+	}
+	if err := f.Merge(New(1000, 0.01)); err != ErrIncompatible {
+		t.Errorf("merging with a filter using FNV-1 returned %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package bloom
+
+import (
+	"sync/atomic"
+)
+
+// Callbacks an instrumented filter calls, e.g. to update Prometheus metrics,
+// as an alternative to reading Metrics periodically. Any of them may be nil.
+// They are called synchronously, so they should be fast.
+type Hooks struct {
+	// Called after an item is added.
+	Add func()
+
+	// Called after an item is tested, with the result.
+	Test func(present bool)
+
+	// Called when the filter has allocated more layers, with their number.
+	LayerGrowth func(layers int)
+}
+
+// A snapshot of the metrics of an instrumented filter. They can be exported
+// as Prometheus collectors, e.g.:
+//
+//	prometheus.MustRegister(prometheus.NewCounterFunc(
+//		prometheus.CounterOpts{Name: "bloom_adds_total"},
+//		func() float64 { return float64(f.Metrics().Adds) },
+//	))
+type Metrics struct {
+	Adds      uint64  // the number of items added
+	Tests     uint64  // the number of items tested
+	Positives uint64  // the number of tests reporting an item as present
+	Negatives uint64  // the number of tests reporting an item as absent
+	FillRatio float64 // the fraction of the (first layer's) bits set, if known
+	Layers    int     // the number of layers, or 0 for a filter without them
+}
+
+// The counters of an instrumented filter, which are safe for concurrent use.
+type instruments struct {
+	hooks     Hooks
+	adds      atomic.Uint64
+	tests     atomic.Uint64
+	positives atomic.Uint64
+	layers    atomic.Int64
+}
+
+// Records an item added to a filter which now has layers layers.
+func (in *instruments) added(layers int) {
+	in.adds.Add(1)
+	if in.hooks.Add != nil {
+		in.hooks.Add()
+	}
+	for {
+		old := in.layers.Load()
+		if int64(layers) <= old {
+			return
+		}
+		if in.layers.CompareAndSwap(old, int64(layers)) {
+			break
+		}
+	}
+	if in.hooks.LayerGrowth != nil {
+		in.hooks.LayerGrowth(layers)
+	}
+}
+
+func (in *instruments) tested(present bool) {
+	in.tests.Add(1)
+	if present {
+		in.positives.Add(1)
+	}
+	if in.hooks.Test != nil {
+		in.hooks.Test(present)
+	}
+}
+
+func (in *instruments) metrics() Metrics {
+	// Every positive is counted after its test, so loading the positives
+	// first keeps them from exceeding the tests
+	positives := in.positives.Load()
+	tests := in.tests.Load()
+	return Metrics{
+		Adds:      in.adds.Load(),
+		Tests:     tests,
+		Positives: positives,
+		Negatives: tests - positives,
+	}
+}
+
+// Returns the number of layers of s, or 0 if it doesn't have any.
+func layersOf(s Set) int {
+	if l, ok := s.(interface{ Layers() int }); ok {
+		return l.Layers()
+	}
+	return 0
+}
+
+// A Set counting the items added to and tested against it, and calling Hooks.
+// Counting filters report the growth of their layers. It is as safe for
+// concurrent use as the Set it wraps.
+type InstrumentedSet struct {
+	Set
+	in *instruments
+}
+
+// Create an instrumented set wrapping s.
+func NewInstrumented(s Set, hooks Hooks) *InstrumentedSet {
+	in := &instruments{hooks: hooks}
+	in.layers.Store(int64(layersOf(s)))
+	return &InstrumentedSet{s, in}
+}
+
+// Adds data to the set.
+func (s *InstrumentedSet) Add(data []byte) {
+	s.Set.Add(data)
+	s.in.added(layersOf(s.Set))
+}
+
+// Checks whether data was (probably) previously added to the set.
+func (s *InstrumentedSet) Test(data []byte) bool {
+	present := s.Set.Test(data)
+	s.in.tested(present)
+	return present
+}
+
+// Adds data to the set, and returns whether it was (probably) already
+// present before it was added. This counts as both a test and an add.
+func (s *InstrumentedSet) TestAndAdd(data []byte) bool {
+	present := s.Set.TestAndAdd(data)
+	s.in.tested(present)
+	s.in.added(layersOf(s.Set))
+	return present
+}
+
+// Returns the metrics of the set. The fill ratio is computed, so this may be
+// slow for a large filter.
+func (s *InstrumentedSet) Metrics() Metrics {
+	m := s.in.metrics()
+	m.Layers = layersOf(s.Set)
+	if f, ok := s.Set.(interface{ FillRatio() float64 }); ok {
+		m.FillRatio = f.FillRatio()
+	}
+	return m
+}
+
+// A LayeredFilter counting the items added to and tested against it, and
+// calling Hooks, including when it adds a layer. Only Add, Test and
+// TestAndAdd are counted; the other methods, e.g. Count and AddN, use the
+// wrapped filter directly.
+type InstrumentedLayered struct {
+	*LayeredFilter
+	in *instruments
+}
+
+// Create an instrumented layered filter wrapping f.
+func NewInstrumentedLayered(f *LayeredFilter, hooks Hooks) *InstrumentedLayered {
+	in := &instruments{hooks: hooks}
+	in.layers.Store(int64(f.Layers()))
+	return &InstrumentedLayered{f, in}
+}
+
+// Adds data to the filter, like LayeredFilter.Add.
+func (f *InstrumentedLayered) Add(data []byte) int {
+	n := f.LayeredFilter.Add(data)
+	f.in.added(f.LayeredFilter.Layers())
+	return n
+}
+
+// Checks whether data was previously added to the filter, like
+// LayeredFilter.Test.
+func (f *InstrumentedLayered) Test(data []byte) (int, bool) {
+	n, present := f.LayeredFilter.Test(data)
+	f.in.tested(present)
+	return n, present
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present before it was added. This counts as both a test and an add.
+func (f *InstrumentedLayered) TestAndAdd(data []byte) bool {
+	present := f.LayeredFilter.TestAndAdd(data)
+	f.in.tested(present)
+	f.in.added(f.LayeredFilter.Layers())
+	return present
+}
+
+// Returns the metrics of the filter, with the fill ratio of its first layer.
+func (f *InstrumentedLayered) Metrics() Metrics {
+	m := f.in.metrics()
+	m.Layers = f.LayeredFilter.Layers()
+	m.FillRatio = f.LayerFillRatio(1)
+	return m
+}
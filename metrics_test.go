@@ -0,0 +1,61 @@
+package bloom
+
+import (
+	"testing"
+)
+
+func TestInstrumentedSet(t *testing.T) {
+	var adds, positives, grew int
+	hooks := Hooks{
+		Add: func() { adds++ },
+		Test: func(present bool) {
+			if present {
+				positives++
+			}
+		},
+		LayerGrowth: func(int) { grew++ },
+	}
+	s := NewInstrumented(New(1000, 0.01), hooks)
+	s.Add(foo)
+	s.Test(foo)
+	s.Test(bar)
+	s.TestAndAdd(bar)
+	m := s.Metrics()
+	if m.Adds != 2 || m.Tests != 3 || m.Positives != 1 || m.Negatives != 2 || m.FillRatio == 0 || m.Layers != 0 {
+		t.Errorf("metrics %+v", m)
+	}
+	if adds != 2 || positives != 1 || grew != 0 {
+		t.Errorf("hooks called %d, %d and %d times", adds, positives, grew)
+	}
+
+	c := NewInstrumented(NewCounting(1000, 0.01), hooks)
+	for i := 0; i < 3; i++ {
+		c.Add(foo)
+	}
+	if grew != 2 || c.Metrics().Layers != 3 {
+		t.Errorf("counting filter grew %d times to %d layers", grew, c.Metrics().Layers)
+	}
+}
+
+func TestInstrumentedLayered(t *testing.T) {
+	var layers []int
+	f := NewInstrumentedLayered(NewLayered(1000, 0.01), Hooks{
+		LayerGrowth: func(n int) { layers = append(layers, n) },
+	})
+	for i := 0; i < 3; i++ {
+		f.Add(foo)
+	}
+	if n, _ := f.Test(foo); n != 3 || f.Count(foo) != 3 {
+		t.Errorf("foo in layer %d", n)
+	}
+	if f.TestAndAdd(bar) {
+		t.Error("bar present before being added")
+	}
+	m := f.Metrics()
+	if m.Adds != 4 || m.Tests != 2 || m.Positives != 1 || m.Layers != 3 || m.FillRatio == 0 {
+		t.Errorf("metrics %+v", m)
+	}
+	if len(layers) != 2 || layers[0] != 2 || layers[1] != 3 {
+		t.Errorf("layer growth reported as %v", layers)
+	}
+}
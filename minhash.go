@@ -0,0 +1,82 @@
+package bloom
+
+import (
+	"hash"
+	"hash/fnv"
+	"math"
+)
+
+// A MinHash signature of a set of items, using the 64-bit FNV-1 hash
+// function. Signatures of two sets can be compared with Similarity to estimate
+// the Jaccard similarity of the sets.
+type MinHash struct {
+	sig []uint64
+	h   hash.Hash64
+}
+
+// Adds data to the set described by the signature.
+func (m *MinHash) Add(data []byte) {
+	a := sum64(m.h, data)
+	b := mix64(a) | 1
+	for i := range m.sig {
+		if v := mix64(a + b*uint64(i)); v < m.sig[i] {
+			m.sig[i] = v
+		}
+	}
+}
+
+// Returns the signature. The returned slice must not be modified.
+func (m *MinHash) Signature() []uint64 {
+	return m.sig
+}
+
+// Merges other into m, so that m becomes the signature of the union of both
+// sets. Returns ErrIncompatible if the signature sizes differ.
+func (m *MinHash) Merge(other *MinHash) error {
+	if len(m.sig) != len(other.sig) {
+		return ErrIncompatible
+	}
+	for i, v := range other.sig {
+		if v < m.sig[i] {
+			m.sig[i] = v
+		}
+	}
+	return nil
+}
+
+// Resets the signature.
+func (m *MinHash) Reset() {
+	for i := range m.sig {
+		m.sig[i] = math.MaxUint64
+	}
+}
+
+// Estimates the Jaccard similarity (the size of the intersection divided by
+// the size of the union) of the sets described by a and b, from 0 to 1.
+// Returns ErrIncompatible if the signature sizes differ.
+func Similarity(a, b *MinHash) (float64, error) {
+	if len(a.sig) != len(b.sig) {
+		return 0, ErrIncompatible
+	}
+	same := 0
+	for i, v := range a.sig {
+		if v == b.sig[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a.sig)), nil
+}
+
+// Create a MinHash with a signature of n values. The expected error of the
+// similarity estimate is about 1/sqrt(n), e.g. 0.05 for n 400.
+func NewMinHash(n int) *MinHash {
+	if n < 1 {
+		panic("Unable to create a MinHash without a positive signature size.")
+	}
+	m := &MinHash{
+		sig: make([]uint64, n),
+		h:   fnv.New64(),
+	}
+	m.Reset()
+	return m
+}
@@ -0,0 +1,54 @@
+package bloom
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestMinHash(t *testing.T) {
+	a := NewMinHash(400)
+	b := NewMinHash(400)
+	// a has 0-999, b has 500-1499; the Jaccard similarity is 500/1500
+	for i := 0; i < 1500; i++ {
+		data := []byte(strconv.Itoa(i))
+		if i < 1000 {
+			a.Add(data)
+		}
+		if i >= 500 {
+			b.Add(data)
+		}
+	}
+	s, err := Similarity(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(s-1.0/3) > 0.1 {
+		t.Errorf("similarity is %f, expected about 0.33", s)
+	}
+	if s, _ := Similarity(a, a); s != 1 {
+		t.Errorf("similarity with itself is %f", s)
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := Similarity(a, b); math.Abs(s-2.0/3) > 0.1 {
+		t.Errorf("similarity after merge is %f, expected about 0.67", s)
+	}
+	if _, err := Similarity(a, NewMinHash(10)); err != ErrIncompatible {
+		t.Errorf("comparing different sizes returned %v", err)
+	}
+}
+
+func TestMinHashInvalid(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic with n %d", n)
+				}
+			}()
+			NewMinHash(n)
+		}()
+	}
+}
@@ -0,0 +1,113 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+)
+
+// A set of standard bloom filters with the same parameters, e.g. one per
+// tenant, identified by name. An item is hashed once no matter how many of
+// the filters it is added to or tested against.
+type MultiFilter struct {
+	*filter
+	ids []string // in the order the filters were created
+	b   map[string]*bitset.Bitset32
+}
+
+// Checks whether data was previously added to the filter with the given id.
+// Returns true if yes, with a false positive chance near the ratio specified
+// upon creation of the filter. The result cannot be falsely negative.
+func (f *MultiFilter) Test(id string, data []byte) bool {
+	b, ok := f.b[id]
+	if !ok {
+		return false
+	}
+	return testBits32(b, f.bits(data))
+}
+
+// Returns the ids of the filters that data was (probably) previously added
+// to, in the order the filters were created.
+func (f *MultiFilter) TestAll(data []byte) []string {
+	is := f.bits(data)
+	var ids []string
+	for _, id := range f.ids {
+		if testBits32(f.b[id], is) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Adds data to the filter with the given id, creating the filter if it
+// doesn't exist.
+func (f *MultiFilter) AddTo(id string, data []byte) {
+	f.TestAndAddTo(id, data)
+}
+
+// Adds data to the filter with the given id, creating the filter if it
+// doesn't exist, and returns whether it was (probably) already present in
+// that filter, as reported by Test, before it was added.
+func (f *MultiFilter) TestAndAddTo(id string, data []byte) bool {
+	b, ok := f.b[id]
+	if !ok {
+		b = bitset.New32(f.m)
+		f.b[id] = b
+		f.ids = append(f.ids, id)
+	}
+	present := true
+	for _, i := range f.bits(data) {
+		if !b.Test(i) {
+			present = false
+			b.Set(i)
+		}
+	}
+	return present
+}
+
+// Returns the ids of the filters, in the order they were created.
+func (f *MultiFilter) IDs() []string {
+	return append([]string(nil), f.ids...)
+}
+
+// Returns the filter with the given id, or nil if it doesn't exist. The
+// returned filter shares its bits with f.
+func (f *MultiFilter) Filter(id string) *Filter {
+	b, ok := f.b[id]
+	if !ok {
+		return nil
+	}
+	return &Filter{f.filter, b}
+}
+
+// Removes the filter with the given id.
+func (f *MultiFilter) Delete(id string) {
+	if _, ok := f.b[id]; !ok {
+		return
+	}
+	delete(f.b, id)
+	for i, v := range f.ids {
+		if v == id {
+			f.ids = append(f.ids[:i], f.ids[i+1:]...)
+			break
+		}
+	}
+}
+
+// Resets the filter, removing every filter it holds.
+func (f *MultiFilter) Reset() {
+	f.ids = nil
+	f.b = map[string]*bitset.Bitset32{}
+}
+
+// Create a set of bloom filters, each with an expected n number of items and
+// an acceptable false positive rate of p, e.g. 0.01. Filters are created as
+// data is added to them with AddTo.
+func NewMulti(n int, p float64, opts ...Option) *MultiFilter {
+	m, k := estimates(int64(n), p)
+	f := &MultiFilter{
+		newFilter(m, k, opts...),
+		nil,
+		map[string]*bitset.Bitset32{},
+	}
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
@@ -0,0 +1,38 @@
+package bloom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMultiFilter(t *testing.T) {
+	calls := 0
+	f := NewMulti(1000, 0.01, WithHash(countingHash(&calls)))
+	f.AddTo("a", foo)
+	f.AddTo("c", foo)
+	f.AddTo("b", bar)
+	calls = 0
+	if got := f.TestAll(foo); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("foo is in filters %v", got)
+	}
+	if calls != 1 {
+		t.Errorf("TestAll hashed %d times, expected once", calls)
+	}
+	if !f.Test("b", bar) || f.Test("a", bar) || f.Test("d", bar) {
+		t.Error("bar not only in filter b")
+	}
+	if g := f.Filter("a"); g == nil || !g.Test(foo) || g.Test(bar) {
+		t.Error("filter a does not hold only foo")
+	}
+	if f.Filter("d") != nil {
+		t.Error("filter d exists")
+	}
+	f.Delete("a")
+	if got := f.IDs(); !reflect.DeepEqual(got, []string{"c", "b"}) {
+		t.Errorf("ids after delete are %v", got)
+	}
+	f.Reset()
+	if len(f.IDs()) != 0 || len(f.TestAll(foo)) != 0 {
+		t.Error("filters remain after reset")
+	}
+}
@@ -0,0 +1,103 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+)
+
+// Returns one bit index for data in each partition: the single 64-bit hash of
+// data modulo the partition's (prime) size, plus the partition's offset. The
+// indexes are stored in is if it has room for them.
+func (f *filter) oneHashBits(is []uint32, data []byte) []uint32 {
+	x, _ := f.sum(data)
+	return f.oneHashIndexes(is, x)
+}
+
+// Returns the bit indexes for the hash x returned by sum, stored in is if it
+// has room for them.
+func (f *filter) oneHashIndexes(is []uint32, x uint64) []uint32 {
+	is = resize32(is, len(f.parts))
+	off := uint32(0)
+	for i, p := range f.parts {
+		is[i] = off + uint32(x%uint64(p))
+		off += p
+	}
+	return is
+}
+
+func isPrime(n uint32) bool {
+	if n < 2 {
+		return false
+	}
+	for d := uint32(2); uint64(d)*uint64(d) <= uint64(n); d++ {
+		if n%d == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns k distinct primes whose sum is close to m, and no larger than it
+// unless there are fewer than k primes from 2 to m/k, in which case the
+// smallest primes above m/k make up the rest.
+func partitions(m, k uint32) []uint32 {
+	parts := make([]uint32, 0, k)
+	sum := uint64(0)
+	p := m / k
+	for uint32(len(parts)) < k && p > 1 {
+		if isPrime(p) {
+			parts = append(parts, p)
+			sum += uint64(p)
+		}
+		p--
+	}
+	for q := m/k + 1; uint32(len(parts)) < k; q++ {
+		if isPrime(q) {
+			parts = append(parts, q)
+			sum += uint64(q)
+		}
+	}
+	// Use the remaining space for the largest partition
+	for q := parts[0] + 1; sum < uint64(m) && uint64(q) <= uint64(parts[0])+uint64(m)-sum; q++ {
+		if isPrime(q) {
+			sum += uint64(q - parts[0])
+			parts[0] = q
+		}
+	}
+	return parts
+}
+
+func newOneHashingFilter(m, k uint32, opts []Option) *filter {
+	f := newFilter(m, k, opts...)
+	f.partition(m)
+	return f
+}
+
+// Splits about m bits into f.k partitions, and sets f.m to their total size.
+func (f *filter) partition(m uint32) {
+	if m/f.k < 2*f.k {
+		// Too small to find k distinct primes; use larger partitions
+		m = 2 * f.k * f.k
+	}
+	f.parts = partitions(m, f.k)
+	f.m = 0
+	for _, p := range f.parts {
+		f.m += p
+	}
+}
+
+// Create a bloom filter with an expected n number of items, and an acceptable
+// false positive rate of p, e.g. 0.01, using the one-hashing scheme: the bits
+// are split into k partitions whose sizes are distinct primes, and the single
+// 64-bit hash of an item modulo each partition size gives its bit in that
+// partition. This avoids deriving k indexes from the hash, which helps
+// throughput for large k.
+func NewOneHashing(n int, p float64, opts ...Option) *Filter {
+	m, k := estimates(int64(n), p)
+	fl := newOneHashingFilter(m, k, opts)
+	f := &Filter{
+		fl,
+		bitset.New32(fl.m),
+	}
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
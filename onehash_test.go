@@ -0,0 +1,71 @@
+package bloom
+
+import (
+	"testing"
+)
+
+func TestOneHashingPartitions(t *testing.T) {
+	parts := partitions(100000, 7)
+	if len(parts) != 7 {
+		t.Fatalf("%d partitions", len(parts))
+	}
+	sum := uint32(0)
+	seen := map[uint32]bool{}
+	for _, p := range parts {
+		if !isPrime(p) || seen[p] {
+			t.Errorf("partition size %d isn't a distinct prime", p)
+		}
+		seen[p] = true
+		sum += p
+	}
+	if sum > 100000 || sum < 99900 {
+		t.Errorf("partitions sum to %d", sum)
+	}
+}
+
+func TestOneHashingPartitionsSmall(t *testing.T) {
+	for _, c := range []struct{ m, k uint32 }{{10, 5}, {1, 3}, {60, 30}, {2 * 40 * 40, 40}} {
+		parts := partitions(c.m, c.k)
+		if uint32(len(parts)) != c.k {
+			t.Fatalf("m %d k %d: %d partitions", c.m, c.k, len(parts))
+		}
+		seen := map[uint32]bool{}
+		for _, p := range parts {
+			if !isPrime(p) || seen[p] {
+				t.Errorf("m %d k %d: partition size %d isn't a distinct prime", c.m, c.k, p)
+			}
+			seen[p] = true
+		}
+	}
+
+	// Few items with a tiny false positive rate need many hash functions
+	f := NewOneHashing(1, 1e-9)
+	if uint32(len(f.parts)) != f.K() {
+		t.Fatalf("%d partitions for k %d", len(f.parts), f.K())
+	}
+	f.Add(foo)
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &Filter{}
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Test(foo) || g.Test(bar) {
+		t.Error("unexpected decoded filter")
+	}
+}
+
+func TestOneHashingFilter(t *testing.T) {
+	n := 10000
+	fp := 0.001
+	f := NewOneHashing(n, fp, WithLegacyHashing())
+	f.Add(foo)
+	if !f.Test(foo) {
+		t.Error("foo not in bloom filter")
+	}
+	if p := estimateP(f, uint32(n)); p > fp {
+		t.Errorf("False positive rate too high: %f", p)
+	}
+}
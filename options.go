@@ -0,0 +1,128 @@
+package bloom
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+)
+
+// A hash function returning two independent 64-bit hashes of data, from which
+// a filter derives its bit indexes. Functions returning a single good 64-bit
+// hash can return e.g. the hash and the hash rotated by 32 bits.
+type HashFunc func(data []byte) (uint64, uint64)
+
+// An option that can be given to the constructors of the filters.
+type Option func(*options)
+
+type options struct {
+	hash       HashFunc
+	hashName   string // set by WithRegisteredHash
+	hashParams []byte
+	hashID     uint64 // set by WithMapHash, to tell its hash functions apart
+	seed       uint64
+	index      IndexMode
+	indexer    Indexer
+	legacy     bool
+	layers     int
+	// The width of the counters of a counting filter, or 0 for layers
+	counterWidth uint
+	conservative bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		index: EnhancedDoubleHashing,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Makes the filter use h instead of the default FNV-1 based hashing, e.g. to
+// use xxHash, MurmurHash3, or SipHash. A filter must always be used with the
+// same hash function, including after decoding it.
+func WithHash(h HashFunc) Option {
+	return func(o *options) {
+		o.hash = h
+		o.hashName = ""
+		o.hashParams = nil
+		o.hashID = 0
+	}
+}
+
+// Makes a Filter, CountingFilter or LayeredFilter derive its two hashes by
+// splitting a single 64-bit FNV-1 sum into two 32-bit halves, as earlier
+// versions of go-bloom did, instead of from a 128-bit FNV-1 sum, and selects
+// DoubleHashing, which those versions derived the bit indexes with, unless
+// WithIndexMode is given after it. The halves are correlated, which raises the
+// false positive rate of large filters, so this is only useful to reproduce
+// filters created by those versions. It has no effect on filters created with
+// WithHash, or on the 64-bit filters, which already use two independent
+// hashes.
+func WithLegacyHashing() Option {
+	return func(o *options) {
+		o.legacy = true
+		o.index = DoubleHashing
+	}
+}
+
+// Makes a CountingFilter or LayeredFilter (or their 64-bit equivalents)
+// allocate n layers up front, rather than one, so that adding items doesn't
+// allocate until more than n layers are needed. ResetAndShrink discards all
+// but the first layer.
+func WithLayers(n int) Option {
+	return func(o *options) {
+		o.layers = n
+	}
+}
+
+// Makes a CountingFilter (or CountingFilter64) use an array of counters of
+// the given width, which must be 4, 8 or 16 bits, instead of layers of
+// bitsets. A counter which reaches its maximum, e.g. 15 for 4-bit counters,
+// saturates: it is no longer incremented or decremented, so it can't make
+// Remove cause false negatives, but OverflowCount reports it. This bounds the
+// memory of the filter, e.g. to 4 bits per bit of a Filter, however skewed
+// the counts of its items are. WithLayers has no effect on such a filter.
+func WithCounterWidth(bits uint) Option {
+	return func(o *options) {
+		o.counterWidth = bits
+	}
+}
+
+// Makes a CountingFilter (or CountingFilter64) use conservative update: adding
+// an item only increments those of its counters which are the smallest, since
+// the count of the item is their value. This makes the counts of items much
+// more accurate when a few items are added far more often than the others,
+// e.g. to track their frequency, and makes counters saturate or add layers
+// much later. However, Remove may then cause false negatives, since an item's
+// counters may not all have been incremented for it, so such a filter should
+// only be reduced with Decay.
+func WithConservativeUpdate() Option {
+	return func(o *options) {
+		o.conservative = true
+	}
+}
+
+// Perturbs the hashes of the filter with seed, so that filters with different
+// seeds holding the same items don't share false positives. A seed of 0 means
+// no perturbation. The seed is included when the filter is encoded. This
+// applies to Filter, Filter64, and the other filters derived from them.
+func WithSeed(seed uint64) Option {
+	return func(o *options) {
+		o.seed = seed
+	}
+}
+
+// Returns a random, non-zero seed.
+func randomSeed() uint64 {
+	var b [8]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(fmt.Sprintf("Unable to read a random seed: %v", err))
+		}
+		if seed := binary.LittleEndian.Uint64(b[:]); seed != 0 {
+			return seed
+		}
+	}
+}
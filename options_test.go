@@ -0,0 +1,92 @@
+package bloom
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"testing"
+)
+
+func countingHash(calls *int) HashFunc {
+	return func(data []byte) (uint64, uint64) {
+		*calls++
+		h := fnv.New64a()
+		h.Write(data)
+		x := mix64(h.Sum64())
+		return x, bits.RotateLeft64(x, 32)
+	}
+}
+
+func TestWithHash(t *testing.T) {
+	calls := 0
+	h := countingHash(&calls)
+	f := New(1000, 0.01, WithHash(h))
+	f.Add(foo)
+	if !f.Test(foo) || f.Test(bar) {
+		t.Error("unexpected results with custom hash")
+	}
+	f64 := New64(1000, 0.01, WithHash(h))
+	f64.Add(foo)
+	if !f64.Test(foo) || f64.Test(bar) {
+		t.Error("unexpected results with custom hash (64-bit)")
+	}
+	c := NewCounting(1000, 0.01, WithHash(h))
+	c.Add(foo)
+	c.Remove(foo)
+	if c.Test(foo) {
+		t.Error("foo still in counting filter")
+	}
+	q := NewQuotient(1000, 0.01, WithHash(h))
+	q.Add(foo)
+	if !q.Test(foo) {
+		t.Error("foo not in quotient filter")
+	}
+	if calls != 11 {
+		t.Errorf("custom hash called %d times, expected 11", calls)
+	}
+}
+
+func TestWithLegacyHashing(t *testing.T) {
+	f := New(1000, 0.01, WithLegacyHashing())
+	x, y := f.sum(foo)
+	h := fnv.New64()
+	h.Write(foo)
+	if x != h.Sum64() || y != h.Sum64()>>32 {
+		t.Errorf("legacy hashes %x %x not split from FNV-64 sum %x", x, y, h.Sum64())
+	}
+	f.Add(foo)
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &Filter{}
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !g.legacy || !g.Test(foo) {
+		t.Error("decoded filter does not use legacy hashing")
+	}
+	if New(1000, 0.01).legacy {
+		t.Error("legacy hashing enabled by default")
+	}
+}
+
+func TestWithLayers(t *testing.T) {
+	c := NewCounting(1000, 0.01, WithLayers(4))
+	l := NewLayered64(1000, 0.01, WithLayers(4))
+	if len(c.b) != 4 || len(l.b) != 4 {
+		t.Fatalf("%d and %d layers allocated, expected 4", len(c.b), len(l.b))
+	}
+	for i := 1; i <= 3; i++ {
+		c.Add(foo)
+		if n := l.Add(foo); n != i {
+			t.Errorf("foo added to layer %d, expected %d", n, i)
+		}
+	}
+	c.Remove(foo)
+	if !c.Test(foo) || len(c.b) != 4 {
+		t.Error("unexpected counting filter with preallocated layers")
+	}
+	if n, _ := l.Test(foo); n != 3 {
+		t.Errorf("foo is in layer %d, expected 3", n)
+	}
+}
@@ -0,0 +1,357 @@
+package bloom
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"hash"
+	"math"
+)
+
+// The growth factors of the filter capacities of a PyScalableBloomFilter,
+// pybloom's ScalableBloomFilter.SMALL_SET_GROWTH and LARGE_SET_GROWTH
+const (
+	PySmallSetGrowth = 2
+	PyLargeSetGrowth = 4
+)
+
+// The factor by which each filter added to a ScalableBloomFilter tightens
+// its error rate; pybloom's default, which its files don't vary
+const pyScalableRatio = 0.9
+
+// The sizes of the headers of pybloom's files: struct formats '<dQQQQ' for a
+// BloomFilter, and '<idQd' followed by '<l' for a ScalableBloomFilter
+const (
+	pyBloomHeaderSize    = 40
+	pyScalableHeaderSize = 32
+)
+
+// A bloom filter which hashes items and lays out its bits the way Python's
+// pybloom (and pybloom-live) BloomFilter does, so that filters built by
+// Python pipelines can be queried by Go services, and the other way around: a
+// file written by BloomFilter.tofile can be decoded with UnmarshalBinary, and
+// one encoded by MarshalBinary can be read with BloomFilter.fromfile.
+//
+// pybloom hashes the UTF-8 encoding of a str key, and of str(key) for any
+// other key, so the items given to the filter must be those bytes, e.g.
+// []byte("42") for the int 42, and []byte("b'abc'") for the bytes b'abc'.
+//
+// The files of pybloomfiltermmap, which are the memory of its C structures
+// mapped to a file, aren't supported.
+type PyBloomFilter struct {
+	errorRate float64
+	k         uint64 // pybloom's num_slices
+	slice     uint64 // pybloom's bits_per_slice
+	capacity  uint64
+	count     uint64
+	bits      []byte // least significant bit first, as bitarray(endian='little')
+	hf        func() hash.Hash
+	chunk     int      // the size of each index taken from a digest
+	salts     [][]byte // prepended to the data for each digest
+}
+
+// Create a filter for capacity items with an error rate of p, e.g. 0.001,
+// sized as pybloom's BloomFilter(capacity, p) sizes it. Panics if capacity
+// isn't positive or p isn't between 0 and 1.
+func NewPyBloom(capacity int64, p float64) *PyBloomFilter {
+	if capacity < 1 || !(p > 0 && p < 1) {
+		panic("Unable to create a pybloom filter without a positive capacity and a valid error rate.")
+	}
+	k := uint64(math.Ceil(math.Log(1/p) / math.Ln2))
+	slice := uint64(math.Ceil(float64(capacity) * math.Abs(math.Log(p)) / (float64(k) * math.Ln2 * math.Ln2)))
+	f := &PyBloomFilter{}
+	f.setup(p, k, slice, uint64(capacity), 0)
+	return f
+}
+
+// Sets the parameters of the filter and clears it, like pybloom's _setup.
+func (f *PyBloomFilter) setup(errorRate float64, k, slice, capacity, count uint64) {
+	f.errorRate, f.k, f.slice, f.capacity, f.count = errorRate, k, slice, capacity, count
+	f.bits = make([]byte, (k*slice+7)/8)
+	switch {
+	case slice >= 1<<31:
+		f.chunk = 8
+	case slice >= 1<<15:
+		f.chunk = 4
+	default:
+		f.chunk = 2
+	}
+	switch hashBits := 8 * k * uint64(f.chunk); {
+	case hashBits > 384:
+		f.hf = sha512.New
+	case hashBits > 256:
+		f.hf = sha512.New384
+	case hashBits > 160:
+		f.hf = sha256.New
+	case hashBits > 128:
+		f.hf = sha1.New
+	default:
+		f.hf = md5.New
+	}
+	perDigest := uint64(f.hf().Size() / f.chunk)
+	f.salts = make([][]byte, (k+perDigest-1)/perDigest)
+	h := f.hf()
+	for i := range f.salts {
+		h.Reset()
+		h.Write(binary.LittleEndian.AppendUint32(nil, uint32(i)))
+		f.salts[i] = h.Sum(nil)
+	}
+}
+
+// Returns the index of each bit for data, one in each slice of the filter.
+func (f *PyBloomFilter) indexes(data []byte) []uint64 {
+	is := make([]uint64, 0, f.k)
+	h := f.hf()
+	var sum []byte
+	for _, salt := range f.salts {
+		h.Reset()
+		h.Write(salt)
+		h.Write(data)
+		sum = h.Sum(sum[:0])
+		for j := 0; j+f.chunk <= len(sum) && uint64(len(is)) < f.k; j += f.chunk {
+			var x uint64
+			switch f.chunk {
+			case 2:
+				x = uint64(binary.LittleEndian.Uint16(sum[j:]))
+			case 4:
+				x = uint64(binary.LittleEndian.Uint32(sum[j:]))
+			default:
+				x = binary.LittleEndian.Uint64(sum[j:])
+			}
+			is = append(is, uint64(len(is))*f.slice+x%f.slice)
+		}
+	}
+	return is
+}
+
+func (f *PyBloomFilter) test(is []uint64) bool {
+	for _, i := range is {
+		if f.bits[i/8]&(1<<(i%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks whether data was (probably) added to the filter.
+func (f *PyBloomFilter) Test(data []byte) bool {
+	return f.test(f.indexes(data))
+}
+
+// Adds data to the filter.
+func (f *PyBloomFilter) Add(data []byte) {
+	f.TestAndAdd(data)
+}
+
+// Equivalent to calling Test(data) then Add(data). Returns the result of Test.
+// As with pybloom, the count of items only grows if data wasn't present.
+// Unlike pybloom, items can be added beyond the filter's capacity, at the
+// expense of its error rate.
+func (f *PyBloomFilter) TestAndAdd(data []byte) bool {
+	is := f.indexes(data)
+	if f.test(is) {
+		return true
+	}
+	for _, i := range is {
+		f.bits[i/8] |= 1 << (i % 8)
+	}
+	f.count++
+	return false
+}
+
+// Returns the number of items added to the filter, as pybloom counts them.
+func (f *PyBloomFilter) Len() int {
+	return int(f.count)
+}
+
+// Returns the number of items the filter was created for.
+func (f *PyBloomFilter) Capacity() uint64 {
+	return f.capacity
+}
+
+// Returns the error rate the filter was created with.
+func (f *PyBloomFilter) FPRate() float64 {
+	return f.errorRate
+}
+
+// Resets the filter.
+func (f *PyBloomFilter) Reset() {
+	clear(f.bits)
+	f.count = 0
+}
+
+// Encodes the filter as pybloom's BloomFilter.tofile writes it: the error
+// rate, number of slices, bits per slice, capacity and count, little-endian,
+// followed by the bits.
+func (f *PyBloomFilter) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, pyBloomHeaderSize+len(f.bits))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(f.errorRate))
+	buf = binary.LittleEndian.AppendUint64(buf, f.k)
+	buf = binary.LittleEndian.AppendUint64(buf, f.slice)
+	buf = binary.LittleEndian.AppendUint64(buf, f.capacity)
+	buf = binary.LittleEndian.AppendUint64(buf, f.count)
+	return append(buf, f.bits...), nil
+}
+
+// Decodes a filter written by pybloom's BloomFilter.tofile, replacing the
+// contents of f.
+func (f *PyBloomFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < pyBloomHeaderSize {
+		return ErrInvalidEncoding
+	}
+	errorRate := math.Float64frombits(binary.LittleEndian.Uint64(data))
+	k := binary.LittleEndian.Uint64(data[8:])
+	slice := binary.LittleEndian.Uint64(data[16:])
+	capacity := binary.LittleEndian.Uint64(data[24:])
+	count := binary.LittleEndian.Uint64(data[32:])
+	data = data[pyBloomHeaderSize:]
+	// Checking the size of each factor first keeps their product from
+	// overflowing
+	if k == 0 || slice == 0 || k > 8*uint64(len(data)) || slice > 8*uint64(len(data)) ||
+		(k*slice+7)/8 != uint64(len(data)) {
+		return ErrInvalidEncoding
+	}
+	f.setup(errorRate, k, slice, capacity, count)
+	copy(f.bits, data)
+	return nil
+}
+
+// A scalable bloom filter compatible with pybloom's ScalableBloomFilter: a
+// series of PyBloomFilters, each with a capacity growth times that of the
+// last and a tighter error rate, to which a new filter is added when the last
+// one is full. A file written by ScalableBloomFilter.tofile can be decoded
+// with UnmarshalBinary, and one encoded by MarshalBinary can be read with
+// ScalableBloomFilter.fromfile. Items are given as with PyBloomFilter.
+type PyScalableBloomFilter struct {
+	growth          int32 // pybloom's scale, or mode
+	ratio           float64
+	initialCapacity uint64
+	errorRate       float64
+	filters         []*PyBloomFilter
+}
+
+// Create a scalable filter whose first filter holds initialCapacity items,
+// with an overall error rate of p, e.g. 0.001, and a growth of
+// PySmallSetGrowth or PyLargeSetGrowth, as pybloom's
+// ScalableBloomFilter(initialCapacity, p, growth) creates it. Panics if
+// initialCapacity or growth isn't positive, or p isn't between 0 and 1.
+func NewPyScalableBloom(initialCapacity int64, p float64, growth int) *PyScalableBloomFilter {
+	if initialCapacity < 1 || growth < 1 || growth > math.MaxInt32 || !(p > 0 && p < 1) {
+		panic("Unable to create a pybloom scalable filter without a positive capacity and growth and a valid error rate.")
+	}
+	return &PyScalableBloomFilter{
+		growth:          int32(growth),
+		ratio:           pyScalableRatio,
+		initialCapacity: uint64(initialCapacity),
+		errorRate:       p,
+	}
+}
+
+// Checks whether data was (probably) added to the filter.
+func (f *PyScalableBloomFilter) Test(data []byte) bool {
+	for i := len(f.filters) - 1; i >= 0; i-- {
+		if f.filters[i].Test(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds data to the filter.
+func (f *PyScalableBloomFilter) Add(data []byte) {
+	f.TestAndAdd(data)
+}
+
+// Equivalent to calling Test(data) then Add(data). Returns the result of Test.
+func (f *PyScalableBloomFilter) TestAndAdd(data []byte) bool {
+	if f.Test(data) {
+		return true
+	}
+	var last *PyBloomFilter
+	if len(f.filters) == 0 {
+		last = NewPyBloom(int64(f.initialCapacity), f.errorRate*(1-f.ratio))
+		f.filters = append(f.filters, last)
+	} else if last = f.filters[len(f.filters)-1]; last.count >= last.capacity {
+		last = NewPyBloom(int64(last.capacity)*int64(f.growth), last.errorRate*f.ratio)
+		f.filters = append(f.filters, last)
+	}
+	last.TestAndAdd(data)
+	return false
+}
+
+// Returns the number of items added to the filter, as pybloom counts them.
+func (f *PyScalableBloomFilter) Len() int {
+	n := 0
+	for _, g := range f.filters {
+		n += g.Len()
+	}
+	return n
+}
+
+// Returns the number of filters the scalable filter has grown to.
+func (f *PyScalableBloomFilter) Filters() int {
+	return len(f.filters)
+}
+
+// Resets the filter, removing every filter it has grown to.
+func (f *PyScalableBloomFilter) Reset() {
+	f.filters = nil
+}
+
+// Encodes the filter as pybloom's ScalableBloomFilter.tofile writes it: the
+// growth, ratio, initial capacity and error rate, the number of filters, the
+// size of each filter's encoding, and the encoding of each filter, as
+// PyBloomFilter.MarshalBinary encodes it.
+func (f *PyScalableBloomFilter) MarshalBinary() ([]byte, error) {
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(f.growth))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(f.ratio))
+	buf = binary.LittleEndian.AppendUint64(buf, f.initialCapacity)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(f.errorRate))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(f.filters)))
+	encoded := make([][]byte, len(f.filters))
+	for i, g := range f.filters {
+		encoded[i], _ = g.MarshalBinary()
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(len(encoded[i])))
+	}
+	for _, e := range encoded {
+		buf = append(buf, e...)
+	}
+	return buf, nil
+}
+
+// Decodes a filter written by pybloom's ScalableBloomFilter.tofile, replacing
+// the contents of f.
+func (f *PyScalableBloomFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < pyScalableHeaderSize {
+		return ErrInvalidEncoding
+	}
+	growth := int32(binary.LittleEndian.Uint32(data))
+	ratio := math.Float64frombits(binary.LittleEndian.Uint64(data[4:]))
+	initialCapacity := binary.LittleEndian.Uint64(data[12:])
+	errorRate := math.Float64frombits(binary.LittleEndian.Uint64(data[20:]))
+	n := int32(binary.LittleEndian.Uint32(data[28:]))
+	data = data[pyScalableHeaderSize:]
+	if n < 0 || int64(n) > int64(len(data)/8) {
+		return ErrInvalidEncoding
+	}
+	sizes, data := data[:8*n], data[8*n:]
+	filters := make([]*PyBloomFilter, n)
+	for i := range filters {
+		size := binary.LittleEndian.Uint64(sizes[8*i:])
+		if size > uint64(len(data)) {
+			return ErrInvalidEncoding
+		}
+		filters[i] = &PyBloomFilter{}
+		if err := filters[i].UnmarshalBinary(data[:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	if len(data) != 0 {
+		return ErrInvalidEncoding
+	}
+	f.growth, f.ratio, f.initialCapacity, f.errorRate, f.filters = growth, ratio, initialCapacity, errorRate, filters
+	return nil
+}
@@ -0,0 +1,118 @@
+package bloom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+)
+
+// The file pybloom's BloomFilter.tofile writes for BloomFilter(10, 0.1) after
+// adding 'a', 'b' and 42
+const pyBloomFixture = "9a9999999999b93f04000000000000000c000000000000000a0000000000000003000000000000000a2409083820"
+
+func TestPyBloomFilterFixture(t *testing.T) {
+	f := NewPyBloom(10, 0.1)
+	for _, key := range []string{"a", "b", "42"} {
+		f.Add([]byte(key))
+	}
+	data, _ := f.MarshalBinary()
+	if got := hex.EncodeToString(data); got != pyBloomFixture {
+		t.Fatalf("encoded %s, want %s", got, pyBloomFixture)
+	}
+
+	fixture, _ := hex.DecodeString(pyBloomFixture)
+	g := &PyBloomFilter{}
+	if err := g.UnmarshalBinary(fixture); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Test([]byte("a")) || !g.Test([]byte("42")) || g.Len() != 3 || g.Capacity() != 10 || g.FPRate() != 0.1 {
+		t.Error("unexpected decoded filter")
+	}
+	if err := g.UnmarshalBinary(fixture[:len(fixture)-1]); err != ErrInvalidEncoding {
+		t.Errorf("decoding truncated data returned %v", err)
+	}
+}
+
+func TestPyBloomFilterDigests(t *testing.T) {
+	// The SHA-256 sums of the files pybloom writes after adding "0" to "99",
+	// for filters taking 2-byte indexes from SHA-256, and 4-byte indexes
+	// from SHA-384
+	cases := []struct {
+		capacity int64
+		p        float64
+		sum      string
+	}{
+		{1000, 0.0001, "76991df137f48e3fcb8426660a192dc1b61d307c9cf74f574e8024b3ac096926"},
+		{40000, 0.001, "0deb39954c1a3567fccb20bc5a122e78203aa8d6d2192d3b9b3ec1ad50c7d221"},
+	}
+	for _, c := range cases {
+		f := NewPyBloom(c.capacity, c.p)
+		for i := 0; i < 100; i++ {
+			f.Add([]byte(strconv.Itoa(i)))
+		}
+		data, _ := f.MarshalBinary()
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != c.sum {
+			t.Errorf("NewPyBloom(%d, %v): unexpected encoding", c.capacity, c.p)
+		}
+	}
+}
+
+func TestPyBloomFilter(t *testing.T) {
+	f := NewPyBloom(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		if f.TestAndAdd([]byte(strconv.Itoa(i))) {
+			t.Logf("%d was a false positive", i)
+		}
+	}
+	fp := 0
+	for i := 0; i < 1000; i++ {
+		if !f.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%d not found", i)
+		}
+		if f.Test([]byte(strconv.Itoa(1000 + i))) {
+			fp++
+		}
+	}
+	if fp > 30 {
+		t.Errorf("%d false positives in 1000", fp)
+	}
+	f.Reset()
+	if f.Test([]byte("1")) || f.Len() != 0 {
+		t.Error("item found after Reset")
+	}
+}
+
+func TestPyScalableBloomFilter(t *testing.T) {
+	f := NewPyScalableBloom(100, 0.001, PySmallSetGrowth)
+	for i := 0; i < 1000; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	// 100 + 200 + 400 + 800 items
+	if f.Filters() != 4 || f.Len() < 990 {
+		t.Fatalf("%d filters holding %d items", f.Filters(), f.Len())
+	}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &PyScalableBloomFilter{}
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if !g.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%d not found", i)
+		}
+	}
+	if g.Filters() != 4 || g.Len() != f.Len() {
+		t.Error("decoded filter differs")
+	}
+	if err := g.UnmarshalBinary(data[:len(data)-1]); err != ErrInvalidEncoding {
+		t.Errorf("decoding truncated data returned %v", err)
+	}
+	empty, _ := NewPyScalableBloom(100, 0.001, PyLargeSetGrowth).MarshalBinary()
+	if err := g.UnmarshalBinary(empty); err != nil || g.Filters() != 0 || g.Test([]byte("1")) {
+		t.Errorf("decoding an empty filter returned %v", err)
+	}
+}
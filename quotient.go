@@ -0,0 +1,467 @@
+package bloom
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// The fewest remainder bits a quotient filter keeps. Counters are encoded in
+// base 2^rBits-1, which needs at least two bits, so a filter stops doubling its
+// table, which takes one bit from the remainders, at this width.
+const qfMinRBits = 2
+
+// The load of its home slots a quotient filter doubles its table beyond.
+const qfMaxLoad = 0.75
+
+// A fingerprint found in a quotient filter, with its number of occurrences.
+type qfEntry struct {
+	q, r  uint64
+	count uint64
+}
+
+// A counting quotient filter using the 64-bit FNV-1 hash function. Every item
+// is reduced to a fingerprint whose high bits (the quotient) give a home slot
+// in a linearly-probed table. The low bits (the remainder) are stored in the
+// run of slots of the fingerprints with that quotient, kept sorted, and each
+// slot holds just a remainder and three bits of metadata, so lookups touch
+// only a few adjacent words.
+//
+// Counts are stored in the slots following a remainder, as variable-length
+// counters: an item added once uses one slot, twice two slots, and n times
+// about 2+log(n)/rBits slots, so that both rare and very frequent items, e.g.
+// k-mers of a genome, are stored compactly. When three quarters of its home
+// slots are used, the table is doubled by moving one bit of every remainder
+// to its quotient, which doesn't change the false positive rate for the
+// fingerprints already added. Two filters with the same fingerprint size can
+// be merged, even if only one of them grew.
+type QuotientFilter struct {
+	qBits     uint
+	rBits     uint
+	qBits0    uint // qBits upon creation, restored by ResetAndShrink
+	nslots    int  // at least 1<<qBits, as clusters overflow past the end
+	used      int
+	rems      []uint64 // rBits-wide remainders and counter digits
+	occupieds []uint64 // bit q is set if a fingerprint has quotient q
+	runends   []uint64 // bit i is set if slot i is the last of a run
+	filled    []uint64 // bit i is set if slot i isn't empty
+	hf        HashFunc
+}
+
+func qfBit(b []uint64, i uint64) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+func qfSetBit(b []uint64, i uint64, v bool) {
+	if v {
+		b[i/64] |= 1 << (i % 64)
+	} else {
+		b[i/64] &^= 1 << (i % 64)
+	}
+}
+
+func (f *QuotientFilter) fingerprint(data []byte) uint64 {
+	return hash64(f.hf, nil, data) >> (64 - f.qBits - f.rBits)
+}
+
+func (f *QuotientFilter) isFilled(i int) bool {
+	return i < f.nslots && qfBit(f.filled, uint64(i))
+}
+
+func (f *QuotientFilter) slot(i int) uint64 {
+	bit := uint64(i) * uint64(f.rBits)
+	w, o := bit/64, uint(bit%64)
+	x := f.rems[w] >> o
+	if o+f.rBits > 64 {
+		x |= f.rems[w+1] << (64 - o)
+	}
+	return x & (1<<f.rBits - 1)
+}
+
+func (f *QuotientFilter) setSlot(i int, x uint64) {
+	bit := uint64(i) * uint64(f.rBits)
+	w, o := bit/64, uint(bit%64)
+	mask := uint64(1)<<f.rBits - 1
+	f.rems[w] = f.rems[w]&^(mask<<o) | x<<o
+	if o+f.rBits > 64 {
+		f.rems[w+1] = f.rems[w+1]&^(mask>>(64-o)) | x>>(64-o)
+	}
+}
+
+// Grows the table to n slots, which are empty.
+func (f *QuotientFilter) resize(n int) {
+	grow := func(b []uint64, words int) []uint64 {
+		if words <= len(b) {
+			return b
+		}
+		return append(b, make([]uint64, words-len(b))...)
+	}
+	f.rems = grow(f.rems, (n*int(f.rBits)+63)/64)
+	f.filled = grow(f.filled, (n+63)/64)
+	f.runends = grow(f.runends, (n+63)/64)
+	f.occupieds = grow(f.occupieds, (1<<f.qBits+63)/64)
+	f.nslots = n
+}
+
+// Appends the slots encoding count occurrences of the remainder x, which are:
+//
+//	x                  if count is 1
+//	x, x               if count is 2
+//	x, d..., x         if count > 2 and x > 0
+//	0, 0, 0, d..., 0   if count > 2 and x is 0
+//
+// where d... are the digits of count-3 in base 2^rBits-1, most significant
+// first, skipping the value x, preceded by a 0 if the first one is above x.
+// Since the remainders of a run are sorted, a slot following x which isn't
+// above it is always part of its counter.
+func (f *QuotientFilter) appendCounter(dst []uint64, x, count uint64) []uint64 {
+	dst = append(dst, x)
+	switch {
+	case count == 1:
+		return dst
+	case count == 2:
+		return append(dst, x)
+	case x == 0:
+		dst = append(dst, 0, 0)
+	}
+	base := uint64(1)<<f.rBits - 1
+	var digits [64]uint64
+	n := 0
+	for v := count - 3; n == 0 || v > 0; v /= base {
+		if digits[n] = v % base; digits[n] >= x {
+			digits[n]++
+		}
+		n++
+	}
+	if x > 0 && digits[n-1] > x {
+		dst = append(dst, 0)
+	}
+	for n > 0 {
+		n--
+		dst = append(dst, digits[n])
+	}
+	return append(dst, x)
+}
+
+// Decodes the remainder starting at slot i of a run ending at slot end.
+// Returns the remainder, its count, and the number of slots encoding them.
+func (f *QuotientFilter) readCounter(i, end int) (x, count uint64, n int) {
+	x = f.slot(i)
+	if i == end {
+		return x, 1, 1
+	}
+	j := i + 1
+	switch y := f.slot(j); {
+	case x > 0 && y > x, x == 0 && y != 0:
+		return x, 1, 1
+	case y == x:
+		if x > 0 || j == end || f.slot(j+1) != 0 {
+			return x, 2, 2
+		}
+		j += 2
+	}
+	base := uint64(1)<<f.rBits - 1
+	var v uint64
+	for ; f.slot(j) != x; j++ {
+		d := f.slot(j)
+		if d > x {
+			d--
+		}
+		v = v*base + d
+	}
+	return x, v + 3, j - i + 1
+}
+
+// Returns the first slot of the cluster containing the filled slot i.
+func (f *QuotientFilter) clusterStart(i int) int {
+	for i > 0 && f.isFilled(i-1) {
+		i--
+	}
+	return i
+}
+
+// Returns the last slot of the run starting at slot i.
+func (f *QuotientFilter) runEnd(i int) int {
+	for !qfBit(f.runends, uint64(i)) {
+		i++
+	}
+	return i
+}
+
+// Appends the entries of the cluster starting at slot start, if it is filled,
+// to dst, and returns them with the slot following the cluster.
+func (f *QuotientFilter) readCluster(dst []qfEntry, start int) ([]qfEntry, int) {
+	i := start
+	q := uint64(start)
+	for f.isFilled(i) {
+		// The runs of a cluster belong to its occupied quotients, in order
+		for !qfBit(f.occupieds, q) {
+			q++
+		}
+		for end := f.runEnd(i); i <= end; {
+			r, count, n := f.readCounter(i, end)
+			dst = append(dst, qfEntry{q, r, count})
+			i += n
+		}
+		q++
+	}
+	return dst, i
+}
+
+// A slot of a quotient filter, as laid out by layout.
+type qfSlot struct {
+	i      int
+	x      uint64
+	runEnd bool
+}
+
+// Returns the slots encoding the sorted entries, starting at slot start, and
+// the slot following the last one. Every run starts at its home slot, or right
+// after the previous run if that one ends past it.
+func (f *QuotientFilter) layout(entries []qfEntry, start int) ([]qfSlot, int) {
+	var (
+		slots []qfSlot
+		vals  []uint64
+	)
+	i := start
+	for n, e := range entries {
+		if n == 0 || e.q != entries[n-1].q {
+			i = max(i, int(e.q))
+		}
+		vals = f.appendCounter(vals[:0], e.r, e.count)
+		for _, x := range vals {
+			slots = append(slots, qfSlot{i: i, x: x})
+			i++
+		}
+		if n+1 == len(entries) || entries[n+1].q != e.q {
+			slots[len(slots)-1].runEnd = true
+		}
+	}
+	return slots, i
+}
+
+// Writes the sorted entries from slot start, replacing the slots before end,
+// which must be empty or part of clusters starting after start. Clusters after
+// end which the entries would now overlap are read and rewritten with them.
+func (f *QuotientFilter) writeCluster(entries []qfEntry, start, end int) {
+	slots, last := f.layout(entries, start)
+	for {
+		next := end
+		for next < last && !f.isFilled(next) {
+			next++
+		}
+		if next >= last {
+			break
+		}
+		entries, end = f.readCluster(entries, next)
+		slots, last = f.layout(entries, start)
+	}
+	for i := start; i < min(end, f.nslots); i++ {
+		if f.isFilled(i) {
+			qfSetBit(f.filled, uint64(i), false)
+			qfSetBit(f.runends, uint64(i), false)
+			f.used--
+		}
+	}
+	if last > f.nslots {
+		f.resize(last)
+	}
+	for _, s := range slots {
+		f.setSlot(s.i, s.x)
+		qfSetBit(f.filled, uint64(s.i), true)
+		qfSetBit(f.runends, uint64(s.i), s.runEnd)
+	}
+	for _, e := range entries {
+		qfSetBit(f.occupieds, e.q, true)
+	}
+	f.used += len(slots)
+}
+
+// Returns the number of occurrences of the fingerprint fp.
+func (f *QuotientFilter) count(fp uint64) uint64 {
+	q, r := fp>>f.rBits, fp&(1<<f.rBits-1)
+	if !qfBit(f.occupieds, q) {
+		return 0
+	}
+	// Skip the runs of the cluster before the one of q
+	i := f.clusterStart(int(q))
+	for j := uint64(i); j < q; j++ {
+		if qfBit(f.occupieds, j) {
+			i = f.runEnd(i) + 1
+		}
+	}
+	for end := f.runEnd(i); i <= end; {
+		x, count, n := f.readCounter(i, end)
+		if x >= r {
+			if x == r {
+				return count
+			}
+			break
+		}
+		i += n
+	}
+	return 0
+}
+
+// Adds n occurrences of the fingerprint fp if n > 0, or removes one
+// otherwise, and returns its previous number of occurrences.
+func (f *QuotientFilter) update(fp, n uint64) uint64 {
+	if n > 0 && f.rBits > qfMinRBits && float64(f.used) >= qfMaxLoad*float64(uint64(1)<<f.qBits) {
+		f.grow()
+	}
+	q, r := fp>>f.rBits, fp&(1<<f.rBits-1)
+	start := int(q)
+	if f.isFilled(start) {
+		start = f.clusterStart(start)
+	}
+	entries, end := f.readCluster(nil, start)
+	i := sort.Search(len(entries), func(i int) bool {
+		e := entries[i]
+		return e.q > q || e.q == q && e.r >= r
+	})
+	var old uint64
+	if found := i < len(entries) && entries[i].q == q && entries[i].r == r; found {
+		old = entries[i].count
+	}
+	switch {
+	case n > 0 && old > 0:
+		entries[i].count += n
+	case n > 0:
+		entries = append(entries[:i], append([]qfEntry{{q, r, n}}, entries[i:]...)...)
+	case old > 1:
+		entries[i].count--
+	case old == 1:
+		entries = append(entries[:i], entries[i+1:]...)
+		if (i == 0 || entries[i-1].q != q) && (i == len(entries) || entries[i].q != q) {
+			qfSetBit(f.occupieds, q, false)
+		}
+	default:
+		return 0
+	}
+	f.writeCluster(entries, start, end)
+	return old
+}
+
+// Calls fn with every fingerprint of the filter and its number of
+// occurrences, in order.
+func (f *QuotientFilter) each(fn func(fp, count uint64)) {
+	var entries []qfEntry
+	for i := 0; i < f.nslots; {
+		if !f.isFilled(i) {
+			i++
+			continue
+		}
+		entries, i = f.readCluster(entries[:0], i)
+		for _, e := range entries {
+			fn(e.q<<f.rBits|e.r, e.count)
+		}
+	}
+}
+
+// Doubles the table, moving the highest bit of every remainder to its
+// quotient.
+func (f *QuotientFilter) grow() {
+	var entries []qfEntry
+	f.each(func(fp, count uint64) {
+		entries = append(entries, qfEntry{fp >> (f.rBits - 1), fp & (1<<(f.rBits-1) - 1), count})
+	})
+	f.qBits++
+	f.rBits--
+	f.allocate()
+	f.writeCluster(entries, 0, 0)
+}
+
+// Allocates an empty table of 1<<qBits slots.
+func (f *QuotientFilter) allocate() {
+	f.rems, f.filled, f.runends, f.occupieds = nil, nil, nil, nil
+	f.used = 0
+	f.resize(1 << f.qBits)
+}
+
+// Checks whether data was previously added to the filter. Returns true if
+// yes, with a false positive chance near the ratio specified upon creation
+// of the filter. The result cannot be falsely negative (unless one has
+// removed an item that wasn't actually added to the filter previously.)
+func (f *QuotientFilter) Test(data []byte) bool {
+	return f.count(f.fingerprint(data)) > 0
+}
+
+// Returns the number of times data was added to the filter, minus the number
+// of times it was removed. The count may be too high if another item has the
+// same fingerprint, but is never too low.
+func (f *QuotientFilter) Count(data []byte) uint64 {
+	return f.count(f.fingerprint(data))
+}
+
+// Adds data to the filter.
+func (f *QuotientFilter) Add(data []byte) {
+	f.update(f.fingerprint(data), 1)
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. Data is hashed only once.
+func (f *QuotientFilter) TestAndAdd(data []byte) bool {
+	return f.update(f.fingerprint(data), 1) > 0
+}
+
+// Removes one occurrence of data from the filter. This exact data must have
+// been previously added to the filter, or future results will be inconsistent.
+func (f *QuotientFilter) Remove(data []byte) {
+	f.update(f.fingerprint(data), 0)
+}
+
+// Merges other into f, adding its counts to those of f. Returns
+// ErrIncompatible if the fingerprints of the filters have different sizes,
+// i.e. they were created with different parameters.
+func (f *QuotientFilter) Merge(other *QuotientFilter) error {
+	if f.qBits+f.rBits != other.qBits+other.rBits {
+		return ErrIncompatible
+	}
+	// Read other first, in case it is f
+	var entries []qfEntry
+	other.each(func(fp, count uint64) {
+		entries = append(entries, qfEntry{r: fp, count: count})
+	})
+	for _, e := range entries {
+		f.update(e.r, e.count)
+	}
+	return nil
+}
+
+// Resets the filter. Its table is kept, including any slots added as it grew
+// or as clusters overflowed past its end; use ResetAndShrink to release them.
+func (f *QuotientFilter) Reset() {
+	clear(f.filled)
+	clear(f.runends)
+	clear(f.occupieds)
+	f.used = 0
+}
+
+// Resets the filter, and reallocates its table at its original size.
+func (f *QuotientFilter) ResetAndShrink() {
+	f.rBits += f.qBits - f.qBits0
+	f.qBits = f.qBits0
+	f.allocate()
+}
+
+// Create a counting quotient filter with an expected n number of distinct
+// items, and an acceptable false positive rate of p, e.g. 0.01. The table
+// doubles if more items are added.
+func NewQuotient(n int, p float64, opts ...Option) *QuotientFilter {
+	// Keep the table at most three quarters full
+	slots := uint64(math.Ceil(float64(n) / qfMaxLoad))
+	qBits := max(uint(bits.Len64(slots-1)), 1)
+	rBits := max(uint(math.Ceil(math.Log2(1/p))), qfMinRBits)
+	if qBits > 40 || qBits+rBits > 64 {
+		panic(fmt.Sprintf("A quotient filter with n %d and p %f requires %d-bit fingerprints, but the maximum supported size is 64 bits.", n, p, qBits+rBits))
+	}
+	f := &QuotientFilter{
+		qBits:  qBits,
+		rBits:  rBits,
+		qBits0: qBits,
+		hf:     newOptions(opts).hash,
+	}
+	f.allocate()
+	return f
+}
@@ -0,0 +1,192 @@
+package bloom
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestQuotientFilter(t *testing.T) {
+	f := NewQuotient(3000, 0.01)
+	f.Add(foo)
+	f.Add(foo)
+	f.Add(bar)
+	if c := f.Count(foo); c != 2 {
+		t.Errorf("foo count is %d", c)
+	}
+	f.Remove(foo)
+	if !f.Test(foo) {
+		t.Error("foo not in filter")
+	}
+	f.Remove(foo)
+	if f.Test(foo) {
+		t.Error("foo still in filter")
+	}
+	if !f.Test(bar) {
+		t.Error("bar not in filter")
+	}
+}
+
+func TestQuotientFilterFull(t *testing.T) {
+	n := 10000
+	f := NewQuotient(n, 0.001)
+	for i := 0; i < n; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < n; i += 3 {
+		f.Remove([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < n; i++ {
+		if ok := f.Test([]byte(strconv.Itoa(i))); !ok && i%3 != 0 {
+			t.Fatalf("%d not in filter", i)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if f.Test([]byte(strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if p := float64(fp) / float64(n); p > 0.002 {
+		t.Errorf("False positive rate too high: %f", p)
+	}
+}
+
+func TestQuotientFilterMerge(t *testing.T) {
+	a := NewQuotient(1000, 0.01)
+	b := NewQuotient(1000, 0.01)
+	a.Add(foo)
+	b.Add(foo)
+	b.Add(bar)
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if a.Count(foo) != 2 || a.Count(bar) != 1 {
+		t.Errorf("counts after merge: foo %d, bar %d", a.Count(foo), a.Count(bar))
+	}
+	if err := a.Merge(NewQuotient(100000, 0.01)); err != ErrIncompatible {
+		t.Errorf("merging different parameters returned %v", err)
+	}
+}
+
+func TestQuotientFilterReset(t *testing.T) {
+	f := NewQuotient(100, 0.01)
+	size := f.nslots
+	for i := 0; i < 300; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	grown := f.nslots
+	if grown <= size {
+		t.Fatalf("table of %d slots didn't grow", grown)
+	}
+	f.Reset()
+	if f.nslots != grown {
+		t.Errorf("Reset changed the table from %d to %d slots", grown, f.nslots)
+	}
+	if f.Test([]byte("1")) {
+		t.Error("1 in the filter after Reset")
+	}
+	f.Add([]byte("1"))
+	f.ResetAndShrink()
+	if f.nslots != size {
+		t.Errorf("%d slots after ResetAndShrink, expected %d", f.nslots, size)
+	}
+	if f.Test([]byte("1")) {
+		t.Error("1 in the filter after ResetAndShrink")
+	}
+}
+
+func TestQuotientFilterCounters(t *testing.T) {
+	// Few remainder bits, so that runs hold many remainders and counters
+	f := NewQuotient(50, 0.3)
+	want := map[uint64]uint64{}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		data := []byte(strconv.Itoa(rng.Intn(200)))
+		fp := f.fingerprint(data)
+		if rng.Intn(3) == 0 {
+			if want[fp] > 0 {
+				f.Remove(data)
+				want[fp]--
+			}
+		} else {
+			if present := f.TestAndAdd(data); present != (want[fp] > 0) {
+				t.Fatalf("TestAndAdd returned %v with a count of %d", present, want[fp])
+			}
+			want[fp]++
+		}
+	}
+	if f.rBits != qfMinRBits {
+		t.Errorf("%d remainder bits after growing, expected %d", f.rBits, qfMinRBits)
+	}
+	for i := 0; i < 200; i++ {
+		data := []byte(strconv.Itoa(i))
+		if c := f.Count(data); c != want[f.fingerprint(data)] {
+			t.Fatalf("%d: count is %d, expected %d", i, c, want[f.fingerprint(data)])
+		}
+	}
+
+	// Large counts use a few slots
+	g := NewQuotient(1000, 0.01)
+	fp := g.fingerprint(foo)
+	for _, n := range []uint64{1, 1, 1, 1 << 20, 1 << 40, 1 << 62} {
+		want := g.Count(foo) + n
+		g.update(fp, n)
+		if c := g.Count(foo); c != want {
+			t.Fatalf("count is %d, expected %d", c, want)
+		}
+	}
+	if g.used > 2+64/int(g.rBits) {
+		t.Errorf("a large counter uses %d slots", g.used)
+	}
+	g.Add(bar)
+	g.Remove(foo)
+	if c := g.Count(bar); c != 1 {
+		t.Errorf("bar count is %d", c)
+	}
+}
+
+func TestQuotientFilterMergeGrown(t *testing.T) {
+	a := NewQuotient(100, 0.01)
+	b := NewQuotient(100, 0.01)
+	for i := 0; i < 1000; i++ {
+		b.Add([]byte(strconv.Itoa(i)))
+	}
+	a.Add(foo)
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Merge(a); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if c := b.Count([]byte(strconv.Itoa(i))); c < 2 {
+			t.Fatalf("%d: count is %d after merging", i, c)
+		}
+	}
+	if !b.Test(foo) {
+		t.Error("foo not merged")
+	}
+}
+
+func TestQuotientFilterConcurrentTest(t *testing.T) {
+	f := NewQuotient(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if !f.Test([]byte(strconv.Itoa(i))) {
+					t.Errorf("%d not in filter", i)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
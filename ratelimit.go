@@ -0,0 +1,78 @@
+package bloom
+
+import (
+	"sync"
+	"time"
+)
+
+// An approximate per-key rate limiter which allows each key at most a number
+// of times per window, e.g. 100 requests per minute per client IP, in memory
+// bounded by the expected number of distinct keys per window rather than the
+// number of keys seen. Its counts are kept in a layered filter, which is
+// reset at the end of each window. Since the filter may count a key too
+// high, but never too low, a key is occasionally limited before it reaches
+// the limit, with about the false positive rate given, but is never allowed
+// more often than the limit. The limiter is safe for concurrent use.
+type RateLimiter struct {
+	mu     sync.Mutex
+	f      *LayeredFilter
+	max    int
+	window time.Duration
+	start  time.Time
+	now    func() time.Time
+}
+
+// Allows key if it was allowed fewer than the limit's number of times in the
+// current window, and counts it. Returns false, without counting key, if not.
+func (l *RateLimiter) Allow(key []byte) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now := l.now(); now.Sub(l.start) >= l.window {
+		l.f.Reset()
+		l.start = now
+	}
+	if l.f.TestAtLeast(key, l.max) {
+		return false
+	}
+	l.f.Add(key)
+	return true
+}
+
+// Returns the number of times key was (probably) allowed in the current
+// window.
+func (l *RateLimiter) Count(key []byte) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.now().Sub(l.start) >= l.window {
+		return 0
+	}
+	return l.f.Count(key)
+}
+
+// Resets the limiter, and starts a new window.
+func (l *RateLimiter) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.f.Reset()
+	l.start = l.now()
+}
+
+// Create a rate limiter allowing each key at most maxPerWindow times per
+// window, for an expected n number of distinct keys per window, with an
+// acceptable false positive rate of p for each count. It holds up to
+// maxPerWindow layers of the size of a Filter for n items and p, so a high
+// limit uses a lot of memory. Panics if maxPerWindow or window isn't
+// positive.
+func NewRateLimiter(n int, maxPerWindow int, window time.Duration, p float64, opts ...Option) *RateLimiter {
+	if maxPerWindow < 1 || window <= 0 {
+		panic("Unable to create a rate limiter without a positive limit and window.")
+	}
+	l := &RateLimiter{
+		f:      NewLayeredMax(n, p, maxPerWindow, opts...),
+		max:    maxPerWindow,
+		window: window,
+		now:    time.Now,
+	}
+	l.start = l.now()
+	return l
+}
@@ -0,0 +1,35 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := NewRateLimiter(1000, 3, time.Minute, 0.001)
+	l.now = func() time.Time { return now }
+	l.Reset()
+	for i := 1; i <= 5; i++ {
+		if allowed := l.Allow(foo); allowed != (i <= 3) {
+			t.Errorf("request %d allowed: %v", i, allowed)
+		}
+	}
+	if !l.Allow(bar) || l.Count(foo) != 3 || l.Count(bar) != 1 {
+		t.Errorf("counts %d and %d", l.Count(foo), l.Count(bar))
+	}
+	now = now.Add(time.Minute)
+	if l.Count(foo) != 0 || !l.Allow(foo) {
+		t.Error("foo limited in a new window")
+	}
+	denied := 0
+	for i := 0; i < 500; i++ {
+		if !l.Allow([]byte(strconv.Itoa(i))) {
+			denied++
+		}
+	}
+	if denied > 5 {
+		t.Errorf("%d of 500 new keys denied", denied)
+	}
+}
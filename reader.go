@@ -0,0 +1,73 @@
+package bloom
+
+import (
+	"bufio"
+	"io"
+)
+
+// Create a bloom filter with an acceptable false positive rate of p, holding
+// every token read from r, split by split, e.g. bufio.ScanLines for newline-
+// separated keys, which is used if split is nil. Empty tokens, e.g. blank
+// lines, are skipped. The filter is sized for the number of tokens: if r is an
+// io.Seeker, it is read twice, first to count them, and otherwise the tokens
+// are kept in memory until they have been counted. Returns an error if
+// reading fails, or one wrapping ErrInvalidParameters if p is invalid.
+func NewFromReader(r io.Reader, split bufio.SplitFunc, p float64, opts ...Option) (*Filter, error) {
+	if split == nil {
+		split = bufio.ScanLines
+	}
+	scan := func(fn func([]byte)) error {
+		s := bufio.NewScanner(r)
+		s.Split(split)
+		for s.Scan() {
+			if len(s.Bytes()) > 0 {
+				fn(s.Bytes())
+			}
+		}
+		return s.Err()
+	}
+
+	var (
+		n    int
+		keys [][]byte
+	)
+	rs, seekable := r.(io.Seeker)
+	var start int64
+	if seekable {
+		var err error
+		if start, err = rs.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+	if seekable {
+		if err := scan(func([]byte) { n++ }); err != nil {
+			return nil, err
+		}
+		if _, err := rs.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := scan(func(key []byte) { keys = append(keys, append([]byte(nil), key...)) }); err != nil {
+			return nil, err
+		}
+		n = len(keys)
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	f, err := NewWithError(n, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !seekable {
+		for _, key := range keys {
+			f.Add(key)
+		}
+		return f, nil
+	}
+	if err := scan(f.Add); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
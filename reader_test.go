@@ -0,0 +1,50 @@
+package bloom
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNewFromReader(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 1000; i++ {
+		sb.WriteString(strconv.Itoa(i))
+		sb.WriteString("\n\n")
+	}
+	text := sb.String()
+	// A strings.Reader is read twice, a bufio.Reader once
+	for _, r := range []io.Reader{strings.NewReader(text), bufio.NewReader(strings.NewReader(text))} {
+		f, err := NewFromReader(r, nil, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Capacity() != 1000 {
+			t.Errorf("filter created for %d items, expected 1000", f.Capacity())
+		}
+		for i := 0; i < 1000; i++ {
+			if !f.Test([]byte(strconv.Itoa(i))) {
+				t.Fatalf("%d not in the filter", i)
+			}
+		}
+	}
+}
+
+func TestNewFromReaderWords(t *testing.T) {
+	f, err := NewFromReader(strings.NewReader("foo bar\tbaz"), bufio.ScanWords, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Capacity() != 3 || !f.Test(foo) || !f.Test(bar) || !f.Test(baz) {
+		t.Error("words missing from the filter")
+	}
+}
+
+func TestNewFromReaderInvalid(t *testing.T) {
+	if _, err := NewFromReader(strings.NewReader("foo"), nil, 2); !errors.Is(err, ErrInvalidParameters) {
+		t.Errorf("NewFromReader with p 2 returned %v", err)
+	}
+}
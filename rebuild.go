@@ -0,0 +1,79 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+
+	"math"
+)
+
+// The false positive rate to rebuild a filter with: the one it was created
+// with, or if that is unknown, the rate at which k hash functions are optimal.
+func rebuildFPRate(p float64, k uint64) float64 {
+	if p > 0 {
+		return p
+	}
+	return math.Pow(0.5, float64(k))
+}
+
+// Panics unless the expected number of items of a rebuilt filter is positive.
+func checkRebuildNum(newNum int64) {
+	if newNum <= 0 {
+		panic("Unable to rebuild a bloom filter without a positive expected number of items.")
+	}
+}
+
+// Returns a new, empty filter for an expected newNum number of items, with the
+// same false positive rate, hash function, seed and other options as f, and
+// adds to it every item each passes to emit. Since a bloom filter can't list
+// its items, the application must provide them again, e.g. from its database:
+//
+//	if f.ApproximatedSize() > uint32(f.Capacity()) {
+//		f = f.Rebuild(2*int(f.Capacity()), func(emit func([]byte)) {
+//			for _, key := range keys {
+//				emit(key)
+//			}
+//		})
+//	}
+//
+// f is left unchanged. If f was decoded, and so its false positive rate is
+// unknown, the rate for which its number of hash functions is optimal is used.
+// Panics if newNum isn't positive.
+func (f *Filter) Rebuild(newNum int, each func(emit func([]byte))) *Filter {
+	checkRebuildNum(int64(newNum))
+	p := rebuildFPRate(f.fpRate, uint64(f.k))
+	m, k := estimates(int64(newNum), p)
+	fl := f.filter.clone()
+	fl.m, fl.k = m, k
+	if fl.parts != nil {
+		fl.partition(m)
+	}
+	fl.capacity, fl.fpRate = uint64(newNum), p
+	r := &Filter{
+		fl,
+		bitset.New32(fl.m),
+	}
+	if each != nil {
+		each(r.Add)
+	}
+	return r
+}
+
+// Returns a new, empty filter for an expected newNum number of items, with the
+// same false positive rate and options as f, and adds to it every item each
+// passes to emit, like Filter.Rebuild. Panics if newNum isn't positive.
+func (f *Filter64) Rebuild(newNum int64, each func(emit func([]byte))) *Filter64 {
+	checkRebuildNum(newNum)
+	p := rebuildFPRate(f.fpRate, f.k)
+	m, k := estimates64(newNum, p)
+	fl := f.filter64.clone()
+	fl.m, fl.k = m, k
+	fl.capacity, fl.fpRate = uint64(newNum), p
+	r := &Filter64{
+		fl,
+		bitset.New64(m),
+	}
+	if each != nil {
+		each(r.Add)
+	}
+	return r
+}
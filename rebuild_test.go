@@ -0,0 +1,74 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRebuild(t *testing.T) {
+	keys := make([][]byte, 2000)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	emitAll := func(emit func([]byte)) {
+		for _, key := range keys {
+			emit(key)
+		}
+	}
+	for _, opts := range [][]Option{nil, {WithSeed(42)}, {WithIndexMode(FastRange)}} {
+		f := New(1000, 0.01, opts...)
+		emitAll(f.Add)
+		r := f.Rebuild(4000, emitAll)
+		if r.Capacity() != 4000 || r.FPRate() != 0.01 || r.M() <= f.M() {
+			t.Errorf("rebuilt filter has capacity %d, p %f and %d bits", r.Capacity(), r.FPRate(), r.M())
+		}
+		if r.seed != f.seed || r.index != f.index {
+			t.Error("rebuilt filter has different options")
+		}
+		for _, key := range keys {
+			if !r.Test(key) {
+				t.Fatalf("%s not in the rebuilt filter", key)
+			}
+		}
+		if f.Capacity() != 1000 {
+			t.Error("Rebuild changed the original filter")
+		}
+	}
+}
+
+func TestRebuildOneHashing(t *testing.T) {
+	f := NewOneHashing(100, 0.01)
+	r := f.Rebuild(1000, func(emit func([]byte)) {
+		emit(foo)
+	})
+	if r.parts == nil || r.m <= f.m || !r.Test(foo) {
+		t.Error("rebuilt one-hashing filter is invalid")
+	}
+}
+
+func TestRebuild64(t *testing.T) {
+	f := New64(100, 0.01)
+	r := f.Rebuild(1000, func(emit func([]byte)) {
+		emit(foo)
+		emit(bar)
+	})
+	if r.Capacity() != 1000 || !r.Test(foo) || !r.Test(bar) {
+		t.Error("rebuilt 64-bit filter is invalid")
+	}
+}
+
+func TestRebuildInvalid(t *testing.T) {
+	for _, rebuild := range []func(){
+		func() { New(100, 0.01).Rebuild(0, nil) },
+		func() { New64(100, 0.01).Rebuild(-1, nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("rebuilding for no items should have caused a panic")
+				}
+			}()
+			rebuild()
+		}()
+	}
+}
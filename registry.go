@@ -0,0 +1,67 @@
+package bloom
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Creates a hash function from the parameters it was registered with, e.g. a
+// key or seed. It should return an error if the parameters are invalid.
+type HashConstructor func(params []byte) (HashFunc, error)
+
+var (
+	hashesMu sync.RWMutex
+	hashes   = map[string]HashConstructor{}
+)
+
+// The longest accepted name of a registered hash function
+const maxHashName = 64
+
+// Registers a hash function under name, so that filters created with
+// WithRegisteredHash can be decoded without knowing in advance which hash
+// function produced them. It is meant to be called from an init function,
+// and panics if name is empty or too long, or is already registered.
+func RegisterHash(name string, c HashConstructor) {
+	if name == "" || len(name) > maxHashName {
+		panic(fmt.Sprintf("Invalid hash function name %q", name))
+	}
+	if c == nil {
+		panic(fmt.Sprintf("The constructor of hash function %q is nil", name))
+	}
+	hashesMu.Lock()
+	defer hashesMu.Unlock()
+	if _, ok := hashes[name]; ok {
+		panic(fmt.Sprintf("Hash function %q is registered twice", name))
+	}
+	hashes[name] = c
+}
+
+// Returns the hash function registered as name, created with params.
+func lookupHash(name string, params []byte) (HashFunc, error) {
+	hashesMu.RLock()
+	c, ok := hashes[name]
+	hashesMu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownHash
+	}
+	return c(params)
+}
+
+// Makes the filter use the hash function registered as name, created with
+// params, instead of the default FNV-1 based hashing. Unlike with WithHash,
+// the name and params are included when the filter is encoded, so it can
+// be decoded by any process which has registered the same hash function.
+// Note that this includes any key given in params. Panics if name isn't
+// registered or params are invalid.
+func WithRegisteredHash(name string, params []byte) Option {
+	h, err := lookupHash(name, params)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to create hash function %q: %v", name, err))
+	}
+	params = append([]byte(nil), params...)
+	return func(o *options) {
+		o.hash = h
+		o.hashName = name
+		o.hashParams = params
+	}
+}
@@ -0,0 +1,85 @@
+package bloom
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestRegisteredHash(t *testing.T) {
+	calls := 0
+	RegisterHash("test-counting", func(params []byte) (HashFunc, error) {
+		if len(params) != 0 {
+			return nil, errors.New("unexpected params")
+		}
+		return countingHash(&calls), nil
+	})
+	f := New(1000, 0.01, WithRegisteredHash("test-counting", nil))
+	for i := 0; i < 100; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &Filter{}
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	calls = 0
+	for i := 0; i < 100; i++ {
+		if !g.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%d not in decoded filter", i)
+		}
+	}
+	if calls != 100 {
+		t.Errorf("registered hash called %d times, expected 100", calls)
+	}
+
+	f64 := New64(1000, 0.01, WithRegisteredHash("test-counting", nil))
+	f64.Add(foo)
+	data64, err := f64.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g64 := &Filter64{}
+	if err := g64.UnmarshalBinary(data64); err != nil {
+		t.Fatal(err)
+	}
+	if g64.hname != "test-counting" || !g64.Test(foo) {
+		t.Error("decoded 64-bit filter does not use the registered hash")
+	}
+
+	hashesMu.Lock()
+	delete(hashes, "test-counting")
+	hashesMu.Unlock()
+	if err := g.UnmarshalBinary(data); err != ErrUnknownHash {
+		t.Errorf("decoding with an unregistered hash returned %v", err)
+	}
+}
+
+func TestRegisteredSipHash(t *testing.T) {
+	key := [16]byte{1, 2, 3}
+	f := New(1000, 0.01, WithRegisteredHash(SipHashName, key[:]))
+	g := New(1000, 0.01, WithSipHashKey(key))
+	fx, _ := f.sum(foo)
+	gx, _ := g.sum(foo)
+	if fx != gx {
+		t.Error("registered SipHash differs from WithSipHashKey")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic with an invalid SipHash key")
+		}
+	}()
+	WithRegisteredHash(SipHashName, key[:8])
+}
+
+func TestRegisterHashTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic when registering a hash twice")
+		}
+	}()
+	RegisterHash(SipHashName, func([]byte) (HashFunc, error) { return nil, nil })
+}
@@ -0,0 +1,124 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+
+	"time"
+)
+
+// A time-window bloom filter using the 128-bit FNV-1 hash function, like
+// Filter. The window is split into a number of buckets, and items are added
+// to the bucket for the current time. When a bucket's time has passed, the
+// oldest bucket is reset and takes its place, so items expire automatically
+// once they fall out of the window.
+type RotatingFilter struct {
+	*filter
+	b        []*bitset.Bitset32
+	cur      int
+	interval time.Duration
+	start    time.Time
+	now      func() time.Time
+}
+
+// Returns the number of buckets whose time has passed since the filter was
+// last rotated, up to the number of buckets.
+func (f *RotatingFilter) expired() int {
+	steps := f.now().Sub(f.start) / f.interval
+	return int(min(max(steps, 0), time.Duration(len(f.b))))
+}
+
+// Advances the current bucket if its time has passed, resetting the buckets
+// that fell out of the window. Add and TestAndAdd rotate the filter, so this
+// is only needed to release the items of expired buckets, e.g. from a timer,
+// before more are added. Test ignores expired buckets without rotating, so
+// that it doesn't change the filter.
+func (f *RotatingFilter) Rotate() {
+	steps := f.now().Sub(f.start) / f.interval
+	if steps <= 0 {
+		return
+	}
+	f.start = f.start.Add(steps * f.interval)
+	for i := 0; i < int(min(steps, time.Duration(len(f.b)))); i++ {
+		f.cur = (f.cur + 1) % len(f.b)
+		f.b[f.cur].Reset()
+	}
+}
+
+// Checks whether data was added to the filter within the window. Returns true
+// if yes, with a false positive chance near the ratio specified upon creation
+// of the filter. Since expiry happens a bucket at a time, data is remembered
+// for at least the window minus one bucket's duration, and at most the whole
+// window. Test doesn't change the filter, so, like Filter.Test, it can be
+// called concurrently with itself, but not with Add, TestAndAdd or Rotate.
+func (f *RotatingFilter) Test(data []byte) bool {
+	return f.test(f.bits(data), f.expired())
+}
+
+// Checks whether the bits is are set in a bucket which hasn't expired, i.e.
+// one of the newest len(f.b)-expired, from the current one backwards.
+func (f *RotatingFilter) test(is []uint32, expired int) bool {
+	for i := 0; i < len(f.b)-expired; i++ {
+		if testBits32(f.b[(f.cur-i+len(f.b))%len(f.b)], is) {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds data to the filter.
+func (f *RotatingFilter) Add(data []byte) {
+	f.Rotate()
+	f.add(f.bits(data))
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. Data is hashed only once.
+func (f *RotatingFilter) TestAndAdd(data []byte) bool {
+	f.Rotate()
+	is := f.bits(data)
+	present := f.test(is, 0)
+	f.add(is)
+	return present
+}
+
+func (f *RotatingFilter) add(is []uint32) {
+	b := f.b[f.cur]
+	for _, i := range is {
+		b.Set(i)
+	}
+}
+
+// Resets the filter.
+func (f *RotatingFilter) Reset() {
+	for _, b := range f.b {
+		b.Reset()
+	}
+	f.cur = 0
+	f.start = f.now()
+}
+
+// Create a time-window bloom filter which remembers items for a duration of
+// window, split into the given number of buckets, e.g. 60 buckets for a window
+// of an hour expires items a minute at a time. Every bucket can hold an
+// expected n number of items, and the false positive rate p, e.g. 0.01,
+// applies to the whole window. Memory usage grows with the number of buckets.
+func NewRotating(n int, p float64, window time.Duration, buckets int, opts ...Option) *RotatingFilter {
+	if window <= 0 || buckets < 1 {
+		panic("Unable to create a rotating filter without a positive window and number of buckets.")
+	}
+	// Test consults every bucket, so each gets its share of the budget
+	m, k := estimates(int64(n), p/float64(buckets))
+	b := make([]*bitset.Bitset32, buckets)
+	for i := range b {
+		b[i] = bitset.New32(m)
+	}
+	f := &RotatingFilter{
+		filter:   newFilter(m, k, opts...),
+		b:        b,
+		interval: max(window/time.Duration(buckets), 1),
+		now:      time.Now,
+	}
+	f.start = f.now()
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
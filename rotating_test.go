@@ -0,0 +1,73 @@
+package bloom
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRotatingFilter(t *testing.T) {
+	now := time.Now()
+	f := NewRotating(1000, 0.01, time.Minute, 6)
+	f.now = func() time.Time { return now }
+	f.Reset()
+	f.Add(foo)
+	now = now.Add(30 * time.Second)
+	f.Add(bar)
+	if !f.Test(foo) || !f.Test(bar) {
+		t.Error("foo or bar not in filter")
+	}
+	now = now.Add(35 * time.Second)
+	if f.Test(foo) {
+		t.Error("foo still in filter after the window has passed")
+	}
+	if !f.Test(bar) {
+		t.Error("bar not in filter")
+	}
+	now = now.Add(time.Hour)
+	if f.Test(bar) {
+		t.Error("bar still in filter after an hour")
+	}
+}
+
+func TestRotatingFilterRotate(t *testing.T) {
+	now := time.Now()
+	f := NewRotating(1000, 0.01, time.Minute, 6)
+	f.now = func() time.Time { return now }
+	f.Reset()
+	f.Add(foo)
+	now = now.Add(65 * time.Second)
+	start, cur := f.start, f.cur
+	if f.Test(foo) {
+		t.Error("foo still in filter after the window has passed")
+	}
+	if f.start != start || f.cur != cur || !testBits32(f.b[cur], f.bits(foo)) {
+		t.Error("Test rotated the filter")
+	}
+	f.Rotate()
+	if testBits32(f.b[cur], f.bits(foo)) {
+		t.Error("Rotate didn't reset the expired bucket")
+	}
+	if f.Test(foo) {
+		t.Error("foo in filter after rotating")
+	}
+}
+
+func TestRotatingFilterShortWindow(t *testing.T) {
+	// A window shorter than one nanosecond per bucket
+	f := NewRotating(1000, 0.01, 3, 6)
+	f.Add(foo)
+	f.Test(foo)
+	for _, c := range []struct {
+		window  time.Duration
+		buckets int
+	}{{0, 6}, {-time.Second, 6}, {time.Minute, 0}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic with window %v and %d buckets", c.window, c.buckets)
+				}
+			}()
+			NewRotating(1000, 0.01, c.window, c.buckets)
+		}()
+	}
+}
@@ -0,0 +1,63 @@
+package bloom
+
+import (
+	"encoding"
+)
+
+// Implemented by every filter which can add an item and report whether it was
+// already present in one step, e.g. to drop duplicates from a stream.
+type TestAndAdder interface {
+	// Adds data to the filter, and returns whether it was (probably) already
+	// present before it was added.
+	TestAndAdd(data []byte) bool
+}
+
+// A probabilistic set of items, implemented by most of the filters, so that
+// applications can choose between them, e.g. a Filter or a CountingFilter,
+// behind one type. LayeredFilter and DLeftFilter don't implement it, since
+// their Add and Test report more than membership.
+type Set interface {
+	// Adds data to the set.
+	Add(data []byte)
+
+	// Checks whether data was (probably) previously added to the set.
+	Test(data []byte) bool
+
+	TestAndAdder
+
+	// Removes every item from the set.
+	Reset()
+}
+
+// A Set which can be encoded and decoded, e.g. to persist it, implemented by
+// Filter, Filter64, the counting filters, RotatingFilter, and the filters
+// compatible with other libraries: GuavaFilter and the pybloom filters.
+type PersistentSet interface {
+	Set
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+var (
+	_ PersistentSet = (*Filter)(nil)
+	_ PersistentSet = (*Filter64)(nil)
+	_ PersistentSet = (*CountingFilter)(nil)
+	_ PersistentSet = (*CountingFilter64)(nil)
+	_ PersistentSet = (*RotatingFilter)(nil)
+	_ PersistentSet = (*GuavaFilter)(nil)
+	_ PersistentSet = (*PyBloomFilter)(nil)
+	_ PersistentSet = (*PyScalableBloomFilter)(nil)
+
+	_ Set = (*AgingFilter)(nil)
+	_ Set = (*DeletableFilter)(nil)
+	_ Set = (*QuotientFilter)(nil)
+	_ Set = (*WeightedFilter)(nil)
+	_ Set = (*InverseFilter)(nil)
+	_ Set = (*InstrumentedSet)(nil)
+
+	_ TestAndAdder = (*LayeredFilter)(nil)
+	_ TestAndAdder = (*LayeredFilter64)(nil)
+	_ TestAndAdder = (*ConcurrentLayeredFilter)(nil)
+	_ TestAndAdder = (*InstrumentedLayered)(nil)
+	_ TestAndAdder = (*DLeftFilter)(nil)
+)
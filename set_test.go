@@ -0,0 +1,38 @@
+package bloom
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSet(t *testing.T) {
+	for _, s := range []Set{
+		New(1000, 0.01),
+		NewCounting(1000, 0.01),
+		NewRotating(1000, 0.01, time.Hour, 4),
+		NewInverse(1000),
+	} {
+		s.Add(foo)
+		if !s.Test(foo) || s.Test(bar) {
+			t.Errorf("%T: unexpected results", s)
+		}
+		s.Reset()
+		if s.Test(foo) {
+			t.Errorf("%T: foo in set after reset", s)
+		}
+	}
+
+	var p PersistentSet = New64(1000, 0.01)
+	p.Add(foo)
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var q PersistentSet = &Filter64{}
+	if err := q.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !q.Test(foo) {
+		t.Error("foo not in decoded set")
+	}
+}
@@ -0,0 +1,114 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"unsafe"
+)
+
+// The header of a SharedFilter's file: a magic number, the version of the
+// layout, m and k. The bits follow it, in 64-bit words.
+const (
+	sharedMagic      = 0x626c6d73
+	sharedVersion    = 1
+	sharedHeaderSize = 16
+)
+
+// A bloom filter whose bits are kept in shared memory, e.g. a file in
+// /dev/shm, which is where POSIX shared memory objects live on Linux, so that
+// several processes on one machine, e.g. the workers of a pre-fork server,
+// can add to and test one filter. Bits are set and tested with atomic
+// operations, so the filter is safe for concurrent use by multiple
+// goroutines and processes without a lock, with the caveats of
+// ConcurrentCountingFilter: an item being added may be reported as present
+// before all of its bits are set.
+//
+// Every process must open the filter with the same options, e.g. the same
+// hash function and seed, which are not stored in the file.
+type SharedFilter struct {
+	*filter
+	shm   *sharedMemory
+	words []uint64
+}
+
+func (f *SharedFilter) test(is []uint32) bool {
+	for _, i := range is {
+		if atomic.LoadUint64(&f.words[i>>6])&(1<<(i&63)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks whether data was (probably) added to the filter, by this or another
+// process.
+func (f *SharedFilter) Test(data []byte) bool {
+	return f.test(f.bits(data))
+}
+
+// Adds data to the filter.
+func (f *SharedFilter) Add(data []byte) {
+	for _, i := range f.bits(data) {
+		atomic.OrUint64(&f.words[i>>6], 1<<(i&63))
+	}
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present before it was added. Of several processes adding the same item at
+// once, at least one gets false.
+func (f *SharedFilter) TestAndAdd(data []byte) bool {
+	present := true
+	for _, i := range f.bits(data) {
+		bit := uint64(1) << (i & 63)
+		if atomic.OrUint64(&f.words[i>>6], bit)&bit == 0 {
+			present = false
+		}
+	}
+	return present
+}
+
+// Resets the filter for every process using it. Items added concurrently may
+// be partly cleared.
+func (f *SharedFilter) Reset() {
+	for i := range f.words {
+		atomic.StoreUint64(&f.words[i], 0)
+	}
+}
+
+// Unmaps the filter's memory and closes its file. The file, and the filter's
+// contents, remain for the other processes; remove it once none use it.
+func (f *SharedFilter) Close() error {
+	f.words = nil
+	return f.shm.close()
+}
+
+// Returns the size in bytes of the memory of a shared filter of m bits.
+func sharedSize(m uint32) int {
+	return sharedHeaderSize + 8*int((uint64(m)+63)/64)
+}
+
+// Writes the header of a shared filter of m bits and k hash functions to mem.
+func putSharedHeader(mem []byte, m, k uint32) {
+	binary.LittleEndian.PutUint32(mem, sharedMagic)
+	binary.LittleEndian.PutUint32(mem[4:], sharedVersion)
+	binary.LittleEndian.PutUint32(mem[8:], m)
+	binary.LittleEndian.PutUint32(mem[12:], k)
+}
+
+// Checks that mem holds a shared filter of m bits and k hash functions.
+func checkSharedHeader(mem []byte, m, k uint32) error {
+	if len(mem) < sharedHeaderSize ||
+		binary.LittleEndian.Uint32(mem) != sharedMagic ||
+		binary.LittleEndian.Uint32(mem[4:]) != sharedVersion {
+		return ErrInvalidEncoding
+	}
+	if binary.LittleEndian.Uint32(mem[8:]) != m || binary.LittleEndian.Uint32(mem[12:]) != k || len(mem) != sharedSize(m) {
+		return ErrIncompatible
+	}
+	return nil
+}
+
+// Returns the words of the bits of the shared filter in mem.
+func sharedWords(mem []byte) []uint64 {
+	return unsafe.Slice((*uint64)(unsafe.Pointer(&mem[sharedHeaderSize])), (len(mem)-sharedHeaderSize)/8)
+}
@@ -0,0 +1,20 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package bloom
+
+import (
+	"errors"
+	"fmt"
+)
+
+type sharedMemory struct{}
+
+func (s *sharedMemory) close() error {
+	return nil
+}
+
+// Open the shared filter in the file at path. Shared filters need mmap and
+// flock, so on this platform the error returned wraps errors.ErrUnsupported.
+func OpenShared(path string, n int, p float64, opts ...Option) (*SharedFilter, error) {
+	return nil, fmt.Errorf("bloom: opening shared filter %s: %w", path, errors.ErrUnsupported)
+}
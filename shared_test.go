@@ -0,0 +1,74 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package bloom
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSharedFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared")
+	f, err := OpenShared(path, 1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// A second mapping of the file sees the same memory, as another
+	// process's would
+	g, err := OpenShared(path, 1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w; i < 1000; i += 4 {
+				f.Add([]byte(strconv.Itoa(i)))
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 1000; i++ {
+		if !g.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%d not found in the other mapping", i)
+		}
+	}
+	if g.TestAndAdd([]byte("new")) || !f.Test([]byte("new")) {
+		t.Error("unexpected TestAndAdd")
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := OpenShared(path, 1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Test([]byte("new")) {
+		t.Error("contents lost when reopened")
+	}
+	h.Reset()
+	if f.Test([]byte("new")) {
+		t.Error("item found after Reset")
+	}
+	h.Close()
+
+	if _, err := OpenShared(path, 2000, 0.01); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("opening with other parameters returned %v", err)
+	}
+	other := filepath.Join(t.TempDir(), "other")
+	os.WriteFile(other, []byte("not a filter at all"), 0o600)
+	if _, err := OpenShared(other, 1000, 0.01); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("opening another file returned %v", err)
+	}
+	if _, err := OpenShared(path, 0, 0.01); !errors.Is(err, ErrInvalidParameters) {
+		t.Errorf("opening with invalid parameters returned %v", err)
+	}
+}
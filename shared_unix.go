@@ -0,0 +1,86 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package bloom
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// A file mapped into memory.
+type sharedMemory struct {
+	file *os.File
+	mem  []byte
+}
+
+func (s *sharedMemory) close() error {
+	err := syscall.Munmap(s.mem)
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Open the shared filter in the file at path, e.g. /dev/shm/myfilter,
+// creating it for an expected n number of items and an acceptable false
+// positive rate of p, e.g. 0.01, if the file doesn't exist or is empty. The
+// processes sharing the filter must open it with the same n, p and options.
+// Returns an error wrapping ErrIncompatible if the file holds a filter
+// created with other parameters, and ErrInvalidEncoding if it holds
+// something else.
+func OpenShared(path string, n int, p float64, opts ...Option) (*SharedFilter, error) {
+	m, k, err := checkedEstimates(int64(n), p)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	shm, err := mapShared(file, m, k)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("bloom: opening shared filter %s: %w", path, err)
+	}
+	f := &SharedFilter{newFilter(m, k, opts...), shm, sharedWords(shm.mem)}
+	f.capacity, f.fpRate = uint64(n), p
+	return f, nil
+}
+
+// Maps file into memory, initializing it for a filter of m bits and k hash
+// functions if it is empty. The file is locked meanwhile, so that only one
+// of several processes opening it at once initializes it.
+func mapShared(file *os.File, m, k uint32) (*sharedMemory, error) {
+	fd := int(file.Fd())
+	if err := syscall.Flock(fd, syscall.LOCK_EX); err != nil {
+		return nil, err
+	}
+	defer syscall.Flock(fd, syscall.LOCK_UN)
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size, created := fi.Size(), fi.Size() == 0
+	if created {
+		size = int64(sharedSize(m))
+		if err := file.Truncate(size); err != nil {
+			return nil, err
+		}
+	}
+	if size < sharedHeaderSize || size != int64(int(size)) {
+		return nil, ErrInvalidEncoding
+	}
+	mem, err := syscall.Mmap(fd, 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		putSharedHeader(mem, m, k)
+	}
+	if err := checkSharedHeader(mem, m, k); err != nil {
+		syscall.Munmap(mem)
+		return nil, err
+	}
+	return &sharedMemory{file, mem}, nil
+}
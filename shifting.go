@@ -0,0 +1,95 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+
+	"fmt"
+)
+
+// A shifting bloom filter using the 128-bit FNV-1 hash function, like Filter,
+// which records which of several sets an item belongs to. An item's bits are
+// shifted by the number of its set, so one hash computation (and usually the
+// same cache lines) answers membership for every set.
+type ShiftingFilter struct {
+	*filter
+	b    *bitset.Bitset32
+	sets int
+}
+
+func (f *ShiftingFilter) testShifted(is []uint32, set int) bool {
+	for _, i := range is {
+		if !f.b.Test(i + uint32(set)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks whether data was previously added to the given set, from 0 to the
+// number of sets minus one. Returns true if yes, with a false positive chance
+// near the ratio specified upon creation of the filter. The result cannot be
+// falsely negative.
+func (f *ShiftingFilter) Test(data []byte, set int) bool {
+	if set < 0 || set >= f.sets {
+		return false
+	}
+	return f.testShifted(f.bits(data), set)
+}
+
+// Returns the sets that data was (probably) previously added to, in
+// ascending order.
+func (f *ShiftingFilter) Sets(data []byte) []int {
+	is := f.bits(data)
+	var sets []int
+	for s := 0; s < f.sets; s++ {
+		if f.testShifted(is, s) {
+			sets = append(sets, s)
+		}
+	}
+	return sets
+}
+
+// Adds data to the given set, from 0 to the number of sets minus one. Data
+// can be added to more than one set.
+func (f *ShiftingFilter) Add(data []byte, set int) {
+	f.TestAndAdd(data, set)
+}
+
+// Adds data to the given set, and returns whether it was (probably) already
+// present in that set, as reported by Test, before it was added. Data is
+// hashed only once.
+func (f *ShiftingFilter) TestAndAdd(data []byte, set int) bool {
+	if set < 0 || set >= f.sets {
+		panic(fmt.Sprintf("Set %d is out of range for a shifting filter with %d sets.", set, f.sets))
+	}
+	present := true
+	for _, i := range f.bits(data) {
+		if !f.b.Test(i + uint32(set)) {
+			present = false
+			f.b.Set(i + uint32(set))
+		}
+	}
+	return present
+}
+
+// Resets the filter.
+func (f *ShiftingFilter) Reset() {
+	f.b.Reset()
+}
+
+// Create a shifting bloom filter for the given number of sets, with an
+// expected n number of additions across all of them, and an acceptable false
+// positive rate of p, e.g. 0.01, for each set.
+func NewShifting(n int, p float64, sets int, opts ...Option) *ShiftingFilter {
+	if sets < 1 {
+		sets = 1
+	}
+	m, k := estimates(int64(n), p)
+	f := &ShiftingFilter{
+		newFilter(m, k, opts...),
+		bitset.New32(m + uint32(sets) - 1),
+		sets,
+	}
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
@@ -0,0 +1,44 @@
+package bloom
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestShiftingFilter(t *testing.T) {
+	f := NewShifting(3000, 0.01, 4)
+	f.Add(foo, 0)
+	f.Add(foo, 3)
+	f.Add(bar, 2)
+	if got := f.Sets(foo); !reflect.DeepEqual(got, []int{0, 3}) {
+		t.Errorf("foo is in sets %v", got)
+	}
+	if !f.Test(bar, 2) || f.Test(bar, 1) {
+		t.Error("bar not only in set 2")
+	}
+	if got := f.Sets(baz); len(got) != 0 {
+		t.Errorf("baz is in sets %v", got)
+	}
+}
+
+func TestShiftingFilterRate(t *testing.T) {
+	n := 10000
+	f := NewShifting(n, 0.01, 4)
+	for i := 0; i < n; i++ {
+		f.Add([]byte(strconv.Itoa(i)), i%4)
+	}
+	fp := 0
+	for i := 0; i < n; i++ {
+		v := []byte(strconv.Itoa(i))
+		if !f.Test(v, i%4) {
+			t.Fatalf("%d not in set %d", i, i%4)
+		}
+		if f.Test(v, (i+1)%4) {
+			fp++
+		}
+	}
+	if p := float64(fp) / float64(n); p > 0.02 {
+		t.Errorf("False positive rate too high: %f", p)
+	}
+}
@@ -0,0 +1,88 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// Returns the SipHash-c-d sum of data with the key k0, k1.
+func sipHash(k0, k1 uint64, data []byte, c, d int) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+	n := len(data)
+	for len(data) >= 8 {
+		m := binary.LittleEndian.Uint64(data)
+		v3 ^= m
+		for i := 0; i < c; i++ {
+			round()
+		}
+		v0 ^= m
+		data = data[8:]
+	}
+	m := uint64(n) << 56
+	for i, b := range data {
+		m |= uint64(b) << (8 * uint(i))
+	}
+	v3 ^= m
+	for i := 0; i < c; i++ {
+		round()
+	}
+	v0 ^= m
+	v2 ^= 0xff
+	for i := 0; i < d; i++ {
+		round()
+	}
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+// Returns a HashFunc computing SipHash-1-3 keyed with key.
+func sipHashFunc(key []byte) HashFunc {
+	k0 := binary.LittleEndian.Uint64(key[:8])
+	k1 := binary.LittleEndian.Uint64(key[8:])
+	return func(data []byte) (uint64, uint64) {
+		x := sipHash(k0, k1, data, 1, 3)
+		return x, bits.RotateLeft64(x, 32)
+	}
+}
+
+// The registered name of SipHash-1-3, whose parameters are its 16-byte key
+const SipHashName = "siphash-1-3"
+
+func init() {
+	RegisterHash(SipHashName, func(params []byte) (HashFunc, error) {
+		if len(params) != 16 {
+			return nil, fmt.Errorf("bloom: SipHash key is %d bytes, expected 16", len(params))
+		}
+		return sipHashFunc(params), nil
+	})
+}
+
+// Makes the filter use SipHash-1-3 keyed with key instead of the default
+// FNV-1 based hashing. Without the key, the bits set by an item can't be
+// predicted, so an attacker who can choose the items added to or tested
+// against a filter can't manufacture false positives. The key should be
+// random and kept secret, e.g. read from crypto/rand. The key is not
+// included when the filter is encoded; to include it, use
+// WithRegisteredHash(SipHashName, key[:]) instead.
+func WithSipHashKey(key [16]byte) Option {
+	return WithHash(sipHashFunc(key[:]))
+}
@@ -0,0 +1,47 @@
+package bloom
+
+import (
+	"testing"
+)
+
+var sipHashVectors = []struct {
+	n     int
+	sum13 uint64 // key 0
+	sum24 uint64 // key 00 01 .. 0f
+}{
+	{0, 0xd1fba762150c532c, 0x726fdb47dd0e0e31},
+	{1, 0x68a914128e01e473, 0x74f839c593dc67fd},
+	{3, 0x4d4c9a4a8ef6e0ad, 0x85676696d7fb7e2d},
+	{8, 0xead411e67ebe2eea, 0x93f5f5799a932462},
+	{9, 0x75927f9d95124362, 0x9e0082df0ba9e4b0},
+	{17, 0x4883c49a2c009c1d, 0x699ae9f52cbe4794},
+	{100, 0x1c6a66e1506e7908, 0x096f3fec85c52a7e},
+}
+
+func TestSipHash(t *testing.T) {
+	for _, v := range sipHashVectors {
+		data := make([]byte, v.n)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		if got := sipHash(0, 0, data, 1, 3); got != v.sum13 {
+			t.Errorf("SipHash-1-3 of %d bytes: %#x, expected %#x", v.n, got, v.sum13)
+		}
+		if got := sipHash(0x0706050403020100, 0x0f0e0d0c0b0a0908, data, 2, 4); got != v.sum24 {
+			t.Errorf("SipHash-2-4 of %d bytes: %#x, expected %#x", v.n, got, v.sum24)
+		}
+	}
+}
+
+func TestWithSipHashKey(t *testing.T) {
+	a := New(1000, 0.01, WithSipHashKey([16]byte{1}))
+	b := New(1000, 0.01, WithSipHashKey([16]byte{2}))
+	a.Add(foo)
+	b.Add(foo)
+	if !a.Test(foo) || !b.Test(foo) {
+		t.Error("foo not in bloom filter")
+	}
+	if a.bits(foo)[0] == b.bits(foo)[0] && a.bits(foo)[1] == b.bits(foo)[1] {
+		t.Error("filters with different keys use the same bits")
+	}
+}
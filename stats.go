@@ -0,0 +1,71 @@
+package bloom
+
+import (
+	"expvar"
+	"math"
+)
+
+// The parameters and health of a filter, e.g. to expose on a debug endpoint.
+type Stats struct {
+	M           uint64  `json:"m"`            // the number of bits
+	K           uint64  `json:"k"`            // the number of hash functions
+	BitsSet     uint64  `json:"bits_set"`     // the number of bits set
+	ApproxItems uint64  `json:"approx_items"` // the estimated number of distinct items added
+	EstFPRate   float64 `json:"est_fp_rate"`  // the estimated current false positive rate
+	Capacity    uint64  `json:"capacity"`     // the expected number of items, or 0 if unknown
+	FPRate      float64 `json:"fp_rate"`      // the acceptable false positive rate, or 0 if unknown
+	Memory      uint64  `json:"memory_bytes"` // the (approximate) number of bytes used by the bits
+}
+
+// Fills in the estimates of s from its M, K and BitsSet.
+func (s *Stats) estimate() {
+	m, k, set := float64(s.M), float64(s.K), float64(s.BitsSet)
+	if e := estimateCount(m, k, set); e >= math.MaxUint64 {
+		s.ApproxItems = math.MaxUint64
+	} else {
+		s.ApproxItems = uint64(math.Round(e))
+	}
+	s.EstFPRate = math.Pow(set/m, k)
+}
+
+// Returns the parameters of the filter, and estimates of the number of items
+// in it and of its current false positive rate, like ApproximatedSize and
+// EstimatedFPRate, from one pass over its bits.
+func (f *Filter) Stats() Stats {
+	s := Stats{
+		M:        uint64(f.m),
+		K:        uint64(f.k),
+		BitsSet:  uint64(f.setBits()),
+		Capacity: f.capacity,
+		FPRate:   f.fpRate,
+		Memory:   (uint64(f.m) + 31) / 32 * 4,
+	}
+	s.estimate()
+	return s
+}
+
+// Returns the parameters of the filter and estimates of its health, like
+// Filter.Stats.
+func (f *Filter64) Stats() Stats {
+	s := Stats{
+		M:        f.m,
+		K:        f.k,
+		BitsSet:  f.setBits(),
+		Capacity: f.capacity,
+		FPRate:   f.fpRate,
+		Memory:   (f.m + 63) / 64 * 8,
+	}
+	s.estimate()
+	return s
+}
+
+// Publishes the result of stats as the expvar variable name, e.g. f.Stats,
+// so that it is served as JSON by the /debug/vars endpoint. stats is called
+// for every request to the endpoint, from its goroutine, so if the filter is
+// changed concurrently, stats should take the lock guarding it. Panics if
+// name is already published, like expvar.Publish.
+func PublishStats(name string, stats func() Stats) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return stats()
+	}))
+}
@@ -0,0 +1,36 @@
+package bloom
+
+import (
+	"encoding/json"
+	"expvar"
+	"strconv"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	f := New(1000, 0.01)
+	f64 := New64(1000, 0.01)
+	for i := 0; i < 500; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+		f64.Add([]byte(strconv.Itoa(i)))
+	}
+	s := f.Stats()
+	if s.M != uint64(f.M()) || s.K != uint64(f.K()) || s.Capacity != 1000 || s.Memory < s.M/8 {
+		t.Errorf("stats %+v", s)
+	}
+	if s.ApproxItems != uint64(f.ApproximatedSize()) || s.EstFPRate != f.EstimatedFPRate() {
+		t.Errorf("estimated %d items at %f, expected %d at %f", s.ApproxItems, s.EstFPRate, f.ApproximatedSize(), f.EstimatedFPRate())
+	}
+	if s64 := f64.Stats(); s64.ApproxItems != f64.ApproximatedSize() || s64.BitsSet == 0 {
+		t.Errorf("64-bit stats %+v", s64)
+	}
+
+	PublishStats("bloom_test_filter", f.Stats)
+	var got Stats
+	if err := json.Unmarshal([]byte(expvar.Get("bloom_test_filter").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != s {
+		t.Errorf("published %+v, expected %+v", got, s)
+	}
+}
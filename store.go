@@ -0,0 +1,192 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+
+	"fmt"
+)
+
+// Holds the bits of a StoreFilter, e.g. in a Redis bitmap, a page of an
+// embedded database, a memory-mapped file or shared memory, so that such a
+// filter can be used without reimplementing the filter's hashing and
+// indexing. Errors, e.g. from the network, are returned by the filter's
+// methods.
+type BitStore interface {
+	// Returns the number of bits in the store.
+	Len() uint64
+
+	// Checks whether bit i is set.
+	Test(i uint64) (bool, error)
+
+	// Sets bit i.
+	Set(i uint64) error
+
+	// Clears bit i.
+	Clear(i uint64) error
+}
+
+// A BitStore which can test and set several bits in one operation, e.g. in
+// one round trip to a server. StoreFilter uses these methods when a store
+// has them.
+type BatchBitStore interface {
+	BitStore
+
+	// Checks whether every bit in is is set.
+	TestAll(is []uint64) (bool, error)
+
+	// Sets every bit in is.
+	SetAll(is []uint64) error
+}
+
+// A BitStore which can clear all of its bits at once. StoreFilter.Reset uses
+// Reset when a store has it, rather than clearing each bit.
+type ResettableBitStore interface {
+	BitStore
+
+	// Clears every bit.
+	Reset() error
+}
+
+// A BitStore keeping its bits in memory, like the other filters do, e.g. to
+// test an application using a StoreFilter without its real store.
+type MemoryStore struct {
+	b *bitset.Bitset64
+}
+
+// Create a store of m bits kept in memory.
+func NewMemoryStore(m uint64) *MemoryStore {
+	return &MemoryStore{bitset.New64(m)}
+}
+
+func (s *MemoryStore) Len() uint64 {
+	return s.b.Len()
+}
+
+func (s *MemoryStore) Test(i uint64) (bool, error) {
+	return s.b.Test(i), nil
+}
+
+func (s *MemoryStore) Set(i uint64) error {
+	s.b.Set(i)
+	return nil
+}
+
+func (s *MemoryStore) Clear(i uint64) error {
+	s.b.Clear(i)
+	return nil
+}
+
+func (s *MemoryStore) TestAll(is []uint64) (bool, error) {
+	return testBits64(s.b, is), nil
+}
+
+func (s *MemoryStore) SetAll(is []uint64) error {
+	for _, i := range is {
+		s.b.Set(i)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Reset() error {
+	s.b.Reset()
+	return nil
+}
+
+// A bloom filter whose bits are kept in a BitStore. It hashes and indexes
+// items like a Filter64, so it accepts the same options. Hashing keeps no
+// state, so the filter is safe for concurrent use, e.g. by the handlers of a
+// server sharing a Redis bitmap, if its store, and any custom hash function
+// or indexer, are.
+type StoreFilter struct {
+	*filter64
+	s BitStore
+}
+
+// Checks whether data was previously added to the filter, with a false
+// positive chance near the ratio specified upon creation of the filter. The
+// result cannot be falsely negative, unless the store returns an error.
+func (f *StoreFilter) Test(data []byte) (bool, error) {
+	return f.test(f.bits(data))
+}
+
+func (f *StoreFilter) test(is []uint64) (bool, error) {
+	if bs, ok := f.s.(BatchBitStore); ok {
+		return bs.TestAll(is)
+	}
+	for _, i := range is {
+		if set, err := f.s.Test(i); err != nil || !set {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Adds data to the filter. If the store returns an error, some of the bits of
+// data may have been set.
+func (f *StoreFilter) Add(data []byte) error {
+	return f.add(f.bits(data))
+}
+
+func (f *StoreFilter) add(is []uint64) error {
+	if bs, ok := f.s.(BatchBitStore); ok {
+		return bs.SetAll(is)
+	}
+	for _, i := range is {
+		if err := f.s.Set(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Adds data to the filter, and returns whether it was (probably) already
+// present, as reported by Test, before it was added. Data is hashed only
+// once, but the test and the change are separate operations on the store.
+func (f *StoreFilter) TestAndAdd(data []byte) (bool, error) {
+	is := f.bits(data)
+	present, err := f.test(is)
+	if err != nil || present {
+		return present, err
+	}
+	return false, f.add(is)
+}
+
+// Clears every bit in the store.
+func (f *StoreFilter) Reset() error {
+	if rs, ok := f.s.(ResettableBitStore); ok {
+		return rs.Reset()
+	}
+	for i := uint64(0); i < f.m; i++ {
+		if err := f.s.Clear(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the store holding the filter's bits.
+func (f *StoreFilter) Store() BitStore {
+	return f.s
+}
+
+// Create a bloom filter with an expected n number of items, and an acceptable
+// false positive rate of p, whose bits are kept in the store returned by open
+// for the number of bits the filter needs. The store may be larger; only its
+// first bits are used. Returns an error wrapping ErrInvalidParameters if n or
+// p are invalid, or the store is too small, and any error returned by open.
+func NewWithStore(n int64, p float64, open func(m uint64) (BitStore, error), opts ...Option) (*StoreFilter, error) {
+	m, k, err := checkedEstimates64(n, p)
+	if err != nil {
+		return nil, err
+	}
+	s, err := open(m)
+	if err != nil {
+		return nil, err
+	}
+	if s.Len() < m {
+		return nil, fmt.Errorf("%w: a store of %d bits is too small for a filter of %d bits", ErrInvalidParameters, s.Len(), m)
+	}
+	f := &StoreFilter{newFilter64(m, k, opts...), s}
+	f.capacity, f.fpRate = uint64(n), p
+	return f, nil
+}
@@ -0,0 +1,151 @@
+package bloom
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// A BitStore without batch operations, failing once fail bits have been set.
+type slowStore struct {
+	b    []bool
+	fail int
+}
+
+var errStore = errors.New("store failed")
+
+func (s *slowStore) Len() uint64 {
+	return uint64(len(s.b))
+}
+
+func (s *slowStore) Test(i uint64) (bool, error) {
+	return s.b[i], nil
+}
+
+func (s *slowStore) Set(i uint64) error {
+	if s.fail == 0 {
+		return errStore
+	}
+	s.fail--
+	s.b[i] = true
+	return nil
+}
+
+func (s *slowStore) Clear(i uint64) error {
+	s.b[i] = false
+	return nil
+}
+
+func TestStoreFilter(t *testing.T) {
+	for _, open := range []func(m uint64) (BitStore, error){
+		func(m uint64) (BitStore, error) { return NewMemoryStore(m), nil },
+		func(m uint64) (BitStore, error) { return &slowStore{b: make([]bool, m), fail: 1 << 30}, nil },
+	} {
+		f, err := NewWithStore(1000, 0.01, open)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := New64(1000, 0.01)
+		for i := 0; i < 500; i++ {
+			if err := f.Add([]byte(strconv.Itoa(i))); err != nil {
+				t.Fatal(err)
+			}
+			g.Add([]byte(strconv.Itoa(i)))
+		}
+		for i := 0; i < 1000; i++ {
+			v := []byte(strconv.Itoa(i))
+			if present, err := f.Test(v); err != nil || present != g.Test(v) {
+				t.Fatalf("test of %s returned %v, %v", v, present, err)
+			}
+		}
+		if present, err := f.TestAndAdd(foo); err != nil || present {
+			t.Errorf("foo present before being added: %v", err)
+		}
+		if present, _ := f.TestAndAdd(foo); !present {
+			t.Error("foo not present after being added")
+		}
+		if err := f.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		if present, _ := f.Test(foo); present {
+			t.Error("foo present after Reset")
+		}
+	}
+}
+
+func TestStoreFilterErrors(t *testing.T) {
+	f, err := NewWithStore(1000, 0.01, func(m uint64) (BitStore, error) {
+		return &slowStore{b: make([]bool, m), fail: 1}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Add(foo); err != errStore {
+		t.Errorf("add returned %v", err)
+	}
+	_, err = NewWithStore(1000, 0.01, func(m uint64) (BitStore, error) {
+		return NewMemoryStore(m / 2), nil
+	})
+	if !errors.Is(err, ErrInvalidParameters) {
+		t.Errorf("creating a filter with a small store returned %v", err)
+	}
+	if _, err := NewWithStore(1000, 0.01, func(uint64) (BitStore, error) { return nil, errStore }); err != errStore {
+		t.Errorf("creating a filter whose store fails to open returned %v", err)
+	}
+}
+
+// A BitStore guarded by a mutex, as a store shared by several goroutines
+// would be.
+type lockedStore struct {
+	mu sync.Mutex
+	s  *MemoryStore
+}
+
+func (s *lockedStore) Len() uint64 {
+	return s.s.Len()
+}
+
+func (s *lockedStore) Test(i uint64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Test(i)
+}
+
+func (s *lockedStore) Set(i uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Set(i)
+}
+
+func (s *lockedStore) Clear(i uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Clear(i)
+}
+
+func TestStoreFilterParallel(t *testing.T) {
+	f, err := NewWithStore(1000, 0.01, func(m uint64) (BitStore, error) {
+		return &lockedStore{s: NewMemoryStore(m)}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := []byte(strconv.Itoa(i))
+				if err := f.Add(key); err != nil {
+					t.Error(err)
+				}
+				if ok, err := f.Test(key); !ok || err != nil {
+					t.Errorf("%s not in the filter: %v", key, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,262 @@
+package bloom
+
+import (
+	"unsafe"
+)
+
+// Returns the bytes of s without copying them. The result must not be
+// modified, which holds for every filter: items are only hashed, or copied
+// when they are kept.
+func stringBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *Filter) AddString(s string) {
+	f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *Filter) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *CountingFilter) AddString(s string) {
+	f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *CountingFilter) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Remove, but for a string, which isn't copied.
+func (f *CountingFilter) RemoveString(s string) {
+	f.Remove(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *LayeredFilter) AddString(s string) int {
+	return f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *LayeredFilter) TestString(s string) (int, bool) {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *Filter64) AddString(s string) {
+	f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *Filter64) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *CountingFilter64) AddString(s string) {
+	f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *CountingFilter64) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Remove, but for a string, which isn't copied.
+func (f *CountingFilter64) RemoveString(s string) {
+	f.Remove(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *LayeredFilter64) AddString(s string) int {
+	return f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *LayeredFilter64) TestString(s string) (int, bool) {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *AgingFilter) AddString(s string) {
+	f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *AgingFilter) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *RotatingFilter) AddString(s string) {
+	f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *RotatingFilter) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *DeletableFilter) AddString(s string) {
+	f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *DeletableFilter) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Remove, but for a string, which isn't copied.
+func (f *DeletableFilter) RemoveString(s string) bool {
+	return f.Remove(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *DLeftFilter) AddString(s string) bool {
+	return f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *DLeftFilter) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Remove, but for a string, which isn't copied.
+func (f *DLeftFilter) RemoveString(s string) {
+	f.Remove(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *InverseFilter) AddString(s string) {
+	f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *InverseFilter) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *InverseFilter) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *QuotientFilter) AddString(s string) {
+	f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *QuotientFilter) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Remove, but for a string, which isn't copied.
+func (f *QuotientFilter) RemoveString(s string) {
+	f.Remove(stringBytes(s))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *WeightedFilter) AddString(s string) {
+	f.Add(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *WeightedFilter) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *BloomierFilter) TestString(s string) bool {
+	return f.Test(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (c *Cascade) TestString(s string) bool {
+	return c.Test(stringBytes(s))
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (s *GolombSet) TestString(str string) bool {
+	return s.Test(stringBytes(str))
+}
+
+// Equivalent to Add, but for a string, which isn't copied.
+func (f *ShiftingFilter) AddString(s string, set int) {
+	f.Add(stringBytes(s), set)
+}
+
+// Equivalent to Test, but for a string, which isn't copied.
+func (f *ShiftingFilter) TestString(s string, set int) bool {
+	return f.Test(stringBytes(s), set)
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *Filter) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *CountingFilter) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *LayeredFilter) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *Filter64) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *CountingFilter64) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *LayeredFilter64) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *AgingFilter) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *RotatingFilter) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *DeletableFilter) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *DLeftFilter) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *QuotientFilter) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *WeightedFilter) TestAndAddString(s string) bool {
+	return f.TestAndAdd(stringBytes(s))
+}
+
+// Equivalent to TestAndAdd, but for a string, which isn't copied.
+func (f *ShiftingFilter) TestAndAddString(s string, set int) bool {
+	return f.TestAndAdd(stringBytes(s), set)
+}
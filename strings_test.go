@@ -0,0 +1,40 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestStrings(t *testing.T) {
+	f := New(1000, 0.01)
+	f.AddString("foo")
+	if !f.Test(foo) || !f.TestString("foo") || f.TestString("bar") {
+		t.Error("unexpected results with strings")
+	}
+	c := NewCounting(1000, 0.01)
+	c.AddString("foo")
+	c.RemoveString("foo")
+	if c.TestString("foo") {
+		t.Error("foo still in counting filter")
+	}
+	l := NewLayered64(1000, 0.01)
+	l.AddString("foo")
+	if n := l.AddString("foo"); n != 2 {
+		t.Errorf("foo added to layer %d, expected 2", n)
+	}
+	i := NewInverse(100)
+	i.AddString("foo")
+	if !i.TestAndAddString("foo") || !i.Test(foo) {
+		t.Error("foo not in inverse filter")
+	}
+}
+
+func TestStringsNoAllocation(t *testing.T) {
+	f := New(1000, 0.01)
+	s := strconv.Itoa(123456789)
+	f.AddString(s)
+	// Only the index slice is allocated
+	if n := testing.AllocsPerRun(100, func() { f.TestString(s) }); n > 1 {
+		t.Errorf("TestString allocated %v times", n)
+	}
+}
@@ -0,0 +1,56 @@
+package bloom
+
+// Checks whether any of items was previously added to the filter, with the
+// same false positive chance for each item as Test. Stops at the first item
+// which is present, e.g. to check whether any of a request's tokens is
+// blocked. Returns false if there are no items.
+func (f *Filter) TestAny(items [][]byte) bool {
+	var is []uint32
+	for _, data := range items {
+		is = f.bitsTo(is, data)
+		if testBits32(f.b, is) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks whether every one of items was previously added to the filter, with
+// the same false positive chance for each item as Test. Stops at the first
+// item which is absent. Returns true if there are no items.
+func (f *Filter) TestEvery(items [][]byte) bool {
+	var is []uint32
+	for _, data := range items {
+		is = f.bitsTo(is, data)
+		if !testBits32(f.b, is) {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks whether any of items was previously added to the filter, stopping at
+// the first item which is present, like Filter.TestAny.
+func (f *Filter64) TestAny(items [][]byte) bool {
+	var is []uint64
+	for _, data := range items {
+		is = f.bitsTo(is, data)
+		if testBits64(f.b, is) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks whether every one of items was previously added to the filter,
+// stopping at the first item which is absent, like Filter.TestEvery.
+func (f *Filter64) TestEvery(items [][]byte) bool {
+	var is []uint64
+	for _, data := range items {
+		is = f.bitsTo(is, data)
+		if !testBits64(f.b, is) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package bloom
+
+import (
+	"testing"
+)
+
+func TestTestAnyEvery(t *testing.T) {
+	f := New(1000, 0.001)
+	f64 := New64(1000, 0.001)
+	o := NewOneHashing(1000, 0.001)
+	f.Add(foo)
+	f64.Add(foo)
+	o.Add(foo)
+	f.Add(bar)
+	f64.Add(bar)
+	o.Add(bar)
+	cases := []struct {
+		items      [][]byte
+		any, every bool
+	}{
+		{nil, false, true},
+		{[][]byte{foo}, true, true},
+		{[][]byte{foo, bar}, true, true},
+		{[][]byte{baz}, false, false},
+		{[][]byte{baz, bar}, true, false},
+		{[][]byte{foo, baz}, true, false},
+	}
+	for _, c := range cases {
+		if got := f.TestAny(c.items); got != c.any {
+			t.Errorf("TestAny(%q) = %v", c.items, got)
+		}
+		if got := f.TestEvery(c.items); got != c.every {
+			t.Errorf("TestEvery(%q) = %v", c.items, got)
+		}
+		if f64.TestAny(c.items) != c.any || f64.TestEvery(c.items) != c.every {
+			t.Errorf("64-bit TestAny or TestEvery of %q wrong", c.items)
+		}
+		if o.TestAny(c.items) != c.any || o.TestEvery(c.items) != c.every {
+			t.Errorf("one-hashing TestAny or TestEvery of %q wrong", c.items)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package bloom
+
+import (
+	"sync"
+)
+
+// The width of the counters of a TinyLFU's frequency sketch, which bounds the
+// frequencies it tells apart
+const tinyLFUCounterWidth = 4
+
+// A TinyLFU cache admission policy, which tells a cache whether an item
+// should replace the item the cache would evict for it, by comparing their
+// recent frequencies of access. A plain filter, the doorkeeper, records items
+// accessed once, so that the many items accessed only once don't take up
+// the counters of the frequency sketch, a counting filter with 4-bit
+// counters. After every sample accesses, the sketch's counters are halved
+// and the doorkeeper is reset, so that the frequencies reflect recent
+// accesses. The policy is safe for concurrent use.
+type TinyLFU struct {
+	mu       sync.Mutex
+	door     *Filter
+	sketch   *CountingFilter
+	sample   int
+	accesses int // since the sketch was last halved
+}
+
+// Records an access to key, e.g. on every cache lookup, hit or miss.
+func (t *TinyLFU) Record(key []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.door.TestAndAdd(key) {
+		t.sketch.Add(key)
+	}
+	if t.accesses++; t.accesses >= t.sample {
+		t.sketch.Decay()
+		t.door.Reset()
+		t.accesses = 0
+	}
+}
+
+// Returns the (estimated) number of recent accesses to key.
+func (t *TinyLFU) Estimate(key []byte) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.estimate(key)
+}
+
+func (t *TinyLFU) estimate(key []byte) int {
+	n := t.sketch.Count(key)
+	if t.door.Test(key) {
+		n++
+	}
+	return n
+}
+
+// Returns whether candidate, an item about to be added to the cache, should
+// be admitted in place of victim, the item the cache would evict for it,
+// i.e. whether candidate was accessed more often recently.
+func (t *TinyLFU) Admit(candidate, victim []byte) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.estimate(candidate) > t.estimate(victim)
+}
+
+// Forgets every access.
+func (t *TinyLFU) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.door.Reset()
+	t.sketch.Reset()
+	t.accesses = 0
+}
+
+// Create a TinyLFU admission policy which halves its frequencies after every
+// sample accesses, e.g. ten times the number of items the cache holds, with
+// an acceptable false positive rate of p for its doorkeeper and sketch. The
+// sketch uses conservative updates (see WithConservativeUpdate); the options
+// given apply to both filters.
+func NewTinyLFU(sample int, p float64, opts ...Option) *TinyLFU {
+	if sample < 1 {
+		panic("Unable to create a TinyLFU policy without a positive sample size.")
+	}
+	sketchOpts := append([]Option{WithCounterWidth(tinyLFUCounterWidth), WithConservativeUpdate()}, opts...)
+	return &TinyLFU{
+		door:   New(sample, p, opts...),
+		sketch: NewCounting(sample, p, sketchOpts...),
+		sample: sample,
+	}
+}
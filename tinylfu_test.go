@@ -0,0 +1,34 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestTinyLFU(t *testing.T) {
+	p := NewTinyLFU(1000, 0.01)
+	for i := 0; i < 5; i++ {
+		p.Record(foo)
+	}
+	p.Record(bar)
+	if p.Estimate(foo) != 5 || p.Estimate(bar) != 1 || p.Estimate([]byte("baz")) != 0 {
+		t.Errorf("estimates %d, %d and %d", p.Estimate(foo), p.Estimate(bar), p.Estimate([]byte("baz")))
+	}
+	if !p.Admit(foo, bar) || p.Admit(bar, foo) || p.Admit(bar, bar) {
+		t.Error("unexpected admission")
+	}
+	// Fill the rest of the sample with items accessed once
+	for i := 6; i < 1000; i++ {
+		p.Record([]byte(strconv.Itoa(i)))
+	}
+	if e := p.Estimate(foo); e != 2 {
+		t.Errorf("estimate of foo %d after halving, expected 2", e)
+	}
+	if p.Estimate(bar) != 0 {
+		t.Errorf("estimate of bar %d after halving", p.Estimate(bar))
+	}
+	p.Reset()
+	if p.Estimate(foo) != 0 {
+		t.Error("foo estimated after Reset")
+	}
+}
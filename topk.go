@@ -0,0 +1,153 @@
+package bloom
+
+import (
+	"container/heap"
+	"hash"
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// An item tracked by a TopK, and its estimated count.
+type TopKItem struct {
+	Data  []byte
+	Count uint64
+}
+
+type topKHeap struct {
+	items []*topKEntry
+	index map[string]*topKEntry
+}
+
+type topKEntry struct {
+	key   string
+	count uint64
+	i     int
+}
+
+func (h *topKHeap) Len() int           { return len(h.items) }
+func (h *topKHeap) Less(i, j int) bool { return h.items[i].count < h.items[j].count }
+
+func (h *topKHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].i = i
+	h.items[j].i = j
+}
+
+func (h *topKHeap) Push(x interface{}) {
+	e := x.(*topKEntry)
+	e.i = len(h.items)
+	h.items = append(h.items, e)
+	h.index[e.key] = e
+}
+
+func (h *topKHeap) Pop() interface{} {
+	last := len(h.items) - 1
+	e := h.items[last]
+	h.items = h.items[:last]
+	delete(h.index, e.key)
+	return e
+}
+
+// A heavy hitters structure which keeps track of the k most frequently added
+// items. Counts are estimated with a count-min sketch using the 64-bit FNV-1
+// hash function, so only the k candidates themselves are kept in memory.
+type TopK struct {
+	k      int
+	width  uint64
+	counts [][]uint64
+	top    *topKHeap
+	h      hash.Hash64
+}
+
+// Returns the column of data in every row. The columns are derived with
+// enhanced double hashing, so that they differ from row to row even for items
+// whose second hash is a multiple of the width.
+func (t *TopK) cells(data []byte) []uint64 {
+	x := sum64(t.h, data)
+	is := make([]uint64, len(t.counts))
+	indexes64(is, x&0xffffffff, x>>32, t.width, EnhancedDoubleHashing)
+	return is
+}
+
+// Adds data to the structure, and returns its new estimated count.
+func (t *TopK) Add(data []byte) uint64 {
+	est := uint64(math.MaxUint64)
+	for row, i := range t.cells(data) {
+		t.counts[row][i]++
+		if c := t.counts[row][i]; c < est {
+			est = c
+		}
+	}
+	if e, ok := t.top.index[string(data)]; ok {
+		e.count = est
+		heap.Fix(t.top, e.i)
+	} else if t.top.Len() < t.k {
+		heap.Push(t.top, &topKEntry{key: string(data), count: est})
+	} else if est > t.top.items[0].count {
+		delete(t.top.index, t.top.items[0].key)
+		e := t.top.items[0]
+		e.key = string(data)
+		e.count = est
+		t.top.index[e.key] = e
+		heap.Fix(t.top, 0)
+	}
+	return est
+}
+
+// Returns the estimated number of times data was added. The estimate is never
+// lower than the real count, but may be higher.
+func (t *TopK) Count(data []byte) uint64 {
+	est := uint64(math.MaxUint64)
+	for row, i := range t.cells(data) {
+		if c := t.counts[row][i]; c < est {
+			est = c
+		}
+	}
+	return est
+}
+
+// Returns the (up to) k most frequently added items, in descending order of
+// their estimated counts.
+func (t *TopK) List() []TopKItem {
+	items := make([]TopKItem, len(t.top.items))
+	for i, e := range t.top.items {
+		items[i] = TopKItem{[]byte(e.key), e.count}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Count > items[j].Count
+	})
+	return items
+}
+
+// Resets the structure.
+func (t *TopK) Reset() {
+	for _, row := range t.counts {
+		for i := range row {
+			row[i] = 0
+		}
+	}
+	t.top = &topKHeap{index: make(map[string]*topKEntry)}
+}
+
+// Create a structure tracking the k most frequently added items. The counts
+// of the items are overestimated by at most a fraction e of the total number
+// of additions, with a probability of 1-e, e.g. 0.001.
+func NewTopK(k int, e float64) *TopK {
+	if k <= 0 || !(e > 0 && e < 1) {
+		panic("Unable to create a top-k structure without a positive k and an error rate between 0 and 1.")
+	}
+	width := uint64(math.Ceil(math.E / e))
+	depth := int(math.Ceil(math.Log(1 / e)))
+	counts := make([][]uint64, depth)
+	for i := range counts {
+		counts[i] = make([]uint64, width)
+	}
+	return &TopK{
+		k:      k,
+		width:  width,
+		counts: counts,
+		top:    &topKHeap{index: make(map[string]*topKEntry)},
+		h:      fnv.New64(),
+	}
+}
@@ -0,0 +1,66 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestTopK(t *testing.T) {
+	tk := NewTopK(3, 0.001)
+	for i := 0; i < 1000; i++ {
+		tk.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < 50; i++ {
+		tk.Add(foo)
+		if i < 40 {
+			tk.Add(bar)
+		}
+		if i < 30 {
+			tk.Add(baz)
+		}
+	}
+	list := tk.List()
+	if len(list) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(list))
+	}
+	for i, want := range []string{"foo", "bar", "baz"} {
+		if string(list[i].Data) != want {
+			t.Errorf("item %d is %s, expected %s", i, list[i].Data, want)
+		}
+	}
+	if c := tk.Count(foo); c < 50 {
+		t.Errorf("count of foo is %d, expected at least 50", c)
+	}
+	tk.Reset()
+	if len(tk.List()) != 0 || tk.Count(foo) != 0 {
+		t.Error("structure not empty after reset")
+	}
+}
+
+func TestTopKRows(t *testing.T) {
+	// 3 rows of 28 columns: with plain double hashing, the items whose
+	// second hash is a multiple of 28 would use the same column in every
+	// row
+	tk := NewTopK(10, 0.1)
+	if len(tk.counts) != 3 || tk.width != 28 {
+		t.Fatalf("%d rows of %d columns, expected 3 of 28", len(tk.counts), tk.width)
+	}
+	for i := 0; i < 1000; i++ {
+		if is := tk.cells([]byte(strconv.Itoa(i))); is[0] == is[1] && is[1] == is[2] {
+			t.Fatalf("%d uses column %d in every row", i, is[0])
+		}
+	}
+}
+
+func TestTopKInvalid(t *testing.T) {
+	for _, k := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic with k %d", k)
+				}
+			}()
+			NewTopK(k, 0.001)
+		}()
+	}
+}
@@ -0,0 +1,76 @@
+package bloom
+
+import (
+	"encoding/binary"
+)
+
+// A function appending the bytes identifying v to buf, and returning the
+// extended buffer, in the manner of strconv.AppendInt. Values that should be
+// considered the same item must produce the same bytes.
+type KeyFunc[T any] func(buf []byte, v T) []byte
+
+// Appends the bytes of v to buf.
+func StringKey(buf []byte, v string) []byte {
+	return append(buf, v...)
+}
+
+// Appends v to buf as eight big-endian bytes.
+func IntKey(buf []byte, v int) []byte {
+	return binary.BigEndian.AppendUint64(buf, uint64(v))
+}
+
+// Appends v to buf as eight big-endian bytes.
+func Uint64Key(buf []byte, v uint64) []byte {
+	return binary.BigEndian.AppendUint64(buf, v)
+}
+
+// A standard bloom filter holding values of type T, which are converted to
+// bytes with a KeyFunc, so that callers don't have to convert them at every
+// call site.
+type TypedFilter[T any] struct {
+	f   *Filter
+	key KeyFunc[T]
+	buf []byte
+}
+
+func (f *TypedFilter[T]) bytes(v T) []byte {
+	f.buf = f.key(f.buf[:0], v)
+	return f.buf
+}
+
+// Checks whether v was previously added to the filter, as with Filter.Test.
+func (f *TypedFilter[T]) Test(v T) bool {
+	return f.f.Test(f.bytes(v))
+}
+
+// Adds v to the filter.
+func (f *TypedFilter[T]) Add(v T) {
+	f.f.Add(f.bytes(v))
+}
+
+// Adds v to the filter, and returns whether it was (probably) already present,
+// as with Filter.TestAndAdd.
+func (f *TypedFilter[T]) TestAndAdd(v T) bool {
+	return f.f.TestAndAdd(f.bytes(v))
+}
+
+// Resets the filter.
+func (f *TypedFilter[T]) Reset() {
+	f.f.Reset()
+}
+
+// Returns the underlying filter, e.g. to encode it.
+func (f *TypedFilter[T]) Filter() *Filter {
+	return f.f
+}
+
+// Create a bloom filter holding values of type T with an expected n number of
+// items, and an acceptable false positive rate of p, e.g. 0.01. key converts
+// the values to bytes; StringKey, IntKey and Uint64Key can be used for those
+// types.
+func NewTyped[T any](n int, p float64, key KeyFunc[T], opts ...Option) *TypedFilter[T] {
+	return &TypedFilter[T]{
+		f:   New(n, p, opts...),
+		key: key,
+	}
+}
@@ -0,0 +1,40 @@
+package bloom
+
+import (
+	"testing"
+)
+
+func TestTypedFilter(t *testing.T) {
+	s := NewTyped(1000, 0.01, StringKey)
+	s.Add("foo")
+	if !s.Test("foo") || s.Test("bar") || !s.Filter().Test(foo) {
+		t.Error("unexpected results with strings")
+	}
+	if s.TestAndAdd("bar") || !s.Test("bar") {
+		t.Error("TestAndAdd did not add bar")
+	}
+	s.Reset()
+	if s.Test("foo") {
+		t.Error("foo in filter after reset")
+	}
+
+	i := NewTyped(1000, 0.01, IntKey)
+	i.Add(-1)
+	if !i.Test(-1) || i.Test(1) {
+		t.Error("unexpected results with ints")
+	}
+	u := NewTyped(1000, 0.01, Uint64Key)
+	u.Add(1 << 63)
+	if !u.Test(1<<63) || u.Test(1) {
+		t.Error("unexpected results with uint64s")
+	}
+
+	type point struct{ x, y int }
+	p := NewTyped(1000, 0.01, func(buf []byte, v point) []byte {
+		return IntKey(IntKey(buf, v.x), v.y)
+	})
+	p.Add(point{1, 2})
+	if !p.Test(point{1, 2}) || p.Test(point{2, 1}) {
+		t.Error("unexpected results with a custom key")
+	}
+}
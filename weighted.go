@@ -0,0 +1,88 @@
+package bloom
+
+import (
+	"github.com/pmylund/go-bitset"
+)
+
+// A weighted bloom filter using the 128-bit FNV-1 hash function, like Filter.
+// Items are added and tested with a class: class 0 uses the number of hash
+// functions that is optimal for the false positive rate specified upon
+// creation of the filter, and every class above that uses one more, roughly
+// halving the false positive chance for items of that class. This lets
+// high-value items get a lower false positive rate than low-value ones within
+// one filter.
+type WeightedFilter struct {
+	*filter
+	b *bitset.Bitset32
+}
+
+func (f *WeightedFilter) classBits(data []byte, class int) []uint32 {
+	if class < 0 {
+		class = 0
+	}
+	return f.bitsN(nil, data, f.k+uint32(class))
+}
+
+// Checks whether data was previously added to the filter with the given
+// class. Returns true if yes, with a false positive chance near the ratio
+// specified upon creation of the filter divided by 2^class. The result
+// cannot be falsely negative if data is tested with the class it was added
+// with.
+func (f *WeightedFilter) TestWeighted(data []byte, class int) bool {
+	return testBits32(f.b, f.classBits(data, class))
+}
+
+// Adds data to the filter with the given class, e.g. 0 for ordinary items, and
+// 1 or more for items where false positives are more costly. Items of higher
+// classes set more bits, so a filter expected to hold many of them should be
+// created with a larger n.
+func (f *WeightedFilter) AddWeighted(data []byte, class int) {
+	f.TestAndAddWeighted(data, class)
+}
+
+// Adds data to the filter with the given class, and returns whether it was
+// (probably) already present, as reported by TestWeighted, before it was
+// added. Data is hashed only once.
+func (f *WeightedFilter) TestAndAddWeighted(data []byte, class int) bool {
+	present := true
+	for _, i := range f.classBits(data, class) {
+		if !f.b.Test(i) {
+			present = false
+			f.b.Set(i)
+		}
+	}
+	return present
+}
+
+// Checks whether data was previously added to the filter with class 0.
+func (f *WeightedFilter) Test(data []byte) bool {
+	return f.TestWeighted(data, 0)
+}
+
+// Adds data to the filter with class 0.
+func (f *WeightedFilter) Add(data []byte) {
+	f.AddWeighted(data, 0)
+}
+
+// Adds data to the filter with class 0, and returns whether it was (probably)
+// already present.
+func (f *WeightedFilter) TestAndAdd(data []byte) bool {
+	return f.TestAndAddWeighted(data, 0)
+}
+
+// Resets the filter.
+func (f *WeightedFilter) Reset() {
+	f.b.Reset()
+}
+
+// Create a weighted bloom filter with an expected n number of items, and an
+// acceptable false positive rate of p, e.g. 0.01, for items of class 0.
+func NewWeighted(n int, p float64, opts ...Option) *WeightedFilter {
+	m, k := estimates(int64(n), p)
+	f := &WeightedFilter{
+		newFilter(m, k, opts...),
+		bitset.New32(m),
+	}
+	f.capacity, f.fpRate = uint64(n), p
+	return f
+}
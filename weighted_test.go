@@ -0,0 +1,32 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestWeightedFilter(t *testing.T) {
+	n := 10000
+	f := NewWeighted(n, 0.05)
+	for i := 0; i < n; i++ {
+		f.AddWeighted([]byte(strconv.Itoa(i)), i%2*4)
+	}
+	for i := 0; i < n; i++ {
+		if !f.TestWeighted([]byte(strconv.Itoa(i)), i%2*4) {
+			t.Fatalf("%d not in bloom filter", i)
+		}
+	}
+	fp0, fp4 := 0, 0
+	for i := n; i < 3*n; i++ {
+		v := []byte(strconv.Itoa(i))
+		if f.Test(v) {
+			fp0++
+		}
+		if f.TestWeighted(v, 4) {
+			fp4++
+		}
+	}
+	if fp4*4 > fp0 {
+		t.Errorf("class 4 false positives (%d) not much rarer than class 0 (%d)", fp4, fp0)
+	}
+}
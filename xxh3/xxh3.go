@@ -0,0 +1,245 @@
+// Package xxh3 implements the 64-bit variant of the XXH3 hash function, which
+// hashes long keys several times faster than FNV-1. It is kept separate from
+// go-bloom so that the core package has no dependencies beyond the bitset.
+//
+// To use it with a filter:
+//
+//	f := bloom.New(100000, 0.01, bloom.WithHash(xxh3.Hash))
+//
+// Importing the package also registers XXH3 with bloom.RegisterHash, so that
+// filters encoding the name of their hash function can be created with:
+//
+//	f := bloom.New(100000, 0.01, bloom.WithRegisteredHash(xxh3.Name, nil))
+package xxh3
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+const (
+	prime32_1 = 0x9E3779B1
+	prime32_2 = 0x85EBCA77
+	prime32_3 = 0xC2B2AE3D
+
+	prime64_1 = 0x9E3779B185EBCA87
+	prime64_2 = 0xC2B2AE3D27D4EB4F
+	prime64_3 = 0x165667B19E3779F9
+	prime64_4 = 0x85EBCA77C2B2AE63
+	prime64_5 = 0x27D4EB2F165667C5
+
+	stripeLen          = 64
+	secretConsumeRate  = 8
+	accNB              = stripeLen / 8
+	secretMergeStart   = 11
+	secretLastAccStart = 7
+	midSizeMax         = 240
+	secretSizeMin      = 136
+)
+
+var defaultSecret = [192]byte{
+	0xb8, 0xfe, 0x6c, 0x39, 0x23, 0xa4, 0x4b, 0xbe, 0x7c, 0x01, 0x81, 0x2c, 0xf7, 0x21, 0xad, 0x1c,
+	0xde, 0xd4, 0x6d, 0xe9, 0x83, 0x90, 0x97, 0xdb, 0x72, 0x40, 0xa4, 0xa4, 0xb7, 0xb3, 0x67, 0x1f,
+	0xcb, 0x79, 0xe6, 0x4e, 0xcc, 0xc0, 0xe5, 0x78, 0x82, 0x5a, 0xd0, 0x7d, 0xcc, 0xff, 0x72, 0x21,
+	0xb8, 0x08, 0x46, 0x74, 0xf7, 0x43, 0x24, 0x8e, 0xe0, 0x35, 0x90, 0xe6, 0x81, 0x3a, 0x26, 0x4c,
+	0x3c, 0x28, 0x52, 0xbb, 0x91, 0xc3, 0x00, 0xcb, 0x88, 0xd0, 0x65, 0x8b, 0x1b, 0x53, 0x2e, 0xa3,
+	0x71, 0x64, 0x48, 0x97, 0xa2, 0x0d, 0xf9, 0x4e, 0x38, 0x19, 0xef, 0x46, 0xa9, 0xde, 0xac, 0xd8,
+	0xa8, 0xfa, 0x76, 0x3f, 0xe3, 0x9c, 0x34, 0x3f, 0xf9, 0xdc, 0xbb, 0xc7, 0xc7, 0x0b, 0x4f, 0x1d,
+	0x8a, 0x51, 0xe0, 0x4b, 0xcd, 0xb4, 0x59, 0x31, 0xc8, 0x9f, 0x7e, 0xc9, 0xd9, 0x78, 0x73, 0x64,
+	0xea, 0xc5, 0xac, 0x83, 0x34, 0xd3, 0xeb, 0xc3, 0xc5, 0x81, 0xa0, 0xff, 0xfa, 0x13, 0x63, 0xeb,
+	0x17, 0x0d, 0xdd, 0x51, 0xb7, 0xf0, 0xda, 0x49, 0xd3, 0x16, 0x55, 0x26, 0x29, 0xd4, 0x68, 0x9e,
+	0x2b, 0x16, 0xbe, 0x58, 0x7d, 0x47, 0xa1, 0xfc, 0x8f, 0xf8, 0xb8, 0xd1, 0x7a, 0xd0, 0x31, 0xce,
+	0x45, 0xcb, 0x3a, 0x8f, 0x95, 0x16, 0x04, 0x28, 0xaf, 0xd7, 0xfb, 0xca, 0xbb, 0x4b, 0x40, 0x7e,
+}
+
+func u32(b []byte, i int) uint64 {
+	return uint64(binary.LittleEndian.Uint32(b[i:]))
+}
+
+func u64(b []byte, i int) uint64 {
+	return binary.LittleEndian.Uint64(b[i:])
+}
+
+func mul128Fold64(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+func xxh64Avalanche(x uint64) uint64 {
+	x ^= x >> 33
+	x *= prime64_2
+	x ^= x >> 29
+	x *= prime64_3
+	x ^= x >> 32
+	return x
+}
+
+func avalanche(x uint64) uint64 {
+	x ^= x >> 37
+	x *= 0x165667919E3779F9
+	x ^= x >> 32
+	return x
+}
+
+func strongAvalanche(x, n uint64) uint64 {
+	x ^= bits.RotateLeft64(x, 49) ^ bits.RotateLeft64(x, 24)
+	x *= 0x9FB21C651E98DF25
+	x ^= (x >> 35) + n
+	x *= 0x9FB21C651E98DF25
+	x ^= x >> 28
+	return x
+}
+
+func mix16(data []byte, secret []byte, seed uint64) uint64 {
+	lo := u64(data, 0) ^ (u64(secret, 0) + seed)
+	hi := u64(data, 8) ^ (u64(secret, 8) - seed)
+	return mul128Fold64(lo, hi)
+}
+
+// Returns the 64-bit XXH3 hash of data.
+func Sum64(data []byte) uint64 {
+	return sum64(data, 0, defaultSecret[:])
+}
+
+// Returns the 64-bit XXH3 hash of data using the given seed.
+func Sum64Seed(data []byte, seed uint64) uint64 {
+	if seed == 0 || len(data) <= midSizeMax {
+		return sum64(data, seed, defaultSecret[:])
+	}
+	var secret [len(defaultSecret)]byte
+	for i := 0; i < len(secret); i += 16 {
+		binary.LittleEndian.PutUint64(secret[i:], u64(defaultSecret[:], i)+seed)
+		binary.LittleEndian.PutUint64(secret[i+8:], u64(defaultSecret[:], i+8)-seed)
+	}
+	return sum64(data, 0, secret[:])
+}
+
+// Returns two 64-bit hashes of data from a single XXH3 pass, in the form
+// expected by bloom.WithHash.
+func Hash(data []byte) (uint64, uint64) {
+	x := Sum64(data)
+	return x, bits.RotateLeft64(x, 32)
+}
+
+// The name XXH3 is registered as with bloom.RegisterHash. Its parameters are
+// either empty, or an 8-byte little-endian seed.
+const Name = "xxh3"
+
+func init() {
+	bloom.RegisterHash(Name, func(params []byte) (bloom.HashFunc, error) {
+		switch len(params) {
+		case 0:
+			return Hash, nil
+		case 8:
+			seed := binary.LittleEndian.Uint64(params)
+			return func(data []byte) (uint64, uint64) {
+				x := Sum64Seed(data, seed)
+				return x, bits.RotateLeft64(x, 32)
+			}, nil
+		}
+		return nil, fmt.Errorf("xxh3: seed is %d bytes, expected 8", len(params))
+	})
+}
+
+func sum64(data []byte, seed uint64, secret []byte) uint64 {
+	n := len(data)
+	switch {
+	case n == 0:
+		return xxh64Avalanche(seed ^ u64(secret, 56) ^ u64(secret, 64))
+	case n <= 3:
+		c1, c2, c3 := uint32(data[0]), uint32(data[n>>1]), uint32(data[n-1])
+		combo := c1<<16 | c2<<24 | c3 | uint32(n)<<8
+		flip := (u32(secret, 0) ^ u32(secret, 4)) + seed
+		return xxh64Avalanche(uint64(combo) ^ flip)
+	case n <= 8:
+		seed ^= uint64(bits.ReverseBytes32(uint32(seed))) << 32
+		in1, in2 := u32(data, 0), u32(data, n-4)
+		flip := (u64(secret, 8) ^ u64(secret, 16)) - seed
+		return strongAvalanche((in2+in1<<32)^flip, uint64(n))
+	case n <= 16:
+		flip1 := (u64(secret, 24) ^ u64(secret, 32)) + seed
+		flip2 := (u64(secret, 40) ^ u64(secret, 48)) - seed
+		lo := u64(data, 0) ^ flip1
+		hi := u64(data, n-8) ^ flip2
+		return avalanche(uint64(n) + bits.ReverseBytes64(lo) + hi + mul128Fold64(lo, hi))
+	case n <= 128:
+		acc := uint64(n) * prime64_1
+		if n > 32 {
+			if n > 64 {
+				if n > 96 {
+					acc += mix16(data[48:], secret[96:], seed)
+					acc += mix16(data[n-64:], secret[112:], seed)
+				}
+				acc += mix16(data[32:], secret[64:], seed)
+				acc += mix16(data[n-48:], secret[80:], seed)
+			}
+			acc += mix16(data[16:], secret[32:], seed)
+			acc += mix16(data[n-32:], secret[48:], seed)
+		}
+		acc += mix16(data, secret, seed)
+		acc += mix16(data[n-16:], secret[16:], seed)
+		return avalanche(acc)
+	case n <= midSizeMax:
+		acc := uint64(n) * prime64_1
+		rounds := n / 16
+		for i := 0; i < 8; i++ {
+			acc += mix16(data[16*i:], secret[16*i:], seed)
+		}
+		acc = avalanche(acc)
+		for i := 8; i < rounds; i++ {
+			acc += mix16(data[16*i:], secret[16*(i-8)+3:], seed)
+		}
+		acc += mix16(data[n-16:], secret[secretSizeMin-17:], seed)
+		return avalanche(acc)
+	}
+	return hashLong(data, secret)
+}
+
+func accumulate512(acc *[accNB]uint64, data, secret []byte) {
+	for i := 0; i < accNB; i++ {
+		v := u64(data, 8*i)
+		key := v ^ u64(secret, 8*i)
+		acc[i^1] += v
+		acc[i] += (key & 0xffffffff) * (key >> 32)
+	}
+}
+
+func scramble(acc *[accNB]uint64, secret []byte) {
+	for i := 0; i < accNB; i++ {
+		a := acc[i]
+		a ^= a >> 47
+		a ^= u64(secret, 8*i)
+		acc[i] = a * prime32_1
+	}
+}
+
+func hashLong(data, secret []byte) uint64 {
+	acc := [accNB]uint64{
+		prime32_3, prime64_1, prime64_2, prime64_3,
+		prime64_4, prime32_2, prime64_5, prime32_1,
+	}
+	n := len(data)
+	stripes := (len(secret) - stripeLen) / secretConsumeRate
+	blockLen := stripeLen * stripes
+	blocks := (n - 1) / blockLen
+	for b := 0; b < blocks; b++ {
+		for s := 0; s < stripes; s++ {
+			accumulate512(&acc, data[b*blockLen+s*stripeLen:], secret[s*secretConsumeRate:])
+		}
+		scramble(&acc, secret[len(secret)-stripeLen:])
+	}
+	last := ((n - 1) - blockLen*blocks) / stripeLen
+	for s := 0; s < last; s++ {
+		accumulate512(&acc, data[blocks*blockLen+s*stripeLen:], secret[s*secretConsumeRate:])
+	}
+	accumulate512(&acc, data[n-stripeLen:], secret[len(secret)-stripeLen-secretLastAccStart:])
+
+	result := uint64(n) * prime64_1
+	for i := 0; i < 4; i++ {
+		result += mul128Fold64(acc[2*i]^u64(secret, secretMergeStart+16*i), acc[2*i+1]^u64(secret, secretMergeStart+16*i+8))
+	}
+	return avalanche(result)
+}
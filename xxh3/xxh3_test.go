@@ -0,0 +1,69 @@
+package xxh3
+
+import (
+	"github.com/pmylund/go-bloom"
+
+	"testing"
+)
+
+var vectors = []struct {
+	n      int
+	sum    uint64
+	seeded uint64
+}{
+	{0, 0x2d06800538d394c2, 0xcc1ca35a1b089c5c},
+	{1, 0xc44bdff4074eecdb, 0xd5dd68911c7f195d},
+	{3, 0x5f4299fc161c9cbb, 0x6db0802353336496},
+	{4, 0x60dab036a58211f2, 0xbb981e431fa9e4e3},
+	{8, 0x3a1c2d7c85af88f8, 0xd204fc26419c7d22},
+	{9, 0xe9612598145bb9dc, 0xd813523b210d751b},
+	{16, 0x8355e3a6f61770db, 0x1dca78f4947ed52c},
+	{17, 0x9ef341a99de37328, 0xebce4845f0c75f02},
+	{100, 0x004e4f921a64bd1c, 0x40c3f876b151a905},
+	{128, 0x85c6174c7ff4c46b, 0xde26ec476dc43954},
+	{129, 0xec7642b431ba3e5a, 0x75bc8ed192c9cc73},
+	{240, 0x375a384d957fe865, 0x4b1593ee9603224a},
+	{241, 0x02e8cd95421c6d02, 0xace3afe84c9adf7c},
+	{1000, 0x33ef703fb2b20ed1, 0xf59b39466ffee4f0},
+	{2048, 0x25339063db861586, 0xf3bf67155ca223ad},
+}
+
+func TestSum64(t *testing.T) {
+	for _, v := range vectors {
+		data := make([]byte, v.n)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+		if got := Sum64(data); got != v.sum {
+			t.Errorf("Sum64 of %d bytes: %#x, expected %#x", v.n, got, v.sum)
+		}
+		if got := Sum64Seed(data, 0x0123456789abcdef); got != v.seeded {
+			t.Errorf("Sum64Seed of %d bytes: %#x, expected %#x", v.n, got, v.seeded)
+		}
+	}
+}
+
+func BenchmarkSum64(b *testing.B) {
+	data := make([]byte, 1024)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		Sum64(data)
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	f := bloom.New(1000, 0.01, bloom.WithRegisteredHash(Name, nil))
+	f.Add([]byte("foo"))
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &bloom.Filter{}
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Test([]byte("foo")) {
+		t.Error("foo not in decoded filter")
+	}
+	bloom.New(1000, 0.01, bloom.WithRegisteredHash(Name, make([]byte, 8)))
+}